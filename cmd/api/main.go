@@ -15,6 +15,14 @@ package main
 // @host      localhost:8888
 // @BasePath  /
 
+// @securityDefinitions.apikey  AdminAPIKey
+// @in                          header
+// @name                        X-API-Key
+
+// @securityDefinitions.apikey  AdminBearer
+// @in                          header
+// @name                        Authorization
+
 import (
 	"context"
 	"os"
@@ -30,6 +38,11 @@ func main() {
 	exitCode := 0
 	defer func() { os.Exit(exitCode) }()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		exitCode = runMigrate(os.Args[2:])
+		return
+	}
+
 	a := fx.New(app.Module)
 	startCtx, cancel := context.WithTimeout(context.Background(), app.DefaultStartTimeout)
 	defer cancel()
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/fatflowers/cashier/internal/platform/db"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/logger"
+)
+
+const migrateUsage = `usage: cashier migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [N]   roll back the last N applied migrations (default 1)
+  status     list migrations and whether they are applied`
+
+// runMigrate implements the "migrate" subcommand. It only wires config, logging and the database,
+// so it can run before the schema the server expects exists.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	var (
+		log      *zap.SugaredLogger
+		gdb      *gorm.DB
+		migrator *db.Migrator
+	)
+	a := fx.New(
+		fx.NopLogger,
+		logger.Module,
+		config.Module,
+		fx.Provide(db.NewDB, db.NewMigrator),
+		fx.Populate(&log, &gdb, &migrator),
+	)
+	if err := a.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to init: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Errorf("migrate up failed: %v", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		if errors.Is(err, db.ErrNoMigrationToRollback) {
+			fmt.Println(err)
+			return 0
+		}
+		if err != nil {
+			log.Errorf("migrate down failed: %v", err)
+			return 1
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Errorf("migrate status failed: %v", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if s.Missing {
+				appliedAt += " (missing from this build)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		_ = w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
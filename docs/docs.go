@@ -24,8 +24,58 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/admin/create_payment_item": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Adds an active payment item to the catalog as version 1. The authenticated admin is recorded as the operator.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Create Payment Item (Admin)",
+                "parameters": [
+                    {
+                        "description": "Payment item",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentItem"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespPaymentItem"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/admin/get_membership_statistic": {
             "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
                 "description": "Retrieves daily membership statistics.",
                 "consumes": [
                     "application/json"
@@ -58,9 +108,17 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/admin/list_user_membership_item": {
+        "/api/v1/admin/get_user_membership_timeline": {
             "post": {
-                "description": "Retrieves a paginated and filterable list of all membership transactions.",
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Recomputes a user's membership periods from all transactions, including skipped transactions and the state at query_at.",
                 "consumes": [
                     "application/json"
                 ],
@@ -70,15 +128,15 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "List Membership Transactions (Admin)",
+                "summary": "Get User Membership Timeline (Admin)",
                 "parameters": [
                     {
-                        "description": "List transaction request with filters, pagination, and sorting",
+                        "description": "Timeline request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.ListTransactionRequest"
+                            "$ref": "#/definitions/handlers.GetUserMembershipTimelineRequest"
                         }
                     }
                 ],
@@ -86,17 +144,26 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.RespListMembershipTransactions"
+                            "$ref": "#/definitions/handlers.RespUserMembershipTimeline"
                         }
                     }
                 }
             }
         },
-        "/api/v1/admin/send_free_gift": {
+        "/api/v1/admin/import_fx_rates": {
             "post": {
-                "description": "Grants a free membership item to a user.",
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Stores daily exchange rates (rate_date, base_currency, quote_currency, rate: one base unit in quote units), replacing those stored for the same day and pair. Send JSON, or CSV with Content-Type text/csv, a header row and the source in the query.",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/csv"
                 ],
                 "produces": [
                     "application/json"
@@ -104,31 +171,45 @@ const docTemplate = `{
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Send Free Gift (Admin)",
+                "summary": "Import FX Rates (Admin)",
                 "parameters": [
                     {
-                        "description": "Send free gift request",
+                        "description": "Import FX rates request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/transaction.SendFreeGiftRequest"
+                            "$ref": "#/definitions/fxrate.ImportRatesRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source of CSV rates",
+                        "name": "source",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.RespOK"
+                            "$ref": "#/definitions/handlers.RespImportFxRates"
                         }
                     }
                 }
             }
         },
-        "/api/v2/payment/verify_transaction": {
+        "/api/v1/admin/list_credit_ledger": {
             "post": {
-                "description": "Verifies a payment transaction and returns downgrade auto-renew information when needed.",
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists the credit grants, spends and refund clawbacks of a user, optionally filtered by kind, newest first.",
                 "consumes": [
                     "application/json"
                 ],
@@ -136,17 +217,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Payment"
+                    "Admin"
                 ],
-                "summary": "Verify Transaction V2",
+                "summary": "List Credit Ledger (Admin)",
                 "parameters": [
                     {
-                        "description": "Transaction verification request",
+                        "description": "List credit ledger request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/transaction.TransactionVerifyRequest"
+                            "$ref": "#/definitions/wallet.ListCreditLedgerRequest"
                         }
                     }
                 ],
@@ -154,15 +235,23 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.RespOK"
+                            "$ref": "#/definitions/handlers.RespListCreditLedger"
                         }
                     }
                 }
             }
         },
-        "/api/v2/payment/webhook/apple": {
+        "/api/v1/admin/list_fx_rates": {
             "post": {
-                "description": "Handles App Store Server Notifications V2. The request body should be a Signed JWS payload.",
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists stored exchange rates, optionally of one rate_date or involving one currency, newest day first.",
                 "consumes": [
                     "application/json"
                 ],
@@ -170,17 +259,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Webhook"
+                    "Admin"
                 ],
-                "summary": "Apple Webhook",
+                "summary": "List FX Rates (Admin)",
                 "parameters": [
                     {
-                        "description": "App Store Server Notification V2 JWS payload",
-                        "name": "payload",
+                        "description": "List FX rates request",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/fxrate.ListRatesRequest"
                         }
                     }
                 ],
@@ -188,160 +277,2291 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.RespOK"
+                            "$ref": "#/definitions/handlers.RespListFxRates"
                         }
                     }
                 }
             }
         },
-        "/healthz": {
-            "get": {
-                "description": "Returns service status",
+        "/api/v1/admin/list_job_runs": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists the runs of scheduled jobs, optionally filtered by job and status, newest first.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "Admin"
+                ],
+                "summary": "List Job Runs (Admin)",
+                "parameters": [
+                    {
+                        "description": "List job runs request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scheduler.ListJobRunsRequest"
+                        }
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.RespListJobRuns"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.ListMembershipTransactionsResponse": {
-            "type": "object",
-            "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/handlers.TransactionItem"
-                    }
-                },
-                "total": {
-                    "type": "integer"
-                }
-            }
         },
-        "handlers.ListTransactionRequest": {
-            "type": "object",
-            "properties": {
-                "filters": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/types.CommonFilter"
+        "/api/v1/admin/list_payment_item_versions": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists every version of a payment item with the operator who made it, newest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Payment Item Versions (Admin)",
+                "parameters": [
+                    {
+                        "description": "List payment item versions request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ListPaymentItemVersionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListPaymentItemVersions"
+                        }
                     }
-                },
-                "from": {
-                    "type": "integer"
-                },
-                "size": {
-                    "type": "integer"
-                },
-                "sort_by": {
-                    "type": "string"
-                },
-                "sort_order": {
-                    "type": "string"
                 }
             }
         },
-        "handlers.RespListMembershipTransactions": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "$ref": "#/definitions/response.APIResponseCode"
-                },
-                "data": {
-                    "$ref": "#/definitions/handlers.ListMembershipTransactionsResponse"
-                },
-                "message": {
-                    "type": "string"
+        "/api/v1/admin/list_payment_items": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists the payment item catalog, optionally filtered by provider and status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Payment Items (Admin)",
+                "parameters": [
+                    {
+                        "description": "List payment items request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/catalog.ListPaymentItemsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListPaymentItems"
+                        }
+                    }
                 }
             }
         },
-        "handlers.RespMembershipStatistic": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "$ref": "#/definitions/response.APIResponseCode"
-                },
-                "data": {
-                    "$ref": "#/definitions/statistics.MembershipStatisticResponse"
-                },
-                "message": {
-                    "type": "string"
+        "/api/v1/admin/list_reconcile_drifts": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists the differences the scheduled reconciliation found between stored subscriptions and the provider, newest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Reconcile Drifts (Admin)",
+                "parameters": [
+                    {
+                        "description": "List reconcile drifts request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/transaction.ListReconcileDriftsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListReconcileDrifts"
+                        }
+                    }
                 }
             }
         },
-        "handlers.RespOK": {
-            "type": "object",
-            "properties": {
+        "/api/v1/admin/list_transaction_refunds": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists the refunds and refund reversals of a transaction, oldest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Transaction Refunds (Admin)",
+                "parameters": [
+                    {
+                        "description": "Transaction refunds request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ListTransactionRefundsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListTransactionRefunds"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/list_user_membership_item": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Retrieves a paginated and filterable list of all membership transactions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Membership Transactions (Admin)",
+                "parameters": [
+                    {
+                        "description": "List transaction request with filters, pagination, and sorting",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ListTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListMembershipTransactions"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/list_webhook_dead_letters": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Lists membership webhook deliveries that failed every retry.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Webhook Dead Letters (Admin)",
+                "parameters": [
+                    {
+                        "description": "List dead letters request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.ListDeadLettersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespListWebhookDeadLetters"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/recover_apple_notifications": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Reads the App Store Server API notification history between start_at and end_at and handles every notification not recorded as handled, reporting the result of each.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Recover Apple Notifications (Admin)",
+                "parameters": [
+                    {
+                        "description": "Recover Apple notifications request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notification_handler.RecoverAppleNotificationsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespRecoverAppleNotifications"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/redeliver_webhook": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Queues a dead-lettered membership webhook for delivery again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Redeliver Webhook (Admin)",
+                "parameters": [
+                    {
+                        "description": "Redeliver webhook request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RedeliverWebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespRedeliverWebhook"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/send_free_gift": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Grants a free membership item to a user. The authenticated admin is recorded as the operator.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Send Free Gift (Admin)",
+                "parameters": [
+                    {
+                        "description": "Send free gift request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/transaction.SendFreeGiftRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/sync_transaction_refund": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Re-reads a transaction from Apple or Google Play and applies the refund state it reports, recovering a missed refund or refund reversal notification. Stripe transactions are not supported.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Sync Transaction Refund (Admin)",
+                "parameters": [
+                    {
+                        "description": "Sync transaction refund request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SyncTransactionRefundRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/update_payment_item": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Changes the type, duration or status (active/archived) of a payment item based on the version last read, storing a new version. Archived items can no longer be sold.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Update Payment Item (Admin)",
+                "parameters": [
+                    {
+                        "description": "Update payment item request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/catalog.UpdatePaymentItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespPaymentItem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/credits": {
+            "get": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Returns the user's credit balance, zero when they never bought credits.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Get Credit Balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespCreditBalance"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/credits/spend": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Takes credits from the user's balance. Retrying with the same idempotency_key returns the first ledger entry instead of spending again; spending more than the balance fails.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Spend Credits",
+                "parameters": [
+                    {
+                        "description": "Spend request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/wallet.SpendRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespCreditLedgerEntry"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/stripe/checkout_session": {
+            "post": {
+                "description": "Creates a Stripe hosted checkout session for a Stripe payment item and returns its redirect URL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Create Stripe Checkout Session",
+                "parameters": [
+                    {
+                        "description": "Checkout session request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/transaction.CreateCheckoutSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespStripeCheckoutSession"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/subscription": {
+            "get": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Returns the user's current membership, active items and pending downgrade.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Get User Subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespUserMembership"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/subscription/batch": {
+            "post": {
+                "security": [
+                    {
+                        "AdminAPIKey": []
+                    },
+                    {
+                        "AdminBearer": []
+                    }
+                ],
+                "description": "Returns memberships for up to 50 users, in request order.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Batch Get User Subscriptions",
+                "parameters": [
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchGetUserSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespUserMemberships"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/verify_transaction": {
+            "post": {
+                "description": "Verifies a payment transaction and returns downgrade auto-renew information when needed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payment"
+                ],
+                "summary": "Verify Transaction V2",
+                "parameters": [
+                    {
+                        "description": "Transaction verification request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/transaction.TransactionVerifyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/webhook/apple": {
+            "post": {
+                "description": "Handles App Store Server Notifications V2. The request body should be a Signed JWS payload.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Apple Webhook",
+                "parameters": [
+                    {
+                        "description": "App Store Server Notification V2 JWS payload",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/webhook/google": {
+            "post": {
+                "description": "Handles Google Play Real-time Developer Notifications delivered by a Cloud Pub/Sub push subscription.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Google Play Webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Push token configured on the Pub/Sub subscription endpoint",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Pub/Sub push request",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/payment/webhook/stripe": {
+            "post": {
+                "description": "Handles Stripe webhook events. The raw body is verified against the Stripe-Signature header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Stripe Webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stripe webhook signature",
+                        "name": "Stripe-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Stripe event",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespOK"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Returns service status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "catalog.ListPaymentItemsRequest": {
+            "type": "object",
+            "properties": {
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "status": {
+                    "$ref": "#/definitions/types.PaymentItemStatus"
+                }
+            }
+        },
+        "catalog.UpdatePaymentItemRequest": {
+            "type": "object",
+            "properties": {
+                "credits": {
+                    "type": "integer"
+                },
+                "duration_hour": {
+                    "type": "integer"
+                },
+                "entitlements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.EntitlementGrant"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/types.PaymentItemStatus"
+                },
+                "subscription_group": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type and Status keep their stored value when empty, Credits, Entitlements, SubscriptionGroup and Level\nwhen null; DurationHour is always replaced.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentItemType"
+                        }
+                    ]
+                },
+                "version": {
+                    "description": "Version is the version the change is based on; the update fails if the item changed since.",
+                    "type": "integer"
+                }
+            }
+        },
+        "fxrate.ImportRatesRequest": {
+            "type": "object",
+            "properties": {
+                "rates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FxRate"
+                    }
+                },
+                "source": {
+                    "description": "Source names where the rates come from, e.g. \"ecb\".",
+                    "type": "string"
+                }
+            }
+        },
+        "fxrate.ImportRatesResponse": {
+            "type": "object",
+            "properties": {
+                "imported": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fxrate.ListRatesRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "rate_date": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fxrate.ListRatesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FxRate"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BatchGetUserSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.GetUserMembershipTimelineRequest": {
+            "type": "object",
+            "properties": {
+                "query_at": {
+                    "description": "QueryAt is the point in time to evaluate; defaults to now.",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ListMembershipTransactionsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.TransactionItem"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ListPaymentItemVersionsRequest": {
+            "type": "object",
+            "properties": {
+                "payment_item_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ListTransactionRefundsRequest": {
+            "type": "object",
+            "properties": {
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ListTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "filters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.CommonFilter"
+                    }
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "sort_by": {
+                    "type": "string"
+                },
+                "sort_order": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RedeliverWebhookRequest": {
+            "type": "object",
+            "properties": {
+                "dead_letter_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RedeliverWebhookResponse": {
+            "type": "object",
+            "properties": {
+                "delivery_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespCreditBalance": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/models.CreditBalance"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespCreditLedgerEntry": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/models.CreditLedgerEntry"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespImportFxRates": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/fxrate.ImportRatesResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListCreditLedger": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/wallet.ListCreditLedgerResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListFxRates": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/fxrate.ListRatesResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListJobRuns": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/scheduler.ListJobRunsResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListMembershipTransactions": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/handlers.ListMembershipTransactionsResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListPaymentItemVersions": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PaymentItemVersion"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListPaymentItems": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PaymentItem"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListReconcileDrifts": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/transaction.ListReconcileDriftsResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListTransactionRefunds": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TransactionRefund"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespListWebhookDeadLetters": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/webhook.ListDeadLettersResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespMembershipStatistic": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/statistics.MembershipStatisticResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespOK": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespPaymentItem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/models.PaymentItem"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespRecoverAppleNotifications": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/notification_handler.RecoverAppleNotificationsResult"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespRedeliverWebhook": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/handlers.RedeliverWebhookResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespStripeCheckoutSession": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/transaction.CreateCheckoutSessionResponse"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespUserMembership": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/subscription.UserMembership"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespUserMembershipTimeline": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/response.APIResponseCode"
+                },
+                "data": {
+                    "$ref": "#/definitions/subscription.UserSubscriptionTimeline"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespUserMemberships": {
+            "type": "object",
+            "properties": {
                 "code": {
                     "$ref": "#/definitions/response.APIResponseCode"
                 },
-                "data": {},
-                "message": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/subscription.UserMembership"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SyncTransactionRefundRequest": {
+            "type": "object",
+            "properties": {
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TransactionItem": {
+            "type": "object",
+            "properties": {
+                "auto_renew_expire_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_first_purchase": {
+                    "type": "boolean"
+                },
+                "membership_duration_minutes": {
+                    "type": "integer"
+                },
+                "next_auto_renew_at": {
+                    "type": "string"
+                },
+                "parent_transaction_id": {
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "payment_item_type": {
+                    "$ref": "#/definitions/types.PaymentItemType"
+                },
+                "price": {
+                    "$ref": "#/definitions/types.Money"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "provider_item_id": {
+                    "type": "string"
+                },
+                "purchase_at": {
+                    "type": "string"
+                },
+                "refund_at": {
+                    "type": "string"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreditBalance": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreditLedgerEntry": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is positive for grants and negative for spends and clawbacks.",
+                    "type": "integer"
+                },
+                "balance_after": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "idempotency_key": {
+                    "description": "IdempotencyKey is the caller's key of a spend; a repeated spend with the same key is not applied twice.",
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "description": "ProviderID and TransactionID identify the purchase of grants and clawbacks; empty for spends.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentProvider"
+                        }
+                    ]
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FxRate": {
+            "type": "object",
+            "properties": {
+                "base_currency": {
+                    "type": "string"
+                },
+                "quote_currency": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "rate_date": {
+                    "description": "RateDate is the UTC day the rate applies to, formatted as time.DateOnly.",
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobRun": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "holder": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.JobRunStatus"
+                }
+            }
+        },
+        "models.JobRunStatus": {
+            "type": "string",
+            "enum": [
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "JobRunStatusRunning",
+                "JobRunStatusSucceeded",
+                "JobRunStatusFailed"
+            ]
+        },
+        "models.PaymentItem": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "credits": {
+                    "type": "integer"
+                },
+                "duration_hour": {
+                    "type": "integer"
+                },
+                "entitlements": {
+                    "description": "Entitlements are the entitlements the item grants; empty grants types.DefaultEntitlement.",
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "provider_item_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/types.PaymentItemStatus"
+                },
+                "subscription_group": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/types.PaymentItemType"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PaymentItemVersion": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "item": {
+                    "type": "object"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PendingDowngrade": {
+            "type": "object",
+            "properties": {
+                "effective_at": {
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReconcileDrift": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "object"
+                },
+                "applied": {
+                    "type": "boolean"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "original_transaction_id": {
+                    "description": "OriginalTransactionID identifies the renewal chain that was reconciled.",
+                    "type": "string"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "run_id": {
+                    "type": "string"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Transaction": {
+            "type": "object",
+            "properties": {
+                "before_upgraded_transaction_id": {
+                    "description": "BeforeUpgradedTransactionID points to the transaction_id this record upgrades from.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expire_at": {
+                    "description": "AutoRenewExpireAt is the expiry time for auto-renewable subscriptions, calculated by the payment provider.",
+                    "type": "string"
+                },
+                "extra": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "next_auto_renew_at": {
+                    "description": "If IsAutoRenewable is true, NextAutoRenewAt is the next auto-renewal time; otherwise it is nil.",
+                    "type": "string"
+                },
+                "parent_transaction_id": {
+                    "description": "ParentTransactionID is the parent transaction ID used for auto-renewal.",
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price is what the user paid, in the minor units of its currency.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.Money"
+                        }
+                    ]
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "purchase_at": {
+                    "description": "PurchaseAt is the purchase time.",
+                    "type": "string"
+                },
+                "refund_at": {
+                    "description": "RefundAt is the refund time.",
+                    "type": "string"
+                },
+                "revocation_date": {
+                    "description": "RevocationDate and RevocationReason are set when the provider refunded or revoked the transaction, or when an\nupgrade replaced it (RevocationReasonUpgraded, without RefundAt).",
+                    "type": "string"
+                },
+                "revocation_reason": {
+                    "type": "string"
+                },
+                "storefront": {
+                    "description": "Storefront is the country of the store the purchase was made in, as the provider reports it: an ISO\n3166-1 alpha-3 code for Apple, alpha-2 for Google.",
+                    "type": "string"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TransactionRefund": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "event": {
+                    "$ref": "#/definitions/models.TransactionRefundEvent"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "reason": {
+                    "description": "Reason is the revocation reason of the refund, see RevocationReasonRefund.",
+                    "type": "string"
+                },
+                "refund_at": {
+                    "description": "RefundAt is when the provider refunded the transaction; for a reversal, the refund that was reversed.",
+                    "type": "string"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TransactionRefundEvent": {
+            "type": "string",
+            "enum": [
+                "refunded",
+                "reversed"
+            ],
+            "x-enum-varnames": [
+                "TransactionRefundEventRefunded",
+                "TransactionRefundEventReversed"
+            ]
+        },
+        "models.WebhookDeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_id": {
+                    "type": "string"
+                },
+                "endpoint_id": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                }
+            }
+        },
+        "notification_handler.RecoverAppleNotificationsRequest": {
+            "type": "object",
+            "properties": {
+                "end_at": {
+                    "type": "string"
+                },
+                "only_failures": {
+                    "description": "OnlyFailures limits the history to notifications Apple could not deliver.",
+                    "type": "boolean"
+                },
+                "start_at": {
                     "type": "string"
                 }
             }
         },
-        "handlers.TransactionItem": {
+        "notification_handler.RecoverAppleNotificationsResult": {
             "type": "object",
             "properties": {
-                "auto_renew_expire_at": {
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/notification_handler.RecoveredNotification"
+                    }
+                },
+                "replayed": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                }
+            }
+        },
+        "notification_handler.RecoveredNotification": {
+            "type": "object",
+            "properties": {
+                "error": {
                     "type": "string"
                 },
-                "created_at": {
+                "notification_id": {
                     "type": "string"
                 },
-                "currency": {
+                "notification_type": {
+                    "type": "string"
+                },
+                "result": {
+                    "$ref": "#/definitions/notification_handler.RecoveredNotificationResult"
+                },
+                "subtype": {
+                    "type": "string"
+                },
+                "transaction_id": {
                     "type": "string"
+                }
+            }
+        },
+        "notification_handler.RecoveredNotificationResult": {
+            "type": "string",
+            "enum": [
+                "replayed",
+                "skipped",
+                "stale",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "RecoveredNotificationReplayed",
+                "RecoveredNotificationSkipped",
+                "RecoveredNotificationStale",
+                "RecoveredNotificationFailed"
+            ]
+        },
+        "response.APIResponseCode": {
+            "type": "integer",
+            "enum": [
+                0,
+                40000,
+                40100,
+                40300,
+                50000
+            ],
+            "x-enum-varnames": [
+                "APIResponseCodeOK",
+                "APIResponseCodeBadRequest",
+                "APIResponseCodeUnauthorized",
+                "APIResponseCodeForbidden",
+                "APIResponseCodeError"
+            ]
+        },
+        "scheduler.ListJobRunsRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "integer"
+                },
+                "job": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.JobRunStatus"
+                }
+            }
+        },
+        "scheduler.ListJobRunsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.JobRun"
+                    }
                 },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "statistics.MembershipStatisticDataItem": {
+            "type": "object",
+            "properties": {
                 "id": {
+                    "$ref": "#/definitions/statistics.StatisticType"
+                }
+            }
+        },
+        "statistics.MembershipStatisticRequest": {
+            "type": "object",
+            "properties": {
+                "data_items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/statistics.MembershipStatisticDataItem"
+                    }
+                },
+                "filters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.CommonFilter"
+                    }
+                },
+                "reporting_currency": {
+                    "description": "ReportingCurrency converts money series into one series of this currency, with the rate of each\npurchase date. Empty returns one series per currency.",
+                    "type": "string"
+                }
+            }
+        },
+        "statistics.MembershipStatisticResponse": {
+            "type": "object",
+            "properties": {
+                "data_items": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/statistics.MembershipStatisticResponseDataItem"
+                        }
+                    }
+                }
+            }
+        },
+        "statistics.MembershipStatisticResponseDataItem": {
+            "type": "object",
+            "properties": {
+                "date": {
                     "type": "string"
                 },
-                "is_first_purchase": {
-                    "type": "boolean"
+                "exponent": {
+                    "description": "Exponent is set for money series, whose Label is the currency and Value an amount in its minor units.",
+                    "type": "integer"
                 },
-                "membership_duration_minutes": {
+                "label": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "integer"
+                },
+                "value2": {
+                    "type": "integer"
+                },
+                "value3": {
                     "type": "integer"
+                }
+            }
+        },
+        "statistics.StatisticType": {
+            "type": "string",
+            "enum": [
+                "daily_transaction_count",
+                "daily_gmv",
+                "total_gmv",
+                "daily_refund_amount",
+                "daily_net_revenue",
+                "total_net_revenue",
+                "daily_membership_count",
+                "daily_new_membership_count",
+                "total_membership_count",
+                "daily_accumulated_membership_count",
+                "grace_period_membership_count",
+                "billing_retry_membership_count",
+                "daily_mrr",
+                "daily_arr",
+                "daily_arppu",
+                "daily_new_mrr",
+                "daily_expansion_mrr",
+                "daily_contraction_mrr",
+                "daily_churned_mrr",
+                "daily_net_new_mrr",
+                "daily_logo_churn_rate",
+                "daily_revenue_churn_rate",
+                "renewal_success_rate",
+                "refund_rate"
+            ],
+            "x-enum-varnames": [
+                "StatisticTypeDailyTransactionCount",
+                "StatisticTypeDailyGmv",
+                "StatisticTypeTotalGmv",
+                "StatisticTypeDailyRefundAmount",
+                "StatisticTypeDailyNetRevenue",
+                "StatisticTypeTotalNetRevenue",
+                "StatisticTypeDailyMembershipCount",
+                "StatisticTypeDailyNewMembershipCount",
+                "StatisticTypeTotalMembershipCount",
+                "StatisticTypeDailyAccumulatedMembershipCount",
+                "StatisticTypeGracePeriodMembershipCount",
+                "StatisticTypeBillingRetryMembershipCount",
+                "StatisticTypeDailyMrr",
+                "StatisticTypeDailyArr",
+                "StatisticTypeDailyArppu",
+                "StatisticTypeDailyNewMrr",
+                "StatisticTypeDailyExpansionMrr",
+                "StatisticTypeDailyContractionMrr",
+                "StatisticTypeDailyChurnedMrr",
+                "StatisticTypeDailyNetNewMrr",
+                "StatisticTypeDailyLogoChurnRate",
+                "StatisticTypeDailyRevenueChurnRate",
+                "StatisticTypeRenewalSuccessRate",
+                "StatisticTypeRefundRate"
+            ]
+        },
+        "subscription.SkippedTransaction": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "transaction": {
+                    "$ref": "#/definitions/models.Transaction"
+                }
+            }
+        },
+        "subscription.UserEntitlement": {
+            "type": "object",
+            "properties": {
+                "entitlement": {
+                    "type": "string"
+                },
+                "expire_at": {
+                    "description": "ExpireAt is the end of the uninterrupted run of periods granting the entitlement, including queued ones.\nIt is nil for a permanent unlock.",
+                    "type": "string"
                 },
                 "next_auto_renew_at": {
                     "type": "string"
                 },
-                "parent_transaction_id": {
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "tier": {
+                    "description": "Tier is granted by the current period.",
+                    "type": "integer"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "subscription.UserMembership": {
+            "type": "object",
+            "properties": {
+                "active_items": {
+                    "description": "ActiveItems are the current and queued periods, ordered by activation.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/subscription.UserMembershipItem"
+                    }
+                },
+                "entitlements": {
+                    "description": "Entitlements are held now, each with its own timeline.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/subscription.UserEntitlement"
+                    }
+                },
+                "pending_downgrade": {
+                    "description": "PendingDowngrade is set when the provider reported a downgrade for the next renewal.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PendingDowngrade"
+                        }
+                    ]
+                },
+                "subscription": {
+                    "$ref": "#/definitions/types.UserSubsctiptionInfo"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "subscription.UserMembershipItem": {
+            "type": "object",
+            "properties": {
+                "activated_at": {
+                    "type": "string"
+                },
+                "expire_at": {
+                    "type": "string"
+                },
+                "next_auto_renew_at": {
                     "type": "string"
                 },
                 "payment_item_id": {
                     "type": "string"
                 },
-                "payment_item_type": {
-                    "$ref": "#/definitions/types.PaymentItemType"
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "subscription.UserSubscriptionItem": {
+            "type": "object",
+            "properties": {
+                "activated_at": {
+                    "description": "ActivatedAt is the effective start time.",
+                    "type": "string"
+                },
+                "before_upgraded_transaction_id": {
+                    "description": "BeforeUpgradedTransactionID points to the transaction_id this record upgrades from.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expire_at": {
+                    "description": "ExpireAt is the expiration time. For an auto-renewable period in a grace period it is the end of the grace period.",
+                    "type": "string"
+                },
+                "extra": {
+                    "type": "object"
+                },
+                "grace_period_expire_at": {
+                    "description": "GracePeriodExpireAt is set when the provider keeps access after a failed renewal of this period.\nIt is only taken from the latest transaction of a renewal chain.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "next_auto_renew_at": {
+                    "description": "If IsAutoRenewable is true, NextAutoRenewAt is the next auto-renewal time; otherwise it is nil.",
+                    "type": "string"
+                },
+                "parent_transaction_id": {
+                    "description": "ParentTransactionID is the parent transaction ID used for auto-renewal.",
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
                 },
                 "price": {
-                    "type": "integer"
+                    "description": "Price is what the user paid, in the minor units of its currency.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.Money"
+                        }
+                    ]
                 },
                 "provider_id": {
                     "$ref": "#/definitions/types.PaymentProvider"
                 },
-                "provider_item_id": {
+                "purchase_at": {
+                    "description": "PurchaseAt is the purchase time.",
+                    "type": "string"
+                },
+                "refund_at": {
+                    "description": "RefundAt is the refund time.",
+                    "type": "string"
+                },
+                "remaining_duration_seconds": {
+                    "description": "RemainingDurationSeconds is the remaining active duration in seconds.\nIt is updated when refund-related adjustments occur.",
+                    "type": "integer"
+                },
+                "revocation_date": {
+                    "description": "RevocationDate and RevocationReason are set when the provider refunded or revoked the transaction, or when an\nupgrade replaced it (RevocationReasonUpgraded, without RefundAt).",
                     "type": "string"
                 },
-                "purchase_at": {
+                "revocation_reason": {
                     "type": "string"
                 },
-                "refund_at": {
+                "storefront": {
+                    "description": "Storefront is the country of the store the purchase was made in, as the provider reports it: an ISO\n3166-1 alpha-3 code for Apple, alpha-2 for Google.",
                     "type": "string"
                 },
                 "transaction_id": {
@@ -355,108 +2575,120 @@ const docTemplate = `{
                 }
             }
         },
-        "response.APIResponseCode": {
-            "type": "integer",
-            "enum": [
-                0,
-                40000,
-                50000
-            ],
-            "x-enum-varnames": [
-                "APIResponseCodeOK",
-                "APIResponseCodeBadRequest",
-                "APIResponseCodeError"
-            ]
-        },
-        "statistics.MembershipStatisticDataItem": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "$ref": "#/definitions/statistics.StatisticType"
-                }
-            }
-        },
-        "statistics.MembershipStatisticRequest": {
+        "subscription.UserSubscriptionTimeline": {
             "type": "object",
             "properties": {
-                "data_items": {
+                "active_items": {
+                    "description": "ActiveItems is the last contiguous chain of Periods, the one that decides membership.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/statistics.MembershipStatisticDataItem"
+                        "$ref": "#/definitions/subscription.UserSubscriptionItem"
                     }
                 },
-                "filters": {
+                "current_item": {
+                    "description": "CurrentItem is the period covering QueryAt, if any.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/subscription.UserSubscriptionItem"
+                        }
+                    ]
+                },
+                "expire_at": {
+                    "type": "string"
+                },
+                "periods": {
+                    "description": "Periods are all computed periods in activation order, each embedding the transaction that produced it.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/types.CommonFilter"
+                        "$ref": "#/definitions/subscription.UserSubscriptionItem"
+                    }
+                },
+                "query_at": {
+                    "type": "string"
+                },
+                "skipped": {
+                    "description": "Skipped lists transactions that produced no period, with the reason.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/subscription.SkippedTransaction"
                     }
+                },
+                "status": {
+                    "description": "Status and ExpireAt are the membership state at QueryAt.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.SubscriptionStatus"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "statistics.MembershipStatisticResponse": {
+        "transaction.CreateCheckoutSessionRequest": {
             "type": "object",
             "properties": {
-                "data_items": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "array",
-                        "items": {
-                            "$ref": "#/definitions/statistics.MembershipStatisticResponseDataItem"
-                        }
-                    }
+                "cancel_url": {
+                    "type": "string"
+                },
+                "payment_item_id": {
+                    "type": "string"
+                },
+                "success_url": {
+                    "description": "SuccessURL and CancelURL override the configured defaults.",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "statistics.MembershipStatisticResponseDataItem": {
+        "transaction.CreateCheckoutSessionResponse": {
             "type": "object",
             "properties": {
-                "date": {
+                "session_id": {
                     "type": "string"
                 },
-                "label": {
+                "url": {
                     "type": "string"
-                },
-                "value": {
+                }
+            }
+        },
+        "transaction.ListReconcileDriftsRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
                     "type": "integer"
                 },
-                "value2": {
-                    "type": "integer"
+                "run_id": {
+                    "type": "string"
                 },
-                "value3": {
+                "size": {
                     "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "statistics.StatisticType": {
-            "type": "string",
-            "enum": [
-                "daily_transaction_count",
-                "daily_gmv",
-                "total_gmv",
-                "daily_membership_count",
-                "daily_new_membership_count",
-                "total_membership_count",
-                "daily_accumulated_membership_count",
-                "renewal_success_rate"
-            ],
-            "x-enum-varnames": [
-                "StatisticTypeDailyTransactionCount",
-                "StatisticTypeDailyGmv",
-                "StatisticTypeTotalGmv",
-                "StatisticTypeDailyMembershipCount",
-                "StatisticTypeDailyNewMembershipCount",
-                "StatisticTypeTotalMembershipCount",
-                "StatisticTypeDailyAccumulatedMembershipCount",
-                "StatisticTypeRenewalSuccessRate"
-            ]
+        "transaction.ListReconcileDriftsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ReconcileDrift"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
         },
         "transaction.SendFreeGiftRequest": {
             "type": "object",
             "properties": {
-                "operator_id": {
-                    "description": "operator ID",
-                    "type": "string"
-                },
                 "payment_item_id": {
                     "type": "string"
                 },
@@ -468,6 +2700,10 @@ const docTemplate = `{
         "transaction.TransactionVerifyRequest": {
             "type": "object",
             "properties": {
+                "product_id": {
+                    "description": "ProductID is required by Google Play to verify one-time products.",
+                    "type": "string"
+                },
                 "provider_id": {
                     "type": "string"
                 },
@@ -525,15 +2761,108 @@ const docTemplate = `{
                 "CommonFilterOperatorIn"
             ]
         },
+        "types.EntitlementGrant": {
+            "type": "object",
+            "properties": {
+                "entitlement": {
+                    "type": "string"
+                },
+                "tier": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.Money": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "exponent": {
+                    "description": "Exponent is the number of minor unit digits of Currency, see CurrencyExponent.",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.PaymentItem": {
+            "type": "object",
+            "properties": {
+                "credits": {
+                    "description": "Credits is the number of credits a consumable item adds to the credit balance.",
+                    "type": "integer"
+                },
+                "duration_hour": {
+                    "description": "DurationHour is set for duration-based products and nil for non-duration products.",
+                    "type": "integer"
+                },
+                "entitlements": {
+                    "description": "Entitlements lists what the item grants; empty grants DefaultEntitlement at tier 0.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.EntitlementGrant"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "level": {
+                    "description": "Level ranks the items of a subscription group; higher levels are higher tiers.",
+                    "type": "integer"
+                },
+                "provider_id": {
+                    "$ref": "#/definitions/types.PaymentProvider"
+                },
+                "provider_item_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is empty for items that were never stored in the catalog and treated as active.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentItemStatus"
+                        }
+                    ]
+                },
+                "subscription_group": {
+                    "description": "SubscriptionGroup groups the items a subscription can switch between; changes are only classified\nbetween items of the same group.",
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/types.PaymentItemType"
+                },
+                "version": {
+                    "description": "Version is the catalog version of the item, incremented on every change; 0 outside the catalog.",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.PaymentItemStatus": {
+            "type": "string",
+            "enum": [
+                "active",
+                "archived"
+            ],
+            "x-enum-varnames": [
+                "PaymentItemStatusActive",
+                "PaymentItemStatusArchived"
+            ]
+        },
         "types.PaymentItemType": {
             "type": "string",
             "enum": [
                 "auto_renewable_subscription",
-                "non_renewable_subscription"
+                "non_renewable_subscription",
+                "consumable",
+                "non_consumable"
             ],
             "x-enum-varnames": [
                 "PaymentItemTypeAutoRenewableSubscription",
-                "PaymentItemTypeNonRenewableSubscription"
+                "PaymentItemTypeNonRenewableSubscription",
+                "PaymentItemTypeConsumable",
+                "PaymentItemTypeNonConsumable"
             ]
         },
         "types.PaymentProvider": {
@@ -541,13 +2870,133 @@ const docTemplate = `{
             "enum": [
                 "apple",
                 "google",
+                "stripe",
                 "inner"
             ],
             "x-enum-varnames": [
                 "PaymentProviderApple",
                 "PaymentProviderGoogle",
+                "PaymentProviderStripe",
                 "PaymentProviderInner"
             ]
+        },
+        "types.SubscriptionStatus": {
+            "type": "string",
+            "enum": [
+                "active",
+                "inactive",
+                "grace_period",
+                "billing_retry"
+            ],
+            "x-enum-varnames": [
+                "SubscriptionStatusActive",
+                "SubscriptionStatusInactive",
+                "SubscriptionStatusGracePeriod",
+                "SubscriptionStatusBillingRetry"
+            ]
+        },
+        "types.UserSubsctiptionInfo": {
+            "type": "object",
+            "properties": {
+                "expire_at": {
+                    "type": "string"
+                },
+                "next_auto_renew_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "wallet.ListCreditLedgerRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "wallet.ListCreditLedgerResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CreditLedgerEntry"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "wallet.SpendRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "idempotency_key": {
+                    "description": "IdempotencyKey identifies the spend; retrying with the same key returns the first entry.",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.ListDeadLettersRequest": {
+            "type": "object",
+            "properties": {
+                "endpoint_id": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "webhook.ListDeadLettersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookDeadLetter"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminAPIKey": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "AdminBearer": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
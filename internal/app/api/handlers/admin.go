@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	mw "github.com/fatflowers/cashier/internal/app/api/middleware"
+	"github.com/fatflowers/cashier/internal/app/service/catalog"
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
+	nh "github.com/fatflowers/cashier/internal/app/service/notification_handler"
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
 	"github.com/fatflowers/cashier/internal/app/service/statistics"
 	subsvc "github.com/fatflowers/cashier/internal/app/service/subscription"
 	"github.com/fatflowers/cashier/internal/app/service/transaction"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
+	"github.com/fatflowers/cashier/internal/app/service/webhook"
 	models "github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/pkg/config"
 	"github.com/fatflowers/cashier/pkg/response"
@@ -28,8 +35,7 @@ type TransactionItem struct {
 	ID                  string                `json:"id"`
 	TransactionID       string                `json:"transaction_id"`
 	UserID              string                `json:"user_id"`
-	Currency            string                `json:"currency"`
-	Price               int64                 `json:"price"`
+	Price               types.Money           `json:"price"`
 	ProviderID          types.PaymentProvider `json:"provider_id"`
 	IsFirstPurchase     bool                  `json:"is_first_purchase"`
 	PurchaseAt          time.Time             `json:"purchase_at"`
@@ -85,7 +91,6 @@ func toTransactionItem(cfg *config.Config, m *models.Transaction) *TransactionIt
 		ID:            m.ID,
 		TransactionID: m.TransactionID,
 		UserID:        m.UserID,
-		Currency:      m.Currency,
 		Price:         m.Price,
 		ProviderID:    m.ProviderID,
 		IsFirstPurchase: func() bool {
@@ -120,6 +125,8 @@ type ListMembershipTransactionsResponse struct {
 // @Produce      json
 // @Param        request body ListTransactionRequest true "List transaction request with filters, pagination, and sorting"
 // @Success      200  {object}  handlers.RespListMembershipTransactions
+// @Security     AdminAPIKey
+// @Security     AdminBearer
 // @Router       /api/v1/admin/list_user_membership_item [post]
 func ApiListMembershipTransactions(mgr transaction.TransactionManager, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -146,6 +153,8 @@ func ApiListMembershipTransactions(mgr transaction.TransactionManager, cfg *conf
 // @Produce      json
 // @Param        request body statistics.MembershipStatisticRequest true "Statistic request parameters"
 // @Success      200  {object}  handlers.RespMembershipStatistic
+// @Security     AdminAPIKey
+// @Security     AdminBearer
 // @Router       /api/v1/admin/get_membership_statistic [post]
 // ApiGetMembershipStatistic handles POST /v1/admin/get_membership_statistic
 func ApiGetMembershipStatistic(svc *statistics.Service) gin.HandlerFunc {
@@ -165,30 +174,201 @@ func ApiGetMembershipStatistic(svc *statistics.Service) gin.HandlerFunc {
 }
 
 // @Summary      Send Free Gift (Admin)
-// @Description  Grants a free membership item to a user.
+// @Description  Grants a free membership item to a user. The authenticated admin is recorded as the operator.
 // @Tags         Admin
 // @Accept       json
 // @Produce      json
 // @Param        request body transaction.SendFreeGiftRequest true "Send free gift request"
 // @Success      200  {object}  handlers.RespOK
+// @Security     AdminAPIKey
+// @Security     AdminBearer
 // @Router       /api/v1/admin/send_free_gift [post]
 // ApiSendFreeGift handles POST /api/v1/send_free_gift
 func ApiSendFreeGift(sub *subsvc.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			UserID        string `json:"user_id"`
-			PaymentItemID string `json:"payment_item_id"`
-			OperatorId    string `json:"operator_id"`
+		var req transaction.SendFreeGiftRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.UserID == "" || req.PaymentItemID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id or payment_item_id"))
+			return
+		}
+		op := mw.AdminOperatorFromGin(c)
+		if op == nil || op.ID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeUnauthorized, "missing operator"))
+			return
+		}
+		if err := sub.SendFreeGift(c.Request.Context(), req.UserID, req.PaymentItemID, op.ID); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT[any](nil))
+	}
+}
+
+type GetUserMembershipTimelineRequest struct {
+	UserID string `json:"user_id"`
+	// QueryAt is the point in time to evaluate; defaults to now.
+	QueryAt *time.Time `json:"query_at"`
+}
+
+// @Summary      Get User Membership Timeline (Admin)
+// @Description  Recomputes a user's membership periods from all transactions, including skipped transactions and the state at query_at.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body GetUserMembershipTimelineRequest true "Timeline request"
+// @Success      200  {object}  handlers.RespUserMembershipTimeline
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/get_user_membership_timeline [post]
+func ApiGetUserMembershipTimeline(sub *subsvc.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GetUserMembershipTimelineRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.UserID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id"))
+			return
+		}
+		res, err := sub.GetUserSubscriptionTimeline(c.Request.Context(), req.UserID, lo.FromPtr(req.QueryAt))
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List Webhook Dead Letters (Admin)
+// @Description  Lists membership webhook deliveries that failed every retry.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body webhook.ListDeadLettersRequest true "List dead letters request"
+// @Success      200  {object}  handlers.RespListWebhookDeadLetters
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_webhook_dead_letters [post]
+func ApiListWebhookDeadLetters(hooks *webhook.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req webhook.ListDeadLettersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
 		}
+		res, err := hooks.ListDeadLetters(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+type RedeliverWebhookRequest struct {
+	DeadLetterID string `json:"dead_letter_id"`
+}
+
+type RedeliverWebhookResponse struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// @Summary      Redeliver Webhook (Admin)
+// @Description  Queues a dead-lettered membership webhook for delivery again.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body RedeliverWebhookRequest true "Redeliver webhook request"
+// @Success      200  {object}  handlers.RespRedeliverWebhook
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/redeliver_webhook [post]
+func ApiRedeliverWebhook(hooks *webhook.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RedeliverWebhookRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
 			return
 		}
-		if req.UserID == "" || req.PaymentItemID == "" || req.OperatorId == "" {
-			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id or payment_item_id or operator_id"))
+		if req.DeadLetterID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing dead_letter_id"))
 			return
 		}
-		if err := sub.SendFreeGift(c.Request.Context(), req.UserID, req.PaymentItemID, req.OperatorId); err != nil {
+		deliveryID, err := hooks.Redeliver(c.Request.Context(), req.DeadLetterID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(&RedeliverWebhookResponse{DeliveryID: deliveryID}))
+	}
+}
+
+type ListTransactionRefundsRequest struct {
+	ProviderID    types.PaymentProvider `json:"provider_id"`
+	TransactionID string                `json:"transaction_id"`
+}
+
+// @Summary      List Transaction Refunds (Admin)
+// @Description  Lists the refunds and refund reversals of a transaction, oldest first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body ListTransactionRefundsRequest true "Transaction refunds request"
+// @Success      200  {object}  handlers.RespListTransactionRefunds
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_transaction_refunds [post]
+func ApiListTransactionRefunds(sub *subsvc.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ListTransactionRefundsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.ProviderID == "" || req.TransactionID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing provider_id or transaction_id"))
+			return
+		}
+		res, err := sub.ListTransactionRefunds(c.Request.Context(), req.ProviderID, req.TransactionID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+type SyncTransactionRefundRequest struct {
+	TransactionID string `json:"transaction_id"`
+}
+
+// @Summary      Sync Transaction Refund (Admin)
+// @Description  Re-reads a transaction from Apple or Google Play and applies the refund state it reports, recovering a missed refund or refund reversal notification. Stripe transactions are not supported.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body SyncTransactionRefundRequest true "Sync transaction refund request"
+// @Success      200  {object}  handlers.RespOK
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/sync_transaction_refund [post]
+func ApiSyncTransactionRefund(mgr transaction.TransactionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SyncTransactionRefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.TransactionID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing transaction_id"))
+			return
+		}
+		if err := mgr.RefundTransaction(c.Request.Context(), req.TransactionID, ""); err != nil {
 			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
 			return
 		}
@@ -196,8 +376,318 @@ func ApiSendFreeGift(sub *subsvc.Service) gin.HandlerFunc {
 	}
 }
 
-func RegisterAdminPaymentRoutes(r gin.IRouter, mgr transaction.TransactionManager, cfg *config.Config, stats *statistics.Service, sub *subsvc.Service) {
-	r.POST("/list_user_membership_item", ApiListMembershipTransactions(mgr, cfg))
-	r.POST("/get_membership_statistic", ApiGetMembershipStatistic(stats))
-	r.POST("/send_free_gift", ApiSendFreeGift(sub))
+// @Summary      Recover Apple Notifications (Admin)
+// @Description  Reads the App Store Server API notification history between start_at and end_at and handles every notification not recorded as handled, reporting the result of each.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body notification_handler.RecoverAppleNotificationsRequest true "Recover Apple notifications request"
+// @Success      200  {object}  handlers.RespRecoverAppleNotifications
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/recover_apple_notifications [post]
+func ApiRecoverAppleNotifications(recovery *nh.AppleNotificationRecovery) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req nh.RecoverAppleNotificationsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.StartAt.IsZero() || req.EndAt.IsZero() {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing start_at or end_at"))
+			return
+		}
+		res, err := recovery.Recover(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List Reconcile Drifts (Admin)
+// @Description  Lists the differences the scheduled reconciliation found between stored subscriptions and the provider, newest first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body transaction.ListReconcileDriftsRequest true "List reconcile drifts request"
+// @Success      200  {object}  handlers.RespListReconcileDrifts
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_reconcile_drifts [post]
+func ApiListReconcileDrifts(reconciler *transaction.AppleReconciler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req transaction.ListReconcileDriftsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := reconciler.ListDrifts(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List Job Runs (Admin)
+// @Description  Lists the runs of scheduled jobs, optionally filtered by job and status, newest first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body scheduler.ListJobRunsRequest true "List job runs request"
+// @Success      200  {object}  handlers.RespListJobRuns
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_job_runs [post]
+func ApiListJobRuns(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req scheduler.ListJobRunsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := sched.ListRuns(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List Payment Items (Admin)
+// @Description  Lists the payment item catalog, optionally filtered by provider and status.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body catalog.ListPaymentItemsRequest true "List payment items request"
+// @Success      200  {object}  handlers.RespListPaymentItems
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_payment_items [post]
+func ApiListPaymentItems(items *catalog.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req catalog.ListPaymentItemsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := items.List(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+type ListPaymentItemVersionsRequest struct {
+	PaymentItemID string `json:"payment_item_id"`
+}
+
+// @Summary      List Payment Item Versions (Admin)
+// @Description  Lists every version of a payment item with the operator who made it, newest first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body ListPaymentItemVersionsRequest true "List payment item versions request"
+// @Success      200  {object}  handlers.RespListPaymentItemVersions
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_payment_item_versions [post]
+func ApiListPaymentItemVersions(items *catalog.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ListPaymentItemVersionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.PaymentItemID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing payment_item_id"))
+			return
+		}
+		res, err := items.ListVersions(c.Request.Context(), req.PaymentItemID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      Create Payment Item (Admin)
+// @Description  Adds an active payment item to the catalog as version 1. The authenticated admin is recorded as the operator.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body types.PaymentItem true "Payment item"
+// @Success      200  {object}  handlers.RespPaymentItem
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/create_payment_item [post]
+func ApiCreatePaymentItem(items *catalog.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req types.PaymentItem
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		op := mw.AdminOperatorFromGin(c)
+		if op == nil || op.ID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeUnauthorized, "missing operator"))
+			return
+		}
+		res, err := items.Create(c.Request.Context(), &req, op.ID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      Update Payment Item (Admin)
+// @Description  Changes the type, duration or status (active/archived) of a payment item based on the version last read, storing a new version. Archived items can no longer be sold.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body catalog.UpdatePaymentItemRequest true "Update payment item request"
+// @Success      200  {object}  handlers.RespPaymentItem
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/update_payment_item [post]
+func ApiUpdatePaymentItem(items *catalog.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req catalog.UpdatePaymentItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.ID == "" || req.Version <= 0 {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing id or version"))
+			return
+		}
+		op := mw.AdminOperatorFromGin(c)
+		if op == nil || op.ID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeUnauthorized, "missing operator"))
+			return
+		}
+		res, err := items.Update(c.Request.Context(), &req, op.ID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List Credit Ledger (Admin)
+// @Description  Lists the credit grants, spends and refund clawbacks of a user, optionally filtered by kind, newest first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body wallet.ListCreditLedgerRequest true "List credit ledger request"
+// @Success      200  {object}  handlers.RespListCreditLedger
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_credit_ledger [post]
+func ApiListCreditLedger(w *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req wallet.ListCreditLedgerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := w.ListEntries(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      Import FX Rates (Admin)
+// @Description  Stores daily exchange rates (rate_date, base_currency, quote_currency, rate: one base unit in quote units), replacing those stored for the same day and pair. Send JSON, or CSV with Content-Type text/csv, a header row and the source in the query.
+// @Tags         Admin
+// @Accept       json
+// @Accept       text/csv
+// @Produce      json
+// @Param        request body fxrate.ImportRatesRequest true "Import FX rates request"
+// @Param        source query string false "Source of CSV rates"
+// @Success      200  {object}  handlers.RespImportFxRates
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/import_fx_rates [post]
+func ApiImportFxRates(rates *fxrate.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req fxrate.ImportRatesRequest
+		if c.ContentType() == "text/csv" {
+			parsed, err := fxrate.ParseCSV(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+				return
+			}
+			req = fxrate.ImportRatesRequest{Source: c.Query("source"), Rates: parsed}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := rates.Import(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      List FX Rates (Admin)
+// @Description  Lists stored exchange rates, optionally of one rate_date or involving one currency, newest day first.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request body fxrate.ListRatesRequest true "List FX rates request"
+// @Success      200  {object}  handlers.RespListFxRates
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v1/admin/list_fx_rates [post]
+func ApiListFxRates(rates *fxrate.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req fxrate.ListRatesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := rates.List(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+func RegisterAdminPaymentRoutes(r gin.IRouter, mgr transaction.TransactionManager, cfg *config.Config, stats *statistics.Service, sub *subsvc.Service, hooks *webhook.Service, recovery *nh.AppleNotificationRecovery, reconciler *transaction.AppleReconciler, sched *scheduler.Scheduler, items *catalog.Service, w *wallet.Service, rates *fxrate.Service) {
+	r.POST("/list_user_membership_item", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListMembershipTransactions(mgr, cfg))
+	r.POST("/get_membership_statistic", mw.RequireAdminScope(mw.AdminScopeStatisticsRead), ApiGetMembershipStatistic(stats))
+	r.POST("/send_free_gift", mw.RequireAdminScope(mw.AdminScopeGiftWrite), ApiSendFreeGift(sub))
+	r.POST("/get_user_membership_timeline", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiGetUserMembershipTimeline(sub))
+	r.POST("/list_webhook_dead_letters", mw.RequireAdminScope(mw.AdminScopeWebhookRead), ApiListWebhookDeadLetters(hooks))
+	r.POST("/redeliver_webhook", mw.RequireAdminScope(mw.AdminScopeWebhookWrite), ApiRedeliverWebhook(hooks))
+	r.POST("/list_transaction_refunds", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListTransactionRefunds(sub))
+	r.POST("/sync_transaction_refund", mw.RequireAdminScope(mw.AdminScopeRefundWrite), ApiSyncTransactionRefund(mgr))
+	r.POST("/recover_apple_notifications", mw.RequireAdminScope(mw.AdminScopeNotificationWrite), ApiRecoverAppleNotifications(recovery))
+	r.POST("/list_reconcile_drifts", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListReconcileDrifts(reconciler))
+	r.POST("/list_job_runs", mw.RequireAdminScope(mw.AdminScopeJobRead), ApiListJobRuns(sched))
+	r.POST("/list_payment_items", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListPaymentItems(items))
+	r.POST("/list_payment_item_versions", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListPaymentItemVersions(items))
+	r.POST("/create_payment_item", mw.RequireAdminScope(mw.AdminScopeCatalogWrite), ApiCreatePaymentItem(items))
+	r.POST("/update_payment_item", mw.RequireAdminScope(mw.AdminScopeCatalogWrite), ApiUpdatePaymentItem(items))
+	r.POST("/list_credit_ledger", mw.RequireAdminScope(mw.AdminScopeMembershipRead), ApiListCreditLedger(w))
+	r.POST("/import_fx_rates", mw.RequireAdminScope(mw.AdminScopeFxWrite), ApiImportFxRates(rates))
+	r.POST("/list_fx_rates", mw.RequireAdminScope(mw.AdminScopeStatisticsRead), ApiListFxRates(rates))
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	mw "github.com/fatflowers/cashier/internal/app/api/middleware"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary      Get Credit Balance
+// @Description  Returns the user's credit balance, zero when they never bought credits.
+// @Tags         Payment
+// @Produce      json
+// @Param        user_id query string true "User ID"
+// @Success      200  {object}  handlers.RespCreditBalance
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v2/payment/credits [get]
+func ApiGetCreditBalance(w *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("user_id")
+		if userID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id"))
+			return
+		}
+		res, err := w.GetBalance(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// @Summary      Spend Credits
+// @Description  Takes credits from the user's balance. Retrying with the same idempotency_key returns the first ledger entry instead of spending again; spending more than the balance fails.
+// @Tags         Payment
+// @Accept       json
+// @Produce      json
+// @Param        request body wallet.SpendRequest true "Spend request"
+// @Success      200  {object}  handlers.RespCreditLedgerEntry
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v2/payment/credits/spend [post]
+func ApiSpendCredits(w *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req wallet.SpendRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		res, err := w.Spend(c.Request.Context(), &req)
+		if err != nil {
+			if errors.Is(err, wallet.ErrInsufficientCredits) || errors.Is(err, wallet.ErrIdempotencyKeyReused) {
+				c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+				return
+			}
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+// RegisterCreditRoutes registers the credit APIs behind admin auth: product backends call them with the
+// credentials of the service role.
+func RegisterCreditRoutes(r gin.IRouter, cfg *config.Config, w *wallet.Service) {
+	g := r.Group("/credits", mw.AdminAuthMiddleware(cfg))
+	g.GET("", mw.RequireAdminScope(mw.AdminScopeCreditRead), ApiGetCreditBalance(w))
+	g.POST("/spend", mw.RequireAdminScope(mw.AdminScopeCreditWrite), ApiSpendCredits(w))
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	mw "github.com/fatflowers/cashier/internal/app/api/middleware"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/response"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
@@ -11,7 +19,7 @@ func TestRegisterPaymentV2Routes_RegistersEndpoints(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	g := r.Group("/api/v2/payment")
-	RegisterPaymentV2Routes(g, nil, nil)
+	RegisterPaymentV2Routes(g, nil, nil, nil)
 
 	routes := r.Routes()
 	contains := func(target string) bool {
@@ -25,4 +33,83 @@ func TestRegisterPaymentV2Routes_RegistersEndpoints(t *testing.T) {
 
 	require.True(t, contains("POST /api/v2/payment/verify_transaction"))
 	require.True(t, contains("POST /api/v2/payment/webhook/apple"))
+	require.True(t, contains("POST /api/v2/payment/webhook/google"))
+	require.True(t, contains("POST /api/v2/payment/webhook/stripe"))
+	require.True(t, contains("POST /api/v2/payment/stripe/checkout_session"))
+}
+
+func TestRegisterSubscriptionRoutes_RegistersEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterSubscriptionRoutes(r.Group("/api/v2/payment"), &config.Config{}, nil)
+
+	paths := map[string]bool{}
+	for _, rt := range r.Routes() {
+		paths[rt.Method+" "+rt.Path] = true
+	}
+	require.True(t, paths["GET /api/v2/payment/subscription"])
+	require.True(t, paths["POST /api/v2/payment/subscription/batch"])
+}
+
+func TestRegisterCreditRoutes_RegistersEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterCreditRoutes(r.Group("/api/v2/payment"), &config.Config{}, nil)
+
+	paths := map[string]bool{}
+	for _, rt := range r.Routes() {
+		paths[rt.Method+" "+rt.Path] = true
+	}
+	require.True(t, paths["GET /api/v2/payment/credits"])
+	require.True(t, paths["POST /api/v2/payment/credits/spend"])
+}
+
+func TestRegisterCreditRoutes_RequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterCreditRoutes(r.Group("/api/v2/payment"), &config.Config{AdminAuth: config.AdminAuthConfig{APIKeys: []*config.AdminAPIKey{
+		{Operator: "dashboard", Key: "k-viewer", Role: mw.AdminRoleViewer},
+	}}}, nil)
+	spend := func(apiKey string) response.APIResponseCode {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/payment/credits/spend", strings.NewReader(`{"user_id":"u1","amount":10,"idempotency_key":"k1"}`))
+		if apiKey != "" {
+			req.Header.Set(mw.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res response.APIResponse[json.RawMessage]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Code
+	}
+
+	require.Equal(t, response.APIResponseCodeUnauthorized, spend(""))
+	require.Equal(t, response.APIResponseCodeUnauthorized, spend("wrong"))
+	require.Equal(t, response.APIResponseCodeForbidden, spend("k-viewer"), "spending needs credit:write")
+}
+
+func TestRegisterSubscriptionRoutes_RequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterSubscriptionRoutes(r.Group("/api/v2/payment"), &config.Config{AdminAuth: config.AdminAuthConfig{APIKeys: []*config.AdminAPIKey{
+		{Operator: "billing", Key: "k-finance", Role: mw.AdminRoleFinance},
+		{Operator: "app", Key: "k-service", Role: mw.AdminRoleService},
+	}}}, nil)
+	batch := func(apiKey string) response.APIResponseCode {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/payment/subscription/batch", strings.NewReader(`{"user_ids":[]}`))
+		if apiKey != "" {
+			req.Header.Set(mw.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res response.APIResponse[json.RawMessage]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Code
+	}
+
+	require.Equal(t, response.APIResponseCodeUnauthorized, batch(""))
+	require.Equal(t, response.APIResponseCodeUnauthorized, batch("wrong"))
+	require.Equal(t, response.APIResponseCodeBadRequest, batch("k-service"), "the service role may read subscriptions")
+	require.Equal(t, response.APIResponseCodeForbidden, batch("k-finance"), "membership:read is not enough")
 }
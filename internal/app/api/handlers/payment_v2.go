@@ -54,7 +54,39 @@ func ApiVerifyTransactionV2(mgr transaction.TransactionManager) gin.HandlerFunc
 	}
 }
 
-func RegisterPaymentV2Routes(r gin.IRouter, mgr transaction.TransactionManager, notifHandler *nh.NotificationHandler) {
+// @Summary      Create Stripe Checkout Session
+// @Description  Creates a Stripe hosted checkout session for a Stripe payment item and returns its redirect URL.
+// @Tags         Payment
+// @Accept       json
+// @Produce      json
+// @Param        request body transaction.CreateCheckoutSessionRequest true "Checkout session request"
+// @Success      200  {object}  handlers.RespStripeCheckoutSession
+// @Router       /api/v2/payment/stripe/checkout_session [post]
+func ApiCreateStripeCheckoutSession(mgr *transaction.StripeTransactionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req transaction.CreateCheckoutSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if req.UserID == "" || req.PaymentItemID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id or payment_item_id"))
+			return
+		}
+
+		res, err := mgr.CreateCheckoutSession(c.Request.Context(), &req)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+func RegisterPaymentV2Routes(r gin.IRouter, mgr transaction.TransactionManager, stripe *transaction.StripeTransactionManager, notifHandler *nh.NotificationHandler) {
 	r.POST("/verify_transaction", ApiVerifyTransactionV2(mgr))
+	r.POST("/stripe/checkout_session", ApiCreateStripeCheckoutSession(stripe))
 	r.POST("/webhook/apple", ApiAppleWebhook(notifHandler))
+	r.POST("/webhook/google", ApiGoogleWebhook(notifHandler))
+	r.POST("/webhook/stripe", ApiStripeWebhook(notifHandler))
 }
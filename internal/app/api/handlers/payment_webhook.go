@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	nh "github.com/fatflowers/cashier/internal/app/service/notification_handler"
 	"github.com/fatflowers/cashier/pkg/logctx"
 	"github.com/fatflowers/cashier/pkg/response"
@@ -33,7 +34,76 @@ func ApiAppleWebhook(h *nh.NotificationHandler) gin.HandlerFunc {
 	}
 }
 
+// @Summary      Google Play Webhook
+// @Description  Handles Google Play Real-time Developer Notifications delivered by a Cloud Pub/Sub push subscription.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Param        token query string false "Push token configured on the Pub/Sub subscription endpoint"
+// @Param        payload body string true "Pub/Sub push request"
+// @Success      200  {object}  handlers.RespOK
+// @Failure      400  {object}  handlers.RespOK
+// @Failure      401  {object}  handlers.RespOK
+// @Failure      500  {object}  handlers.RespOK
+// @Router       /api/v2/payment/webhook/google [post]
+// ApiGoogleWebhook handles Google Play Real-time Developer Notifications. Pub/Sub redelivers every push that is
+// not answered with a 2xx, so failures are reported with an error status.
+func ApiGoogleWebhook(h *nh.NotificationHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logctx.FromCtx(c, h.Logger).Infow("webhook_google_received")
+
+		if err := h.HandleNotification(c, types.PaymentProviderGoogle); err != nil {
+			logctx.FromCtx(c, h.Logger).Errorw("webhook_google_handle_error", "error", err.Error())
+			c.JSON(webhookErrorStatus(err, http.StatusUnauthorized), response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		logctx.FromCtx(c, h.Logger).Infow("webhook_google_handled")
+		c.JSON(http.StatusOK, response.OKT[any](nil))
+	}
+}
+
+// @Summary      Stripe Webhook
+// @Description  Handles Stripe webhook events. The raw body is verified against the Stripe-Signature header.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Param        Stripe-Signature header string true "Stripe webhook signature"
+// @Param        payload body string true "Stripe event"
+// @Success      200  {object}  handlers.RespOK
+// @Failure      400  {object}  handlers.RespOK
+// @Failure      500  {object}  handlers.RespOK
+// @Router       /api/v2/payment/webhook/stripe [post]
+// ApiStripeWebhook handles Stripe webhook events. Stripe retries events that are not answered with a 2xx, so
+// failures are reported with an error status; a bad signature gets 400 as Stripe recommends.
+func ApiStripeWebhook(h *nh.NotificationHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logctx.FromCtx(c, h.Logger).Infow("webhook_stripe_received")
+
+		if err := h.HandleNotification(c, types.PaymentProviderStripe); err != nil {
+			logctx.FromCtx(c, h.Logger).Errorw("webhook_stripe_handle_error", "error", err.Error())
+			c.JSON(webhookErrorStatus(err, http.StatusBadRequest), response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		logctx.FromCtx(c, h.Logger).Infow("webhook_stripe_handled")
+		c.JSON(http.StatusOK, response.OKT[any](nil))
+	}
+}
+
+// webhookErrorStatus is the status of a response to a notification that failed. Providers redeliver
+// notifications that are not acknowledged with a 2xx, so only handled ones and those that change nothing get 200.
+func webhookErrorStatus(err error, unauthenticated int) int {
+	switch {
+	case errors.Is(err, nh.ErrUnauthenticatedNotification):
+		return unauthenticated
+	case errors.Is(err, nh.ErrInvalidNotification):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func RegisterPaymentWebhookRoutes(r gin.IRouter, h *nh.NotificationHandler) {
 	// Mount under provided group, expected at "/api"
 	r.POST("/apple", ApiAppleWebhook(h))
+	r.POST("/google", ApiGoogleWebhook(h))
+	r.POST("/stripe", ApiStripeWebhook(h))
 }
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	nh "github.com/fatflowers/cashier/internal/app/service/notification_handler"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookErrorStatus(t *testing.T) {
+	unauthenticated := fmt.Errorf("%w: invalid pubsub push token", nh.ErrUnauthenticatedNotification)
+	require.Equal(t, http.StatusUnauthorized, webhookErrorStatus(unauthenticated, http.StatusUnauthorized))
+	require.Equal(t, http.StatusBadRequest, webhookErrorStatus(fmt.Errorf("%w: bad body", nh.ErrInvalidNotification), http.StatusUnauthorized))
+	// Anything else may succeed when the provider redelivers the notification.
+	require.Equal(t, http.StatusInternalServerError, webhookErrorStatus(errors.New("notification m1 is being processed"), http.StatusUnauthorized))
+	require.Equal(t, http.StatusBadRequest, webhookErrorStatus(unauthenticated, http.StatusBadRequest), "Stripe expects 400 for a bad signature")
+}
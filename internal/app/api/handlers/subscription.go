@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	mw "github.com/fatflowers/cashier/internal/app/api/middleware"
+	subsvc "github.com/fatflowers/cashier/internal/app/service/subscription"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary      Get User Subscription
+// @Description  Returns the user's current membership, active items and pending downgrade.
+// @Tags         Payment
+// @Produce      json
+// @Param        user_id query string true "User ID"
+// @Success      200  {object}  handlers.RespUserMembership
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v2/payment/subscription [get]
+func ApiGetUserSubscription(sub *subsvc.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("user_id")
+		if userID == "" {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, "missing user_id"))
+			return
+		}
+		res, err := sub.GetUserMembership(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+type BatchGetUserSubscriptionRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// @Summary      Batch Get User Subscriptions
+// @Description  Returns memberships for up to 50 users, in request order.
+// @Tags         Payment
+// @Accept       json
+// @Produce      json
+// @Param        request body BatchGetUserSubscriptionRequest true "User IDs"
+// @Success      200  {object}  handlers.RespUserMemberships
+// @Security     AdminAPIKey
+// @Security     AdminBearer
+// @Router       /api/v2/payment/subscription/batch [post]
+func ApiBatchGetUserSubscription(sub *subsvc.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchGetUserSubscriptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, err.Error()))
+			return
+		}
+		if len(req.UserIDs) == 0 || len(req.UserIDs) > subsvc.MaxBatchMembershipUsers {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeBadRequest, fmt.Sprintf("user_ids must contain 1 to %d ids", subsvc.MaxBatchMembershipUsers)))
+			return
+		}
+		res, err := sub.BatchGetUserMembership(c.Request.Context(), req.UserIDs)
+		if err != nil {
+			c.JSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, response.OKT(res))
+	}
+}
+
+func RegisterSubscriptionRoutes(r gin.IRouter, cfg *config.Config, sub *subsvc.Service) {
+	g := r.Group("/subscription", mw.AdminAuthMiddleware(cfg), mw.RequireAdminScope(mw.AdminScopeSubscriptionRead))
+	g.GET("", ApiGetUserSubscription(sub))
+	g.POST("/batch", ApiBatchGetUserSubscription(sub))
+}
@@ -1,7 +1,15 @@
 package handlers
 
 import (
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
+	"github.com/fatflowers/cashier/internal/app/service/notification_handler"
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
 	"github.com/fatflowers/cashier/internal/app/service/statistics"
+	"github.com/fatflowers/cashier/internal/app/service/subscription"
+	"github.com/fatflowers/cashier/internal/app/service/transaction"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
+	"github.com/fatflowers/cashier/internal/app/service/webhook"
+	models "github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/pkg/response"
 	types "github.com/fatflowers/cashier/pkg/types"
 	"time"
@@ -28,6 +36,76 @@ type RespMembershipStatistic struct {
 	Data    statistics.MembershipStatisticResponse `json:"data"`
 }
 
+// RespStripeCheckoutSession wraps CreateCheckoutSessionResponse in the standard envelope.
+type RespStripeCheckoutSession struct {
+	Code    response.APIResponseCode                  `json:"code"`
+	Message string                                    `json:"message"`
+	Data    transaction.CreateCheckoutSessionResponse `json:"data"`
+}
+
+// RespListTransactionRefunds wraps the refund history of a transaction in the standard envelope.
+type RespListTransactionRefunds struct {
+	Code    response.APIResponseCode    `json:"code"`
+	Message string                      `json:"message"`
+	Data    []*models.TransactionRefund `json:"data"`
+}
+
+// RespRecoverAppleNotifications wraps RecoverAppleNotificationsResult in the standard envelope.
+type RespRecoverAppleNotifications struct {
+	Code    response.APIResponseCode                              `json:"code"`
+	Message string                                                `json:"message"`
+	Data    *notification_handler.RecoverAppleNotificationsResult `json:"data"`
+}
+
+// RespListReconcileDrifts wraps ListReconcileDriftsResponse in the standard envelope.
+type RespListReconcileDrifts struct {
+	Code    response.APIResponseCode                `json:"code"`
+	Message string                                  `json:"message"`
+	Data    transaction.ListReconcileDriftsResponse `json:"data"`
+}
+
+// RespListJobRuns wraps ListJobRunsResponse in the standard envelope.
+type RespListJobRuns struct {
+	Code    response.APIResponseCode      `json:"code"`
+	Message string                        `json:"message"`
+	Data    scheduler.ListJobRunsResponse `json:"data"`
+}
+
+// RespListWebhookDeadLetters wraps ListDeadLettersResponse in the standard envelope.
+type RespListWebhookDeadLetters struct {
+	Code    response.APIResponseCode        `json:"code"`
+	Message string                          `json:"message"`
+	Data    webhook.ListDeadLettersResponse `json:"data"`
+}
+
+// RespRedeliverWebhook wraps RedeliverWebhookResponse in the standard envelope.
+type RespRedeliverWebhook struct {
+	Code    response.APIResponseCode `json:"code"`
+	Message string                   `json:"message"`
+	Data    RedeliverWebhookResponse `json:"data"`
+}
+
+// RespUserMembership wraps UserMembership in the standard envelope.
+type RespUserMembership struct {
+	Code    response.APIResponseCode    `json:"code"`
+	Message string                      `json:"message"`
+	Data    subscription.UserMembership `json:"data"`
+}
+
+// RespUserMemberships wraps a list of UserMembership in the standard envelope.
+type RespUserMemberships struct {
+	Code    response.APIResponseCode      `json:"code"`
+	Message string                        `json:"message"`
+	Data    []subscription.UserMembership `json:"data"`
+}
+
+// RespUserMembershipTimeline wraps UserSubscriptionTimeline in the standard envelope.
+type RespUserMembershipTimeline struct {
+	Code    response.APIResponseCode              `json:"code"`
+	Message string                                `json:"message"`
+	Data    subscription.UserSubscriptionTimeline `json:"data"`
+}
+
 // RespUserListTransactions wraps a list of transactions in the standard envelope.
 type RespUserListTransactions struct {
 	Code    response.APIResponseCode `json:"code"`
@@ -40,8 +118,7 @@ type SwaggerTransaction struct {
 	ID                  string                `json:"id"`
 	TransactionID       string                `json:"transaction_id"`
 	UserID              string                `json:"user_id"`
-	Currency            string                `json:"currency"`
-	Price               int64                 `json:"price"`
+	Price               types.Money           `json:"price"`
 	ProviderID          types.PaymentProvider `json:"provider_id"`
 	PurchaseAt          time.Time             `json:"purchase_at"`
 	RefundAt            *time.Time            `json:"refund_at"`
@@ -52,3 +129,59 @@ type SwaggerTransaction struct {
 	UpdatedAt           time.Time             `json:"updated_at"`
 	PaymentItemID       string                `json:"payment_item_id"`
 }
+
+// RespListPaymentItems wraps the catalog items in the standard envelope.
+type RespListPaymentItems struct {
+	Code    response.APIResponseCode `json:"code"`
+	Message string                   `json:"message"`
+	Data    []*models.PaymentItem    `json:"data"`
+}
+
+// RespPaymentItem wraps a created or updated catalog item in the standard envelope.
+type RespPaymentItem struct {
+	Code    response.APIResponseCode `json:"code"`
+	Message string                   `json:"message"`
+	Data    *models.PaymentItem      `json:"data"`
+}
+
+// RespListPaymentItemVersions wraps the versions of a catalog item in the standard envelope.
+type RespListPaymentItemVersions struct {
+	Code    response.APIResponseCode     `json:"code"`
+	Message string                       `json:"message"`
+	Data    []*models.PaymentItemVersion `json:"data"`
+}
+
+// RespCreditBalance wraps a user's credit balance in the standard envelope.
+type RespCreditBalance struct {
+	Code    response.APIResponseCode `json:"code"`
+	Message string                   `json:"message"`
+	Data    *models.CreditBalance    `json:"data"`
+}
+
+// RespCreditLedgerEntry wraps the ledger entry of a spend in the standard envelope.
+type RespCreditLedgerEntry struct {
+	Code    response.APIResponseCode  `json:"code"`
+	Message string                    `json:"message"`
+	Data    *models.CreditLedgerEntry `json:"data"`
+}
+
+// RespListCreditLedger wraps ListCreditLedgerResponse in the standard envelope.
+type RespListCreditLedger struct {
+	Code    response.APIResponseCode        `json:"code"`
+	Message string                          `json:"message"`
+	Data    wallet.ListCreditLedgerResponse `json:"data"`
+}
+
+// RespImportFxRates wraps ImportRatesResponse in the standard envelope.
+type RespImportFxRates struct {
+	Code    response.APIResponseCode   `json:"code"`
+	Message string                     `json:"message"`
+	Data    fxrate.ImportRatesResponse `json:"data"`
+}
+
+// RespListFxRates wraps ListRatesResponse in the standard envelope.
+type RespListFxRates struct {
+	Code    response.APIResponseCode `json:"code"`
+	Message string                   `json:"message"`
+	Data    fxrate.ListRatesResponse `json:"data"`
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// Admin scopes guard individual admin routes.
+const (
+	AdminScopeMembershipRead = "membership:read"
+	AdminScopeStatisticsRead = "statistics:read"
+	AdminScopeGiftWrite      = "gift:write"
+	AdminScopeWebhookRead    = "webhook:read"
+	AdminScopeWebhookWrite   = "webhook:write"
+	AdminScopeRefundWrite    = "refund:write"
+	// AdminScopeNotificationWrite replays provider notifications.
+	AdminScopeNotificationWrite = "notification:write"
+	// AdminScopeJobRead reads the run history of scheduled jobs.
+	AdminScopeJobRead = "job:read"
+	// AdminScopeCatalogWrite creates and changes payment items.
+	AdminScopeCatalogWrite = "catalog:write"
+	// AdminScopeFxWrite imports exchange rates.
+	AdminScopeFxWrite = "fx:write"
+	// AdminScopeCreditRead and AdminScopeCreditWrite read and spend credit balances.
+	AdminScopeCreditRead  = "credit:read"
+	AdminScopeCreditWrite = "credit:write"
+	// AdminScopeSubscriptionRead reads the membership of users through the /api/v2/payment subscription APIs,
+	// without the admin read routes that membership:read opens.
+	AdminScopeSubscriptionRead = "subscription:read"
+)
+
+// Admin roles and the scopes they grant.
+const (
+	AdminRoleViewer  = "viewer"
+	AdminRoleSupport = "support"
+	AdminRoleFinance = "finance"
+	AdminRoleAdmin   = "admin"
+	// AdminRoleService is for product backends calling the /api/v2/payment user APIs.
+	AdminRoleService = "service"
+)
+
+var adminRoleScopes = map[string][]string{
+	AdminRoleViewer:  {AdminScopeMembershipRead},
+	AdminRoleSupport: {AdminScopeMembershipRead, AdminScopeGiftWrite, AdminScopeWebhookRead, AdminScopeWebhookWrite, AdminScopeRefundWrite},
+	AdminRoleFinance: {AdminScopeMembershipRead, AdminScopeStatisticsRead, AdminScopeFxWrite},
+	AdminRoleService: {AdminScopeSubscriptionRead, AdminScopeCreditRead, AdminScopeCreditWrite},
+	AdminRoleAdmin: {AdminScopeMembershipRead, AdminScopeStatisticsRead, AdminScopeGiftWrite, AdminScopeWebhookRead, AdminScopeWebhookWrite, AdminScopeRefundWrite,
+		AdminScopeNotificationWrite, AdminScopeJobRead, AdminScopeCatalogWrite, AdminScopeFxWrite, AdminScopeCreditRead, AdminScopeCreditWrite,
+		AdminScopeSubscriptionRead},
+}
+
+const (
+	APIKeyHeader        = "X-API-Key"
+	adminOperatorCtxKey = "admin_operator"
+)
+
+// AdminOperator is the authenticated caller of an admin route.
+type AdminOperator struct {
+	ID     string   `json:"id"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+	// Method is "api_key" or "jwt".
+	Method string `json:"method"`
+}
+
+func (o *AdminOperator) HasScope(scope string) bool {
+	return o != nil && slices.Contains(o.Scopes, scope)
+}
+
+// AdminClaims are the JWT claims accepted for admin bearer tokens.
+type AdminClaims struct {
+	jwt.StandardClaims
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+var (
+	errAdminAuthNotConfigured = errors.New("admin auth is not configured")
+	errMissingCredentials     = errors.New("missing credentials")
+	errInvalidAPIKey          = errors.New("invalid api key")
+)
+
+// AdminAuthMiddleware authenticates admin callers by X-API-Key or an HS256 "Authorization: Bearer" token
+// and stores the AdminOperator in the gin and request contexts.
+func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, err := authenticateAdmin(&cfg.AdminAuth, c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeUnauthorized, err.Error()))
+			return
+		}
+		c.Set(adminOperatorCtxKey, op)
+		ctx := context.WithValue(c.Request.Context(), adminOperatorCtxKey, op)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireAdminScope rejects callers lacking scope. It must run after AdminAuthMiddleware.
+func RequireAdminScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := AdminOperatorFromGin(c)
+		if op == nil {
+			c.AbortWithStatusJSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeUnauthorized, errMissingCredentials.Error()))
+			return
+		}
+		if !op.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusOK, response.ErrorT[any](response.APIResponseCodeForbidden, fmt.Sprintf("missing scope %s", scope)))
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminOperatorFromGin returns the authenticated operator, or nil.
+func AdminOperatorFromGin(c *gin.Context) *AdminOperator {
+	if v, ok := c.Get(adminOperatorCtxKey); ok {
+		if op, ok := v.(*AdminOperator); ok {
+			return op
+		}
+	}
+	return nil
+}
+
+func authenticateAdmin(cfg *config.AdminAuthConfig, r *http.Request) (*AdminOperator, error) {
+	if len(cfg.APIKeys) == 0 && cfg.JWTSecret == "" {
+		return nil, errAdminAuthNotConfigured
+	}
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return authenticateAPIKey(cfg, key)
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return authenticateJWT(cfg, token)
+	}
+	return nil, errMissingCredentials
+}
+
+func authenticateAPIKey(cfg *config.AdminAuthConfig, key string) (*AdminOperator, error) {
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" || subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) != 1 {
+			continue
+		}
+		return &AdminOperator{ID: k.Operator, Role: k.Role, Scopes: resolveAdminScopes(k.Role, k.Scopes), Method: "api_key"}, nil
+	}
+	return nil, errInvalidAPIKey
+}
+
+func authenticateJWT(cfg *config.AdminAuthConfig, tokenString string) (*AdminOperator, error) {
+	if cfg.JWTSecret == "" {
+		return nil, errors.New("jwt auth is not configured")
+	}
+	var claims AdminClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("invalid token: exp required")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid token: sub required")
+	}
+	if cfg.JWTIssuer != "" && !claims.VerifyIssuer(cfg.JWTIssuer, true) {
+		return nil, errors.New("invalid token: unexpected issuer")
+	}
+	return &AdminOperator{ID: claims.Subject, Role: claims.Role, Scopes: resolveAdminScopes(claims.Role, claims.Scopes), Method: "jwt"}, nil
+}
+
+// resolveAdminScopes merges the role's scopes with explicitly granted ones. Unknown scopes are dropped.
+func resolveAdminScopes(role string, extra []string) []string {
+	scopes := slices.Clone(adminRoleScopes[role])
+	known := adminRoleScopes[AdminRoleAdmin]
+	for _, s := range extra {
+		if slices.Contains(known, s) && !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTestRouter(cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/admin")
+	g.Use(AdminAuthMiddleware(cfg))
+	g.POST("/gift", RequireAdminScope(AdminScopeGiftWrite), func(c *gin.Context) {
+		c.JSON(http.StatusOK, response.OKT(AdminOperatorFromGin(c)))
+	})
+	return r
+}
+
+func doAdminRequest(t *testing.T, r *gin.Engine, header, value string) *response.APIResponse[json.RawMessage] {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/gift", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var res response.APIResponse[json.RawMessage]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return &res
+}
+
+func signAdminToken(t *testing.T, secret string, claims AdminClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return s
+}
+
+func TestAdminAuth_APIKey(t *testing.T) {
+	r := newAdminTestRouter(&config.Config{AdminAuth: config.AdminAuthConfig{APIKeys: []*config.AdminAPIKey{
+		{Operator: "alice", Key: "k-support", Role: AdminRoleSupport},
+		{Operator: "bob", Key: "k-viewer", Role: AdminRoleViewer},
+		{Operator: "carol", Key: "k-viewer-gift", Role: AdminRoleViewer, Scopes: []string{AdminScopeGiftWrite}},
+	}}})
+
+	res := doAdminRequest(t, r, APIKeyHeader, "k-support")
+	require.Equal(t, response.APIResponseCodeOK, res.Code)
+	var op AdminOperator
+	require.NoError(t, json.Unmarshal(res.Data, &op))
+	require.Equal(t, "alice", op.ID)
+	require.Equal(t, "api_key", op.Method)
+
+	require.Equal(t, response.APIResponseCodeForbidden, doAdminRequest(t, r, APIKeyHeader, "k-viewer").Code)
+	require.Equal(t, response.APIResponseCodeOK, doAdminRequest(t, r, APIKeyHeader, "k-viewer-gift").Code)
+	require.Equal(t, response.APIResponseCodeUnauthorized, doAdminRequest(t, r, APIKeyHeader, "wrong").Code)
+	require.Equal(t, response.APIResponseCodeUnauthorized, doAdminRequest(t, r, "", "").Code)
+}
+
+func TestAdminAuth_JWT(t *testing.T) {
+	r := newAdminTestRouter(&config.Config{AdminAuth: config.AdminAuthConfig{JWTSecret: "secret", JWTIssuer: "sso"}})
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token := signAdminToken(t, "secret", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "dave", Issuer: "sso", ExpiresAt: exp}, Role: AdminRoleAdmin})
+	res := doAdminRequest(t, r, "Authorization", "Bearer "+token)
+	require.Equal(t, response.APIResponseCodeOK, res.Code)
+	var op AdminOperator
+	require.NoError(t, json.Unmarshal(res.Data, &op))
+	require.Equal(t, "dave", op.ID)
+	require.Equal(t, "jwt", op.Method)
+
+	finance := signAdminToken(t, "secret", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "erin", Issuer: "sso", ExpiresAt: exp}, Role: AdminRoleFinance})
+	require.Equal(t, response.APIResponseCodeForbidden, doAdminRequest(t, r, "Authorization", "Bearer "+finance).Code)
+
+	for name, bad := range map[string]string{
+		"wrong secret": signAdminToken(t, "other", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "dave", Issuer: "sso", ExpiresAt: exp}, Role: AdminRoleAdmin}),
+		"expired":      signAdminToken(t, "secret", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "dave", Issuer: "sso", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, Role: AdminRoleAdmin}),
+		"no exp":       signAdminToken(t, "secret", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "dave", Issuer: "sso"}, Role: AdminRoleAdmin}),
+		"wrong issuer": signAdminToken(t, "secret", AdminClaims{StandardClaims: jwt.StandardClaims{Subject: "dave", Issuer: "other", ExpiresAt: exp}, Role: AdminRoleAdmin}),
+	} {
+		require.Equal(t, response.APIResponseCodeUnauthorized, doAdminRequest(t, r, "Authorization", "Bearer "+bad).Code, name)
+	}
+}
+
+func TestAdminAuth_NotConfigured(t *testing.T) {
+	r := newAdminTestRouter(&config.Config{})
+	require.Equal(t, response.APIResponseCodeUnauthorized, doAdminRequest(t, r, APIKeyHeader, "anything").Code)
+}
+
+func TestAdminRoleScopes_ServiceRoleHasNoAdminReads(t *testing.T) {
+	service := &AdminOperator{Scopes: adminRoleScopes[AdminRoleService]}
+	require.True(t, service.HasScope(AdminScopeSubscriptionRead))
+	require.True(t, service.HasScope(AdminScopeCreditWrite))
+	require.False(t, service.HasScope(AdminScopeMembershipRead), "product backends do not get the support console")
+	require.True(t, (&AdminOperator{Scopes: adminRoleScopes[AdminRoleAdmin]}).HasScope(AdminScopeSubscriptionRead))
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"github.com/fatflowers/cashier/docs"
 	"github.com/fatflowers/cashier/internal/app/api/handlers"
+	"github.com/fatflowers/cashier/internal/app/service/catalog"
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
 	nh "github.com/fatflowers/cashier/internal/app/service/notification_handler"
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
 	"github.com/fatflowers/cashier/internal/app/service/statistics"
 	subsvc "github.com/fatflowers/cashier/internal/app/service/subscription"
 	"github.com/fatflowers/cashier/internal/app/service/transaction"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
+	"github.com/fatflowers/cashier/internal/app/service/webhook"
 	cfgpkg "github.com/fatflowers/cashier/pkg/config"
 	"net/http"
 	"time"
@@ -32,7 +37,7 @@ func newEngine() *gin.Engine {
 	return r
 }
 
-func registerRoutes(r *gin.Engine, log *zap.SugaredLogger, notifHandler *nh.NotificationHandler, txMgr transaction.TransactionManager, sub *subsvc.Service, cfg *cfgpkg.Config, stats *statistics.Service) {
+func registerRoutes(r *gin.Engine, log *zap.SugaredLogger, notifHandler *nh.NotificationHandler, txMgr transaction.TransactionManager, stripeMgr *transaction.StripeTransactionManager, sub *subsvc.Service, cfg *cfgpkg.Config, stats *statistics.Service, hooks *webhook.Service, recovery *nh.AppleNotificationRecovery, reconciler *transaction.AppleReconciler, sched *scheduler.Scheduler, items *catalog.Service, w *wallet.Service, rates *fxrate.Service) {
 	// Prometheus metrics
 	if cfg != nil && cfg.MetricsAddr != "" {
 		p := metrics.NewPrometheus(metrics.NewPrometheusOptions{
@@ -57,17 +62,21 @@ func registerRoutes(r *gin.Engine, log *zap.SugaredLogger, notifHandler *nh.Noti
 	docs.SwaggerInfo.BasePath = "/"
 	pub.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Protected group using auth middleware
+	// API v1; the admin group is protected by admin auth
 	apiV1 := r.Group("/api/v1")
 	apiV1.Use(mw.RequestLoggerMiddleware(log), mw.AccessLogMiddleware())
 
 	// Admin payment APIs
-	handlers.RegisterAdminPaymentRoutes(apiV1.Group("/admin"), txMgr, cfg, stats, sub)
+	admin := apiV1.Group("/admin")
+	admin.Use(mw.AdminAuthMiddleware(cfg))
+	handlers.RegisterAdminPaymentRoutes(admin, txMgr, cfg, stats, sub, hooks, recovery, reconciler, sched, items, w, rates)
 
 	// Payment v2 APIs
 	apiV2Payment := r.Group("/api/v2/payment")
 	apiV2Payment.Use(mw.RequestLoggerMiddleware(log), mw.AccessLogMiddleware())
-	handlers.RegisterPaymentV2Routes(apiV2Payment, txMgr, notifHandler)
+	handlers.RegisterPaymentV2Routes(apiV2Payment, txMgr, stripeMgr, notifHandler)
+	handlers.RegisterSubscriptionRoutes(apiV2Payment, cfg, sub)
+	handlers.RegisterCreditRoutes(apiV2Payment, cfg, w)
 }
 
 func runServer(lc fx.Lifecycle, log *zap.SugaredLogger, cfg *cfgpkg.Config, r *gin.Engine) {
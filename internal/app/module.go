@@ -2,11 +2,17 @@ package app
 
 import (
 	"github.com/fatflowers/cashier/internal/app/api/server"
+	"github.com/fatflowers/cashier/internal/app/service/catalog"
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
 	notificationhandler "github.com/fatflowers/cashier/internal/app/service/notification_handler"
 	notificationlog "github.com/fatflowers/cashier/internal/app/service/notification_log"
+	"github.com/fatflowers/cashier/internal/app/service/outbox"
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
 	"github.com/fatflowers/cashier/internal/app/service/statistics"
 	"github.com/fatflowers/cashier/internal/app/service/subscription"
 	"github.com/fatflowers/cashier/internal/app/service/transaction"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
+	"github.com/fatflowers/cashier/internal/app/service/webhook"
 	"github.com/fatflowers/cashier/internal/platform/db"
 	"github.com/fatflowers/cashier/pkg/config"
 	"github.com/fatflowers/cashier/pkg/logger"
@@ -24,10 +30,19 @@ var Module = fx.Options(
 	logger.Module,
 	config.Module,
 	db.Module,
+	// catalog is loaded right after the schema check so every service sees the payment items.
+	catalog.Module,
+	// outbox starts before and stops after the server so in-flight requests are drained.
+	outbox.Module,
 	server.Module,
+	wallet.Module,
 	subscription.Module,
+	fxrate.Module,
 	statistics.Module,
 	notificationlog.Module,
 	notificationhandler.Module,
 	transaction.Module,
+	webhook.Module,
+	// scheduler starts last and stops first so jobs finish while their dependencies are still running.
+	scheduler.Module,
 )
@@ -0,0 +1,459 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultRefreshInterval = 30 * time.Second
+	// seedOperator is recorded as the operator of items seeded from the config.
+	seedOperator = "config"
+)
+
+var (
+	ErrPaymentItemNotFound = errors.New("payment item not found")
+	// ErrVersionConflict means the item changed since the version the caller read.
+	ErrVersionConflict = errors.New("payment item version conflict")
+)
+
+// index is an immutable view of the catalog, swapped atomically on reload.
+type index struct {
+	byID           map[string]*types.PaymentItem
+	byProviderItem map[providerItemKey]*types.PaymentItem
+}
+
+type providerItemKey struct {
+	providerID     types.PaymentProvider
+	providerItemID string
+}
+
+func newIndex(items []*models.PaymentItem) *index {
+	idx := &index{
+		byID:           make(map[string]*types.PaymentItem, len(items)),
+		byProviderItem: make(map[providerItemKey]*types.PaymentItem, len(items)),
+	}
+	for _, m := range items {
+		item := m.ToType()
+		idx.byID[item.ID] = item
+		idx.byProviderItem[providerItemKey{item.ProviderID, item.ProviderItemID}] = item
+	}
+	return idx
+}
+
+// Service stores the payment item catalog in Postgres and serves lookups from an in-memory copy. The copy is
+// reloaded after every change made through the service and every Catalog.RefreshInterval for changes made by
+// other replicas.
+type Service struct {
+	cfg *config.Config
+	db  *gorm.DB
+	log *zap.SugaredLogger
+
+	index atomic.Pointer[index]
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(cfg *config.Config, db *gorm.DB, log *zap.SugaredLogger) *Service {
+	s := &Service{cfg: cfg, db: db, log: log}
+	s.index.Store(newIndex(nil))
+	return s
+}
+
+// PaymentItemByID returns a copy of the item, archived or not, or nil.
+func (s *Service) PaymentItemByID(id string) *types.PaymentItem {
+	if item, ok := s.index.Load().byID[id]; ok {
+		return lo.ToPtr(*item)
+	}
+	return nil
+}
+
+// PaymentItemByProviderItemID returns a copy of the item sold as providerItemID by the provider, or nil.
+func (s *Service) PaymentItemByProviderItemID(providerID types.PaymentProvider, providerItemID string) *types.PaymentItem {
+	if item, ok := s.index.Load().byProviderItem[providerItemKey{providerID, providerItemID}]; ok {
+		return lo.ToPtr(*item)
+	}
+	return nil
+}
+
+// Reload replaces the in-memory copy with the stored catalog.
+func (s *Service) Reload(ctx context.Context) error {
+	var items []*models.PaymentItem
+	if err := s.db.WithContext(ctx).Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to load payment items: %w", err)
+	}
+	s.index.Store(newIndex(items))
+	return nil
+}
+
+// Seed stores the config payment items missing from the catalog as version 1. A stored item that differs from
+// the config is updated to a new version when its current version was seeded from the config too; once an item
+// was changed through the admin API the stored item wins, so those changes survive restarts, and the
+// difference is logged instead.
+func (s *Service) Seed(ctx context.Context) error {
+	var seeded, updated int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range s.cfg.PaymentItems {
+			if err := validatePaymentItem(item); err != nil {
+				return fmt.Errorf("invalid payment item %s in config: %w", item.ID, err)
+			}
+			m := newPaymentItemModel(item)
+			m.Status = lo.CoalesceOrEmpty(item.Status, types.PaymentItemStatusActive)
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+			if res.Error != nil {
+				return fmt.Errorf("failed to seed payment item %s: %w", item.ID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				changed, err := s.reseed(tx, m)
+				if err != nil {
+					return err
+				}
+				if changed {
+					updated++
+				}
+				continue
+			}
+			if err := createVersion(tx, m, seedOperator); err != nil {
+				return err
+			}
+			seeded++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if seeded > 0 || updated > 0 {
+		s.log.Infow("seeded payment items from config", "created", seeded, "updated", updated)
+	}
+	return nil
+}
+
+// reseed brings the stored item up to date with want, the config version of an item that is already stored,
+// and reports whether it stored a new version.
+func (s *Service) reseed(tx *gorm.DB, want *models.PaymentItem) (bool, error) {
+	var stored models.PaymentItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", want.ID).Take(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.log.Warnw("payment item in config is not seeded: its provider item belongs to another item",
+				"payment_item_id", want.ID, "provider_id", want.ProviderID, "provider_item_id", want.ProviderItemID)
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to load payment item %s: %w", want.ID, err)
+	}
+	if !seedDiffers(stored.ToType(), want.ToType()) {
+		return false, nil
+	}
+	if stored.ProviderID != want.ProviderID || stored.ProviderItemID != want.ProviderItemID {
+		s.log.Warnw("payment item in config differs from the catalog: the provider item of a stored item cannot change",
+			"payment_item_id", want.ID, "provider_item_id", stored.ProviderItemID, "config_provider_item_id", want.ProviderItemID)
+		return false, nil
+	}
+	var current models.PaymentItemVersion
+	if err := tx.Where("payment_item_id = ? AND version = ?", stored.ID, stored.Version).Take(&current).Error; err != nil {
+		return false, fmt.Errorf("failed to load payment item %s version %d: %w", stored.ID, stored.Version, err)
+	}
+	if current.Operator != seedOperator {
+		s.log.Warnw("payment item in config differs from the catalog, keeping the version changed through the admin API",
+			"payment_item_id", stored.ID, "version", stored.Version, "operator", current.Operator)
+		return false, nil
+	}
+
+	stored.Type = want.Type
+	stored.DurationHour = want.DurationHour
+	stored.Credits = want.Credits
+	stored.Entitlements = want.Entitlements
+	stored.SubscriptionGroup = want.SubscriptionGroup
+	stored.Level = want.Level
+	stored.Status = want.Status
+	stored.Version++
+	if err := tx.Save(&stored).Error; err != nil {
+		return false, fmt.Errorf("failed to update payment item %s: %w", stored.ID, err)
+	}
+	if err := createVersion(tx, &stored, seedOperator); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// seedDiffers reports whether a stored item and its config version differ in anything but the version.
+func seedDiffers(stored, want *types.PaymentItem) bool {
+	a, b := *stored, *want
+	a.Version, b.Version = 0, 0
+	if len(a.Entitlements) == 0 {
+		a.Entitlements = nil
+	}
+	if len(b.Entitlements) == 0 {
+		b.Entitlements = nil
+	}
+	return !reflect.DeepEqual(a, b)
+}
+
+type ListPaymentItemsRequest struct {
+	ProviderID types.PaymentProvider   `json:"provider_id"`
+	Status     types.PaymentItemStatus `json:"status"`
+}
+
+// List returns the stored items ordered by ID.
+func (s *Service) List(ctx context.Context, req *ListPaymentItemsRequest) ([]*models.PaymentItem, error) {
+	q := s.db.WithContext(ctx).Model(&models.PaymentItem{})
+	if req.ProviderID != "" {
+		q = q.Where("provider_id = ?", req.ProviderID)
+	}
+	if req.Status != "" {
+		q = q.Where("status = ?", req.Status)
+	}
+	items := []*models.PaymentItem{}
+	if err := q.Order("id").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list payment items: %w", err)
+	}
+	return items, nil
+}
+
+// Create stores a new active item as version 1.
+func (s *Service) Create(ctx context.Context, item *types.PaymentItem, operator string) (*models.PaymentItem, error) {
+	if err := validatePaymentItem(item); err != nil {
+		return nil, err
+	}
+	m := newPaymentItemModel(item)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+		if res.Error != nil {
+			return fmt.Errorf("failed to create payment item: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("payment item %s or provider item %s already exists", item.ID, item.ProviderItemID)
+		}
+		return createVersion(tx, m, operator)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.reloadAfterChange(ctx)
+	return m, nil
+}
+
+type UpdatePaymentItemRequest struct {
+	ID string `json:"id"`
+	// Version is the version the change is based on; the update fails if the item changed since.
+	Version int64 `json:"version"`
+	// Type and Status keep their stored value when empty, Credits, Entitlements, SubscriptionGroup and Level
+	// when null; DurationHour is always replaced.
+	Type              types.PaymentItemType     `json:"type"`
+	DurationHour      *int64                    `json:"duration_hour"`
+	Credits           *int64                    `json:"credits"`
+	Entitlements      []*types.EntitlementGrant `json:"entitlements"`
+	SubscriptionGroup *string                   `json:"subscription_group"`
+	Level             *int                      `json:"level"`
+	Status            types.PaymentItemStatus   `json:"status"`
+}
+
+// Update changes the type, duration, credits, entitlements, subscription group, level and status of an item and
+// stores the result as a new version. The ID and provider item ID are immutable because transactions refer to them.
+func (s *Service) Update(ctx context.Context, req *UpdatePaymentItemRequest, operator string) (*models.PaymentItem, error) {
+	var m models.PaymentItem
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.ID).Take(&m).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentItemNotFound
+			}
+			return fmt.Errorf("failed to load payment item: %w", err)
+		}
+		if m.Version != req.Version {
+			return fmt.Errorf("%w: %s is at version %d", ErrVersionConflict, m.ID, m.Version)
+		}
+
+		m.Type = lo.CoalesceOrEmpty(req.Type, m.Type)
+		m.DurationHour = req.DurationHour
+		m.Status = lo.CoalesceOrEmpty(req.Status, m.Status)
+		if req.Credits != nil {
+			m.Credits = *req.Credits
+		}
+		if req.Entitlements != nil {
+			m.Entitlements = datatypes.NewJSONType(req.Entitlements)
+		}
+		if req.SubscriptionGroup != nil {
+			m.SubscriptionGroup = *req.SubscriptionGroup
+		}
+		if req.Level != nil {
+			m.Level = *req.Level
+		}
+		if err := validatePaymentItem(m.ToType()); err != nil {
+			return err
+		}
+		m.Version++
+		if err := tx.Save(&m).Error; err != nil {
+			return fmt.Errorf("failed to update payment item: %w", err)
+		}
+		return createVersion(tx, &m, operator)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.reloadAfterChange(ctx)
+	return &m, nil
+}
+
+// ListVersions returns every version of an item, newest first.
+func (s *Service) ListVersions(ctx context.Context, paymentItemID string) ([]*models.PaymentItemVersion, error) {
+	versions := []*models.PaymentItemVersion{}
+	if err := s.db.WithContext(ctx).Where("payment_item_id = ?", paymentItemID).Order("version desc").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list payment item versions: %w", err)
+	}
+	return versions, nil
+}
+
+// newPaymentItemModel returns version 1 of an active item. Internal items without a provider item ID use
+// their ID so they stay unique.
+func newPaymentItemModel(item *types.PaymentItem) *models.PaymentItem {
+	return &models.PaymentItem{
+		ID:                item.ID,
+		ProviderID:        item.ProviderID,
+		ProviderItemID:    lo.CoalesceOrEmpty(item.ProviderItemID, item.ID),
+		Type:              item.Type,
+		DurationHour:      item.DurationHour,
+		Credits:           item.Credits,
+		Entitlements:      datatypes.NewJSONType(item.Entitlements),
+		SubscriptionGroup: item.SubscriptionGroup,
+		Level:             item.Level,
+		Status:            types.PaymentItemStatusActive,
+		Version:           1,
+	}
+}
+
+func createVersion(tx *gorm.DB, m *models.PaymentItem, operator string) error {
+	v := &models.PaymentItemVersion{
+		ID:            tool.GenerateUUIDV7(),
+		PaymentItemID: m.ID,
+		Version:       m.Version,
+		Item:          datatypes.NewJSONType(m.ToType()),
+		Operator:      operator,
+	}
+	if err := tx.Create(v).Error; err != nil {
+		return fmt.Errorf("failed to record payment item version: %w", err)
+	}
+	return nil
+}
+
+// reloadAfterChange refreshes this replica's copy right away; a failure is repaired by the next refresh.
+func (s *Service) reloadAfterChange(ctx context.Context) {
+	if err := s.Reload(context.WithoutCancel(ctx)); err != nil {
+		s.log.Errorw("failed to reload payment item catalog", "error", err.Error())
+	}
+}
+
+func validatePaymentItem(item *types.PaymentItem) error {
+	if item == nil || item.ID == "" {
+		return errors.New("id is required")
+	}
+	switch item.ProviderID {
+	case types.PaymentProviderApple, types.PaymentProviderGoogle, types.PaymentProviderStripe, types.PaymentProviderInner:
+	default:
+		return fmt.Errorf("unknown provider_id %q", item.ProviderID)
+	}
+	if item.ProviderItemID == "" && item.ProviderID != types.PaymentProviderInner {
+		return errors.New("provider_item_id is required")
+	}
+	switch item.Type {
+	case types.PaymentItemTypeAutoRenewableSubscription, types.PaymentItemTypeNonRenewableSubscription:
+	case types.PaymentItemTypeConsumable, types.PaymentItemTypeNonConsumable:
+		if item.DurationHour != nil {
+			return fmt.Errorf("duration_hour is not allowed for %s items", item.Type)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", item.Type)
+	}
+	if item.IsConsumable() != (item.Credits != 0) {
+		return errors.New("credits must be set for consumable items only")
+	}
+	if item.Credits < 0 {
+		return errors.New("credits must be positive")
+	}
+	if item.DurationHour != nil && *item.DurationHour <= 0 {
+		return errors.New("duration_hour must be positive")
+	}
+	if item.Type == types.PaymentItemTypeNonRenewableSubscription && item.DurationHour == nil {
+		return errors.New("duration_hour is required for non-renewable subscriptions")
+	}
+	granted := map[string]bool{}
+	for _, g := range item.Entitlements {
+		if g == nil || g.Entitlement == "" {
+			return errors.New("entitlement name is required")
+		}
+		if g.Tier < 0 {
+			return fmt.Errorf("tier of entitlement %s must not be negative", g.Entitlement)
+		}
+		if granted[g.Entitlement] {
+			return fmt.Errorf("entitlement %s is granted twice", g.Entitlement)
+		}
+		granted[g.Entitlement] = true
+	}
+	if item.Level < 0 {
+		return errors.New("level must not be negative")
+	}
+	if item.Level > 0 && item.SubscriptionGroup == "" {
+		return errors.New("level requires a subscription_group")
+	}
+	switch item.Status {
+	case "", types.PaymentItemStatusActive, types.PaymentItemStatusArchived:
+	default:
+		return fmt.Errorf("unknown status %q", item.Status)
+	}
+	return nil
+}
+
+// Start reloads the catalog every Catalog.RefreshInterval until Stop.
+func (s *Service) Start() {
+	interval := s.cfg.Catalog.RefreshInterval
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.Reload(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				s.log.Errorw("failed to refresh payment item catalog", "error", err.Error())
+			}
+		}
+	}()
+}
+
+// Stop cancels the refresh loop and waits for it to exit or ctx to expire.
+func (s *Service) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,178 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/db"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestService_Lookups(t *testing.T) {
+	cfg := &config.Config{}
+	s := New(cfg, nil, zap.NewNop().Sugar())
+	s.index.Store(newIndex([]*models.PaymentItem{
+		{ID: "vip_month", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.app.vip.month", Type: types.PaymentItemTypeAutoRenewableSubscription, Status: types.PaymentItemStatusActive, Version: 3},
+		{ID: "vip_year", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.app.vip.year", Type: types.PaymentItemTypeAutoRenewableSubscription, Status: types.PaymentItemStatusArchived, Version: 1},
+		{ID: "coins_100", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.app.coins.100", Type: types.PaymentItemTypeConsumable, Credits: 100, Status: types.PaymentItemStatusActive, Version: 1},
+	}))
+	cfg.UsePaymentItemSource(s)
+
+	item := cfg.GetPaymentItemByID("vip_month")
+	require.NotNil(t, item)
+	require.Equal(t, int64(3), item.Version)
+	item.ProviderItemID = "changed"
+	require.Equal(t, "com.app.vip.month", cfg.GetPaymentItemByID("vip_month").ProviderItemID, "lookups return copies")
+
+	archived, err := cfg.GetPaymentItemByProviderItemID(context.Background(), types.PaymentProviderApple, "com.app.vip.year")
+	require.NoError(t, err)
+	require.True(t, archived.IsArchived())
+
+	require.Equal(t, int64(100), cfg.GetPaymentItemByID("coins_100").Credits)
+
+	require.Nil(t, cfg.GetPaymentItemByID("missing"))
+	_, err = cfg.GetPaymentItemByProviderItemID(context.Background(), types.PaymentProviderGoogle, "com.app.vip.month")
+	require.Error(t, err)
+}
+
+func TestValidatePaymentItem(t *testing.T) {
+	valid := types.PaymentItem{ID: "day_pass", ProviderID: types.PaymentProviderStripe, ProviderItemID: "price_1", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(24))}
+	require.NoError(t, validatePaymentItem(&valid))
+	gift := types.PaymentItem{ID: "gift", ProviderID: types.PaymentProviderInner, Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(24))}
+	require.NoError(t, validatePaymentItem(&gift))
+	coins := types.PaymentItem{ID: "coins_100", ProviderID: types.PaymentProviderApple, ProviderItemID: "coins.100", Type: types.PaymentItemTypeConsumable, Credits: 100}
+	require.NoError(t, validatePaymentItem(&coins))
+	lifetime := types.PaymentItem{ID: "lifetime", ProviderID: types.PaymentProviderApple, ProviderItemID: "lifetime", Type: types.PaymentItemTypeNonConsumable}
+	require.NoError(t, validatePaymentItem(&lifetime))
+	coins.DurationHour = lo.ToPtr(int64(24))
+	require.ErrorContains(t, validatePaymentItem(&coins), "duration_hour is not allowed")
+	coins.DurationHour, coins.Credits = nil, 0
+	require.ErrorContains(t, validatePaymentItem(&coins), "consumable items only")
+	coins.Credits = -1
+	require.Error(t, validatePaymentItem(&coins))
+
+	for name, mutate := range map[string]func(*types.PaymentItem){
+		"id is required":               func(it *types.PaymentItem) { it.ID = "" },
+		"unknown provider_id":          func(it *types.PaymentItem) { it.ProviderID = "paypal" },
+		"provider_item_id is required": func(it *types.PaymentItem) { it.ProviderItemID = "" },
+		"unknown type":                 func(it *types.PaymentItem) { it.Type = "coupon" },
+		"must be positive":             func(it *types.PaymentItem) { it.DurationHour = lo.ToPtr(int64(0)) },
+		"duration_hour is required":    func(it *types.PaymentItem) { it.DurationHour = nil },
+		"unknown status":               func(it *types.PaymentItem) { it.Status = "deleted" },
+		"entitlement name is required": func(it *types.PaymentItem) { it.Entitlements = []*types.EntitlementGrant{{Tier: 1}} },
+		"must not be negative": func(it *types.PaymentItem) {
+			it.Entitlements = []*types.EntitlementGrant{{Entitlement: "pro", Tier: -1}}
+		},
+		"level must not be negative":          func(it *types.PaymentItem) { it.SubscriptionGroup, it.Level = "vip", -1 },
+		"consumable items only":               func(it *types.PaymentItem) { it.Credits = 10 },
+		"level requires a subscription_group": func(it *types.PaymentItem) { it.Level = 1 },
+		"granted twice": func(it *types.PaymentItem) {
+			it.Entitlements = []*types.EntitlementGrant{{Entitlement: "pro", Tier: 1}, {Entitlement: "pro", Tier: 2}}
+		},
+	} {
+		item := valid
+		mutate(&item)
+		require.ErrorContains(t, validatePaymentItem(&item), name)
+	}
+}
+
+// testDatabaseDSNEnv points the catalog tests at a disposable Postgres database.
+const testDatabaseDSNEnv = "CASHIER_TEST_DATABASE_DSN"
+
+func newPostgresTestService(t *testing.T, seed ...*types.PaymentItem) *Service {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseDSNEnv)
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	log := zap.NewNop().Sugar()
+	m, err := db.NewMigrator(gdb, log)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	return New(&config.Config{PaymentItems: seed}, gdb, log)
+}
+
+func TestService_SeedCreateUpdate(t *testing.T) {
+	suffix := tool.GenerateUUIDV7()
+	seeded := &types.PaymentItem{ID: "seed_" + suffix, ProviderID: types.PaymentProviderApple, ProviderItemID: "com.app.seed." + suffix, Type: types.PaymentItemTypeAutoRenewableSubscription}
+	s := newPostgresTestService(t, seeded)
+	ctx := context.Background()
+	created := &types.PaymentItem{ID: "new_" + suffix, ProviderID: types.PaymentProviderStripe, ProviderItemID: "price_" + suffix, Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(24))}
+	t.Cleanup(func() {
+		ids := []string{seeded.ID, created.ID}
+		s.db.Where("payment_item_id IN ?", ids).Delete(&models.PaymentItemVersion{})
+		s.db.Where("id IN ?", ids).Delete(&models.PaymentItem{})
+	})
+
+	require.NoError(t, s.Seed(ctx))
+	require.NoError(t, s.Seed(ctx), "seeding twice keeps the stored item")
+	require.NoError(t, s.Reload(ctx))
+	require.Equal(t, int64(1), s.PaymentItemByID(seeded.ID).Version)
+
+	seeded.Level, seeded.SubscriptionGroup = 2, "vip"
+	require.NoError(t, s.Seed(ctx), "config changes update items seeded from the config")
+	require.NoError(t, s.Reload(ctx))
+	require.Equal(t, int64(2), s.PaymentItemByID(seeded.ID).Version)
+	require.Equal(t, 2, s.PaymentItemByID(seeded.ID).Level)
+	_, err := s.Update(ctx, &UpdatePaymentItemRequest{ID: seeded.ID, Version: 2, Level: lo.ToPtr(3)}, "alice")
+	require.NoError(t, err)
+	seeded.Level = 1
+	require.NoError(t, s.Seed(ctx), "config changes leave items changed through the admin API alone")
+	require.NoError(t, s.Reload(ctx))
+	require.Equal(t, int64(3), s.PaymentItemByID(seeded.ID).Version)
+	require.Equal(t, 3, s.PaymentItemByID(seeded.ID).Level)
+
+	_, err = s.Create(ctx, created, "alice")
+	require.NoError(t, err)
+	_, err = s.Create(ctx, created, "alice")
+	require.ErrorContains(t, err, "already exists")
+	require.NotNil(t, s.PaymentItemByProviderItemID(types.PaymentProviderStripe, created.ProviderItemID), "created items are served right away")
+
+	updated, err := s.Update(ctx, &UpdatePaymentItemRequest{ID: created.ID, Version: 1, DurationHour: lo.ToPtr(int64(48)), Status: types.PaymentItemStatusArchived}, "bob")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), updated.Version)
+	require.True(t, s.PaymentItemByID(created.ID).IsArchived())
+	_, err = s.Update(ctx, &UpdatePaymentItemRequest{ID: created.ID, Version: 1, DurationHour: lo.ToPtr(int64(72))}, "carol")
+	require.ErrorIs(t, err, ErrVersionConflict)
+
+	versions, err := s.ListVersions(ctx, created.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, "bob", versions[0].Operator)
+	require.Equal(t, int64(48), *versions[0].Item.Data().DurationHour)
+	require.Equal(t, int64(24), *versions[1].Item.Data().DurationHour)
+}
+
+func TestSeedDiffers(t *testing.T) {
+	stored := &types.PaymentItem{ID: "vip_month", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.app.vip.month", Type: types.PaymentItemTypeAutoRenewableSubscription, DurationHour: lo.ToPtr(int64(720)), Entitlements: []*types.EntitlementGrant{}, Status: types.PaymentItemStatusActive, Version: 4}
+	want := *stored
+	want.DurationHour = lo.ToPtr(int64(720))
+	want.Entitlements = nil
+	want.Version = 1
+	require.False(t, seedDiffers(stored, &want))
+
+	want.Entitlements = []*types.EntitlementGrant{{Entitlement: "pro", Tier: 1}}
+	require.True(t, seedDiffers(stored, &want))
+	want.Entitlements = nil
+	want.DurationHour = lo.ToPtr(int64(744))
+	require.True(t, seedDiffers(stored, &want))
+}
@@ -0,0 +1,35 @@
+package catalog
+
+import (
+	"context"
+
+	"github.com/fatflowers/cashier/pkg/config"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the payment item catalog. It is seeded and loaded before the other services are started,
+// and serves the payment item lookups of config.Config from then on.
+var Module = fx.Options(
+	fx.Provide(New),
+	fx.Invoke(loadCatalog),
+)
+
+func loadCatalog(lc fx.Lifecycle, cfg *config.Config, s *Service) error {
+	ctx := context.Background()
+	if err := s.Seed(ctx); err != nil {
+		return err
+	}
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	cfg.UsePaymentItemSource(s)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			s.Start()
+			return nil
+		},
+		OnStop: s.Stop,
+	})
+	return nil
+}
@@ -0,0 +1,301 @@
+package fxrate
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxRateAge is how far back a conversion looks for a rate when its day has none, e.g. on weekends and
+// bank holidays.
+const MaxRateAge = 7 * 24 * time.Hour
+
+// ErrRateNotFound is returned when no rate within MaxRateAge converts between two currencies.
+var ErrRateNotFound = errors.New("fx rate not found")
+
+// MissingRate is a currency without a rate into the target currency on Date.
+type MissingRate struct {
+	Currency string `json:"currency"`
+	Date     string `json:"date"`
+}
+
+// MissingRatesError lists every rate a conversion into To lacked. It matches ErrRateNotFound.
+type MissingRatesError struct {
+	To      string
+	Missing []MissingRate
+}
+
+func (e *MissingRatesError) Error() string {
+	pairs := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		pairs = append(pairs, m.Currency+" on "+m.Date)
+	}
+	return fmt.Sprintf("%s to %s: %s", ErrRateNotFound, e.To, strings.Join(pairs, ", "))
+}
+
+func (e *MissingRatesError) Is(target error) bool { return target == ErrRateNotFound }
+
+// Service stores daily exchange rates and converts money with them.
+type Service struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+type ImportRatesRequest struct {
+	// Source names where the rates come from, e.g. "ecb".
+	Source string           `json:"source"`
+	Rates  []*models.FxRate `json:"rates"`
+}
+
+type ImportRatesResponse struct {
+	Imported int `json:"imported"`
+}
+
+// Import stores the rates of req, replacing the rates already stored for the same day and currency pair.
+// Of rates repeated within req, the last one is kept.
+func (s *Service) Import(ctx context.Context, req *ImportRatesRequest) (*ImportRatesResponse, error) {
+	if len(req.Rates) == 0 {
+		return nil, errors.New("rates are required")
+	}
+	now := time.Now()
+	for i, r := range req.Rates {
+		if err := normalizeRate(r); err != nil {
+			return nil, fmt.Errorf("rate %d: %w", i, err)
+		}
+		r.Source = req.Source
+		r.UpdatedAt = now
+	}
+	// Postgres rejects an upsert that touches the same row twice.
+	rates := dedupeRates(req.Rates)
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "rate_date"}, {Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(rates, 500).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to store fx rates: %w", err)
+	}
+	return &ImportRatesResponse{Imported: len(rates)}, nil
+}
+
+// dedupeRates keeps the last of the normalized rates of each day and currency pair, in their first order.
+func dedupeRates(rates []*models.FxRate) []*models.FxRate {
+	type key struct{ date, base, quote string }
+	index := map[key]int{}
+	res := make([]*models.FxRate, 0, len(rates))
+	for _, r := range rates {
+		k := key{r.RateDate, r.BaseCurrency, r.QuoteCurrency}
+		if i, ok := index[k]; ok {
+			res[i] = r
+			continue
+		}
+		index[k] = len(res)
+		res = append(res, r)
+	}
+	return res
+}
+
+func normalizeRate(r *models.FxRate) error {
+	if _, err := time.Parse(time.DateOnly, r.RateDate); err != nil {
+		return fmt.Errorf("rate_date must be YYYY-MM-DD: %q", r.RateDate)
+	}
+	r.BaseCurrency = strings.ToUpper(strings.TrimSpace(r.BaseCurrency))
+	r.QuoteCurrency = strings.ToUpper(strings.TrimSpace(r.QuoteCurrency))
+	if len(r.BaseCurrency) != 3 || len(r.QuoteCurrency) != 3 {
+		return fmt.Errorf("currencies must be ISO 4217 codes: %q, %q", r.BaseCurrency, r.QuoteCurrency)
+	}
+	if r.BaseCurrency == r.QuoteCurrency {
+		return fmt.Errorf("base and quote currency are both %s", r.BaseCurrency)
+	}
+	if !(r.Rate > 0) || math.IsInf(r.Rate, 0) {
+		return fmt.Errorf("rate must be positive: %v", r.Rate)
+	}
+	return nil
+}
+
+// ParseCSV reads rates from CSV with a header row naming the rate_date, base_currency, quote_currency and
+// rate columns, in any order. Other columns are ignored.
+func ParseCSV(r io.Reader) ([]*models.FxRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"rate_date", "base_currency", "quote_currency", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header is missing %s", name)
+		}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	var rates []*models.FxRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: invalid rate: %w", len(rates)+2, err)
+		}
+		rates = append(rates, &models.FxRate{
+			RateDate:      strings.TrimSpace(record[columns["rate_date"]]),
+			BaseCurrency:  record[columns["base_currency"]],
+			QuoteCurrency: record[columns["quote_currency"]],
+			Rate:          rate,
+		})
+	}
+}
+
+type ListRatesRequest struct {
+	RateDate string `json:"rate_date"`
+	Currency string `json:"currency"`
+	From     int    `json:"from"`
+	Size     int    `json:"size"`
+}
+
+type ListRatesResponse struct {
+	Items []*models.FxRate `json:"items"`
+	Total int64            `json:"total"`
+}
+
+// List lists stored rates, optionally of one day or involving one currency, newest day first.
+func (s *Service) List(ctx context.Context, req *ListRatesRequest) (*ListRatesResponse, error) {
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	q := s.db.WithContext(ctx).Model(&models.FxRate{})
+	if req.RateDate != "" {
+		q = q.Where("rate_date = ?", req.RateDate)
+	}
+	if req.Currency != "" {
+		c := strings.ToUpper(req.Currency)
+		q = q.Where("base_currency = ? OR quote_currency = ?", c, c)
+	}
+	res := &ListRatesResponse{}
+	if err := q.Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count fx rates: %w", err)
+	}
+	if err := q.Order("rate_date desc, base_currency, quote_currency").Offset(req.From).Limit(req.Size).Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list fx rates: %w", err)
+	}
+	return res, nil
+}
+
+// LoadConverter loads the rates needed to convert money dated between from and until, both formatted as
+// time.DateOnly, into currency to.
+func (s *Service) LoadConverter(ctx context.Context, to, from, until string) (*Converter, error) {
+	start, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", from, err)
+	}
+	var rates []*models.FxRate
+	if err := s.db.WithContext(ctx).
+		Where("rate_date >= ? AND rate_date <= ?", start.Add(-MaxRateAge).Format(time.DateOnly), until).
+		Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load fx rates: %w", err)
+	}
+	return NewConverter(to, rates), nil
+}
+
+type currencyPair struct{ base, quote string }
+
+// Converter converts money into one currency with the rate of the day the money is dated.
+type Converter struct {
+	to   string
+	days map[string]map[currencyPair]float64
+}
+
+// NewConverter returns a converter into currency to using rates.
+func NewConverter(to string, rates []*models.FxRate) *Converter {
+	c := &Converter{to: strings.ToUpper(to), days: map[string]map[currencyPair]float64{}}
+	for _, r := range rates {
+		day := c.days[r.RateDate]
+		if day == nil {
+			day = map[currencyPair]float64{}
+			c.days[r.RateDate] = day
+		}
+		day[currencyPair{r.BaseCurrency, r.QuoteCurrency}] = r.Rate
+	}
+	return c
+}
+
+// Currency returns the currency the converter converts into.
+func (c *Converter) Currency() string { return c.to }
+
+// Convert converts m with the rate of date, formatted as time.DateOnly, or the latest rate before it within
+// MaxRateAge. The result is rounded half away from zero to the minor unit of the target currency.
+func (c *Converter) Convert(m types.Money, date string) (types.Money, error) {
+	exponent := types.CurrencyExponent(c.to)
+	if strings.EqualFold(m.Currency, c.to) {
+		return m, nil
+	}
+	if m.Amount == 0 {
+		return types.Money{Currency: c.to, Exponent: exponent}, nil
+	}
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return types.Money{}, fmt.Errorf("invalid date %q: %w", date, err)
+	}
+	from := strings.ToUpper(m.Currency)
+	for age := time.Duration(0); age <= MaxRateAge; age += 24 * time.Hour {
+		rate, ok := c.rate(day.Add(-age).Format(time.DateOnly), from)
+		if !ok {
+			continue
+		}
+		amount := float64(m.Amount) * rate * math.Pow10(exponent-m.Exponent)
+		return types.Money{Amount: int64(math.Round(amount)), Currency: c.to, Exponent: exponent}, nil
+	}
+	return types.Money{}, fmt.Errorf("%w: %s to %s on %s", ErrRateNotFound, from, c.to, date)
+}
+
+// rate returns the rate from currency from into the target currency on day: quoted directly, inversely, or
+// across a base currency both are quoted against, as in single-base feeds.
+func (c *Converter) rate(day, from string) (float64, bool) {
+	rates := c.days[day]
+	if len(rates) == 0 {
+		return 0, false
+	}
+	if r, ok := rates[currencyPair{from, c.to}]; ok {
+		return r, true
+	}
+	if r, ok := rates[currencyPair{c.to, from}]; ok {
+		return 1 / r, true
+	}
+	// Bases are tried in alphabetical order so a day with several feeds always converts alike.
+	var base string
+	for pair := range rates {
+		if pair.quote != c.to || (base != "" && pair.base >= base) {
+			continue
+		}
+		if _, ok := rates[currencyPair{pair.base, from}]; ok {
+			base = pair.base
+		}
+	}
+	if base == "" {
+		return 0, false
+	}
+	return rates[currencyPair{base, c.to}] / rates[currencyPair{base, from}], true
+}
@@ -0,0 +1,85 @@
+package fxrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter_Convert(t *testing.T) {
+	// A single-base feed: one EUR in each quote currency.
+	c := NewConverter("usd", []*models.FxRate{
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 1.25},
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "JPY", Rate: 160},
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "BHD", Rate: 0.5},
+		{RateDate: "2025-01-06", BaseCurrency: "USD", QuoteCurrency: "GBP", Rate: 0.8},
+	})
+	require.Equal(t, "USD", c.Currency())
+
+	got, err := c.Convert(types.Money{Amount: 1000, Currency: "EUR", Exponent: 2}, "2025-01-03")
+	require.NoError(t, err)
+	require.Equal(t, types.Money{Amount: 1250, Currency: "USD", Exponent: 2}, got)
+
+	// Cross rate through EUR, between currencies with different minor units.
+	got, err = c.Convert(types.Money{Amount: 1600, Currency: "JPY", Exponent: 0}, "2025-01-03")
+	require.NoError(t, err)
+	require.Equal(t, int64(1250), got.Amount)
+	got, err = c.Convert(types.Money{Amount: 1000, Currency: "BHD", Exponent: 3}, "2025-01-03")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), got.Amount)
+
+	// The weekend uses Friday's rate; an inverse quote works too.
+	got, err = c.Convert(types.Money{Amount: 1000, Currency: "EUR", Exponent: 2}, "2025-01-05")
+	require.NoError(t, err)
+	require.Equal(t, int64(1250), got.Amount)
+	got, err = c.Convert(types.Money{Amount: 800, Currency: "GBP", Exponent: 2}, "2025-01-06")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), got.Amount)
+
+	same := types.Money{Amount: 999, Currency: "USD", Exponent: 2}
+	got, err = c.Convert(same, "2024-01-01")
+	require.NoError(t, err)
+	require.Equal(t, same, got)
+
+	_, err = c.Convert(types.Money{Amount: 1000, Currency: "EUR", Exponent: 2}, "2025-01-11")
+	require.ErrorIs(t, err, ErrRateNotFound, "rates older than MaxRateAge are not used")
+	_, err = c.Convert(types.Money{Amount: 1000, Currency: "CHF", Exponent: 2}, "2025-01-03")
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestParseCSV(t *testing.T) {
+	rates, err := ParseCSV(strings.NewReader("base_currency,quote_currency,rate_date,rate\nEUR,usd,2025-01-03,1.0321\nEUR,JPY,2025-01-03, 162.5\n"))
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+	require.Equal(t, &models.FxRate{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "usd", Rate: 1.0321}, rates[0])
+	require.NoError(t, normalizeRate(rates[0]))
+	require.Equal(t, "USD", rates[0].QuoteCurrency)
+	require.Equal(t, 162.5, rates[1].Rate)
+
+	_, err = ParseCSV(strings.NewReader("rate_date,base_currency,rate\n"))
+	require.ErrorContains(t, err, "quote_currency")
+	_, err = ParseCSV(strings.NewReader("rate_date,base_currency,quote_currency,rate\n2025-01-03,EUR,USD,abc\n"))
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestNormalizeRate(t *testing.T) {
+	require.Error(t, normalizeRate(&models.FxRate{RateDate: "03/01/2025", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 1}))
+	require.Error(t, normalizeRate(&models.FxRate{RateDate: "2025-01-03", BaseCurrency: "EURO", QuoteCurrency: "USD", Rate: 1}))
+	require.Error(t, normalizeRate(&models.FxRate{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "eur", Rate: 1}))
+	require.Error(t, normalizeRate(&models.FxRate{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 0}))
+}
+
+func TestDedupeRates(t *testing.T) {
+	rates := []*models.FxRate{
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 1.03},
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "JPY", Rate: 162.5},
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 1.04},
+	}
+	got := dedupeRates(rates)
+	require.Len(t, got, 2)
+	require.Equal(t, 1.04, got[0].Rate)
+	require.Equal(t, "JPY", got[1].QuoteCurrency)
+}
@@ -0,0 +1,8 @@
+package fxrate
+
+import "go.uber.org/fx"
+
+// Module provides the exchange rate store.
+var Module = fx.Options(
+	fx.Provide(New),
+)
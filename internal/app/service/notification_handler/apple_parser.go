@@ -3,6 +3,7 @@ package notification_handler
 import (
 	"context"
 	"fmt"
+	"github.com/fatflowers/cashier/internal/app/service/transaction"
 	"github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
 	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
@@ -15,6 +16,11 @@ import (
 	"gorm.io/datatypes"
 )
 
+// AppleConsumptionResponder answers CONSUMPTION_REQUEST notifications via the App Store Server API.
+type AppleConsumptionResponder interface {
+	SendConsumptionInfo(ctx context.Context, transactionID string, appAccountToken string) (*transaction.ConsumptionResult, error)
+}
+
 type AppleNotificationParser struct {
 	cfg              *config.Config
 	NotificationTime time.Time
@@ -30,10 +36,23 @@ func (p *AppleNotificationParser) GetNotificationTime(ctx context.Context) time.
 }
 
 func (p *AppleNotificationParser) GetApp(ctx context.Context) string {
-	if p == nil || p.Notification == nil || p.Notification.TransactionInfo == nil {
+	if p == nil || p.Notification == nil {
+		return ""
+	}
+	if p.Notification.TransactionInfo != nil {
+		return p.Notification.TransactionInfo.BundleId
+	}
+	if p.Notification.Payload != nil {
+		return p.Notification.Payload.Data.BundleId
+	}
+	return ""
+}
+
+func (p *AppleNotificationParser) GetNotificationID(ctx context.Context) string {
+	if p == nil || p.Notification == nil || p.Notification.Payload == nil {
 		return ""
 	}
-	return p.Notification.TransactionInfo.BundleId
+	return p.Notification.Payload.NotificationUUID
 }
 
 func (p *AppleNotificationParser) GetUserID(ctx context.Context) (string, error) {
@@ -55,6 +74,15 @@ func (p *AppleNotificationParser) GetTransactionID(ctx context.Context) string {
 	return p.Notification.TransactionInfo.TransactionId
 }
 
+// IsConsumptionRequest reports whether Apple asks for consumption information about the refund request of
+// the notification's transaction.
+func (p *AppleNotificationParser) IsConsumptionRequest() bool {
+	if p == nil || p.Notification == nil || p.Notification.Payload == nil || p.Notification.TransactionInfo == nil {
+		return false
+	}
+	return p.Notification.Payload.NotificationType == apple_notification.NotificationTypeConsumptionRequest
+}
+
 func (p *AppleNotificationParser) GetPaymentItem(ctx context.Context) (*types.PaymentItem, error) {
 	if p == nil || p.Notification == nil || p.Notification.TransactionInfo == nil {
 		return nil, fmt.Errorf("transaction info is empty")
@@ -64,7 +92,54 @@ func (p *AppleNotificationParser) GetPaymentItem(ctx context.Context) (*types.Pa
 }
 
 func (p *AppleNotificationParser) GetTransaction(ctx context.Context) (*models.Transaction, error) {
-	if p == nil || p.Notification == nil || p.Notification.TransactionInfo == nil {
+	if p == nil || p.Notification == nil || p.Notification.Payload == nil {
+		return nil, fmt.Errorf("notification is empty")
+	}
+	payload := p.Notification.Payload
+
+	switch payload.NotificationType {
+	case apple_notification.NotificationTypeSubscribed,
+		apple_notification.NotificationTypeDidRenew,
+		apple_notification.NotificationTypeOfferRedeemed,
+		apple_notification.NotificationTypeOneTimeCharge,
+		apple_notification.NotificationTypeDidChangeRenewalPref,
+		apple_notification.NotificationTypeDidChangeRenewalStatus,
+		apple_notification.NotificationTypePriceIncrease,
+		apple_notification.NotificationTypeDidFailToRenew,
+		apple_notification.NotificationTypeExpired,
+		apple_notification.NotificationTypeGracePeriodExpired,
+		apple_notification.NotificationTypeRenewalExtended,
+		apple_notification.NotificationTypeRefund,
+		apple_notification.NotificationTypeRefundReversed,
+		apple_notification.NotificationTypeRevoke:
+	case apple_notification.NotificationTypeTest,
+		// RENEWAL_EXTENSION reports a bulk extension request; each extended subscription gets its own RENEWAL_EXTENDED.
+		apple_notification.NotificationTypeRenewalExtension,
+		apple_notification.NotificationTypeRefundDeclined,
+		// CONSUMPTION_REQUEST is answered by the handler; it does not change the transaction.
+		apple_notification.NotificationTypeConsumptionRequest,
+		apple_notification.NotificationTypeExternalPurchaseToken,
+		apple_notification.NotificationTypeMetadataUpdate,
+		apple_notification.NotificationTypeMigration,
+		apple_notification.NotificationTypePriceChange,
+		apple_notification.NotificationTypeRescindConsent:
+		return nil, fmt.Errorf("%w: %s", ErrNoTransactionChange, payload.NotificationType)
+	default:
+		// Apple adds types over time; acknowledging them avoids a retry storm for changes we cannot apply.
+		return nil, fmt.Errorf("%w: unknown notification type %s", ErrNoTransactionChange, payload.NotificationType)
+	}
+
+	res, err := p.getSignedTransaction(ctx)
+	if err != nil || res == nil {
+		return res, err
+	}
+	applyAppleNotificationEffect(payload, p.Notification.TransactionInfo, res)
+	return res, nil
+}
+
+// getSignedTransaction maps the signed transaction and renewal info onto a transaction as Apple reports it now.
+func (p *AppleNotificationParser) getSignedTransaction(ctx context.Context) (*models.Transaction, error) {
+	if p.Notification.TransactionInfo == nil {
 		return nil, fmt.Errorf("transaction info is empty")
 	}
 
@@ -73,7 +148,10 @@ func (p *AppleNotificationParser) GetTransaction(ctx context.Context) (*models.T
 		return nil, err
 	}
 
-	if paymentItem.Type != types.PaymentItemTypeAutoRenewableSubscription && paymentItem.Type != types.PaymentItemTypeNonRenewableSubscription {
+	switch paymentItem.Type {
+	case types.PaymentItemTypeAutoRenewableSubscription, types.PaymentItemTypeNonRenewableSubscription,
+		types.PaymentItemTypeConsumable, types.PaymentItemTypeNonConsumable:
+	default:
 		return nil, nil
 	}
 
@@ -87,16 +165,25 @@ func (p *AppleNotificationParser) GetTransaction(ctx context.Context) (*models.T
 		ProviderID:    p.GetProvider(ctx),
 		PaymentItemID: paymentItem.ID,
 		TransactionID: p.GetTransactionID(ctx),
-		Currency:      p.Notification.TransactionInfo.Currency,
-		Price:         p.Notification.TransactionInfo.Price * 100,
+		Price:         transaction.AppleMoney(p.Notification.TransactionInfo.Price, p.Notification.TransactionInfo.Currency),
 		PurchaseAt:    time.UnixMilli(int64(p.Notification.TransactionInfo.PurchaseDate)),
+		Storefront:    p.Notification.TransactionInfo.StoreFront,
 		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
 			PaymentItemSnapshot: paymentItem,
+			Offer: transaction.AppleOffer(p.Notification.TransactionInfo.OfferType, p.Notification.TransactionInfo.OfferIdentifier,
+				p.Notification.TransactionInfo.OfferDiscountType),
 		}),
 	}
 
-	if p.Notification.TransactionInfo.RevocationDate > 0 {
-		res.RefundAt = lo.ToPtr(time.UnixMilli(int64(p.Notification.TransactionInfo.RevocationDate)))
+	if info := p.Notification.TransactionInfo; info.RevocationDate > 0 {
+		revokedAt := time.UnixMilli(int64(info.RevocationDate))
+		res.RevocationDate = lo.ToPtr(revokedAt)
+		if info.IsUpgraded {
+			res.RevocationReason = lo.ToPtr(models.RevocationReasonUpgraded)
+		} else {
+			res.RefundAt = lo.ToPtr(revokedAt)
+			res.RevocationReason = lo.ToPtr(transaction.AppleRevocationReason(info.RevocationReason))
+		}
 	}
 
 	if paymentItem.Renewable() && p.Notification.TransactionInfo.ExpiresDate > 0 {
@@ -109,11 +196,69 @@ func (p *AppleNotificationParser) GetTransaction(ctx context.Context) (*models.T
 			res.NextAutoRenewAt = lo.ToPtr(time.UnixMilli(int64(p.Notification.RenewalInfo.RenewalDate)))
 		}
 		res.ParentTransactionID = lo.ToPtr(p.Notification.RenewalInfo.OriginalTransactionId)
+		res.Extra.Data().PendingDowngrade = p.getPendingDowngrade(ctx)
+		if paymentItem.Renewable() {
+			if p.Notification.RenewalInfo.GracePeriodExpiresDate > 0 {
+				res.Extra.Data().GracePeriodExpireAt = lo.ToPtr(time.UnixMilli(int64(p.Notification.RenewalInfo.GracePeriodExpiresDate)))
+			}
+			res.Extra.Data().InBillingRetry = p.Notification.RenewalInfo.IsInBillingRetryPeriod
+		}
 	}
 
 	return res, nil
 }
 
+// applyAppleNotificationEffect adjusts the signed transaction for what the notification type says happened.
+// Types not listed carry the new state in the signed info itself: a new or renewed period (SUBSCRIBED,
+// DID_RENEW, OFFER_REDEEMED, ONE_TIME_CHARGE), a changed renewal product or price (DID_CHANGE_RENEWAL_PREF,
+// PRICE_INCREASE), a failed renewal whose grace period and billing retry come from the renewal info
+// (DID_FAIL_TO_RENEW, GRACE_PERIOD_EXPIRED) or a later expiry (RENEWAL_EXTENDED).
+func applyAppleNotificationEffect(payload *apple_notification.NotificationPayload, info *apple_notification.TransactionInfo, txn *models.Transaction) {
+	signedAt := time.UnixMilli(int64(payload.SignedDate))
+
+	switch payload.NotificationType {
+	case apple_notification.NotificationTypeDidChangeRenewalStatus:
+		if payload.Subtype == apple_notification.SubtypeAutoRenewDisabled {
+			txn.NextAutoRenewAt = nil
+			txn.Extra.Data().PendingDowngrade = nil
+		}
+	case apple_notification.NotificationTypeExpired:
+		// The subscription will not renew again unless the user resubscribes, which arrives as SUBSCRIBED.
+		txn.NextAutoRenewAt = nil
+		txn.Extra.Data().PendingDowngrade = nil
+		txn.Extra.Data().GracePeriodExpireAt = nil
+		txn.Extra.Data().InBillingRetry = false
+	case apple_notification.NotificationTypeGracePeriodExpired:
+		// Access ends, but Apple may keep retrying billing; isInBillingRetryPeriod in the renewal info says so.
+		txn.NextAutoRenewAt = nil
+		txn.Extra.Data().PendingDowngrade = nil
+	case apple_notification.NotificationTypeRefund, apple_notification.NotificationTypeRevoke:
+		revokedAt := signedAt
+		if info.RevocationDate > 0 {
+			revokedAt = time.UnixMilli(int64(info.RevocationDate))
+		}
+		txn.RefundAt = lo.ToPtr(revokedAt)
+		txn.RevocationDate = lo.ToPtr(revokedAt)
+		txn.RevocationReason = lo.ToPtr(models.RevocationReasonRevoke)
+		if payload.NotificationType == apple_notification.NotificationTypeRefund {
+			txn.RevocationReason = lo.ToPtr(transaction.AppleRevocationReason(info.RevocationReason))
+		}
+		txn.NextAutoRenewAt = nil
+	case apple_notification.NotificationTypeRefundReversed:
+		// The refund was reversed, so the purchase grants access again.
+		txn.RefundAt = nil
+		txn.RevocationDate = nil
+		txn.RevocationReason = nil
+	}
+}
+
+// getPendingDowngrade reports the item Apple will renew into when it differs from the current one.
+func (p *AppleNotificationParser) getPendingDowngrade(ctx context.Context) *models.PendingDowngrade {
+	renewal := p.Notification.RenewalInfo
+	return transaction.ApplePendingDowngrade(ctx, p.cfg, p.Notification.TransactionInfo.ProductId, renewal.AutoRenewProductId,
+		renewal.AutoRenewStatus == 1, int64(renewal.RenewalDate))
+}
+
 func (p *AppleNotificationParser) GetData(ctx context.Context) any {
 	return p.Notification
 }
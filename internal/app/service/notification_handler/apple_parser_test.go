@@ -3,8 +3,14 @@ package notification_handler
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
 	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification/apple_notificationtest"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,3 +27,206 @@ func TestAppleNotificationParser_GetUserID_EmptyToken(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "app account token is empty")
 }
+
+func TestAppleNotificationParser_GetNotificationID(t *testing.T) {
+	p := &AppleNotificationParser{
+		Notification: &apple_notification.AppStoreServerNotification{
+			Payload: &apple_notification.NotificationPayload{NotificationUUID: "002e14d5-51f5-4503-b5a8-c3a1af68eb20"},
+		},
+	}
+	require.Equal(t, "002e14d5-51f5-4503-b5a8-c3a1af68eb20", p.GetNotificationID(context.Background()))
+	require.Empty(t, (&AppleNotificationParser{}).GetNotificationID(context.Background()))
+}
+
+const testAppleProductID = "com.example.vip.monthly"
+
+func newTestAppleParserConfig() *config.Config {
+	return &config.Config{PaymentItems: []*types.PaymentItem{{
+		ID:             "vip_monthly",
+		ProviderID:     types.PaymentProviderApple,
+		ProviderItemID: testAppleProductID,
+		Type:           types.PaymentItemTypeAutoRenewableSubscription,
+	}}}
+}
+
+// parseSignedAppleNotification signs the fixture with a throwaway chain and parses it like the webhook does.
+func parseSignedAppleNotification(t *testing.T, signer *apple_notificationtest.Signer, payload *apple_notification.NotificationPayload, txn *apple_notification.TransactionInfo, renewal *apple_notification.RenewalInfo) *AppleNotificationParser {
+	t.Helper()
+	signed, err := signer.SignNotification(payload, txn, renewal)
+	require.NoError(t, err)
+	notification, err := apple_notification.NewWithRootCert(signed, signer.RootCertPem)
+	require.NoError(t, err)
+	return &AppleNotificationParser{cfg: newTestAppleParserConfig(), NotificationTime: time.Now(), Notification: notification}
+}
+
+func TestAppleNotificationParser_GetTransaction_ByType(t *testing.T) {
+	signer, err := apple_notificationtest.NewSigner()
+	require.NoError(t, err)
+
+	appAccountToken, err := apple_iap.UserIDToUUID("10001")
+	require.NoError(t, err)
+	purchaseAt := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+	expiresAt := purchaseAt.Add(30 * 24 * time.Hour)
+	extendedAt := expiresAt.Add(7 * 24 * time.Hour)
+	graceEndAt := expiresAt.Add(16 * 24 * time.Hour)
+	revokedAt := purchaseAt.Add(time.Hour)
+	signedAt := time.Now().Truncate(time.Millisecond)
+
+	transactionInfo := func(mutate ...func(*apple_notification.TransactionInfo)) *apple_notification.TransactionInfo {
+		info := &apple_notification.TransactionInfo{
+			AppAccountToken:       appAccountToken,
+			BundleId:              "com.example.app",
+			ProductId:             testAppleProductID,
+			TransactionId:         "2000000000000002",
+			OriginalTransactionId: "2000000000000001",
+			PurchaseDate:          int(purchaseAt.UnixMilli()),
+			ExpiresDate:           int(expiresAt.UnixMilli()),
+			Currency:              "USD",
+			Price:                 9990,
+		}
+		for _, m := range mutate {
+			m(info)
+		}
+		return info
+	}
+	renewalInfo := func(autoRenew int32) *apple_notification.RenewalInfo {
+		return &apple_notification.RenewalInfo{
+			AutoRenewProductId:    testAppleProductID,
+			AutoRenewStatus:       autoRenew,
+			OriginalTransactionId: "2000000000000001",
+			ProductId:             testAppleProductID,
+			RenewalDate:           int(expiresAt.UnixMilli()),
+		}
+	}
+	inBillingRetry := func(info *apple_notification.RenewalInfo, graceEnd *time.Time) *apple_notification.RenewalInfo {
+		info.IsInBillingRetryPeriod = true
+		if graceEnd != nil {
+			info.GracePeriodExpiresDate = int(graceEnd.UnixMilli())
+		}
+		return info
+	}
+
+	tests := []struct {
+		name          string
+		notifType     string
+		subtype       string
+		txn           *apple_notification.TransactionInfo
+		renewal       *apple_notification.RenewalInfo
+		wantNoChange  bool
+		wantExpire    time.Time
+		wantRenew     bool
+		wantRefundAt  *time.Time
+		wantRevokedBy string
+		wantGraceEnd  *time.Time
+		wantRetry     bool
+	}{
+		{name: "renewal", notifType: apple_notification.NotificationTypeDidRenew, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true},
+		{
+			name: "failed renewal in grace period keeps the period", notifType: apple_notification.NotificationTypeDidFailToRenew, subtype: apple_notification.SubtypeGracePeriod,
+			txn: transactionInfo(), renewal: inBillingRetry(renewalInfo(1), &graceEndAt), wantExpire: expiresAt, wantRenew: true, wantGraceEnd: &graceEndAt, wantRetry: true,
+		},
+		{
+			name: "failed renewal without grace period", notifType: apple_notification.NotificationTypeDidFailToRenew,
+			txn: transactionInfo(), renewal: inBillingRetry(renewalInfo(1), nil), wantExpire: expiresAt, wantRenew: true, wantRetry: true,
+		},
+		{name: "expired", notifType: apple_notification.NotificationTypeExpired, subtype: apple_notification.SubtypeVoluntary, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt},
+		{
+			name: "expired after billing retry", notifType: apple_notification.NotificationTypeExpired, subtype: apple_notification.SubtypeBillingRetry,
+			txn: transactionInfo(), renewal: inBillingRetry(renewalInfo(1), &graceEndAt), wantExpire: expiresAt,
+		},
+		{
+			name: "grace period expired", notifType: apple_notification.NotificationTypeGracePeriodExpired,
+			txn: transactionInfo(), renewal: inBillingRetry(renewalInfo(1), &graceEndAt), wantExpire: expiresAt, wantGraceEnd: &graceEndAt, wantRetry: true,
+		},
+		{
+			name: "refund", notifType: apple_notification.NotificationTypeRefund,
+			txn:     transactionInfo(func(i *apple_notification.TransactionInfo) { i.RevocationDate = int(revokedAt.UnixMilli()) }),
+			renewal: renewalInfo(1), wantExpire: expiresAt, wantRefundAt: &revokedAt, wantRevokedBy: "refund",
+		},
+		{
+			name: "refund for an app issue", notifType: apple_notification.NotificationTypeRefund,
+			txn: transactionInfo(func(i *apple_notification.TransactionInfo) {
+				i.RevocationDate = int(revokedAt.UnixMilli())
+				i.RevocationReason = 1
+			}),
+			renewal: renewalInfo(1), wantExpire: expiresAt, wantRefundAt: &revokedAt, wantRevokedBy: "refund_app_issue",
+		},
+		{
+			name: "upgraded transaction is revoked without a refund", notifType: apple_notification.NotificationTypeDidChangeRenewalPref, subtype: apple_notification.SubtypeUpgrade,
+			txn: transactionInfo(func(i *apple_notification.TransactionInfo) {
+				i.RevocationDate = int(revokedAt.UnixMilli())
+				i.IsUpgraded = true
+			}),
+			renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true, wantRevokedBy: "upgraded",
+		},
+		{name: "revoke without revocation date uses signed date", notifType: apple_notification.NotificationTypeRevoke, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRefundAt: &signedAt, wantRevokedBy: "revoke"},
+		{name: "refund reversed restores access", notifType: apple_notification.NotificationTypeRefundReversed, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true},
+		{name: "renewal preference changed", notifType: apple_notification.NotificationTypeDidChangeRenewalPref, subtype: apple_notification.SubtypeDowngrade, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true},
+		{name: "auto renew disabled", notifType: apple_notification.NotificationTypeDidChangeRenewalStatus, subtype: apple_notification.SubtypeAutoRenewDisabled, txn: transactionInfo(), renewal: renewalInfo(0), wantExpire: expiresAt},
+		{name: "auto renew enabled", notifType: apple_notification.NotificationTypeDidChangeRenewalStatus, subtype: apple_notification.SubtypeAutoRenewEnabled, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true},
+		{name: "price increase", notifType: apple_notification.NotificationTypePriceIncrease, subtype: apple_notification.SubtypePending, txn: transactionInfo(), renewal: renewalInfo(1), wantExpire: expiresAt, wantRenew: true},
+		{
+			name: "renewal extended", notifType: apple_notification.NotificationTypeRenewalExtended,
+			txn:     transactionInfo(func(i *apple_notification.TransactionInfo) { i.ExpiresDate = int(extendedAt.UnixMilli()) }),
+			renewal: renewalInfo(1), wantExpire: extendedAt, wantRenew: true,
+		},
+		{name: "test", notifType: apple_notification.NotificationTypeTest, wantNoChange: true},
+		{name: "renewal extension summary", notifType: apple_notification.NotificationTypeRenewalExtension, subtype: apple_notification.SubtypeSummary, wantNoChange: true},
+		{name: "refund declined", notifType: apple_notification.NotificationTypeRefundDeclined, txn: transactionInfo(), renewal: renewalInfo(1), wantNoChange: true},
+		{name: "unknown type", notifType: "SOMETHING_NEW", txn: transactionInfo(), wantNoChange: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &apple_notification.NotificationPayload{
+				NotificationType: tt.notifType,
+				Subtype:          tt.subtype,
+				NotificationUUID: "uuid-" + tt.name,
+				SignedDate:       int(signedAt.UnixMilli()),
+				Data:             apple_notification.NotificationData{BundleId: "com.example.app", Environment: "Sandbox"},
+			}
+			p := parseSignedAppleNotification(t, signer, payload, tt.txn, tt.renewal)
+
+			txn, err := p.GetTransaction(context.Background())
+			if tt.wantNoChange {
+				require.ErrorIs(t, err, ErrNoTransactionChange)
+				require.Nil(t, txn)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, txn)
+			require.Equal(t, "10001", txn.UserID)
+			require.Equal(t, "vip_monthly", txn.PaymentItemID)
+			require.Equal(t, "2000000000000002", txn.TransactionID)
+			require.Equal(t, types.Money{Amount: 999, Currency: "USD", Exponent: 2}, txn.Price)
+			require.True(t, tt.wantExpire.Equal(*txn.AutoRenewExpireAt))
+			require.Equal(t, tt.wantRenew, txn.NextAutoRenewAt != nil)
+			if tt.wantRefundAt == nil {
+				require.Nil(t, txn.RefundAt)
+			} else {
+				require.True(t, tt.wantRefundAt.Equal(*txn.RefundAt))
+			}
+			require.Equal(t, tt.wantRevokedBy, lo.FromPtr(txn.RevocationReason))
+			if tt.wantGraceEnd == nil {
+				require.Nil(t, txn.GetGracePeriodExpireAt())
+			} else {
+				require.True(t, tt.wantGraceEnd.Equal(*txn.GetGracePeriodExpireAt()))
+			}
+			require.Equal(t, tt.wantRetry, txn.IsInBillingRetry())
+		})
+	}
+}
+
+func TestAppleNotificationParser_TestNotification(t *testing.T) {
+	signer, err := apple_notificationtest.NewSigner()
+	require.NoError(t, err)
+	p := parseSignedAppleNotification(t, signer, &apple_notification.NotificationPayload{
+		NotificationType: apple_notification.NotificationTypeTest,
+		NotificationUUID: "test-uuid",
+		Data:             apple_notification.NotificationData{BundleId: "com.example.app"},
+	}, nil, nil)
+
+	require.True(t, p.Notification.IsTestNotification)
+	require.Equal(t, "com.example.app", p.GetApp(context.Background()))
+	require.Empty(t, p.GetTransactionID(context.Background()))
+}
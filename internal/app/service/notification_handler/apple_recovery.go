@@ -0,0 +1,231 @@
+package notification_handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	notificationlog "github.com/fatflowers/cashier/internal/app/service/notification_log"
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
+	"github.com/fatflowers/cashier/internal/app/service/transaction"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+const defaultAppleNotificationRecoveryLookback = 24 * time.Hour
+
+// AppleNotificationHistoryClient reads pages of the App Store Server API notification history.
+type AppleNotificationHistoryClient interface {
+	GetNotificationHistory(ctx context.Context, body api.NotificationHistoryRequest, paginationToken string) (*api.NotificationHistoryResponses, error)
+}
+
+type appleNotificationReplayer interface {
+	ReplayAppleNotification(ctx context.Context, notification *apple_notification.AppStoreServerNotification) error
+}
+
+type handledNotificationLookup interface {
+	HandledNotificationIDs(ctx context.Context, providerID string, notificationIDs []string) (map[string]bool, error)
+}
+
+type transactionUpdateLookup interface {
+	TransactionUpdateTimes(ctx context.Context, transactionIDs []string) (map[string]time.Time, error)
+}
+
+// RecoveredNotificationResult is the outcome of one notification found in Apple's history.
+type RecoveredNotificationResult string
+
+const (
+	// RecoveredNotificationReplayed means the notification was handled as if it had just arrived.
+	RecoveredNotificationReplayed RecoveredNotificationResult = "replayed"
+	// RecoveredNotificationSkipped means the notification was already handled.
+	RecoveredNotificationSkipped RecoveredNotificationResult = "skipped"
+	// RecoveredNotificationStale means the transaction was stored after Apple signed the notification, so
+	// replaying it could roll back newer state. Stale notifications are counted as skipped.
+	RecoveredNotificationStale RecoveredNotificationResult = "stale"
+	// RecoveredNotificationFailed means the notification could not be verified or handled.
+	RecoveredNotificationFailed RecoveredNotificationResult = "failed"
+)
+
+type RecoverAppleNotificationsRequest struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+	// OnlyFailures limits the history to notifications Apple could not deliver.
+	OnlyFailures bool `json:"only_failures"`
+}
+
+type RecoveredNotification struct {
+	NotificationID   string                      `json:"notification_id"`
+	NotificationType string                      `json:"notification_type"`
+	Subtype          string                      `json:"subtype,omitempty"`
+	TransactionID    string                      `json:"transaction_id,omitempty"`
+	Result           RecoveredNotificationResult `json:"result"`
+	Error            string                      `json:"error,omitempty"`
+}
+
+type RecoverAppleNotificationsResult struct {
+	Replayed int                      `json:"replayed"`
+	Skipped  int                      `json:"skipped"`
+	Failed   int                      `json:"failed"`
+	Items    []*RecoveredNotification `json:"items"`
+}
+
+// AppleNotificationRecovery replays notifications from Apple's notification history that were never handled,
+// recovering the ones lost while the webhook was unavailable.
+type AppleNotificationRecovery struct {
+	cfg      *config.Config
+	client   AppleNotificationHistoryClient
+	handler  appleNotificationReplayer
+	notifSvc handledNotificationLookup
+	txns     transactionUpdateLookup
+	log      *zap.SugaredLogger
+	// verify checks the signature of a signed payload; tests trust their own root certificate.
+	verify func(signedPayload string) (*apple_notification.AppStoreServerNotification, error)
+}
+
+func NewAppleNotificationRecovery(cfg *config.Config, apple *transaction.AppleTransactionManager, handler *NotificationHandler, notif *notificationlog.Service, log *zap.SugaredLogger) *AppleNotificationRecovery {
+	return &AppleNotificationRecovery{
+		cfg:      cfg,
+		client:   apple,
+		handler:  handler,
+		notifSvc: notif,
+		txns:     apple,
+		log:      log,
+		verify:   apple_notification.New,
+	}
+}
+
+// Recover handles every notification Apple sent between req.StartAt and req.EndAt that is not recorded as
+// handled. Per-notification failures are reported in the result; an error means the history could not be read.
+func (r *AppleNotificationRecovery) Recover(ctx context.Context, req *RecoverAppleNotificationsRequest) (*RecoverAppleNotificationsResult, error) {
+	if req == nil || req.StartAt.IsZero() || req.EndAt.IsZero() {
+		return nil, errors.New("start_at and end_at are required")
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return nil, errors.New("end_at must be after start_at")
+	}
+
+	res := &RecoverAppleNotificationsResult{Items: []*RecoveredNotification{}}
+	body := api.NotificationHistoryRequest{
+		StartDate:    req.StartAt.UnixMilli(),
+		EndDate:      req.EndAt.UnixMilli(),
+		OnlyFailures: req.OnlyFailures,
+	}
+	var paginationToken string
+	for {
+		page, err := r.client.GetNotificationHistory(ctx, body, paginationToken)
+		if err != nil {
+			return res, fmt.Errorf("failed to get notification history: %w", err)
+		}
+		if err := r.recoverPage(ctx, page.NotificationHistory, res); err != nil {
+			return res, err
+		}
+		if !page.HasMore || page.PaginationToken == "" {
+			return res, nil
+		}
+		paginationToken = page.PaginationToken
+	}
+}
+
+func (r *AppleNotificationRecovery) recoverPage(ctx context.Context, history []api.NotificationHistoryResponseItem, res *RecoverAppleNotificationsResult) error {
+	items := make([]*RecoveredNotification, len(history))
+	notifications := make([]*apple_notification.AppStoreServerNotification, len(history))
+	for i, h := range history {
+		items[i] = &RecoveredNotification{}
+		n, err := r.verify(h.SignedPayload)
+		if err != nil {
+			items[i].Result = RecoveredNotificationFailed
+			items[i].Error = fmt.Sprintf("failed to verify signed payload: %v", err)
+			continue
+		}
+		items[i].NotificationID = n.Payload.NotificationUUID
+		items[i].NotificationType = n.Payload.NotificationType
+		items[i].Subtype = n.Payload.Subtype
+		if n.TransactionInfo != nil {
+			items[i].TransactionID = n.TransactionInfo.TransactionId
+		}
+		notifications[i] = n
+	}
+
+	ids := lo.FilterMap(items, func(it *RecoveredNotification, _ int) (string, bool) {
+		return it.NotificationID, it.NotificationID != ""
+	})
+	handled, err := r.notifSvc.HandledNotificationIDs(ctx, string(types.PaymentProviderApple), ids)
+	if err != nil {
+		return err
+	}
+	txnIDs := lo.FilterMap(items, func(it *RecoveredNotification, _ int) (string, bool) {
+		return it.TransactionID, it.TransactionID != ""
+	})
+	updatedAt, err := r.txns.TransactionUpdateTimes(ctx, lo.Uniq(txnIDs))
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		switch {
+		case item.Result == RecoveredNotificationFailed:
+		case handled[item.NotificationID]:
+			item.Result = RecoveredNotificationSkipped
+		case isStaleNotification(notifications[i], updatedAt[item.TransactionID]):
+			item.Result = RecoveredNotificationStale
+		default:
+			if err := r.handler.ReplayAppleNotification(ctx, notifications[i]); err != nil {
+				item.Result = RecoveredNotificationFailed
+				item.Error = err.Error()
+			} else {
+				item.Result = RecoveredNotificationReplayed
+			}
+		}
+
+		switch item.Result {
+		case RecoveredNotificationReplayed:
+			res.Replayed++
+		case RecoveredNotificationSkipped, RecoveredNotificationStale:
+			res.Skipped++
+		default:
+			res.Failed++
+			r.log.Warnw("failed to recover apple notification", "notification_id", item.NotificationID, "error", item.Error)
+		}
+		res.Items = append(res.Items, item)
+	}
+	return nil
+}
+
+// isStaleNotification reports whether the transaction of n was stored after Apple signed n.
+func isStaleNotification(n *apple_notification.AppStoreServerNotification, storedAt time.Time) bool {
+	if storedAt.IsZero() || n.Payload == nil || n.Payload.SignedDate == 0 {
+		return false
+	}
+	return storedAt.After(time.UnixMilli(int64(n.Payload.SignedDate)))
+}
+
+// AppleNotificationRecoveryJob is the scheduler job that replays the notifications of the preceding
+// NotificationRecovery.Lookback.
+const AppleNotificationRecoveryJob = "apple_notification_recovery"
+
+func registerAppleNotificationRecoveryJob(sched *scheduler.Scheduler, cfg *config.Config, r *AppleNotificationRecovery) error {
+	var spec string
+	if interval := cfg.AppleIAP.NotificationRecovery.Interval; interval > 0 {
+		spec = "@every " + interval.String()
+	}
+	return sched.Register(AppleNotificationRecoveryJob, spec, r.recoverLookback)
+}
+
+func (r *AppleNotificationRecovery) recoverLookback(ctx context.Context, now time.Time) error {
+	lookback := r.cfg.AppleIAP.NotificationRecovery.Lookback
+	if lookback <= 0 {
+		lookback = defaultAppleNotificationRecoveryLookback
+	}
+	res, err := r.Recover(ctx, &RecoverAppleNotificationsRequest{StartAt: now.Add(-lookback), EndAt: now})
+	if err != nil {
+		return err
+	}
+	r.log.Infow("apple notification recovery finished", "replayed", res.Replayed, "skipped", res.Skipped, "failed", res.Failed)
+	return nil
+}
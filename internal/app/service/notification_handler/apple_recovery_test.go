@@ -0,0 +1,190 @@
+package notification_handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification/apple_notificationtest"
+	"github.com/fatflowers/cashier/pkg/config"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeAppleHistory stands in for the App Store Server API notification history endpoint, serving one page per
+// pagination token.
+type fakeAppleHistory struct {
+	pages    []*api.NotificationHistoryResponses
+	requests []api.NotificationHistoryRequest
+}
+
+func (f *fakeAppleHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != api.PathGetNotificationHistory || r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body api.NotificationHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, body)
+	page := 0
+	if token := r.URL.Query().Get("paginationToken"); token != "" {
+		page = int(token[0] - '0')
+	}
+	_ = json.NewEncoder(w).Encode(f.pages[page])
+}
+
+func newTestStoreClient(t *testing.T, host string) *api.StoreClient {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return api.NewStoreClient(&api.StoreConfig{
+		KeyContent: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		KeyID:      "TESTKEY",
+		BundleID:   "com.example.app",
+		Issuer:     "test-issuer",
+		HostDebug:  host,
+	})
+}
+
+type fakeReplayer struct {
+	replayed []string
+	fail     map[string]error
+}
+
+func (f *fakeReplayer) ReplayAppleNotification(ctx context.Context, n *apple_notification.AppStoreServerNotification) error {
+	if err := f.fail[n.Payload.NotificationUUID]; err != nil {
+		return err
+	}
+	f.replayed = append(f.replayed, n.Payload.NotificationUUID)
+	return nil
+}
+
+type fakeHandledLookup map[string]bool
+
+func (f fakeHandledLookup) HandledNotificationIDs(ctx context.Context, providerID string, notificationIDs []string) (map[string]bool, error) {
+	res := map[string]bool{}
+	for _, id := range notificationIDs {
+		if f[id] {
+			res[id] = true
+		}
+	}
+	return res, nil
+}
+
+type fakeTransactionUpdates map[string]time.Time
+
+func (f fakeTransactionUpdates) TransactionUpdateTimes(ctx context.Context, transactionIDs []string) (map[string]time.Time, error) {
+	res := map[string]time.Time{}
+	for _, id := range transactionIDs {
+		if at, ok := f[id]; ok {
+			res[id] = at
+		}
+	}
+	return res, nil
+}
+
+func TestAppleNotificationRecovery_Recover(t *testing.T) {
+	signer, err := apple_notificationtest.NewSigner()
+	require.NoError(t, err)
+	signedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sign := func(uuid, notificationType string) string {
+		payload := &apple_notification.NotificationPayload{NotificationType: notificationType, NotificationUUID: uuid, SignedDate: int(signedAt.UnixMilli())}
+		signed, err := signer.SignNotification(payload, &apple_notification.TransactionInfo{TransactionId: "txn-" + uuid}, nil)
+		require.NoError(t, err)
+		return signed
+	}
+
+	history := &fakeAppleHistory{pages: []*api.NotificationHistoryResponses{
+		{HasMore: true, PaginationToken: "1", NotificationHistory: []api.NotificationHistoryResponseItem{
+			{SignedPayload: sign("n-handled", apple_notification.NotificationTypeDidRenew)},
+			{SignedPayload: sign("n-missed", apple_notification.NotificationTypeDidRenew)},
+			{SignedPayload: sign("n-stale", apple_notification.NotificationTypeDidChangeRenewalStatus)},
+		}},
+		{NotificationHistory: []api.NotificationHistoryResponseItem{
+			{SignedPayload: sign("n-failing", apple_notification.NotificationTypeRefund)},
+			{SignedPayload: "not-a-jws"},
+		}},
+	}}
+	srv := httptest.NewServer(history)
+	defer srv.Close()
+
+	replayer := &fakeReplayer{fail: map[string]error{"n-failing": errors.New("boom")}}
+	r := &AppleNotificationRecovery{
+		cfg:      &config.Config{},
+		client:   newTestStoreClient(t, srv.URL),
+		handler:  replayer,
+		notifSvc: fakeHandledLookup{"n-handled": true},
+		txns: fakeTransactionUpdates{
+			"txn-n-missed": signedAt.Add(-time.Hour),
+			"txn-n-stale":  signedAt.Add(time.Hour),
+		},
+		log: zap.NewNop().Sugar(),
+		verify: func(signedPayload string) (*apple_notification.AppStoreServerNotification, error) {
+			return apple_notification.NewWithRootCert(signedPayload, signer.RootCertPem)
+		},
+	}
+
+	startAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endAt := startAt.Add(24 * time.Hour)
+	res, err := r.Recover(context.Background(), &RecoverAppleNotificationsRequest{StartAt: startAt, EndAt: endAt, OnlyFailures: true})
+	require.NoError(t, err)
+
+	require.Len(t, history.requests, 2)
+	require.Equal(t, startAt.UnixMilli(), history.requests[0].StartDate)
+	require.Equal(t, endAt.UnixMilli(), history.requests[0].EndDate)
+	require.True(t, history.requests[0].OnlyFailures)
+
+	require.Equal(t, []string{"n-missed"}, replayer.replayed)
+	require.Equal(t, 1, res.Replayed)
+	require.Equal(t, 2, res.Skipped)
+	require.Equal(t, 2, res.Failed)
+	require.Len(t, res.Items, 5)
+	require.Equal(t, RecoveredNotificationSkipped, res.Items[0].Result)
+	require.Equal(t, RecoveredNotificationReplayed, res.Items[1].Result)
+	require.Equal(t, "txn-n-missed", res.Items[1].TransactionID)
+	require.Equal(t, apple_notification.NotificationTypeDidRenew, res.Items[1].NotificationType)
+	// The transaction was written after Apple signed the notification, so replaying it would roll it back.
+	require.Equal(t, RecoveredNotificationStale, res.Items[2].Result)
+	require.Equal(t, RecoveredNotificationFailed, res.Items[3].Result)
+	require.Equal(t, "boom", res.Items[3].Error)
+	require.Equal(t, RecoveredNotificationFailed, res.Items[4].Result)
+	require.Contains(t, res.Items[4].Error, "failed to verify signed payload")
+}
+
+func TestAppleNotificationRecovery_Recover_InvalidWindow(t *testing.T) {
+	r := &AppleNotificationRecovery{}
+	now := time.Now()
+	_, err := r.Recover(context.Background(), &RecoverAppleNotificationsRequest{StartAt: now, EndAt: now.Add(-time.Hour)})
+	require.ErrorContains(t, err, "end_at must be after start_at")
+	_, err = r.Recover(context.Background(), &RecoverAppleNotificationsRequest{EndAt: now})
+	require.ErrorContains(t, err, "required")
+}
+
+func TestAppleNotificationRecovery_HistoryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	r := &AppleNotificationRecovery{cfg: &config.Config{}, client: newTestStoreClient(t, srv.URL), log: zap.NewNop().Sugar()}
+	now := time.Now()
+	_, err := r.Recover(context.Background(), &RecoverAppleNotificationsRequest{StartAt: now.Add(-time.Hour), EndAt: now})
+	require.ErrorContains(t, err, "failed to get notification history")
+}
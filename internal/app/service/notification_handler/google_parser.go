@@ -0,0 +1,162 @@
+package notification_handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/google/google_play"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GoogleTransactionResolver maps a Real-time Developer Notification onto a transaction via the Play Developer API.
+type GoogleTransactionResolver interface {
+	GetTransactionByNotification(ctx context.Context, n *google_play.DeveloperNotification) (*models.Transaction, error)
+}
+
+type GoogleNotificationParser struct {
+	cfg              *config.Config
+	resolver         GoogleTransactionResolver
+	NotificationTime time.Time
+	Notification     *google_play.DeveloperNotification
+
+	once sync.Once
+	txn  *models.Transaction
+	err  error
+}
+
+func (p *GoogleNotificationParser) GetProvider(ctx context.Context) types.PaymentProvider {
+	return types.PaymentProviderGoogle
+}
+
+func (p *GoogleNotificationParser) GetNotificationTime(ctx context.Context) time.Time {
+	return p.NotificationTime
+}
+
+func (p *GoogleNotificationParser) GetApp(ctx context.Context) string {
+	if p == nil || p.Notification == nil {
+		return ""
+	}
+	return p.Notification.PackageName
+}
+
+func (p *GoogleNotificationParser) GetNotificationID(ctx context.Context) string {
+	if p == nil || p.Notification == nil {
+		return ""
+	}
+	return p.Notification.MessageID
+}
+
+// resolve calls the Play Developer API at most once per notification.
+func (p *GoogleNotificationParser) resolve(ctx context.Context) (*models.Transaction, error) {
+	p.once.Do(func() {
+		p.txn, p.err = p.resolver.GetTransactionByNotification(ctx, p.Notification)
+	})
+	return p.txn, p.err
+}
+
+func (p *GoogleNotificationParser) GetUserID(ctx context.Context) (string, error) {
+	txn, err := p.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	if txn == nil {
+		return "", fmt.Errorf("notification has no purchase")
+	}
+	return txn.UserID, nil
+}
+
+func (p *GoogleNotificationParser) GetTransactionID(ctx context.Context) string {
+	if txn, err := p.resolve(ctx); err == nil && txn != nil {
+		return txn.TransactionID
+	}
+	if p.Notification != nil && p.Notification.VoidedPurchaseNotification != nil {
+		return p.Notification.VoidedPurchaseNotification.OrderID
+	}
+	return ""
+}
+
+func (p *GoogleNotificationParser) GetPaymentItem(ctx context.Context) (*types.PaymentItem, error) {
+	if p == nil || p.Notification == nil {
+		return nil, fmt.Errorf("notification is empty")
+	}
+	switch {
+	case p.Notification.SubscriptionNotification != nil:
+		return p.cfg.GetPaymentItemByProviderItemID(ctx, p.GetProvider(ctx), p.Notification.SubscriptionNotification.SubscriptionID)
+	case p.Notification.OneTimeProductNotification != nil:
+		return p.cfg.GetPaymentItemByProviderItemID(ctx, p.GetProvider(ctx), p.Notification.OneTimeProductNotification.SKU)
+	}
+	txn, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if txn == nil {
+		return nil, fmt.Errorf("notification has no purchase")
+	}
+	if snap := txn.GetPaymentItemSnapshot(); snap != nil {
+		return snap, nil
+	}
+	if item := p.cfg.GetPaymentItemByID(txn.PaymentItemID); item != nil {
+		return item, nil
+	}
+	return nil, fmt.Errorf("payment item not found")
+}
+
+func (p *GoogleNotificationParser) GetTransaction(ctx context.Context) (*models.Transaction, error) {
+	if p == nil || p.Notification == nil {
+		return nil, fmt.Errorf("notification is empty")
+	}
+	if p.Notification.TestNotification != nil {
+		return nil, fmt.Errorf("%w: test notification", ErrNoTransactionChange)
+	}
+	txn, err := p.resolve(ctx)
+	if err == nil && txn == nil {
+		return nil, fmt.Errorf("%w: notification has no purchase", ErrNoTransactionChange)
+	}
+	return txn, err
+}
+
+func (p *GoogleNotificationParser) GetData(ctx context.Context) any {
+	return p.Notification
+}
+
+func GetGoogleNotificationParser(cfg *config.Config, resolver GoogleTransactionResolver, ginCtx *gin.Context, notificationTime time.Time) (NotificationParser, error) {
+	if notificationTime.IsZero() {
+		notificationTime = time.Now()
+	}
+
+	// Pub/Sub push subscriptions are configured with ?token=<push_token> on the endpoint URL. Without it anyone
+	// could post notifications, so the endpoint refuses them until a token is configured.
+	want := cfg.GooglePlay.PushToken
+	if want == "" {
+		return nil, fmt.Errorf("google play push token is not configured")
+	}
+	if subtle.ConstantTimeCompare([]byte(ginCtx.Query("token")), []byte(want)) != 1 {
+		return nil, fmt.Errorf("%w: invalid pubsub push token", ErrUnauthenticatedNotification)
+	}
+
+	body, err := io.ReadAll(ginCtx.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := google_play.ParsePushRequest(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidNotification, err)
+	}
+	if notification.PackageName != cfg.GooglePlay.PackageName {
+		return nil, fmt.Errorf("%w: unexpected package name: %s", ErrInvalidNotification, notification.PackageName)
+	}
+
+	return &GoogleNotificationParser{
+		cfg:              cfg,
+		resolver:         resolver,
+		NotificationTime: notificationTime,
+		Notification:     notification,
+	}, nil
+}
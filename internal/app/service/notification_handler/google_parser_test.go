@@ -0,0 +1,49 @@
+package notification_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newGooglePushContext(token string, notification string) *gin.Context {
+	body := fmt.Sprintf(`{"message":{"data":%q,"messageId":"m1"},"subscription":"projects/p/subscriptions/s"}`,
+		base64.StdEncoding.EncodeToString([]byte(notification)))
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v2/payment/webhook/google?token="+token, bytes.NewReader([]byte(body)))
+	return c
+}
+
+func TestGetGoogleNotificationParser(t *testing.T) {
+	testNotification := `{"version":"1.0","packageName":"com.example.app","eventTimeMillis":"1767225600000","testNotification":{"version":"1.0"}}`
+	cfg := &config.Config{GooglePlay: config.GooglePlayConfig{PackageName: "com.example.app", PushToken: "secret"}}
+
+	parser, err := GetGoogleNotificationParser(cfg, nil, newGooglePushContext("secret", testNotification), time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, "m1", parser.GetNotificationID(context.Background()))
+	// Test notifications are acknowledged without touching a purchase.
+	_, err = parser.GetTransaction(context.Background())
+	require.ErrorIs(t, err, ErrNoTransactionChange)
+
+	_, err = GetGoogleNotificationParser(cfg, nil, newGooglePushContext("wrong", testNotification), time.Time{})
+	require.ErrorIs(t, err, ErrUnauthenticatedNotification)
+	require.ErrorContains(t, err, "invalid pubsub push token")
+
+	_, err = GetGoogleNotificationParser(cfg, nil, newGooglePushContext("secret", strings.Replace(testNotification, "com.example.app", "com.other.app", 1)), time.Time{})
+	require.ErrorIs(t, err, ErrInvalidNotification)
+
+	// Without a push token anyone could post notifications.
+	cfg.GooglePlay.PushToken = ""
+	_, err = GetGoogleNotificationParser(cfg, nil, newGooglePushContext("", testNotification), time.Time{})
+	require.ErrorContains(t, err, "push token is not configured")
+}
@@ -1,11 +1,15 @@
 package notification_handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	notificationlog "github.com/fatflowers/cashier/internal/app/service/notification_log"
 	subscription "github.com/fatflowers/cashier/internal/app/service/subscription"
+	"github.com/fatflowers/cashier/internal/app/service/transaction"
 	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
 	"github.com/fatflowers/cashier/pkg/config"
 	"github.com/fatflowers/cashier/pkg/types"
 	"time"
@@ -20,14 +24,17 @@ type NotificationHandler struct {
 	cfg      *config.Config
 	notifSvc *notificationlog.Service
 	subSvc   *subscription.Service
+	apple    AppleConsumptionResponder
+	google   GoogleTransactionResolver
+	stripe   StripeTransactionResolver
 	Logger   *zap.SugaredLogger
 }
 
-func NewNotificationHandler(cfg *config.Config, notif *notificationlog.Service, sub *subscription.Service, log *zap.SugaredLogger) *NotificationHandler {
-	return &NotificationHandler{cfg: cfg, notifSvc: notif, subSvc: sub, Logger: log}
+func NewNotificationHandler(cfg *config.Config, notif *notificationlog.Service, sub *subscription.Service, apple *transaction.AppleTransactionManager, google *transaction.GoogleTransactionManager, stripe *transaction.StripeTransactionManager, log *zap.SugaredLogger) *NotificationHandler {
+	return &NotificationHandler{cfg: cfg, notifSvc: notif, subSvc: sub, apple: apple, google: google, stripe: stripe, Logger: log}
 }
 
-func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.PaymentProvider) (resErr error) {
+func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.PaymentProvider) error {
 	// Build provider-specific parser
 	var parser NotificationParser
 	var err error
@@ -37,25 +44,72 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 		if err != nil {
 			return err
 		}
+	case types.PaymentProviderGoogle:
+		parser, err = GetGoogleNotificationParser(h.cfg, h.google, c, time.Now())
+		if err != nil {
+			return err
+		}
+	case types.PaymentProviderStripe:
+		parser, err = GetStripeNotificationParser(h.cfg, h.stripe, c, time.Now())
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported provider: %s", provider)
 	}
 
-	// Prepare initial log fields
-	var userID string
-	if v, e := parser.GetUserID(c.Request.Context()); e == nil {
-		userID = v
-	}
 	var traceID string
 	if v, ok := c.Get("traceID"); ok {
 		if s, ok2 := v.(string); ok2 {
 			traceID = s
 		}
 	}
-	dataBytes, _ := json.Marshal(parser.GetData(c.Request.Context()))
+	return h.handle(c.Request.Context(), provider, parser, traceID)
+}
+
+// ReplayAppleNotification runs a verified notification fetched from the App Store Server API through the same
+// pipeline as a delivered one.
+func (h *NotificationHandler) ReplayAppleNotification(ctx context.Context, notification *apple_notification.AppStoreServerNotification) error {
+	return h.handle(ctx, types.PaymentProviderApple, &AppleNotificationParser{
+		cfg:              h.cfg,
+		NotificationTime: time.Now(),
+		Notification:     notification,
+	}, "")
+}
+
+func (h *NotificationHandler) handle(ctx context.Context, provider types.PaymentProvider, parser NotificationParser, traceID string) (resErr error) {
+	// Redeliveries of a handled notification are acknowledged without re-running the pipeline.
+	notificationID := parser.GetNotificationID(ctx)
+	if notificationID != "" {
+		claim, err := h.notifSvc.Claim(ctx, string(provider), notificationID)
+		if err != nil {
+			return fmt.Errorf("failed to claim notification: %w", err)
+		}
+		switch claim {
+		case notificationlog.DedupClaimHandled:
+			h.Logger.Infow("notification already handled, skipping", "provider", provider, "notification_id", notificationID)
+			return nil
+		case notificationlog.DedupClaimInProgress:
+			return fmt.Errorf("notification %s is being processed", notificationID)
+		}
+		defer func() {
+			// Finish the claim even when the provider gave up on the request, or redeliveries are refused as
+			// in progress until the claim expires.
+			if err := h.notifSvc.FinishClaim(context.WithoutCancel(ctx), string(provider), notificationID, parser.GetTransactionID(ctx), resErr); err != nil {
+				h.Logger.Errorw("failed to finish notification claim", "notification_id", notificationID, "error", err.Error())
+			}
+		}()
+	}
+
+	// Prepare initial log fields
+	var userID string
+	if v, e := parser.GetUserID(ctx); e == nil {
+		userID = v
+	}
+	dataBytes, _ := json.Marshal(parser.GetData(ctx))
 
 	// Save 'received' log
-	h.notifSvc.Save(c.Request.Context(), &models.PaymentNotificationLog{
+	h.notifSvc.Save(ctx, &models.PaymentNotificationLog{
 		ProviderID: string(provider),
 		UserID: func() *string {
 			if userID == "" {
@@ -64,19 +118,23 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 			return lo.ToPtr(userID)
 		}(),
 		TraceID:          traceID,
-		TransactionID:    parser.GetTransactionID(c.Request.Context()),
-		NotificationTime: parser.GetNotificationTime(c.Request.Context()),
+		TransactionID:    parser.GetTransactionID(ctx),
+		NotificationTime: parser.GetNotificationTime(ctx),
 		Data:             datatypes.JSON(dataBytes),
 		Status:           models.PaymentNotificationLogStatusReceived,
 	})
 
 	// Process notification → transaction → subscription
 	var txn *models.Transaction
+	var consumption *transaction.ConsumptionResult
 	defer func() {
 		// Build result payload
 		resMap := map[string]any{
 			"transaction": txn,
 		}
+		if consumption != nil {
+			resMap["consumption"] = consumption
+		}
 		if resErr != nil {
 			resMap["error"] = resErr.Error()
 		}
@@ -85,7 +143,7 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 		if resErr != nil {
 			status = models.PaymentNotificationLogStatusHandleFailed
 		}
-		h.notifSvc.Save(c.Request.Context(), &models.PaymentNotificationLog{
+		h.notifSvc.Save(ctx, &models.PaymentNotificationLog{
 			ProviderID: string(provider),
 			UserID: func() *string {
 				if userID == "" {
@@ -94,7 +152,7 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 				return lo.ToPtr(userID)
 			}(),
 			TraceID:          traceID,
-			TransactionID:    parser.GetTransactionID(c.Request.Context()),
+			TransactionID:    parser.GetTransactionID(ctx),
 			NotificationTime: time.Now(),
 			Data:             datatypes.JSON(dataBytes),
 			Result:           func() *datatypes.JSON { j := datatypes.JSON(resBytes); return &j }(),
@@ -102,7 +160,23 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 		})
 	}()
 
-	txn, resErr = parser.GetTransaction(c.Request.Context())
+	if ap, ok := parser.(*AppleNotificationParser); ok && ap.IsConsumptionRequest() {
+		consumption, resErr = h.apple.SendConsumptionInfo(ctx, ap.GetTransactionID(ctx), ap.Notification.TransactionInfo.AppAccountToken)
+		if resErr != nil {
+			h.Logger.Errorw("failed to send consumption info", "error", resErr.Error())
+			resErr = fmt.Errorf("failed to send consumption info: %w", resErr)
+			return resErr
+		}
+		h.Logger.Infow("consumption request answered", "transaction_id", consumption.TransactionID, "sent", consumption.Sent, "status_code", consumption.StatusCode)
+		return nil
+	}
+
+	txn, resErr = parser.GetTransaction(ctx)
+	if errors.Is(resErr, ErrNoTransactionChange) {
+		h.Logger.Infow("notification acknowledged without transaction change", "provider", provider, "reason", resErr.Error())
+		resErr = nil
+		return nil
+	}
 	if resErr != nil {
 		h.Logger.Errorw("failed to get transaction", "error", resErr.Error())
 		resErr = fmt.Errorf("failed to get transaction: %w", resErr)
@@ -112,7 +186,7 @@ func (h *NotificationHandler) HandleNotification(c *gin.Context, provider types.
 	h.Logger.Infow("got transaction", "transaction", txn)
 
 	if txn != nil {
-		resErr = h.subSvc.UpsertUserSubscriptionByItem(c.Request.Context(), txn)
+		resErr = h.subSvc.UpsertUserSubscriptionByItem(ctx, txn)
 		return resErr
 	}
 
@@ -1,7 +1,11 @@
 package notification_handler
 
-import "go.uber.org/fx"
+import (
+	"go.uber.org/fx"
+)
 
 var Module = fx.Options(
 	fx.Provide(NewNotificationHandler),
+	fx.Provide(NewAppleNotificationRecovery),
+	fx.Invoke(registerAppleNotificationRecoveryJob),
 )
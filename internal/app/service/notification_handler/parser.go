@@ -2,15 +2,28 @@ package notification_handler
 
 import (
 	"context"
+	"errors"
 	"github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/pkg/types"
 	"time"
 )
 
+// ErrNoTransactionChange is returned by GetTransaction for notifications that are acknowledged
+// without changing any transaction, such as test or informational notifications.
+var ErrNoTransactionChange = errors.New("notification does not change a transaction")
+
+// ErrUnauthenticatedNotification is returned for deliveries that fail the provider's token or signature check.
+var ErrUnauthenticatedNotification = errors.New("notification is not authenticated")
+
+// ErrInvalidNotification is returned for deliveries that cannot be decoded or are meant for another app.
+var ErrInvalidNotification = errors.New("invalid notification")
+
 type NotificationParser interface {
 	GetProvider(ctx context.Context) types.PaymentProvider
 	GetNotificationTime(ctx context.Context) time.Time
 	GetApp(ctx context.Context) string
+	// GetNotificationID returns the provider's unique id of the delivery, used for dedup. Empty disables dedup.
+	GetNotificationID(ctx context.Context) string
 	GetUserID(ctx context.Context) (string, error)
 	GetTransactionID(ctx context.Context) string
 	GetPaymentItem(ctx context.Context) (*types.PaymentItem, error)
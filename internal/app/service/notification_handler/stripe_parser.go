@@ -0,0 +1,138 @@
+package notification_handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/stripe/stripe_api"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StripeTransactionResolver maps a verified Stripe event onto a transaction.
+type StripeTransactionResolver interface {
+	GetTransactionByEvent(ctx context.Context, event *stripe_api.Event) (*models.Transaction, error)
+}
+
+type StripeNotificationParser struct {
+	cfg              *config.Config
+	resolver         StripeTransactionResolver
+	NotificationTime time.Time
+	Event            *stripe_api.Event
+
+	once sync.Once
+	txn  *models.Transaction
+	err  error
+}
+
+func (p *StripeNotificationParser) GetProvider(ctx context.Context) types.PaymentProvider {
+	return types.PaymentProviderStripe
+}
+
+func (p *StripeNotificationParser) GetNotificationTime(ctx context.Context) time.Time {
+	return p.NotificationTime
+}
+
+func (p *StripeNotificationParser) GetApp(ctx context.Context) string {
+	return ""
+}
+
+func (p *StripeNotificationParser) GetNotificationID(ctx context.Context) string {
+	if p == nil || p.Event == nil {
+		return ""
+	}
+	return p.Event.ID
+}
+
+// resolve calls the Stripe API at most once per event.
+func (p *StripeNotificationParser) resolve(ctx context.Context) (*models.Transaction, error) {
+	p.once.Do(func() {
+		p.txn, p.err = p.resolver.GetTransactionByEvent(ctx, p.Event)
+	})
+	return p.txn, p.err
+}
+
+func (p *StripeNotificationParser) GetUserID(ctx context.Context) (string, error) {
+	txn, err := p.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	if txn == nil {
+		return "", fmt.Errorf("event has no purchase")
+	}
+	return txn.UserID, nil
+}
+
+func (p *StripeNotificationParser) GetTransactionID(ctx context.Context) string {
+	if txn, err := p.resolve(ctx); err == nil && txn != nil {
+		return txn.TransactionID
+	}
+	return ""
+}
+
+func (p *StripeNotificationParser) GetPaymentItem(ctx context.Context) (*types.PaymentItem, error) {
+	txn, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if txn == nil {
+		return nil, fmt.Errorf("event has no purchase")
+	}
+	if snap := txn.GetPaymentItemSnapshot(); snap != nil {
+		return snap, nil
+	}
+	if item := p.cfg.GetPaymentItemByID(txn.PaymentItemID); item != nil {
+		return item, nil
+	}
+	return nil, fmt.Errorf("payment item not found")
+}
+
+func (p *StripeNotificationParser) GetTransaction(ctx context.Context) (*models.Transaction, error) {
+	if p == nil || p.Event == nil {
+		return nil, fmt.Errorf("event is empty")
+	}
+	txn, err := p.resolve(ctx)
+	if err == nil && txn == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoTransactionChange, p.Event.Type)
+	}
+	return txn, err
+}
+
+func (p *StripeNotificationParser) GetData(ctx context.Context) any {
+	return p.Event
+}
+
+func GetStripeNotificationParser(cfg *config.Config, resolver StripeTransactionResolver, ginCtx *gin.Context, notificationTime time.Time) (NotificationParser, error) {
+	if notificationTime.IsZero() {
+		notificationTime = time.Now()
+	}
+	if cfg.Stripe.WebhookSecret == "" {
+		return nil, fmt.Errorf("stripe webhook secret is not configured")
+	}
+
+	// The signature covers the raw body, so it must be read before any JSON binding.
+	body, err := io.ReadAll(ginCtx.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	event, err := stripe_api.ConstructEvent(body, ginCtx.GetHeader(stripe_api.SignatureHeader), cfg.Stripe.WebhookSecret)
+	switch {
+	case errors.Is(err, stripe_api.ErrInvalidSignatureHeader), errors.Is(err, stripe_api.ErrNoValidSignature), errors.Is(err, stripe_api.ErrSignatureExpired):
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticatedNotification, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidNotification, err)
+	}
+
+	return &StripeNotificationParser{
+		cfg:              cfg,
+		resolver:         resolver,
+		NotificationTime: notificationTime,
+		Event:            event,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package notification_handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/stripe/stripe_api"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newStripeWebhookContext(payload []byte, signature string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v2/payment/webhook/stripe", bytes.NewReader(payload))
+	c.Request.Header.Set(stripe_api.SignatureHeader, signature)
+	return c
+}
+
+func TestGetStripeNotificationParser(t *testing.T) {
+	cfg := &config.Config{Stripe: config.StripeConfig{WebhookSecret: "whsec_test"}}
+	payload := []byte(`{"id":"evt_1","type":"invoice.paid","data":{"object":{}}}`)
+	ts := time.Now().Unix()
+	signature := fmt.Sprintf("t=%d,v1=%s", ts, stripe_api.ComputeSignature(ts, payload, "whsec_test"))
+
+	parser, err := GetStripeNotificationParser(cfg, nil, newStripeWebhookContext(payload, signature), time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, "evt_1", parser.GetNotificationID(context.Background()))
+
+	_, err = GetStripeNotificationParser(cfg, nil, newStripeWebhookContext(payload, fmt.Sprintf("t=%d,v1=bad", ts)), time.Time{})
+	require.ErrorIs(t, err, stripe_api.ErrNoValidSignature)
+	require.ErrorIs(t, err, ErrUnauthenticatedNotification)
+
+	bad := []byte(`not json`)
+	signature = fmt.Sprintf("t=%d,v1=%s", ts, stripe_api.ComputeSignature(ts, bad, "whsec_test"))
+	_, err = GetStripeNotificationParser(cfg, nil, newStripeWebhookContext(bad, signature), time.Time{})
+	require.ErrorIs(t, err, ErrInvalidNotification)
+}
+
+type stripeResolverFunc func(ctx context.Context, event *stripe_api.Event) (*models.Transaction, error)
+
+func (f stripeResolverFunc) GetTransactionByEvent(ctx context.Context, event *stripe_api.Event) (*models.Transaction, error) {
+	return f(ctx, event)
+}
+
+func TestStripeNotificationParser_IgnoredEventsAreAcknowledged(t *testing.T) {
+	p := &StripeNotificationParser{
+		resolver: stripeResolverFunc(func(ctx context.Context, event *stripe_api.Event) (*models.Transaction, error) { return nil, nil }),
+		Event:    &stripe_api.Event{ID: "evt_1", Type: "customer.created"},
+	}
+	_, err := p.GetTransaction(context.Background())
+	require.ErrorIs(t, err, ErrNoTransactionChange)
+}
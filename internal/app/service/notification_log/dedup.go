@@ -0,0 +1,116 @@
+package notification_log
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DedupClaim is the outcome of claiming a notification for processing.
+type DedupClaim string
+
+const (
+	// DedupClaimAcquired means the caller owns the notification and must call FinishClaim.
+	DedupClaimAcquired DedupClaim = "acquired"
+	// DedupClaimHandled means the notification was already handled successfully.
+	DedupClaimHandled DedupClaim = "handled"
+	// DedupClaimInProgress means another delivery of the notification is being processed.
+	DedupClaimInProgress DedupClaim = "in_progress"
+)
+
+// dedupProcessingLease bounds how long a claim may stay in processing before a redelivery may take it over,
+// so a crash mid-processing does not block the notification forever.
+const dedupProcessingLease = 10 * time.Minute
+
+// Claim atomically reserves a provider notification for processing. New notifications and ones that failed
+// before are acquired; handled ones are reported as duplicates.
+func (s *Service) Claim(ctx context.Context, providerID, notificationID string) (DedupClaim, error) {
+	now := time.Now()
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PaymentNotificationDedup{
+		ProviderID:     providerID,
+		NotificationID: notificationID,
+		Status:         models.PaymentNotificationDedupStatusProcessing,
+		Attempts:       1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if res.Error != nil {
+		return "", fmt.Errorf("failed to insert notification dedup: %w", res.Error)
+	}
+	if res.RowsAffected == 1 {
+		return DedupClaimAcquired, nil
+	}
+
+	// Already seen: take it over only if the previous attempt failed or its lease expired.
+	res = s.db.WithContext(ctx).Model(&models.PaymentNotificationDedup{}).
+		Where("provider_id = ? AND notification_id = ?", providerID, notificationID).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.PaymentNotificationDedupStatusHandleFailed,
+			models.PaymentNotificationDedupStatusProcessing, now.Add(-dedupProcessingLease)).
+		Updates(map[string]any{
+			"status":     models.PaymentNotificationDedupStatusProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		})
+	if res.Error != nil {
+		return "", fmt.Errorf("failed to reclaim notification dedup: %w", res.Error)
+	}
+	if res.RowsAffected == 1 {
+		return DedupClaimAcquired, nil
+	}
+
+	var existing models.PaymentNotificationDedup
+	if err := s.db.WithContext(ctx).
+		Where("provider_id = ? AND notification_id = ?", providerID, notificationID).
+		First(&existing).Error; err != nil {
+		return "", fmt.Errorf("failed to get notification dedup: %w", err)
+	}
+	if existing.Status == models.PaymentNotificationDedupStatusHandled {
+		return DedupClaimHandled, nil
+	}
+	return DedupClaimInProgress, nil
+}
+
+// FinishClaim records the result of processing a claimed notification. A failed notification may be claimed again.
+func (s *Service) FinishClaim(ctx context.Context, providerID, notificationID, transactionID string, handleErr error) error {
+	status := models.PaymentNotificationDedupStatusHandled
+	if handleErr != nil {
+		status = models.PaymentNotificationDedupStatusHandleFailed
+	}
+	updates := map[string]any{
+		"status":     status,
+		"updated_at": time.Now(),
+	}
+	if transactionID != "" {
+		updates["transaction_id"] = transactionID
+	}
+	if err := s.db.WithContext(ctx).Model(&models.PaymentNotificationDedup{}).
+		Where("provider_id = ? AND notification_id = ?", providerID, notificationID).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update notification dedup: %w", err)
+	}
+	return nil
+}
+
+// HandledNotificationIDs returns which of notificationIDs were already handled successfully.
+func (s *Service) HandledNotificationIDs(ctx context.Context, providerID string, notificationIDs []string) (map[string]bool, error) {
+	res := map[string]bool{}
+	if len(notificationIDs) == 0 {
+		return res, nil
+	}
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&models.PaymentNotificationDedup{}).
+		Where("provider_id = ? AND notification_id IN ? AND status = ?", providerID, notificationIDs, models.PaymentNotificationDedupStatusHandled).
+		Pluck("notification_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get handled notifications: %w", err)
+	}
+	for _, id := range ids {
+		res[id] = true
+	}
+	return res, nil
+}
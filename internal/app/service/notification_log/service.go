@@ -2,9 +2,11 @@ package notification_log
 
 import (
 	"context"
+	"github.com/fatflowers/cashier/internal/app/service/outbox"
 	"github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/pkg/logctx"
 	"github.com/fatflowers/cashier/pkg/tool"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -17,17 +19,20 @@ type Service struct {
 
 func New(db *gorm.DB, log *zap.SugaredLogger) *Service { return &Service{db: db, log: log} }
 
-// Save asynchronously persists a payment notification log. Nil input is ignored.
+// Save durably queues a payment notification log in the outbox; the outbox dispatcher writes the row.
+// Nil input is ignored. Errors are logged but not returned.
 func (s *Service) Save(ctx context.Context, log *models.PaymentNotificationLog) {
-	go func() {
-		if log == nil {
-			return
-		}
-		if log.ID == "" {
-			log.ID = tool.GenerateUUIDV7()
-		}
-		if err := s.db.Save(log).Error; err != nil {
-			logctx.FromCtx(ctx, s.log).Errorf("failed to save notification log: %v", err)
-		}
-	}()
+	if log == nil {
+		return
+	}
+	if log.ID == "" {
+		log.ID = tool.GenerateUUIDV7()
+	}
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+	// The log outlives the request, so a client disconnect must not cancel the write.
+	if err := outbox.Enqueue(context.WithoutCancel(ctx), s.db, outbox.TopicPaymentNotificationLog, log); err != nil {
+		logctx.FromCtx(ctx, s.log).Errorf("failed to save notification log: %v", err)
+	}
 }
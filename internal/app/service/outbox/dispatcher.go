@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	// maxAttempts bounds retries before an event is parked as failed.
+	maxAttempts    = 10
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = time.Hour
+)
+
+// Handler applies one event. tx is the dispatcher transaction that also marks the event dispatched,
+// so handlers that only write to the database are applied exactly once.
+type Handler func(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error
+
+// Dispatcher drains pending outbox events. Several instances may run concurrently; rows are claimed
+// with FOR UPDATE SKIP LOCKED.
+type Dispatcher struct {
+	db  *gorm.DB
+	log *zap.SugaredLogger
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher(db *gorm.DB, log *zap.SugaredLogger) *Dispatcher {
+	d := &Dispatcher{
+		db:       db,
+		log:      log,
+		handlers: map[string]Handler{},
+	}
+	d.Register(TopicTransactionLog, createRecord[models.TransactionLog])
+	d.Register(TopicSubscriptionLog, createRecord[models.SubscriptionLog])
+	d.Register(TopicPaymentNotificationLog, createRecord[models.PaymentNotificationLog])
+	return d
+}
+
+// Register sets the handler of a topic. It must be called before Start.
+func (d *Dispatcher) Register(topic string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[topic] = h
+}
+
+// Start runs the dispatch loop until Stop.
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(defaultPollInterval)
+		defer ticker.Stop()
+		for {
+			d.drain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the loop to exit, then drains what is left until ctx expires.
+// Events not applied by then stay pending and are picked up on the next start.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	d.drain(ctx)
+	return nil
+}
+
+// drain dispatches batches until nothing is due or ctx is done.
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := d.DispatchBatch(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				d.log.Errorw("outbox dispatch failed", "error", err.Error())
+			}
+			return
+		}
+		if n < defaultBatchSize {
+			return
+		}
+	}
+}
+
+// DispatchBatch applies up to one batch of due events and returns how many were claimed.
+func (d *Dispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	var claimed int
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []*models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ?", models.OutboxEventStatusPending, time.Now()).
+			Order("available_at, id").
+			Limit(defaultBatchSize).
+			Find(&events).Error; err != nil {
+			return fmt.Errorf("failed to load outbox events: %w", err)
+		}
+		claimed = len(events)
+		for _, event := range events {
+			if err := d.dispatchOne(ctx, tx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// dispatchOne applies an event inside a savepoint so a failing handler does not roll back the batch.
+func (d *Dispatcher) dispatchOne(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	d.mu.RLock()
+	h, ok := d.handlers[event.Topic]
+	d.mu.RUnlock()
+
+	var handleErr error
+	if !ok {
+		handleErr = fmt.Errorf("no handler for topic %s", event.Topic)
+	} else {
+		handleErr = tx.Transaction(func(sp *gorm.DB) error {
+			return h(ctx, sp, event)
+		})
+	}
+
+	now := time.Now()
+	updates := map[string]any{"attempts": event.Attempts + 1, "updated_at": now}
+	if handleErr == nil {
+		updates["status"] = models.OutboxEventStatusDispatched
+		updates["dispatched_at"] = now
+		updates["last_error"] = ""
+	} else {
+		d.log.Warnw("outbox event handle failed", "id", event.ID, "topic", event.Topic, "attempts", event.Attempts+1, "error", handleErr.Error())
+		updates["last_error"] = handleErr.Error()
+		if event.Attempts+1 >= maxAttempts {
+			updates["status"] = models.OutboxEventStatusFailed
+		} else {
+			updates["available_at"] = now.Add(retryDelay(event.Attempts + 1))
+		}
+	}
+	if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update outbox event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// retryDelay is an exponential backoff starting at baseRetryDelay and capped at maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+// createRecord decodes the payload into T and inserts it, ignoring rows already written by an earlier attempt.
+func createRecord[T any](ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	var record T
+	if err := json.Unmarshal(event.Payload, &record); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", event.Topic, err)
+	}
+	return tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error
+}
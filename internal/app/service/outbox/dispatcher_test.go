@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, baseRetryDelay, retryDelay(1))
+	require.Equal(t, 2*baseRetryDelay, retryDelay(2))
+	require.Equal(t, 8*baseRetryDelay, retryDelay(4))
+	require.Equal(t, maxRetryDelay, retryDelay(maxAttempts*2))
+}
+
+func TestTransactionLogPayloadRoundTrip(t *testing.T) {
+	after := &models.Transaction{ID: "t1", UserID: "u1", TransactionID: "1000", PurchaseAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	log := &models.TransactionLog{
+		ID:            "l1",
+		UserID:        "u1",
+		ProviderID:    types.PaymentProviderApple,
+		TransactionID: "1000",
+		Reason:        types.UserSubscriptionChangeReasonPurchase,
+		Before:        datatypes.NewJSONType[*models.Transaction](nil),
+		After:         datatypes.NewJSONType(after),
+		Extra:         datatypes.JSONMap{},
+	}
+	b, err := json.Marshal(log)
+	require.NoError(t, err)
+
+	var decoded models.TransactionLog
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, "l1", decoded.ID)
+	require.Equal(t, types.UserSubscriptionChangeReasonPurchase, decoded.Reason)
+	require.Nil(t, decoded.Before.Data())
+	require.Equal(t, "1000", decoded.After.Data().TransactionID)
+	require.True(t, after.PurchaseAt.Equal(decoded.After.Data().PurchaseAt))
+}
+
+func TestRetention(t *testing.T) {
+	require.Equal(t, defaultRetention, retention(&config.OutboxConfig{}))
+	require.Equal(t, 48*time.Hour, retention(&config.OutboxConfig{Retention: 48 * time.Hour}))
+}
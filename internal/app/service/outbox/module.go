@@ -0,0 +1,24 @@
+package outbox
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the outbox dispatcher, runs it for the lifetime of the app and purges what it dispatched.
+var Module = fx.Options(
+	fx.Provide(NewDispatcher),
+	fx.Invoke(registerDispatcher),
+	fx.Invoke(registerPurgeJob),
+)
+
+func registerDispatcher(lc fx.Lifecycle, d *Dispatcher) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			d.Start()
+			return nil
+		},
+		OnStop: d.Stop,
+	})
+}
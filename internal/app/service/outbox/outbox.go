@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/tool"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Topics of the audit log events materialized by the dispatcher.
+const (
+	TopicTransactionLog         = "transaction_log"
+	TopicSubscriptionLog        = "subscription_log"
+	TopicPaymentNotificationLog = "payment_notification_log"
+)
+
+// TopicMembershipChanged carries a types.MembershipChangeEvent for outbound webhooks.
+const TopicMembershipChanged = "membership_changed"
+
+// Enqueue writes an event through db. Pass the GORM transaction of the state change so the event
+// commits or rolls back with it.
+func Enqueue(ctx context.Context, db *gorm.DB, topic string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	now := time.Now()
+	event := &models.OutboxEvent{
+		ID:          tool.GenerateUUIDV7(),
+		Topic:       topic,
+		Payload:     datatypes.JSON(b),
+		Status:      models.OutboxEventStatusPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	return nil
+}
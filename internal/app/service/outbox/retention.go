@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+)
+
+const (
+	// PurgeJob is the scheduler job that deletes dispatched events past their retention.
+	PurgeJob             = "outbox_purge"
+	defaultPurgeSchedule = "15 4 * * *"
+	defaultRetention     = 7 * 24 * time.Hour
+	purgeBatchSize       = 1000
+)
+
+// retention is how long dispatched events are kept.
+func retention(cfg *config.OutboxConfig) time.Duration {
+	if cfg.Retention > 0 {
+		return cfg.Retention
+	}
+	return defaultRetention
+}
+
+// Purge deletes the events dispatched before cutoff, in batches so that no statement holds many row locks,
+// and returns how many were deleted. Failed events are kept until someone looks at them.
+func (d *Dispatcher) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		res := d.db.WithContext(ctx).
+			Where("id IN (?)", d.db.Model(&models.OutboxEvent{}).Select("id").
+				Where("status = ? AND dispatched_at < ?", models.OutboxEventStatusDispatched, cutoff).
+				Limit(purgeBatchSize)).
+			Delete(&models.OutboxEvent{})
+		if res.Error != nil {
+			return total, fmt.Errorf("failed to purge outbox events: %w", res.Error)
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func registerPurgeJob(sched *scheduler.Scheduler, cfg *config.Config, d *Dispatcher) error {
+	return sched.Register(PurgeJob, defaultPurgeSchedule, func(ctx context.Context, scheduledAt time.Time) error {
+		n, err := d.Purge(ctx, scheduledAt.Add(-retention(&cfg.Outbox)))
+		if err != nil {
+			return err
+		}
+		d.log.Infow("purged dispatched outbox events", "deleted", n)
+		return nil
+	})
+}
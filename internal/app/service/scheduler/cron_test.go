@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.DateTime, s)
+		require.NoError(t, err)
+		return v
+	}
+	cases := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"55 23 * * *", "2026-03-01 10:00:00", "2026-03-01 23:55:00"},
+		{"55 23 * * *", "2026-03-01 23:55:00", "2026-03-02 23:55:00"},
+		{"*/15 * * * *", "2026-03-01 10:07:30", "2026-03-01 10:15:00"},
+		{"0 9-17/4 * * *", "2026-03-01 14:00:00", "2026-03-01 17:00:00"},
+		{"5/20 * * * *", "2026-03-01 10:30:00", "2026-03-01 10:45:00"},
+		{"0 0 1,15 * *", "2026-03-02 00:00:00", "2026-03-15 00:00:00"},
+		{"0 0 * 2 *", "2026-03-01 00:00:00", "2027-02-01 00:00:00"},
+		// 2026-03-01 is a Sunday; 7 is Sunday as well.
+		{"0 12 * * 7", "2026-03-01 13:00:00", "2026-03-08 12:00:00"},
+		{"0 12 * * 1-5", "2026-02-28 13:00:00", "2026-03-02 12:00:00"},
+		// Day of month or day of week when both are restricted.
+		{"0 0 10 * 1", "2026-03-01 00:00:00", "2026-03-02 00:00:00"},
+		{"0 0 29 2 *", "2026-01-01 00:00:00", "2028-02-29 00:00:00"},
+		{"@daily", "2026-12-31 23:59:00", "2027-01-01 00:00:00"},
+		{"@hourly", "2026-03-01 10:00:00", "2026-03-01 11:00:00"},
+		{"@every 6h", "2026-03-01 10:00:00", "2026-03-01 12:00:00"},
+		// Intervals that do not divide a day stay on multiples since the Unix epoch.
+		{"@every 7h", "1970-01-01 06:59:59", "1970-01-01 07:00:00"},
+		{"@every 7h", "2026-03-01 10:00:00", "2026-03-01 12:00:00"},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.spec)
+		require.NoError(t, err, c.spec)
+		require.Equal(t, at(c.want), s.Next(at(c.from)), c.spec)
+	}
+}
+
+func TestParseSchedule_NeverMatches(t *testing.T) {
+	s, err := ParseSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	require.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1m",
+		"@every soon",
+	} {
+		_, err := ParseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+}
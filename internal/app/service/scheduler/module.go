@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the job scheduler. Jobs are registered by the modules that own them and the loop starts
+// once every module is constructed.
+var Module = fx.Options(
+	fx.Provide(New),
+	fx.Invoke(registerPurgeRunsJob),
+	fx.Invoke(registerScheduler),
+)
+
+func registerScheduler(lc fx.Lifecycle, s *Scheduler) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			s.Start()
+			return nil
+		},
+		OnStop: s.Stop,
+	})
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+)
+
+const (
+	// PurgeRunsJob is the scheduler job that deletes job runs past their retention.
+	PurgeRunsJob             = "job_run_purge"
+	defaultPurgeRunsSchedule = "45 4 * * *"
+	defaultRunRetention      = 30 * 24 * time.Hour
+	purgeBatchSize           = 1000
+)
+
+// runRetention is how long job runs are kept.
+func runRetention(cfg *config.SchedulerConfig) time.Duration {
+	if cfg.RunRetention > 0 {
+		return cfg.RunRetention
+	}
+	return defaultRunRetention
+}
+
+// PurgeRuns deletes the runs started before cutoff, in batches so that no statement holds many row locks,
+// and returns how many were deleted. Runs left running by a replica that died are deleted as well; their
+// activations are long past and will not be scheduled again.
+func (s *Scheduler) PurgeRuns(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		res := s.db.WithContext(ctx).
+			Where("id IN (?)", s.db.Model(&models.JobRun{}).Select("id").
+				Where("started_at < ?", cutoff).
+				Limit(purgeBatchSize)).
+			Delete(&models.JobRun{})
+		if res.Error != nil {
+			return total, fmt.Errorf("failed to purge job runs: %w", res.Error)
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func registerPurgeRunsJob(s *Scheduler) error {
+	return s.Register(PurgeRunsJob, defaultPurgeRunsSchedule, func(ctx context.Context, scheduledAt time.Time) error {
+		n, err := s.PurgeRuns(ctx, scheduledAt.Add(-runRetention(&s.cfg.Scheduler)))
+		if err != nil {
+			return err
+		}
+		s.log.Infow("purged job runs", "deleted", n)
+		return nil
+	})
+}
@@ -0,0 +1,320 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// leaderLease is the scheduler_lease row whose holder runs the jobs.
+	leaderLease          = "scheduler"
+	defaultLeaseDuration = 30 * time.Second
+	// ScheduleDisabled as a job schedule disables the job.
+	ScheduleDisabled = "-"
+)
+
+// JobFunc runs one activation of a job. scheduledAt is the activation time the run belongs to, in UTC.
+type JobFunc func(ctx context.Context, scheduledAt time.Time) error
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      JobFunc
+	next     time.Time
+	running  atomic.Bool
+}
+
+// Scheduler runs registered jobs on their schedules. Every replica runs a Scheduler, but only the one holding
+// the leader lease starts jobs, and job_run keeps a job from running twice for the same activation when the
+// lease changes hands.
+type Scheduler struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	log    *zap.SugaredLogger
+	holder string
+	now    func() time.Time
+
+	mu   sync.Mutex
+	jobs []*job
+
+	running sync.WaitGroup
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func New(cfg *config.Config, db *gorm.DB, log *zap.SugaredLogger) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		cfg:    cfg,
+		db:     db,
+		log:    log,
+		holder: fmt.Sprintf("%s-%s", host, tool.GenerateUUIDV7()),
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Register adds a job running on defaultSpec unless scheduler.jobs overrides it. An empty or "-" schedule
+// leaves the job disabled. It must be called before Start.
+func (s *Scheduler) Register(name, defaultSpec string, run JobFunc) error {
+	spec := defaultSpec
+	if override, ok := s.cfg.Scheduler.Jobs[name]; ok {
+		spec = override
+	}
+	if spec == "" || spec == ScheduleDisabled {
+		s.log.Infow("scheduled job disabled", "job", name)
+		return nil
+	}
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lo.ContainsBy(s.jobs, func(j *job) bool { return j.name == name }) {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	s.jobs = append(s.jobs, &job{name: name, spec: spec, schedule: schedule, run: run})
+	return nil
+}
+
+// Start runs the scheduling loop until Stop. It does nothing when no job is enabled.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+	if len(jobs) == 0 {
+		return
+	}
+	now := s.now()
+	for _, j := range jobs {
+		j.next = j.schedule.Next(now)
+		s.log.Infow("scheduled job registered", "job", j.name, "schedule", j.spec, "next", j.next)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.loop(ctx, jobs)
+	}()
+}
+
+// leaderTerm lasts while this replica holds the lease; ending it cancels the jobs it started.
+type leaderTerm struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *Scheduler) loop(ctx context.Context, jobs []*job) {
+	leaseDuration := s.leaseDuration()
+	var term *leaderTerm
+	defer func() {
+		if term != nil {
+			term.cancel()
+		}
+		s.running.Wait()
+		if err := s.releaseLease(context.Background()); err != nil {
+			s.log.Warnw("failed to release scheduler lease", "error", err.Error())
+		}
+	}()
+
+	for {
+		leader, err := s.acquireLease(ctx, leaseDuration)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.log.Errorw("failed to acquire scheduler lease", "error", err.Error())
+		}
+		switch {
+		case leader && term == nil:
+			termCtx, cancel := context.WithCancel(ctx)
+			term = &leaderTerm{ctx: termCtx, cancel: cancel}
+			s.log.Infow("acquired scheduler lease", "holder", s.holder)
+		case !leader && term != nil:
+			// Another replica may have taken over; its runs are not ours to finish.
+			term.cancel()
+			term = nil
+			s.log.Warnw("lost scheduler lease", "holder", s.holder)
+		}
+
+		now := s.now()
+		wake := now.Add(leaseDuration / 3)
+		for _, j := range jobs {
+			if j.next.IsZero() {
+				continue
+			}
+			if !j.next.After(now) {
+				if term != nil {
+					s.launch(term.ctx, j, j.next)
+				}
+				// Activations missed while not leading or while the loop was late are skipped.
+				j.next = j.schedule.Next(now)
+			}
+			if !j.next.IsZero() && j.next.Before(wake) {
+				wake = j.next
+			}
+		}
+
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// launch runs one activation of j unless the previous one is still running.
+func (s *Scheduler) launch(ctx context.Context, j *job, scheduledAt time.Time) {
+	if !j.running.CompareAndSwap(false, true) {
+		s.log.Warnw("scheduled job still running, skipping activation", "job", j.name, "scheduled_at", scheduledAt)
+		return
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer j.running.Store(false)
+		s.runJob(ctx, j, scheduledAt)
+	}()
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j *job, scheduledAt time.Time) {
+	run := &models.JobRun{
+		ID:          tool.GenerateUUIDV7(),
+		Job:         j.name,
+		ScheduledAt: scheduledAt,
+		Holder:      s.holder,
+		Status:      models.JobRunStatusRunning,
+		StartedAt:   s.now(),
+	}
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if res.Error != nil {
+		s.log.Errorw("failed to record job run", "job", j.name, "scheduled_at", scheduledAt, "error", res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		s.log.Infow("scheduled job already ran", "job", j.name, "scheduled_at", scheduledAt)
+		return
+	}
+
+	err := s.call(ctx, j, scheduledAt)
+	finishedAt := s.now()
+	updates := map[string]any{"status": models.JobRunStatusSucceeded, "finished_at": finishedAt}
+	if err != nil {
+		updates["status"] = models.JobRunStatusFailed
+		updates["error"] = err.Error()
+		s.log.Errorw("scheduled job failed", "job", j.name, "run_id", run.ID, "error", err.Error())
+	} else {
+		s.log.Infow("scheduled job finished", "job", j.name, "run_id", run.ID, "duration", finishedAt.Sub(run.StartedAt))
+	}
+	// Record the outcome even when the run was cancelled by Stop.
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Model(run).Updates(updates).Error; err != nil {
+		s.log.Errorw("failed to record job run result", "job", j.name, "run_id", run.ID, "error", err.Error())
+	}
+}
+
+// call runs the job, turning a panic into an error so one job cannot stop the scheduler.
+func (s *Scheduler) call(ctx context.Context, j *job, scheduledAt time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.run(ctx, scheduledAt)
+}
+
+// acquireLease takes the leader lease if it is free or expired, or renews it if this replica holds it.
+// Expiry is computed by Postgres so replicas with skewed clocks agree.
+func (s *Scheduler) acquireLease(ctx context.Context, d time.Duration) (bool, error) {
+	res := s.db.WithContext(ctx).Exec(`
+INSERT INTO scheduler_lease (name, holder, expires_at, updated_at)
+VALUES (?, ?, now() + make_interval(secs => ?), now())
+ON CONFLICT (name) DO UPDATE
+SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at, updated_at = EXCLUDED.updated_at
+WHERE scheduler_lease.holder = EXCLUDED.holder OR scheduler_lease.expires_at < now()
+`, leaderLease, s.holder, d.Seconds())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// releaseLease lets another replica take over without waiting for the lease to expire.
+func (s *Scheduler) releaseLease(ctx context.Context) error {
+	return s.db.WithContext(ctx).
+		Where("name = ? AND holder = ?", leaderLease, s.holder).
+		Delete(&models.SchedulerLease{}).Error
+}
+
+func (s *Scheduler) leaseDuration() time.Duration {
+	if d := s.cfg.Scheduler.LeaseDuration; d > 0 {
+		return d
+	}
+	return defaultLeaseDuration
+}
+
+// Stop cancels the loop and running jobs, then waits for them to exit or ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type ListJobRunsRequest struct {
+	Job    string              `json:"job"`
+	Status models.JobRunStatus `json:"status"`
+	From   int                 `json:"from"`
+	Size   int                 `json:"size"`
+}
+
+type ListJobRunsResponse struct {
+	Items []*models.JobRun `json:"items"`
+	Total int64            `json:"total"`
+}
+
+// ListRuns lists job runs, newest first.
+func (s *Scheduler) ListRuns(ctx context.Context, req *ListJobRunsRequest) (*ListJobRunsResponse, error) {
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	q := s.db.WithContext(ctx).Model(&models.JobRun{})
+	if req.Job != "" {
+		q = q.Where("job = ?", req.Job)
+	}
+	if req.Status != "" {
+		q = q.Where("status = ?", req.Status)
+	}
+	res := &ListJobRunsResponse{}
+	if err := q.Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count job runs: %w", err)
+	}
+	if err := q.Order("started_at desc").Offset(req.From).Limit(req.Size).Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list job runs: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/db"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func noopJob(ctx context.Context, scheduledAt time.Time) error { return nil }
+
+func TestScheduler_Register(t *testing.T) {
+	cfg := &config.Config{Scheduler: config.SchedulerConfig{Jobs: map[string]string{
+		"overridden": "0 3 * * *",
+		"disabled":   ScheduleDisabled,
+		"invalid":    "every day",
+	}}}
+	s := New(cfg, nil, zap.NewNop().Sugar())
+
+	require.NoError(t, s.Register("defaulted", "@hourly", noopJob))
+	require.NoError(t, s.Register("overridden", "@hourly", noopJob))
+	require.NoError(t, s.Register("disabled", "@hourly", noopJob))
+	require.NoError(t, s.Register("unscheduled", "", noopJob))
+	require.ErrorContains(t, s.Register("invalid", "@hourly", noopJob), "job invalid")
+	require.ErrorContains(t, s.Register("defaulted", "@daily", noopJob), "already registered")
+
+	require.Len(t, s.jobs, 2)
+	require.Equal(t, "@hourly", s.jobs[0].spec)
+	require.Equal(t, "0 3 * * *", s.jobs[1].spec)
+}
+
+func TestScheduler_StartWithoutJobsIsNoop(t *testing.T) {
+	s := New(&config.Config{}, nil, zap.NewNop().Sugar())
+	s.Start()
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+// testDatabaseDSNEnv points the lease tests at a disposable Postgres database.
+const testDatabaseDSNEnv = "CASHIER_TEST_DATABASE_DSN"
+
+func newPostgresTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseDSNEnv)
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	log := zap.NewNop().Sugar()
+	m, err := db.NewMigrator(gdb, log)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	return New(&config.Config{}, gdb, log)
+}
+
+func TestScheduler_LeaderLease(t *testing.T) {
+	a := newPostgresTestScheduler(t)
+	b := New(a.cfg, a.db, a.log)
+	ctx := context.Background()
+	t.Cleanup(func() { a.db.Where("name = ?", leaderLease).Delete(&models.SchedulerLease{}) })
+	require.NoError(t, a.db.Where("name = ?", leaderLease).Delete(&models.SchedulerLease{}).Error)
+
+	leader, err := a.acquireLease(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, leader)
+	leader, err = b.acquireLease(ctx, time.Minute)
+	require.NoError(t, err)
+	require.False(t, leader, "lease is held by another replica")
+	leader, err = a.acquireLease(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, leader, "holder renews its lease")
+
+	require.NoError(t, a.releaseLease(ctx))
+	leader, err = b.acquireLease(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, leader, "released lease is taken over")
+
+	require.NoError(t, b.db.Model(&models.SchedulerLease{}).Where("name = ?", leaderLease).
+		Update("expires_at", time.Now().Add(-time.Second)).Error)
+	leader, err = a.acquireLease(ctx, time.Minute)
+	require.NoError(t, err)
+	require.True(t, leader, "expired lease is taken over")
+}
+
+func TestScheduler_RunJobOncePerActivation(t *testing.T) {
+	a := newPostgresTestScheduler(t)
+	b := New(a.cfg, a.db, a.log)
+	name := "test-" + tool.GenerateUUIDV7()
+	t.Cleanup(func() { a.db.Where("job = ?", name).Delete(&models.JobRun{}) })
+
+	var calls int
+	j := &job{name: name, run: func(ctx context.Context, scheduledAt time.Time) error {
+		calls++
+		return errors.New("boom")
+	}}
+	scheduledAt := time.Now().UTC().Truncate(time.Minute)
+	a.runJob(context.Background(), j, scheduledAt)
+	b.runJob(context.Background(), j, scheduledAt)
+	require.Equal(t, 1, calls)
+
+	res, err := a.ListRuns(context.Background(), &ListJobRunsRequest{Job: name})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, res.Total)
+	require.Equal(t, models.JobRunStatusFailed, res.Items[0].Status)
+	require.Equal(t, "boom", *res.Items[0].Error)
+	require.Equal(t, a.holder, res.Items[0].Holder)
+	require.NotNil(t, res.Items[0].FinishedAt)
+}
+
+func TestRunRetention(t *testing.T) {
+	require.Equal(t, defaultRunRetention, runRetention(&config.SchedulerConfig{}))
+	require.Equal(t, 72*time.Hour, runRetention(&config.SchedulerConfig{RunRetention: 72 * time.Hour}))
+}
@@ -4,4 +4,5 @@ import "go.uber.org/fx"
 
 var Module = fx.Options(
 	fx.Provide(New),
+	fx.Invoke(registerDailySnapshotJob),
 )
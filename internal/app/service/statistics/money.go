@@ -0,0 +1,146 @@
+package statistics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+)
+
+// moneyRow is the sum of the prices of one currency on one day of a money series, purchased on PurchaseDate.
+type moneyRow struct {
+	Date         string
+	PurchaseDate string
+	Currency     string
+	Exponent     int
+	Amount       int64
+}
+
+// sumMoneyRows returns one series per currency, newest day first.
+func sumMoneyRows(rows []moneyRow) []MembershipStatisticResponseDataItem {
+	type key struct {
+		date, currency string
+		exponent       int
+	}
+	sums := map[key]int64{}
+	for _, r := range rows {
+		sums[key{r.Date, r.Currency, r.Exponent}] += r.Amount
+	}
+	results := make([]MembershipStatisticResponseDataItem, 0, len(sums))
+	for k, v := range sums {
+		results = append(results, MembershipStatisticResponseDataItem{Date: k.date, Label: k.currency, Value: v, Exponent: lo.ToPtr(k.exponent)})
+	}
+	slices.SortFunc(results, func(a, b MembershipStatisticResponseDataItem) int {
+		return cmp.Or(cmp.Compare(b.Date, a.Date), cmp.Compare(a.Label, b.Label))
+	})
+	return results
+}
+
+// convertMoneyRows converts rows into currency with the rate of their purchase date and returns a single
+// series of that currency, newest day first.
+func (s *Service) convertMoneyRows(ctx context.Context, rows []moneyRow, currency string) ([]MembershipStatisticResponseDataItem, error) {
+	if len(rows) == 0 {
+		return []MembershipStatisticResponseDataItem{}, nil
+	}
+	from, until := rows[0].PurchaseDate, rows[0].PurchaseDate
+	for _, r := range rows {
+		from, until = min(from, r.PurchaseDate), max(until, r.PurchaseDate)
+	}
+	converter, err := s.rates.LoadConverter(ctx, currency, from, until)
+	if err != nil {
+		return nil, err
+	}
+	return convertRows(converter, rows)
+}
+
+// convertRows sums rows per date once converted. When rates are missing, a *fxrate.MissingRatesError lists
+// all of them, so that they can be imported at once.
+func convertRows(converter *fxrate.Converter, rows []moneyRow) ([]MembershipStatisticResponseDataItem, error) {
+	sums := map[string]int64{}
+	missing := map[fxrate.MissingRate]bool{}
+	for _, r := range rows {
+		m, err := converter.Convert(types.Money{Amount: r.Amount, Currency: r.Currency, Exponent: r.Exponent}, r.PurchaseDate)
+		if errors.Is(err, fxrate.ErrRateNotFound) {
+			missing[fxrate.MissingRate{Currency: strings.ToUpper(r.Currency), Date: r.PurchaseDate}] = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sums[r.Date] += m.Amount
+	}
+	if len(missing) > 0 {
+		pairs := lo.Keys(missing)
+		slices.SortFunc(pairs, func(a, b fxrate.MissingRate) int {
+			return cmp.Or(cmp.Compare(a.Currency, b.Currency), cmp.Compare(a.Date, b.Date))
+		})
+		return nil, &fxrate.MissingRatesError{To: converter.Currency(), Missing: pairs}
+	}
+	exponent := types.CurrencyExponent(converter.Currency())
+	results := make([]MembershipStatisticResponseDataItem, 0, len(sums))
+	for date, v := range sums {
+		results = append(results, MembershipStatisticResponseDataItem{Date: date, Label: converter.Currency(), Value: v, Exponent: lo.ToPtr(exponent)})
+	}
+	slices.SortFunc(results, func(a, b MembershipStatisticResponseDataItem) int { return cmp.Compare(b.Date, a.Date) })
+	return results, nil
+}
+
+// moneySeries returns rows as one series per currency, or converted into currency when it is set.
+func (s *Service) moneySeries(ctx context.Context, rows []moneyRow, currency string) ([]MembershipStatisticResponseDataItem, error) {
+	if currency != "" {
+		return s.convertMoneyRows(ctx, rows, currency)
+	}
+	return sumMoneyRows(rows), nil
+}
+
+// accumulateDaily turns daily series, newest day first, into running totals per label for every day from the
+// first to the last day of the series, newest day first.
+func accumulateDaily(daily []MembershipStatisticResponseDataItem) ([]MembershipStatisticResponseDataItem, error) {
+	if len(daily) == 0 {
+		return daily, nil
+	}
+	type series struct {
+		exponent *int
+		values   map[string]int64
+	}
+	labels := map[string]*series{}
+	from, until := daily[0].Date, daily[0].Date
+	for _, item := range daily {
+		from, until = min(from, item.Date), max(until, item.Date)
+		s := labels[item.Label]
+		if s == nil {
+			s = &series{exponent: item.Exponent, values: map[string]int64{}}
+			labels[item.Label] = s
+		}
+		s.values[item.Date] += item.Value
+	}
+	first, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", from, err)
+	}
+	last, err := time.Parse(time.DateOnly, until)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", until, err)
+	}
+	var results []MembershipStatisticResponseDataItem
+	for label, s := range labels {
+		var total int64
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			date := day.Format(time.DateOnly)
+			total += s.values[date]
+			results = append(results, MembershipStatisticResponseDataItem{Date: date, Label: label, Value: total, Exponent: s.exponent})
+		}
+	}
+	slices.SortFunc(results, func(a, b MembershipStatisticResponseDataItem) int {
+		return cmp.Or(cmp.Compare(b.Date, a.Date), cmp.Compare(a.Label, b.Label))
+	})
+	return results, nil
+}
@@ -0,0 +1,35 @@
+package statistics
+
+import (
+	"testing"
+
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
+	"github.com/fatflowers/cashier/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRows_ReportsEveryMissingRate(t *testing.T) {
+	c := fxrate.NewConverter("USD", []*models.FxRate{
+		{RateDate: "2025-01-03", BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: 1.25},
+	})
+	got, err := convertRows(c, []moneyRow{
+		{Date: "2025-01-03", PurchaseDate: "2025-01-03", Currency: "EUR", Exponent: 2, Amount: 1000},
+		{Date: "2025-01-03", PurchaseDate: "2025-01-03", Currency: "USD", Exponent: 2, Amount: 100},
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, int64(1350), got[0].Value)
+
+	_, err = convertRows(c, []moneyRow{
+		{Date: "2025-01-03", PurchaseDate: "2025-01-03", Currency: "jpy", Exponent: 0, Amount: 1000},
+		{Date: "2025-01-03", PurchaseDate: "2025-01-03", Currency: "CHF", Exponent: 2, Amount: 1000},
+		{Date: "2025-01-04", PurchaseDate: "2025-01-03", Currency: "JPY", Exponent: 0, Amount: 500},
+		{Date: "2025-01-04", PurchaseDate: "2025-01-03", Currency: "EUR", Exponent: 2, Amount: 1000},
+	})
+	require.ErrorIs(t, err, fxrate.ErrRateNotFound)
+	var missing *fxrate.MissingRatesError
+	require.ErrorAs(t, err, &missing)
+	require.Equal(t, []fxrate.MissingRate{{Currency: "CHF", Date: "2025-01-03"}, {Currency: "JPY", Date: "2025-01-03"}}, missing.Missing)
+	require.EqualError(t, err, "fx rate not found to USD: CHF on 2025-01-03, JPY on 2025-01-03")
+}
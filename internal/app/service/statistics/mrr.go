@@ -0,0 +1,303 @@
+package statistics
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+)
+
+// daysPerMonth is the average length of a Gregorian month.
+const daysPerMonth = 365.2425 / 12
+
+// periodMonths returns the length in months of a billing period of hours. Periods of whole weeks shorter
+// than a month count 52/12 weeks a month; other periods count as n months when they last between 28n and 31n
+// days, so that 30-day, quarterly and yearly items are n months whichever day count they are configured with.
+func periodMonths(hours int64) float64 {
+	days := float64(hours) / 24
+	if hours%(7*24) == 0 && days < 28 {
+		return days / 7 * 12 / 52
+	}
+	if n := math.Round(days / daysPerMonth); n >= 1 && days >= 28*n && days <= 31*n {
+		return n
+	}
+	return days / daysPerMonth
+}
+
+// monthlyRecurringRevenue normalizes the price of an auto-renewable transaction to a month. Other
+// transactions have none.
+func monthlyRecurringRevenue(txn *models.Transaction) types.Money {
+	extra := txn.Extra.Data()
+	if extra == nil || extra.PaymentItemSnapshot == nil {
+		return types.Money{}
+	}
+	item := extra.PaymentItemSnapshot
+	if item.Type != types.PaymentItemTypeAutoRenewableSubscription || lo.FromPtr(item.DurationHour) <= 0 {
+		return types.Money{}
+	}
+	amount := math.Round(float64(txn.Price.Amount) / periodMonths(*item.DurationHour))
+	return types.Money{Amount: int64(amount), Currency: txn.Price.Currency, Exponent: txn.Price.Exponent}
+}
+
+// recurringRevenue returns the MRR of subscription at a time: that of the latest auto-renewable purchase
+// still running, while the subscription gives access. Later purchases of other items do not hide it.
+func (s *Service) recurringRevenue(ctx context.Context, subscription *models.Subscription, at time.Time) (types.Money, error) {
+	if !subscription.Status.Entitled() {
+		return types.Money{}, nil
+	}
+	var txns []*models.Transaction
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND provider_id != ?", subscription.UserID, types.PaymentProviderInner).
+		Where("refund_at IS NULL AND revocation_date IS NULL").
+		Where("purchase_at <= ? AND expire_at IS NOT NULL", at).
+		Where("extra->'payment_item_snapshot'->>'type' = ?", types.PaymentItemTypeAutoRenewableSubscription).
+		Order("purchase_at DESC").
+		Limit(1).
+		Find(&txns).Error
+	if err != nil {
+		return types.Money{}, fmt.Errorf("failed to load latest transaction: %w", err)
+	}
+	if len(txns) == 0 {
+		return types.Money{}, nil
+	}
+	// In a grace period the provider keeps access past the expiry of the last paid period.
+	if end := lo.FromPtr(txns[0].GetGracePeriodExpireAt()); !txns[0].AutoRenewExpireAt.After(at) && !end.After(at) {
+		return types.Money{}, nil
+	}
+	return monthlyRecurringRevenue(txns[0]), nil
+}
+
+// getDailyMrr sums the MRR of the daily snapshots, per currency or converted with the rate of the snapshot
+// date.
+func (s *Service) getDailyMrr(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	rows, err := s.getMrrRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.moneySeries(ctx, lo.Map(rows, func(r mrrRow, _ int) moneyRow { return r.moneyRow }), request.ReportingCurrency)
+}
+
+// getDailyArr is twelve times daily_mrr.
+func (s *Service) getDailyArr(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	results, err := s.getDailyMrr(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Value *= 12
+	}
+	return results, nil
+}
+
+// getDailyArppu divides daily_mrr by the number of paying users (value2), per currency or across all of them
+// with a reporting currency.
+func (s *Service) getDailyArppu(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	rows, err := s.getMrrRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]int64{}
+	for _, r := range rows {
+		key := r.Date
+		if request.ReportingCurrency == "" {
+			key += "/" + r.Currency
+		}
+		users[key] += r.Users
+	}
+	results, err := s.moneySeries(ctx, lo.Map(rows, func(r mrrRow, _ int) moneyRow { return r.moneyRow }), request.ReportingCurrency)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range results {
+		key := item.Date
+		if request.ReportingCurrency == "" {
+			key += "/" + item.Label
+		}
+		if n := users[key]; n > 0 {
+			results[i].Value = int64(math.Round(float64(item.Value) / float64(n)))
+			results[i].Value2 = n
+		}
+	}
+	return results, nil
+}
+
+// mrrRow is the MRR of one currency on one snapshot date, paid by Users.
+type mrrRow struct {
+	moneyRow
+	Users int64
+}
+
+func (s *Service) getMrrRows(ctx context.Context) ([]mrrRow, error) {
+	var rows []mrrRow
+	q := s.db.WithContext(ctx).Table((models.SubscriptionDailySnapshot{}).TableName()).
+		Select("snapshot_date as date, snapshot_date as purchase_date, mrr_currency AS currency, mrr_exponent AS exponent, sum(mrr_amount) as amount, count(*) as users").
+		Where("mrr_amount > 0").
+		Group("1, 2, 3, 4")
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// MRR movement kinds between two consecutive snapshot dates. A user changing currency churns in the old
+// currency and is new in the other.
+const (
+	mrrMovementNew         = "new"
+	mrrMovementExpansion   = "expansion"
+	mrrMovementContraction = "contraction"
+	mrrMovementChurn       = "churn"
+)
+
+// mrrMovementRow sums one kind of MRR movement of one currency from the previous snapshot date to Date.
+type mrrMovementRow struct {
+	Date     string
+	Kind     string
+	Currency string
+	Exponent int
+	Amount   int64
+}
+
+// snapshotDatesSQL pairs each snapshot date with the one before it; paying lists the snapshots with MRR.
+// Only dates with MRR captured are paired, so that the first of them, compared with a date taken before MRR
+// was recorded, does not count the whole book as new.
+const snapshotDatesSQL = `
+dates AS (
+    SELECT snapshot_date AS date, LAG(snapshot_date) OVER (ORDER BY snapshot_date) AS prev_date
+    FROM (SELECT DISTINCT snapshot_date FROM subscription_daily_snapshot WHERE mrr_captured) d
+),
+paying AS (
+    SELECT snapshot_date, user_id, mrr_currency, mrr_exponent, mrr_amount
+    FROM subscription_daily_snapshot WHERE mrr_amount > 0
+)`
+
+func (s *Service) getMrrMovementRows(ctx context.Context) ([]mrrMovementRow, error) {
+	var rows []mrrMovementRow
+	err := s.db.WithContext(ctx).Raw(`
+WITH `+snapshotDatesSQL+`,
+pairs AS (
+    SELECT d.date, c.user_id AS cur_user, c.mrr_currency AS cur_currency, c.mrr_exponent AS cur_exponent, c.mrr_amount AS cur_amount,
+           p.user_id AS prev_user, p.mrr_currency AS prev_currency, p.mrr_exponent AS prev_exponent, p.mrr_amount AS prev_amount
+    FROM dates d
+    JOIN paying c ON c.snapshot_date = d.date
+    LEFT JOIN paying p ON p.snapshot_date = d.prev_date AND p.user_id = c.user_id
+    WHERE d.prev_date IS NOT NULL
+    UNION ALL
+    SELECT d.date, NULL, NULL, NULL, NULL, p.user_id, p.mrr_currency, p.mrr_exponent, p.mrr_amount
+    FROM dates d
+    JOIN paying p ON p.snapshot_date = d.prev_date
+    WHERE NOT EXISTS (SELECT 1 FROM paying c WHERE c.snapshot_date = d.date AND c.user_id = p.user_id)
+),
+movements AS (
+    SELECT date, ?::text AS kind, cur_currency AS currency, cur_exponent AS exponent, cur_amount AS amount
+    FROM pairs WHERE cur_user IS NOT NULL AND (prev_user IS NULL OR prev_currency != cur_currency)
+    UNION ALL
+    SELECT date, ?::text, prev_currency, prev_exponent, prev_amount
+    FROM pairs WHERE prev_user IS NOT NULL AND (cur_user IS NULL OR prev_currency != cur_currency)
+    UNION ALL
+    SELECT date, CASE WHEN cur_amount > prev_amount THEN ?::text ELSE ?::text END, cur_currency, cur_exponent, ABS(cur_amount - prev_amount)
+    FROM pairs WHERE prev_currency = cur_currency AND cur_amount != prev_amount
+)
+SELECT date, kind, currency, exponent, SUM(amount) AS amount
+FROM movements
+GROUP BY date, kind, currency, exponent
+`, mrrMovementNew, mrrMovementChurn, mrrMovementExpansion, mrrMovementContraction).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// getMrrMovement reports the MRR movements of the given kinds from the previous snapshot date, per
+// currency or converted with the rate of the later date. Amounts of kinds with sign -1 are subtracted.
+func (s *Service) getMrrMovement(ctx context.Context, request *MembershipStatisticRequest, signs map[string]int64) ([]MembershipStatisticResponseDataItem, error) {
+	movements, err := s.getMrrMovementRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []moneyRow
+	for _, m := range movements {
+		if sign, ok := signs[m.Kind]; ok {
+			rows = append(rows, moneyRow{Date: m.Date, PurchaseDate: m.Date, Currency: m.Currency, Exponent: m.Exponent, Amount: sign * m.Amount})
+		}
+	}
+	return s.moneySeries(ctx, rows, request.ReportingCurrency)
+}
+
+// getDailyLogoChurnRate reports, per snapshot date, the share of the users paying on the previous snapshot
+// date who no longer pay, in hundredths of a percent (value), with the users paying before (value2) and the
+// users lost (value3).
+func (s *Service) getDailyLogoChurnRate(ctx context.Context, _ *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var results []MembershipStatisticResponseDataItem
+	err := s.db.WithContext(ctx).Raw(`
+WITH ` + snapshotDatesSQL + `,
+churn AS (
+    SELECT d.date, COUNT(*) AS paying, COUNT(*) FILTER (WHERE c.user_id IS NULL) AS churned
+    FROM dates d
+    JOIN paying p ON p.snapshot_date = d.prev_date
+    LEFT JOIN paying c ON c.snapshot_date = d.date AND c.user_id = p.user_id
+    GROUP BY d.date
+)
+SELECT date, CAST(ROUND(churned * 100.0 / paying, 2) * 100 AS INTEGER) AS value, paying AS value2, churned AS value3
+FROM churn
+ORDER BY date DESC
+`).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// getDailyRevenueChurnRate reports, per snapshot date, the MRR lost to churn and contraction as a share of
+// the MRR of the previous snapshot date, in hundredths of a percent (value), with the previous MRR (value2)
+// and the MRR lost (value3). Expansion does not offset it. Rates are per currency, or of the MRR converted
+// with the rate of the later date.
+func (s *Service) getDailyRevenueChurnRate(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var opening []moneyRow
+	err := s.db.WithContext(ctx).Raw(`
+WITH ` + snapshotDatesSQL + `
+SELECT d.date, d.date AS purchase_date, p.mrr_currency AS currency, p.mrr_exponent AS exponent, SUM(p.mrr_amount) AS amount
+FROM dates d
+JOIN paying p ON p.snapshot_date = d.prev_date
+GROUP BY d.date, p.mrr_currency, p.mrr_exponent
+`).Scan(&opening).Error
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.moneySeries(ctx, opening, request.ReportingCurrency)
+	if err != nil {
+		return nil, err
+	}
+	lost, err := s.getMrrMovement(ctx, request, map[string]int64{mrrMovementChurn: 1, mrrMovementContraction: 1})
+	if err != nil {
+		return nil, err
+	}
+	lostBy := lo.SliceToMap(lost, func(item MembershipStatisticResponseDataItem) (string, int64) {
+		return item.Date + "/" + item.Label, item.Value
+	})
+	results := make([]MembershipStatisticResponseDataItem, 0, len(before))
+	for _, item := range before {
+		if item.Value <= 0 {
+			continue
+		}
+		l := lostBy[item.Date+"/"+item.Label]
+		results = append(results, MembershipStatisticResponseDataItem{
+			Date:     item.Date,
+			Label:    item.Label,
+			Value:    int64(math.Round(float64(l) * 10000 / float64(item.Value))),
+			Value2:   item.Value,
+			Value3:   l,
+			Exponent: item.Exponent,
+		})
+	}
+	slices.SortFunc(results, func(a, b MembershipStatisticResponseDataItem) int {
+		return cmp.Or(cmp.Compare(b.Date, a.Date), cmp.Compare(a.Label, b.Label))
+	})
+	return results, nil
+}
@@ -0,0 +1,47 @@
+package statistics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/gorm/schema"
+)
+
+func TestPeriodMonths(t *testing.T) {
+	require.Equal(t, 1.0, periodMonths(30*24))
+	require.Equal(t, 1.0, periodMonths(31*24))
+	require.Equal(t, 3.0, periodMonths(90*24))
+	require.Equal(t, 6.0, periodMonths(180*24))
+	require.Equal(t, 12.0, periodMonths(365*24))
+	require.InDelta(t, 12.0/52, periodMonths(7*24), 1e-9)
+	require.InDelta(t, 24.0/52, periodMonths(14*24), 1e-9)
+	require.InDelta(t, 40/daysPerMonth, periodMonths(40*24), 1e-9)
+}
+
+func TestMonthlyRecurringRevenue(t *testing.T) {
+	txn := func(itemType types.PaymentItemType, hours int64, price types.Money) *models.Transaction {
+		return &models.Transaction{Price: price, Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PaymentItemSnapshot: &types.PaymentItem{ID: "vip", Type: itemType, DurationHour: lo.ToPtr(hours)},
+		})}
+	}
+	year := types.Money{Amount: 9999, Currency: "USD", Exponent: 2}
+	require.Equal(t, types.Money{Amount: 833, Currency: "USD", Exponent: 2},
+		monthlyRecurringRevenue(txn(types.PaymentItemTypeAutoRenewableSubscription, 365*24, year)))
+	require.Equal(t, types.Money{Amount: 1200, Currency: "JPY", Exponent: 0},
+		monthlyRecurringRevenue(txn(types.PaymentItemTypeAutoRenewableSubscription, 30*24, types.Money{Amount: 1200, Currency: "JPY", Exponent: 0})))
+	require.Zero(t, monthlyRecurringRevenue(txn(types.PaymentItemTypeNonRenewableSubscription, 365*24, year)))
+	require.Zero(t, monthlyRecurringRevenue(&models.Transaction{Price: year}))
+}
+
+func TestSnapshotDatesSQL_SkipsSnapshotsWithoutMrr(t *testing.T) {
+	require.Contains(t, snapshotDatesSQL, "WHERE mrr_captured")
+	s, err := schema.Parse(&models.SubscriptionDailySnapshot{}, &sync.Map{}, schema.NamingStrategy{SingularTable: true})
+	require.NoError(t, err)
+	require.NotNil(t, s.LookUpField("mrr_captured"))
+}
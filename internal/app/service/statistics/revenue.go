@@ -0,0 +1,94 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"gorm.io/gorm/clause"
+)
+
+// revenueRow is the sum of the prices of one currency on one day of the net revenue series, purchased on
+// PurchaseDate in Storefront, of subscriptions started on ChainStartDate.
+type revenueRow struct {
+	Date           string
+	PurchaseDate   string
+	ChainStartDate string
+	ProviderID     types.PaymentProvider
+	Storefront     string
+	Currency       string
+	Exponent       int
+	Amount         int64
+}
+
+// netAmount estimates what is left of the gross amount of r after the sales tax included in it and the store
+// commission on the rest, rounded half away from zero.
+func netAmount(cfg *config.RevenueConfig, r revenueRow) (int64, error) {
+	purchased, err := time.Parse(time.DateOnly, r.PurchaseDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q: %w", r.PurchaseDate, err)
+	}
+	started, err := time.Parse(time.DateOnly, r.ChainStartDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q: %w", r.ChainStartDate, err)
+	}
+	tax := cfg.TaxRate(r.Storefront)
+	commission := cfg.CommissionRate(r.ProviderID, purchased.Sub(started))
+	return int64(math.Round(float64(r.Amount) / (1 + tax) * (1 - commission))), nil
+}
+
+// getDailyNetRevenue estimates, per day and currency, the revenue left after sales tax and store commission.
+// Refunded transactions are left out.
+func (s *Service) getDailyNetRevenue(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	rows, err := s.getNetRevenueRows(ctx, request.GetFilters(StatisticTypeDailyNetRevenue))
+	if err != nil {
+		return nil, err
+	}
+	return s.moneySeries(ctx, rows, request.ReportingCurrency)
+}
+
+// getTotalNetRevenue is the running total of daily_net_revenue.
+func (s *Service) getTotalNetRevenue(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	rows, err := s.getNetRevenueRows(ctx, request.GetFilters(StatisticTypeTotalNetRevenue))
+	if err != nil {
+		return nil, err
+	}
+	daily, err := s.moneySeries(ctx, rows, request.ReportingCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return accumulateDaily(daily)
+}
+
+// getNetRevenueRows loads the gross amounts of the net revenue series and deducts tax and commission.
+// Commission tiers depend on how long the renewal chain of a transaction has been paid for, so the start of
+// the chain is computed before filters apply.
+func (s *Service) getNetRevenueRows(ctx context.Context, request *MembershipStatisticRequest) ([]moneyRow, error) {
+	chains := s.db.Table("transaction").
+		Select("*, MIN(purchase_at) OVER (PARTITION BY provider_id, COALESCE(parent_transaction_id, transaction_id)) AS chain_start").
+		Where("provider_id != ?", types.PaymentProviderInner)
+	var rows []revenueRow
+	q := s.db.WithContext(ctx).Table("(?) AS t", chains).
+		Select(`TO_CHAR(created_at, 'YYYY-MM-DD') as date, TO_CHAR(purchase_at, 'YYYY-MM-DD') as purchase_date,
+  TO_CHAR(chain_start, 'YYYY-MM-DD') as chain_start_date, provider_id, storefront,
+  price_currency AS currency, price_exponent AS exponent, sum(price_amount) as amount`).
+		Where("refund_at IS NULL").
+		Where(clause.Where{Exprs: []clause.Expression{request}}).
+		Group("1, 2, 3, 4, 5, 6, 7")
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	results := make([]moneyRow, 0, len(rows))
+	for _, r := range rows {
+		amount, err := netAmount(&s.cfg.Revenue, r)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, moneyRow{Date: r.Date, PurchaseDate: r.PurchaseDate, Currency: r.Currency, Exponent: r.Exponent, Amount: amount})
+	}
+	return results, nil
+}
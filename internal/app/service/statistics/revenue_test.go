@@ -0,0 +1,66 @@
+package statistics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetAmount(t *testing.T) {
+	cfg := &config.RevenueConfig{
+		Taxes:          []*config.TaxRule{{Storefronts: []string{"DEU", "de"}, Rate: 0.19}},
+		DefaultTaxRate: 0.1,
+		Commission: map[types.PaymentProvider]*config.CommissionConfig{
+			types.PaymentProviderStripe: {Rate: 0.03},
+		},
+	}
+	net := func(r revenueRow) int64 {
+		t.Helper()
+		if r.ChainStartDate == "" {
+			r.ChainStartDate = r.PurchaseDate
+		}
+		amount, err := netAmount(cfg, r)
+		require.NoError(t, err)
+		return amount
+	}
+
+	// 11.90 EUR including 19% VAT is 10.00 net of tax, of which Apple keeps 30%.
+	require.Equal(t, int64(700), net(revenueRow{PurchaseDate: "2025-01-03", ProviderID: types.PaymentProviderApple, Storefront: "DEU", Amount: 1190}))
+	require.Equal(t, int64(700), net(revenueRow{PurchaseDate: "2025-01-03", ProviderID: types.PaymentProviderGoogle, Storefront: "DE", Amount: 1190}))
+	// A year into the renewal chain the commission drops to 15%.
+	require.Equal(t, int64(850), net(revenueRow{PurchaseDate: "2025-01-03", ChainStartDate: "2024-01-03", ProviderID: types.PaymentProviderApple, Storefront: "DEU", Amount: 1190}))
+	require.Equal(t, int64(700), net(revenueRow{PurchaseDate: "2024-12-31", ChainStartDate: "2024-01-03", ProviderID: types.PaymentProviderApple, Storefront: "DEU", Amount: 1190}))
+	// Storefronts without a rule use the default tax rate; configured providers their own commission.
+	require.Equal(t, int64(970), net(revenueRow{PurchaseDate: "2025-01-03", ProviderID: types.PaymentProviderStripe, Amount: 1100}))
+}
+
+func TestRevenueConfig_CommissionRate(t *testing.T) {
+	cfg := &config.RevenueConfig{Commission: map[types.PaymentProvider]*config.CommissionConfig{
+		types.PaymentProviderApple:  {Rate: 0.3, ReducedRate: 0.15, SmallBusiness: true},
+		types.PaymentProviderGoogle: {Rate: 0.3},
+	}}
+	require.Equal(t, 0.15, cfg.CommissionRate(types.PaymentProviderApple, 0))
+	require.Equal(t, 0.3, cfg.CommissionRate(types.PaymentProviderGoogle, 2*365*24*time.Hour), "no reduced rate without reduced_after")
+	require.Zero(t, cfg.CommissionRate(types.PaymentProviderStripe, 0))
+}
+
+func TestAccumulateDaily(t *testing.T) {
+	two := 2
+	got, err := accumulateDaily([]MembershipStatisticResponseDataItem{
+		{Date: "2025-01-03", Label: "USD", Value: 5, Exponent: &two},
+		{Date: "2025-01-03", Label: "EUR", Value: 7, Exponent: &two},
+		{Date: "2025-01-01", Label: "USD", Value: 1, Exponent: &two},
+	})
+	require.NoError(t, err)
+	values := make([]int64, 0, len(got))
+	for _, item := range got {
+		values = append(values, item.Value)
+	}
+	require.Len(t, got, 6)
+	require.Equal(t, "EUR", got[0].Label)
+	require.Equal(t, []int64{7, 6, 0, 1, 0, 1}, values)
+}
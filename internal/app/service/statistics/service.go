@@ -3,7 +3,9 @@ package statistics
 import (
 	"context"
 	"fmt"
+	"github.com/fatflowers/cashier/internal/app/service/fxrate"
 	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
 	"github.com/fatflowers/cashier/pkg/tool"
 	"github.com/fatflowers/cashier/pkg/types"
 	"sync"
@@ -22,15 +24,38 @@ const (
 	StatisticTypeDailyTransactionCount StatisticType = "daily_transaction_count"
 	StatisticTypeDailyGmv              StatisticType = "daily_gmv"
 	StatisticTypeTotalGmv              StatisticType = "total_gmv"
+	// StatisticTypeDailyRefundAmount sums the price of the transactions refunded each day.
+	StatisticTypeDailyRefundAmount StatisticType = "daily_refund_amount"
+	// Net revenue estimates what is left of the price after sales tax and store commission, see config.RevenueConfig.
+	StatisticTypeDailyNetRevenue StatisticType = "daily_net_revenue"
+	StatisticTypeTotalNetRevenue StatisticType = "total_net_revenue"
 
 	// Membership (subscription) related
 	StatisticTypeDailyMembershipCount            StatisticType = "daily_membership_count"
 	StatisticTypeDailyNewMembershipCount         StatisticType = "daily_new_membership_count"
 	StatisticTypeTotalMembershipCount            StatisticType = "total_membership_count"
 	StatisticTypeDailyAccumulatedMembershipCount StatisticType = "daily_accumulated_membership_count"
+	StatisticTypeGracePeriodMembershipCount      StatisticType = "grace_period_membership_count"
+	StatisticTypeBillingRetryMembershipCount     StatisticType = "billing_retry_membership_count"
+
+	// Recurring revenue, from the MRR of the daily snapshots. Movements compare each snapshot date with the
+	// one before it.
+	StatisticTypeDailyMrr              StatisticType = "daily_mrr"
+	StatisticTypeDailyArr              StatisticType = "daily_arr"
+	StatisticTypeDailyArppu            StatisticType = "daily_arppu"
+	StatisticTypeDailyNewMrr           StatisticType = "daily_new_mrr"
+	StatisticTypeDailyExpansionMrr     StatisticType = "daily_expansion_mrr"
+	StatisticTypeDailyContractionMrr   StatisticType = "daily_contraction_mrr"
+	StatisticTypeDailyChurnedMrr       StatisticType = "daily_churned_mrr"
+	StatisticTypeDailyNetNewMrr        StatisticType = "daily_net_new_mrr"
+	StatisticTypeDailyLogoChurnRate    StatisticType = "daily_logo_churn_rate"
+	StatisticTypeDailyRevenueChurnRate StatisticType = "daily_revenue_churn_rate"
 
 	// Renewal metrics
 	StatisticTypeRenewalSuccessRate StatisticType = "renewal_success_rate"
+
+	// Refund metrics
+	StatisticTypeRefundRate StatisticType = "refund_rate"
 )
 
 // Filter types supported by certain statistic types
@@ -49,9 +74,10 @@ var filterTypes = []MembershipStatisticFilterType{
 }
 
 var validFilters = map[MembershipStatisticFilterType][]StatisticType{
-	MembershipStatisticFilterTypeIsFirstPurchase: {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv},
-	MembershipStatisticFilterTypeIsAutoRenew:     {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv},
-	MembershipStatisticFilterTypePaymentItemID:   {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv},
+	MembershipStatisticFilterTypeIsFirstPurchase: {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv, StatisticTypeDailyNetRevenue, StatisticTypeTotalNetRevenue},
+	MembershipStatisticFilterTypeIsAutoRenew:     {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv, StatisticTypeDailyNetRevenue, StatisticTypeTotalNetRevenue},
+	MembershipStatisticFilterTypePaymentItemID: {StatisticTypeDailyTransactionCount, StatisticTypeDailyGmv, StatisticTypeRefundRate, StatisticTypeDailyRefundAmount,
+		StatisticTypeDailyNetRevenue, StatisticTypeTotalNetRevenue},
 }
 
 type MembershipStatisticDataItem struct {
@@ -61,13 +87,16 @@ type MembershipStatisticDataItem struct {
 type MembershipStatisticRequest struct {
 	Filters   []*types.CommonFilter          `json:"filters"`
 	DataItems []*MembershipStatisticDataItem `json:"data_items"`
+	// ReportingCurrency converts money series into one series of this currency, with the rate of each
+	// purchase date. Empty returns one series per currency.
+	ReportingCurrency string `json:"reporting_currency"`
 }
 
 func (f *MembershipStatisticRequest) GetFilters(statisticType StatisticType) *MembershipStatisticRequest {
 	if f == nil || len(f.Filters) == 0 {
 		return f
 	}
-	var result MembershipStatisticRequest
+	result := MembershipStatisticRequest{ReportingCurrency: f.ReportingCurrency}
 	for _, filter := range f.Filters {
 		if statisticTypes, ok := validFilters[MembershipStatisticFilterType(filter.Field)]; ok {
 			if lo.Contains(statisticTypes, statisticType) {
@@ -116,6 +145,8 @@ type MembershipStatisticResponseDataItem struct {
 	Value  int64  `json:"value"`
 	Value2 int64  `json:"value2,omitempty"`
 	Value3 int64  `json:"value3,omitempty"`
+	// Exponent is set for money series, whose Label is the currency and Value an amount in its minor units.
+	Exponent *int `json:"exponent,omitempty"`
 }
 
 type MembershipStatisticResponse struct {
@@ -124,16 +155,25 @@ type MembershipStatisticResponse struct {
 
 // Service provides statistics operations
 type Service struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cfg   *config.Config
+	rates *fxrate.Service
 }
 
-func New(db *gorm.DB) *Service { return &Service{db: db} }
+func New(db *gorm.DB, cfg *config.Config, rates *fxrate.Service) *Service {
+	return &Service{db: db, cfg: cfg, rates: rates}
+}
 
-// SaveSubscriptionDailySnapshot persists a daily snapshot of a user's subscription state
+// SaveSubscriptionDailySnapshot persists a daily snapshot of a user's subscription state and its MRR at
+// snapshotDate. An existing snapshot of the user for the same date is kept.
 func (s *Service) SaveSubscriptionDailySnapshot(ctx context.Context, subscription *models.Subscription, snapshotDate time.Time) error {
 	if subscription == nil {
 		return fmt.Errorf("nil subscription")
 	}
+	mrr, err := s.recurringRevenue(ctx, subscription, snapshotDate)
+	if err != nil {
+		return err
+	}
 	snap := &models.SubscriptionDailySnapshot{
 		ID:                tool.GenerateUUIDV7(),
 		UserID:            subscription.UserID,
@@ -145,8 +185,10 @@ func (s *Service) SaveSubscriptionDailySnapshot(ctx context.Context, subscriptio
 		UpdatedAt:         subscription.UpdatedAt,
 		SnapshotDate:      snapshotDate.Format(time.DateOnly),
 		SnapshotCreatedAt: time.Now(),
+		Mrr:               mrr,
+		MrrCaptured:       true,
 	}
-	return s.db.WithContext(ctx).Create(snap).Error
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(snap).Error
 }
 
 // Internal helpers for various stats
@@ -165,13 +207,26 @@ func (s *Service) getDailyTransactionCount(ctx context.Context, request *Members
 }
 
 func (s *Service) getDailyGmv(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	if request.ReportingCurrency != "" {
+		var rows []moneyRow
+		q := s.db.WithContext(ctx).Table("transaction").
+			Select("TO_CHAR(created_at, 'YYYY-MM-DD') as date, TO_CHAR(purchase_at, 'YYYY-MM-DD') as purchase_date, price_currency AS currency, price_exponent AS exponent, sum(price_amount) as amount").
+			Where("provider_id != ?", types.PaymentProviderInner).
+			Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeDailyGmv)}}).
+			Group("1, 2, 3, 4")
+		if err := q.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		return s.convertMoneyRows(ctx, rows, request.ReportingCurrency)
+	}
 	var results []MembershipStatisticResponseDataItem
 	q := s.db.WithContext(ctx).Table("transaction").
-		Select("TO_CHAR(created_at, 'YYYY-MM-DD') as date, currency AS label, sum(price) as value").
+		Select("TO_CHAR(created_at, 'YYYY-MM-DD') as date, price_currency AS label, price_exponent AS exponent, sum(price_amount) as value").
 		Where("provider_id != ?", types.PaymentProviderInner).
 		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeDailyGmv)}}).
 		Group("TO_CHAR(created_at, 'YYYY-MM-DD')").
-		Group("currency").
+		Group("price_currency").
+		Group("price_exponent").
 		Order(clause.OrderByColumn{Column: clause.Column{Name: "date"}, Desc: true})
 	if err := q.Find(&results).Error; err != nil {
 		return nil, err
@@ -179,7 +234,22 @@ func (s *Service) getDailyGmv(ctx context.Context, request *MembershipStatisticR
 	return results, nil
 }
 
-func (s *Service) getTotalGmv(ctx context.Context, _ *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+func (s *Service) getTotalGmv(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	if request.ReportingCurrency != "" {
+		var rows []moneyRow
+		q := s.db.WithContext(ctx).Table("transaction").
+			Select("TO_CHAR(created_at, 'YYYY-MM-DD') as date, TO_CHAR(purchase_at, 'YYYY-MM-DD') as purchase_date, price_currency AS currency, price_exponent AS exponent, sum(price_amount) as amount").
+			Where("provider_id != ?", types.PaymentProviderInner).
+			Group("1, 2, 3, 4")
+		if err := q.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		daily, err := s.convertMoneyRows(ctx, rows, request.ReportingCurrency)
+		if err != nil {
+			return nil, err
+		}
+		return accumulateDaily(daily)
+	}
 	var results []MembershipStatisticResponseDataItem
 	err := s.db.WithContext(ctx).Raw(`
 WITH min_max_dates AS (
@@ -193,24 +263,25 @@ dates AS (
     SELECT TO_CHAR(date, 'YYYY-MM-DD') as date FROM distinct_dates
 ),
 currencies AS (
-    SELECT DISTINCT currency as label FROM transaction WHERE provider_id != ?
+    SELECT DISTINCT price_currency as label, price_exponent as exponent FROM transaction WHERE provider_id != ?
 ),
 date_currency_combinations AS (
-    SELECT d.date, c.label FROM dates d CROSS JOIN currencies c
+    SELECT d.date, c.label, c.exponent FROM dates d CROSS JOIN currencies c
 ),
 gmv_date AS (
-    SELECT dc.date, dc.label, COALESCE(SUM(t.price), 0) as value
+    SELECT dc.date, dc.label, dc.exponent, COALESCE(SUM(t.price_amount), 0) as value
     FROM date_currency_combinations dc
     LEFT JOIN transaction t 
       ON TO_CHAR(t.created_at, 'YYYY-MM-DD') = dc.date 
-     AND t.currency = dc.label 
+     AND t.price_currency = dc.label 
+     AND t.price_exponent = dc.exponent 
      AND t.provider_id != ?
-    GROUP BY dc.date, dc.label
+    GROUP BY dc.date, dc.label, dc.exponent
 )
-SELECT d.date as date, d.label as label, SUM(s.value) as value
+SELECT d.date as date, d.label as label, d.exponent as exponent, SUM(s.value) as value
 FROM gmv_date d
-LEFT JOIN gmv_date s ON s.date <= d.date AND s.label = d.label
-GROUP BY d.date, d.label
+LEFT JOIN gmv_date s ON s.date <= d.date AND s.label = d.label AND s.exponent = d.exponent
+GROUP BY d.date, d.label, d.exponent
 ORDER BY d.date DESC, d.label ASC
 `, types.PaymentProviderInner, types.PaymentProviderInner).Scan(&results).Error
 	if err != nil {
@@ -219,6 +290,22 @@ ORDER BY d.date DESC, d.label ASC
 	return results, nil
 }
 
+// getDailyRefundAmount sums, per refund day and currency, the price of the transactions refunded that day.
+// A reversed refund no longer counts.
+func (s *Service) getDailyRefundAmount(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var rows []moneyRow
+	q := s.db.WithContext(ctx).Table("transaction").
+		Select("TO_CHAR(refund_at, 'YYYY-MM-DD') as date, TO_CHAR(purchase_at, 'YYYY-MM-DD') as purchase_date, price_currency AS currency, price_exponent AS exponent, sum(price_amount) as amount").
+		Where("provider_id != ?", types.PaymentProviderInner).
+		Where("refund_at IS NOT NULL").
+		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeDailyRefundAmount)}}).
+		Group("1, 2, 3, 4")
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return s.moneySeries(ctx, rows, request.ReportingCurrency)
+}
+
 func (s *Service) getDailyMembershipCount(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
 	var results []MembershipStatisticResponseDataItem
 	q := s.db.WithContext(ctx).Table((models.SubscriptionDailySnapshot{}).TableName()).
@@ -258,7 +345,7 @@ func (s *Service) getTotalMembershipCount(ctx context.Context, request *Membersh
 	q := s.db.WithContext(ctx).Table((models.Subscription{}).TableName()).
 		Select("count(*) as value").
 		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeTotalMembershipCount)}}).
-		Where("status = ?", types.SubscriptionStatusActive).
+		Where("status IN ?", []types.SubscriptionStatus{types.SubscriptionStatusActive, types.SubscriptionStatusGracePeriod}).
 		Where("expire_at >= ?", time.Now())
 	if err := q.Find(&results).Error; err != nil {
 		return nil, err
@@ -266,6 +353,45 @@ func (s *Service) getTotalMembershipCount(ctx context.Context, request *Membersh
 	return results, nil
 }
 
+// billingRetryPeriod bounds how long a subscription stays in billing retry after its last paid period ends:
+// Apple retries for up to 60 days, Google holds an account for up to 30. Snapshots still in billing retry
+// beyond it belong to subscriptions whose provider stopped retrying without telling us.
+const billingRetryPeriod = 60 * 24 * time.Hour
+
+// getGracePeriodMembershipCount counts, per snapshot date, the members keeping access after a failed renewal;
+// they are also in daily_membership_count.
+func (s *Service) getGracePeriodMembershipCount(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var results []MembershipStatisticResponseDataItem
+	q := s.db.WithContext(ctx).Table((models.SubscriptionDailySnapshot{}).TableName()).
+		Select("snapshot_date as date, count(*) as value").
+		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeGracePeriodMembershipCount)}}).
+		Where("status = ?", types.SubscriptionStatusGracePeriod).
+		Where("expire_at >= snapshot_date::date").
+		Group("snapshot_date").
+		Order("snapshot_date")
+	if err := q.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// getBillingRetryMembershipCount counts, per snapshot date, the users without access whose renewal the
+// provider is still retrying, within billingRetryPeriod of the end of their last period.
+func (s *Service) getBillingRetryMembershipCount(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var results []MembershipStatisticResponseDataItem
+	q := s.db.WithContext(ctx).Table((models.SubscriptionDailySnapshot{}).TableName()).
+		Select("snapshot_date as date, count(*) as value").
+		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeBillingRetryMembershipCount)}}).
+		Where("status = ?", types.SubscriptionStatusBillingRetry).
+		Where("COALESCE(expire_at, updated_at) >= snapshot_date::date - make_interval(secs => ?)", billingRetryPeriod.Seconds()).
+		Group("snapshot_date").
+		Order("snapshot_date")
+	if err := q.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func (s *Service) getDailyAccumulatedMembershipCount(ctx context.Context, _ *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
 	var results []MembershipStatisticResponseDataItem
 	err := s.db.WithContext(ctx).Raw(`
@@ -329,6 +455,26 @@ ORDER BY date DESC`
 	return results, nil
 }
 
+// getRefundRate reports, per payment item (label), the share of paid transactions currently refunded in
+// hundredths of a percent (value), with the transaction count (value2) and refunded count (value3).
+// A reversed refund no longer counts.
+func (s *Service) getRefundRate(ctx context.Context, request *MembershipStatisticRequest) ([]MembershipStatisticResponseDataItem, error) {
+	var results []MembershipStatisticResponseDataItem
+	q := s.db.WithContext(ctx).Table("transaction").
+		Select(`payment_item_id as label,
+  CAST(ROUND(COUNT(refund_at) * 100.0 / COUNT(*), 2) * 100 AS INTEGER) as value,
+  COUNT(*) as value2,
+  COUNT(refund_at) as value3`).
+		Where("provider_id != ?", types.PaymentProviderInner).
+		Where(clause.Where{Exprs: []clause.Expression{request.GetFilters(StatisticTypeRefundRate)}}).
+		Group("payment_item_id").
+		Order("payment_item_id")
+	if err := q.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func (s *Service) getMembershipStatistic(ctx context.Context, request *MembershipStatisticRequest, dataItem *MembershipStatisticDataItem) ([]MembershipStatisticResponseDataItem, error) {
 	switch dataItem.ID {
 	case StatisticTypeDailyTransactionCount:
@@ -337,6 +483,12 @@ func (s *Service) getMembershipStatistic(ctx context.Context, request *Membershi
 		return s.getDailyGmv(ctx, request)
 	case StatisticTypeTotalGmv:
 		return s.getTotalGmv(ctx, request)
+	case StatisticTypeDailyRefundAmount:
+		return s.getDailyRefundAmount(ctx, request)
+	case StatisticTypeDailyNetRevenue:
+		return s.getDailyNetRevenue(ctx, request)
+	case StatisticTypeTotalNetRevenue:
+		return s.getTotalNetRevenue(ctx, request)
 	case StatisticTypeDailyMembershipCount:
 		return s.getDailyMembershipCount(ctx, request)
 	case StatisticTypeDailyNewMembershipCount:
@@ -345,8 +497,34 @@ func (s *Service) getMembershipStatistic(ctx context.Context, request *Membershi
 		return s.getTotalMembershipCount(ctx, request)
 	case StatisticTypeDailyAccumulatedMembershipCount:
 		return s.getDailyAccumulatedMembershipCount(ctx, request)
+	case StatisticTypeGracePeriodMembershipCount:
+		return s.getGracePeriodMembershipCount(ctx, request)
+	case StatisticTypeBillingRetryMembershipCount:
+		return s.getBillingRetryMembershipCount(ctx, request)
+	case StatisticTypeDailyMrr:
+		return s.getDailyMrr(ctx, request)
+	case StatisticTypeDailyArr:
+		return s.getDailyArr(ctx, request)
+	case StatisticTypeDailyArppu:
+		return s.getDailyArppu(ctx, request)
+	case StatisticTypeDailyNewMrr:
+		return s.getMrrMovement(ctx, request, map[string]int64{mrrMovementNew: 1})
+	case StatisticTypeDailyExpansionMrr:
+		return s.getMrrMovement(ctx, request, map[string]int64{mrrMovementExpansion: 1})
+	case StatisticTypeDailyContractionMrr:
+		return s.getMrrMovement(ctx, request, map[string]int64{mrrMovementContraction: 1})
+	case StatisticTypeDailyChurnedMrr:
+		return s.getMrrMovement(ctx, request, map[string]int64{mrrMovementChurn: 1})
+	case StatisticTypeDailyNetNewMrr:
+		return s.getMrrMovement(ctx, request, map[string]int64{mrrMovementNew: 1, mrrMovementExpansion: 1, mrrMovementContraction: -1, mrrMovementChurn: -1})
+	case StatisticTypeDailyLogoChurnRate:
+		return s.getDailyLogoChurnRate(ctx, request)
+	case StatisticTypeDailyRevenueChurnRate:
+		return s.getDailyRevenueChurnRate(ctx, request)
 	case StatisticTypeRenewalSuccessRate:
 		return s.getRenewalSuccessRate(ctx, request)
+	case StatisticTypeRefundRate:
+		return s.getRefundRate(ctx, request)
 	default:
 		return nil, fmt.Errorf("invalid data item id: %s", dataItem.ID)
 	}
@@ -0,0 +1,62 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
+	"github.com/fatflowers/cashier/internal/models"
+)
+
+const (
+	// DailySnapshotJob is the scheduler job that snapshots every subscription once a day.
+	DailySnapshotJob = "subscription_daily_snapshot"
+	// defaultDailySnapshotSchedule takes the snapshot shortly before the end of the UTC day it is dated.
+	defaultDailySnapshotSchedule = "55 23 * * *"
+	dailySnapshotBatchSize       = 500
+)
+
+// SnapshotAllSubscriptions saves the snapshot of every subscription dated snapshotDate. Subscriptions that
+// already have a snapshot for that date keep it, so a failed run can be retried.
+func (s *Service) SnapshotAllSubscriptions(ctx context.Context, snapshotDate time.Time) error {
+	var lastID string
+	var total, failed int
+	var firstErr error
+	for {
+		q := s.db.WithContext(ctx).Model(&models.Subscription{})
+		if lastID != "" {
+			q = q.Where("id > ?", lastID)
+		}
+		var subs []*models.Subscription
+		if err := q.Order("id").Limit(dailySnapshotBatchSize).Find(&subs).Error; err != nil {
+			return fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		for _, sub := range subs {
+			total++
+			if err := s.SaveSubscriptionDailySnapshot(ctx, sub, snapshotDate); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("user %s: %w", sub.UserID, err)
+				}
+			}
+		}
+		if len(subs) < dailySnapshotBatchSize {
+			break
+		}
+		lastID = subs[len(subs)-1].ID
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to snapshot %d of %d subscriptions: %w", failed, total, firstErr)
+	}
+	return nil
+}
+
+func registerDailySnapshotJob(sched *scheduler.Scheduler, s *Service) error {
+	return sched.Register(DailySnapshotJob, defaultDailySnapshotSchedule, func(ctx context.Context, scheduledAt time.Time) error {
+		return s.SnapshotAllSubscriptions(ctx, scheduledAt)
+	})
+}
@@ -0,0 +1,147 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/db"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDatabaseDSNEnv points the concurrency tests at a disposable Postgres database.
+const testDatabaseDSNEnv = "CASHIER_TEST_DATABASE_DSN"
+
+func newPostgresTestService(t *testing.T, items ...*types.PaymentItem) *Service {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseDSNEnv)
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	log := zap.NewNop().Sugar()
+	m, err := db.NewMigrator(gdb, log)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	return NewService(&config.Config{PaymentItems: items}, gdb, log)
+}
+
+func cleanupTestUser(t *testing.T, s *Service, userID string) {
+	t.Cleanup(func() {
+		for _, model := range []any{&models.Transaction{}, &models.UserMembershipActiveItem{}, &models.Subscription{}} {
+			s.db.Where("user_id = ?", userID).Delete(model)
+		}
+	})
+}
+
+func TestUpsertUserSubscriptionByItem_ConcurrentPurchasesForOneUser(t *testing.T) {
+	dayHours := int64(24)
+	item := &types.PaymentItem{ID: "test_day_pass", ProviderID: types.PaymentProviderInner, Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &dayHours}
+	s := newPostgresTestService(t, item)
+	userID := "concurrency-" + tool.GenerateUUIDV7()
+	cleanupTestUser(t, s, userID)
+
+	const n = 8
+	base := time.Now().Add(-time.Minute).Truncate(time.Second)
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.UpsertUserSubscriptionByItem(context.Background(), &models.Transaction{
+				UserID:        userID,
+				ProviderID:    types.PaymentProviderInner,
+				PaymentItemID: item.ID,
+				TransactionID: fmt.Sprintf("%s-%d", userID, i),
+				PurchaseAt:    base.Add(time.Duration(i) * time.Second),
+			})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Queued day passes stack back to back from the first purchase.
+	wantExpire := base.Add(n * 24 * time.Hour)
+
+	var sub models.Subscription
+	require.NoError(t, s.db.Where("user_id = ?", userID).First(&sub).Error)
+	require.Equal(t, types.SubscriptionStatusActive, sub.Status)
+	require.NotNil(t, sub.ExpireAt)
+	require.True(t, wantExpire.Equal(*sub.ExpireAt), "expire_at=%s want=%s", sub.ExpireAt, wantExpire)
+
+	var active []*models.UserMembershipActiveItem
+	require.NoError(t, s.db.Where("user_id = ?", userID).Order("activated_at").Find(&active).Error)
+	require.Len(t, active, n)
+	require.True(t, wantExpire.Equal(active[n-1].ExpireAt))
+
+	var firstPurchases int64
+	require.NoError(t, s.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND (extra->>'is_first_purchase')::boolean", userID).Count(&firstPurchases).Error)
+	require.Equal(t, int64(1), firstPurchases)
+}
+
+func TestUpsertUserSubscriptionByItem_ConcurrentSameTransaction(t *testing.T) {
+	dayHours := int64(24)
+	item := &types.PaymentItem{ID: "test_day_pass", ProviderID: types.PaymentProviderInner, Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &dayHours}
+	s := newPostgresTestService(t, item)
+	userID := "concurrency-" + tool.GenerateUUIDV7()
+	cleanupTestUser(t, s, userID)
+
+	// A verify call and a webhook for the same purchase arriving together.
+	const n = 4
+	purchaseAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.UpsertUserSubscriptionByItem(context.Background(), &models.Transaction{
+				UserID:        userID,
+				ProviderID:    types.PaymentProviderInner,
+				PaymentItemID: item.ID,
+				TransactionID: userID,
+				PurchaseAt:    purchaseAt,
+			})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	var count int64
+	require.NoError(t, s.db.Model(&models.Transaction{}).Where("user_id = ?", userID).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+
+	var active []*models.UserMembershipActiveItem
+	require.NoError(t, s.db.Where("user_id = ?", userID).Find(&active).Error)
+	require.Len(t, active, 1)
+
+	var sub models.Subscription
+	require.NoError(t, s.db.Where("user_id = ?", userID).First(&sub).Error)
+	require.True(t, purchaseAt.Add(24*time.Hour).Equal(*sub.ExpireAt))
+}
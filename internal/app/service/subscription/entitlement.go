@@ -0,0 +1,132 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+)
+
+// UserEntitlement is an entitlement a user holds and until when.
+type UserEntitlement struct {
+	Entitlement string `json:"entitlement"`
+	// Tier is granted by the current period.
+	Tier          int                   `json:"tier"`
+	PaymentItemID string                `json:"payment_item_id"`
+	ProviderID    types.PaymentProvider `json:"provider_id"`
+	TransactionID string                `json:"transaction_id"`
+	// ExpireAt is the end of the uninterrupted run of periods granting the entitlement, including queued ones.
+	// It is nil for a permanent unlock.
+	ExpireAt        *time.Time `json:"expire_at"`
+	NextAutoRenewAt *time.Time `json:"next_auto_renew_at,omitempty"`
+}
+
+// computeUserEntitlements lays out a separate timeline per entitlement from the subscriptions granting it, adds
+// the permanent unlocks of non-consumable purchases and returns the entitlements held at queryAt, ordered by
+// name. When both grant an entitlement the higher tier wins, and the permanent unlock on a tie.
+func (s *Service) computeUserEntitlements(ctx context.Context, txns []*models.Transaction, queryAt time.Time) ([]*UserEntitlement, error) {
+	byEntitlement := map[string][]*models.Transaction{}
+	tiers := map[string]map[string]int{}
+	permanent := map[string]*UserEntitlement{}
+	for _, txn := range txns {
+		if txn == nil {
+			continue
+		}
+		paymentItem := s.purchasedItem(txn)
+		// An item missing from the catalog and the snapshot grants nothing; the rest of the entitlements of
+		// the user are still computed.
+		if paymentItem == nil {
+			s.log.Warnw("skipping transaction of unknown payment item", "user_id", txn.UserID, "id", txn.ID, "payment_item_id", txn.PaymentItemID)
+			continue
+		}
+		if paymentItem.IsConsumable() {
+			continue
+		}
+		for _, g := range paymentItem.GetEntitlements() {
+			if paymentItem.IsNonConsumable() {
+				if txn.RefundAt != nil || txn.PurchaseAt.After(queryAt) {
+					continue
+				}
+				if held := permanent[g.Entitlement]; held == nil || g.Tier > held.Tier {
+					permanent[g.Entitlement] = &UserEntitlement{
+						Entitlement:   g.Entitlement,
+						Tier:          g.Tier,
+						PaymentItemID: txn.PaymentItemID,
+						ProviderID:    txn.ProviderID,
+						TransactionID: txn.TransactionID,
+					}
+				}
+				continue
+			}
+			byEntitlement[g.Entitlement] = append(byEntitlement[g.Entitlement], txn)
+			if tiers[g.Entitlement] == nil {
+				tiers[g.Entitlement] = map[string]int{}
+			}
+			tiers[g.Entitlement][txn.PaymentItemID] = g.Tier
+		}
+	}
+
+	held := map[string]*UserEntitlement{}
+	for entitlement, granting := range byEntitlement {
+		periods, err := s.getAllActiveUserSubscriptionItems(ctx, granting, queryAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute entitlement %s: %w", entitlement, err)
+		}
+		if e := heldEntitlement(entitlement, periods, tiers[entitlement], queryAt); e != nil {
+			held[entitlement] = e
+		}
+	}
+	for entitlement, e := range permanent {
+		if timed := held[entitlement]; timed == nil || e.Tier >= timed.Tier {
+			held[entitlement] = e
+		}
+	}
+
+	res := make([]*UserEntitlement, 0, len(held))
+	for _, e := range held {
+		res = append(res, e)
+	}
+	slices.SortFunc(res, func(a, b *UserEntitlement) int { return strings.Compare(a.Entitlement, b.Entitlement) })
+	return res, nil
+}
+
+// heldEntitlement returns the entitlement if one of the last active periods covers queryAt, or nil.
+func heldEntitlement(entitlement string, periods []*UserSubscriptionItem, tiers map[string]int, queryAt time.Time) *UserEntitlement {
+	idx := slices.IndexFunc(periods, func(p *UserSubscriptionItem) bool {
+		return !p.ActivatedAt.After(queryAt) && p.ExpireAt.After(queryAt)
+	})
+	if idx < 0 {
+		return nil
+	}
+	current := periods[idx]
+	res := &UserEntitlement{
+		Entitlement:   entitlement,
+		Tier:          tiers[current.PaymentItemID],
+		PaymentItemID: current.PaymentItemID,
+		ProviderID:    current.ProviderID,
+		TransactionID: current.TransactionID,
+		ExpireAt:      &periods[len(periods)-1].ExpireAt,
+	}
+	for i := len(periods) - 1; i >= 0; i-- {
+		if periods[i].NextAutoRenewAt != nil {
+			if periods[i].NextAutoRenewAt.After(queryAt) {
+				res.NextAutoRenewAt = periods[i].NextAutoRenewAt
+			}
+			break
+		}
+	}
+	return res
+}
+
+// GetUserEntitlements returns the entitlements a user holds at queryAt.
+func (s *Service) GetUserEntitlements(ctx context.Context, userID string, queryAt time.Time) ([]*UserEntitlement, error) {
+	txns, err := s.GetAllUserTransactions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	return s.computeUserEntitlements(ctx, txns, queryAt)
+}
@@ -0,0 +1,207 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+func TestComputeUserEntitlements(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	day := 24 * time.Hour
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "pro_month", Type: types.PaymentItemTypeAutoRenewableSubscription, Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "pro", Tier: 2},
+			{Entitlement: "storage", Tier: 1},
+		}},
+		{ID: "plus_week", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(7 * 24)), Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "pro", Tier: 1},
+		}},
+		{ID: "storage_pack", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(90 * 24)), Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "storage", Tier: 3},
+		}},
+		{ID: "vip_day", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(24))},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+
+	txs := []*models.Transaction{
+		{
+			ID:                "t-pro",
+			UserID:            "u1",
+			ProviderID:        types.PaymentProviderApple,
+			PaymentItemID:     "pro_month",
+			TransactionID:     "tx-pro",
+			PurchaseAt:        now,
+			AutoRenewExpireAt: ptrTime(now.Add(30 * day)),
+			NextAutoRenewAt:   ptrTime(now.Add(30 * day)),
+		},
+		{
+			ID:            "t-plus",
+			UserID:        "u1",
+			ProviderID:    types.PaymentProviderInner,
+			PaymentItemID: "plus_week",
+			TransactionID: "tx-plus",
+			PurchaseAt:    now.Add(-3 * day),
+		},
+		{
+			ID:            "t-storage",
+			UserID:        "u1",
+			ProviderID:    types.PaymentProviderInner,
+			PaymentItemID: "storage_pack",
+			TransactionID: "tx-storage",
+			PurchaseAt:    now.Add(-100 * day),
+		},
+		{
+			ID:            "t-vip",
+			UserID:        "u1",
+			ProviderID:    types.PaymentProviderInner,
+			PaymentItemID: "vip_day",
+			TransactionID: "tx-vip",
+			PurchaseAt:    now,
+		},
+	}
+
+	got, err := svc.computeUserEntitlements(context.Background(), txs, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+
+	// Items without entitlements grant the default one.
+	require.Equal(t, types.DefaultEntitlement, got[0].Entitlement)
+	require.Equal(t, "vip_day", got[0].PaymentItemID)
+	require.True(t, now.Add(day).Equal(*got[0].ExpireAt))
+
+	// The subscription takes precedence over the week pass, whose remaining days are queued after it.
+	require.Equal(t, "pro", got[1].Entitlement)
+	require.Equal(t, "pro_month", got[1].PaymentItemID)
+	require.Equal(t, 2, got[1].Tier)
+	require.True(t, now.Add(34*day).Equal(*got[1].ExpireAt))
+	require.True(t, now.Add(30*day).Equal(*got[1].NextAutoRenewAt))
+
+	// The storage pack lapsed, so storage comes from the subscription on its own timeline.
+	require.Equal(t, "storage", got[2].Entitlement)
+	require.Equal(t, "pro_month", got[2].PaymentItemID)
+	require.Equal(t, 1, got[2].Tier)
+	require.True(t, now.Add(30*day).Equal(*got[2].ExpireAt))
+
+	// Before the subscription the week pass grants the lower tier.
+	got, err = svc.computeUserEntitlements(context.Background(), txs, now.Add(-day))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "pro", got[0].Entitlement)
+	require.Equal(t, "plus_week", got[0].PaymentItemID)
+	require.Equal(t, 1, got[0].Tier)
+	require.True(t, now.Add(4*day).Equal(*got[0].ExpireAt))
+	require.Nil(t, got[0].NextAutoRenewAt)
+
+	got, err = svc.computeUserEntitlements(context.Background(), txs, now.Add(60*day))
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestComputeUserEntitlements_PermanentUnlocks(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "pro_month", Type: types.PaymentItemTypeAutoRenewableSubscription, Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "pro", Tier: 2},
+		}},
+		{ID: "pro_lifetime", Type: types.PaymentItemTypeNonConsumable, Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "pro", Tier: 1},
+			{Entitlement: "themes", Tier: 1},
+		}},
+		{ID: "coins_100", Type: types.PaymentItemTypeConsumable, Credits: 100},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+	txs := []*models.Transaction{
+		{ID: "t-life", UserID: "u1", ProviderID: types.PaymentProviderApple, PaymentItemID: "pro_lifetime", TransactionID: "tx-life", PurchaseAt: now},
+		{ID: "t-coins", UserID: "u1", ProviderID: types.PaymentProviderApple, PaymentItemID: "coins_100", TransactionID: "tx-coins", PurchaseAt: now},
+		{
+			ID:                "t-pro",
+			UserID:            "u1",
+			ProviderID:        types.PaymentProviderApple,
+			PaymentItemID:     "pro_month",
+			TransactionID:     "tx-pro",
+			PurchaseAt:        now.Add(24 * time.Hour),
+			AutoRenewExpireAt: ptrTime(now.Add(31 * 24 * time.Hour)),
+		},
+	}
+
+	got, err := svc.computeUserEntitlements(context.Background(), txs, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "pro", got[0].Entitlement)
+	require.Equal(t, "pro_lifetime", got[0].PaymentItemID)
+	require.Nil(t, got[0].ExpireAt)
+	require.Equal(t, "themes", got[1].Entitlement)
+
+	// The subscription grants a higher tier while it lasts.
+	got, err = svc.computeUserEntitlements(context.Background(), txs, now.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "pro_month", got[0].PaymentItemID)
+	require.Equal(t, 2, got[0].Tier)
+	require.NotNil(t, got[0].ExpireAt)
+
+	got, err = svc.computeUserEntitlements(context.Background(), txs, now.Add(60*24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "pro_lifetime", got[0].PaymentItemID)
+
+	// A refunded unlock grants nothing.
+	txs[0].RefundAt = ptrTime(now.Add(2 * time.Hour))
+	got, err = svc.computeUserEntitlements(context.Background(), txs, now.Add(60*24*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestComputeUserEntitlements_SkipsUnknownItems(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "pro_lifetime", Type: types.PaymentItemTypeNonConsumable, Entitlements: []*types.EntitlementGrant{{Entitlement: "pro", Tier: 1}}},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+	txs := []*models.Transaction{
+		{ID: "t-gone", UserID: "u1", ProviderID: types.PaymentProviderApple, PaymentItemID: "retired_item", TransactionID: "tx-gone", PurchaseAt: now},
+		{ID: "t-life", UserID: "u1", ProviderID: types.PaymentProviderApple, PaymentItemID: "pro_lifetime", TransactionID: "tx-life", PurchaseAt: now},
+	}
+
+	got, err := svc.computeUserEntitlements(context.Background(), txs, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "pro_lifetime", got[0].PaymentItemID)
+}
+
+func TestComputeUserEntitlements_UsesSnapshot(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "pass", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(24)), Entitlements: []*types.EntitlementGrant{
+			{Entitlement: "pro", Tier: 2},
+		}},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+	// The item granted tier 1 for a week when it was bought; the catalog changed since.
+	bought := &types.PaymentItem{ID: "pass", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: lo.ToPtr(int64(7 * 24)), Entitlements: []*types.EntitlementGrant{
+		{Entitlement: "pro", Tier: 1},
+	}}
+	txn := &models.Transaction{
+		ID:            "t-pass",
+		UserID:        "u1",
+		ProviderID:    types.PaymentProviderInner,
+		PaymentItemID: "pass",
+		TransactionID: "tx-pass",
+		PurchaseAt:    now,
+		Extra:         datatypes.NewJSONType(&models.UserSubscriptionItemExtra{PaymentItemSnapshot: bought}),
+	}
+	require.Same(t, bought, svc.purchasedItem(txn))
+
+	got, err := svc.computeUserEntitlements(context.Background(), []*models.Transaction{txn}, now.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, 1, got[0].Tier)
+	require.True(t, now.Add(7*24*time.Hour).Equal(*got[0].ExpireAt), "duration and grants come from the same version")
+}
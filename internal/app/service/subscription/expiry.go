@@ -0,0 +1,96 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ExpiryJob is the scheduler job that settles subscriptions whose access ran out without a provider
+	// event, so that subscribers hear about natural expiry too.
+	ExpiryJob             = "subscription_expiry"
+	defaultExpirySchedule = "@every 5m"
+	expiryBatchSize       = 500
+)
+
+// entitledStatuses are the statuses that grant access until expire_at.
+var entitledStatuses = []types.SubscriptionStatus{types.SubscriptionStatusActive, types.SubscriptionStatusGracePeriod}
+
+// ExpireSubscriptions recomputes every subscription still granting access whose expiry is not after now,
+// with change reason expire, and returns how many it recomputed.
+func (s *Service) ExpireSubscriptions(ctx context.Context, now time.Time) (int, error) {
+	var lastID string
+	var total, failed int
+	var firstErr error
+	for {
+		q := s.db.WithContext(ctx).Model(&models.Subscription{}).
+			Where("status IN ? AND expire_at <= ?", entitledStatuses, now)
+		if lastID != "" {
+			q = q.Where("id > ?", lastID)
+		}
+		var subs []*models.Subscription
+		if err := q.Order("id").Limit(expiryBatchSize).Find(&subs).Error; err != nil {
+			return total, fmt.Errorf("failed to list expired subscriptions: %w", err)
+		}
+		for _, sub := range subs {
+			if err := s.expireSubscription(ctx, sub.UserID, now); err != nil {
+				if ctx.Err() != nil {
+					return total, ctx.Err()
+				}
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("user %s: %w", sub.UserID, err)
+				}
+				continue
+			}
+			total++
+		}
+		if len(subs) < expiryBatchSize {
+			break
+		}
+		lastID = subs[len(subs)-1].ID
+	}
+	if failed > 0 {
+		return total, fmt.Errorf("failed to expire %d subscriptions: %w", failed, firstErr)
+	}
+	return total, nil
+}
+
+// expireSubscription recomputes the subscription of userID at now unless a change committed since it was
+// listed already did.
+func (s *Service) expireSubscription(ctx context.Context, userID string, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(ctx, tx, userID); err != nil {
+			return err
+		}
+		var sub models.Subscription
+		if err := tx.WithContext(ctx).Where("user_id = ?", userID).First(&sub).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("failed to get subscription: %w", err)
+		}
+		if !sub.Status.Entitled() || sub.ExpireAt == nil || sub.ExpireAt.After(now) {
+			return nil
+		}
+		return s.recomputeSubscription(ctx, tx, userID, now, types.UserSubscriptionChangeReasonExpire)
+	})
+}
+
+func registerExpiryJob(sched *scheduler.Scheduler, s *Service) error {
+	return sched.Register(ExpiryJob, defaultExpirySchedule, func(ctx context.Context, scheduledAt time.Time) error {
+		n, err := s.ExpireSubscriptions(ctx, scheduledAt)
+		if n > 0 {
+			s.log.Infow("expired subscriptions", "count", n)
+		}
+		return err
+	})
+}
@@ -0,0 +1,49 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/outbox"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/tool"
+	"github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireSubscriptions_EmitsMembershipEvent(t *testing.T) {
+	dayHours := int64(24)
+	item := &types.PaymentItem{ID: "test_day_pass", ProviderID: types.PaymentProviderInner, Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &dayHours}
+	s := newPostgresTestService(t, item)
+	userID := "expiry-" + tool.GenerateUUIDV7()
+	cleanupTestUser(t, s, userID)
+
+	ctx := context.Background()
+	purchaseAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, s.UpsertUserSubscriptionByItem(ctx, &models.Transaction{
+		UserID:        userID,
+		ProviderID:    types.PaymentProviderInner,
+		PaymentItemID: item.ID,
+		TransactionID: userID,
+		PurchaseAt:    purchaseAt,
+	}))
+
+	// Nothing has expired yet.
+	_, err := s.ExpireSubscriptions(ctx, time.Now())
+	require.NoError(t, err)
+	var sub models.Subscription
+	require.NoError(t, s.db.Where("user_id = ?", userID).First(&sub).Error)
+	require.Equal(t, types.SubscriptionStatusActive, sub.Status)
+
+	_, err = s.ExpireSubscriptions(ctx, purchaseAt.Add(25*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, s.db.Where("user_id = ?", userID).First(&sub).Error)
+	require.Equal(t, types.SubscriptionStatusInactive, sub.Status)
+	require.Nil(t, sub.ExpireAt)
+
+	var events []*models.OutboxEvent
+	require.NoError(t, s.db.Where("topic = ? AND payload->>'user_id' = ? AND payload->>'reason' = ?",
+		outbox.TopicMembershipChanged, userID, types.UserSubscriptionChangeReasonExpire).Find(&events).Error)
+	require.Len(t, events, 1)
+}
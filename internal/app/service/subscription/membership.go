@@ -0,0 +1,137 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+)
+
+// MaxBatchMembershipUsers bounds BatchGetUserMembership, which loads every transaction of the users.
+const MaxBatchMembershipUsers = 50
+
+// UserMembership is the current membership of a user as exposed to client apps and services.
+type UserMembership struct {
+	UserID       string                     `json:"user_id"`
+	Subscription types.UserSubsctiptionInfo `json:"subscription"`
+	// ActiveItems are the current and queued periods, ordered by activation.
+	ActiveItems []*UserMembershipItem `json:"active_items"`
+	// PendingDowngrade is set when the provider reported a downgrade for the next renewal.
+	PendingDowngrade *models.PendingDowngrade `json:"pending_downgrade,omitempty"`
+	// Entitlements are held now, each with its own timeline.
+	Entitlements []*UserEntitlement `json:"entitlements"`
+}
+
+type UserMembershipItem struct {
+	PaymentItemID   string                `json:"payment_item_id"`
+	ProviderID      types.PaymentProvider `json:"provider_id"`
+	TransactionID   string                `json:"transaction_id"`
+	ActivatedAt     time.Time             `json:"activated_at"`
+	ExpireAt        time.Time             `json:"expire_at"`
+	NextAutoRenewAt *time.Time            `json:"next_auto_renew_at"`
+}
+
+// GetUserMembership returns the membership of one user from the subscription table and the
+// user_membership_active_item projection.
+func (s *Service) GetUserMembership(ctx context.Context, userID string) (*UserMembership, error) {
+	res, err := s.BatchGetUserMembership(ctx, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// BatchGetUserMembership returns memberships in the order of userIDs. Users without purchases are inactive.
+func (s *Service) BatchGetUserMembership(ctx context.Context, userIDs []string) ([]*UserMembership, error) {
+	if len(userIDs) == 0 {
+		return nil, fmt.Errorf("invalid params: user_ids required")
+	}
+	if len(userIDs) > MaxBatchMembershipUsers {
+		return nil, fmt.Errorf("invalid params: at most %d user_ids", MaxBatchMembershipUsers)
+	}
+	now := time.Now()
+	ids := lo.Uniq(userIDs)
+
+	var subs []*models.Subscription
+	if err := s.db.WithContext(ctx).Where("user_id IN ?", ids).Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	var activeItems []*models.UserMembershipActiveItem
+	if err := s.db.WithContext(ctx).
+		Where("user_id IN ? AND expire_at > ?", ids, now).
+		Order("activated_at").
+		Find(&activeItems).Error; err != nil {
+		return nil, fmt.Errorf("failed to get active items: %w", err)
+	}
+
+	// Entitlements are computed from every transaction of the users.
+	var txns []*models.Transaction
+	if err := s.db.WithContext(ctx).Where("user_id IN ?", ids).Find(&txns).Error; err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	subsByUser := lo.KeyBy(subs, func(m *models.Subscription) string { return m.UserID })
+	itemsByUser := lo.GroupBy(activeItems, func(it *models.UserMembershipActiveItem) string { return it.UserID })
+	txnsByID := lo.KeyBy(txns, func(t *models.Transaction) string { return t.ID })
+	txnsByUser := lo.GroupBy(txns, func(t *models.Transaction) string { return t.UserID })
+
+	res := make([]*UserMembership, len(userIDs))
+	for i, userID := range userIDs {
+		res[i] = buildUserMembership(userID, subsByUser[userID], itemsByUser[userID], txnsByID, now)
+		entitlements, err := s.computeUserEntitlements(ctx, txnsByUser[userID], now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get entitlements of user %s: %w", userID, err)
+		}
+		res[i].Entitlements = entitlements
+	}
+	return res, nil
+}
+
+// buildUserMembership assembles a UserMembership at now. An entitled stored status (active or grace_period)
+// is only trusted while it has not expired; billing_retry is reported until the provider resolves it.
+func buildUserMembership(userID string, sub *models.Subscription, items []*models.UserMembershipActiveItem, txnsByID map[string]*models.Transaction, now time.Time) *UserMembership {
+	res := &UserMembership{
+		UserID:       userID,
+		Subscription: types.UserSubsctiptionInfo{Status: string(types.SubscriptionStatusInactive)},
+		ActiveItems:  []*UserMembershipItem{},
+		Entitlements: []*UserEntitlement{},
+	}
+	switch {
+	case sub == nil:
+	case sub.Status.Entitled() && sub.ExpireAt != nil && sub.ExpireAt.After(now):
+		res.Subscription.Status = string(sub.Status)
+		res.Subscription.ExpireAt = *sub.ExpireAt
+		if sub.NextAutoRenewAt != nil && sub.NextAutoRenewAt.After(now) {
+			res.Subscription.NextAutoRenewAt = sub.NextAutoRenewAt
+		}
+	case sub.Status == types.SubscriptionStatusBillingRetry:
+		res.Subscription.Status = string(types.SubscriptionStatusBillingRetry)
+		res.Subscription.ExpireAt = lo.FromPtr(sub.ExpireAt)
+	}
+
+	for _, it := range items {
+		if !it.ExpireAt.After(now) {
+			continue
+		}
+		item := &UserMembershipItem{
+			PaymentItemID:   it.PaymentItemID,
+			ActivatedAt:     it.ActivatedAt,
+			ExpireAt:        it.ExpireAt,
+			NextAutoRenewAt: it.NextAutoRenewAt,
+		}
+		if txn := txnsByID[it.UserTransactionID]; txn != nil {
+			item.ProviderID = txn.ProviderID
+			item.TransactionID = txn.TransactionID
+			if extra := txn.Extra.Data(); extra != nil && extra.PendingDowngrade != nil && extra.PendingDowngrade.EffectiveAt.After(now) {
+				res.PendingDowngrade = extra.PendingDowngrade
+			}
+		}
+		res.ActiveItems = append(res.ActiveItems, item)
+	}
+	return res
+}
@@ -0,0 +1,38 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMembershipChangeEvent(t *testing.T) {
+	expire := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	active := &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusActive, ExpireAt: &expire}
+
+	event := newMembershipChangeEvent(nil, active, types.UserSubscriptionChangeReasonPurchase)
+	require.NotNil(t, event)
+	require.Equal(t, types.MembershipEventTypeChanged, event.Type)
+	require.Equal(t, "u1", event.UserID)
+	require.Equal(t, types.SubscriptionStatusInactive, event.BeforeStatus)
+	require.Equal(t, types.SubscriptionStatusActive, event.AfterStatus)
+	require.Equal(t, types.UserSubscriptionChangeReasonPurchase, event.Reason)
+
+	// Same status and expiry: nothing to announce.
+	same := &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusActive, ExpireAt: &expire}
+	require.Nil(t, newMembershipChangeEvent(active, same, types.UserSubscriptionChangeReasonPurchase))
+
+	renewed := expire.Add(30 * 24 * time.Hour)
+	event = newMembershipChangeEvent(active, &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusActive, ExpireAt: &renewed}, types.UserSubscriptionChangeReasonPurchase)
+	require.NotNil(t, event)
+	require.True(t, expire.Equal(*event.BeforeExpireAt))
+	require.True(t, renewed.Equal(*event.ExpireAt))
+
+	event = newMembershipChangeEvent(active, &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusInactive}, types.UserSubscriptionChangeReasonRefund)
+	require.NotNil(t, event)
+	require.Equal(t, types.SubscriptionStatusInactive, event.AfterStatus)
+	require.Nil(t, event.ExpireAt)
+}
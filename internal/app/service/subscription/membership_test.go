@@ -0,0 +1,70 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestBuildUserMembership(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	expire := now.Add(15 * 24 * time.Hour)
+	renew := expire
+	sub := &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusActive, ExpireAt: &expire, NextAutoRenewAt: &renew}
+	items := []*models.UserMembershipActiveItem{
+		{UserTransactionID: "old", PaymentItemID: "vip_month", ActivatedAt: now.Add(-60 * 24 * time.Hour), ExpireAt: now.Add(-30 * 24 * time.Hour)},
+		{UserTransactionID: "cur", PaymentItemID: "vip_year", ActivatedAt: now.Add(-15 * 24 * time.Hour), ExpireAt: expire, NextAutoRenewAt: &renew},
+	}
+	txns := map[string]*models.Transaction{
+		"cur": {ID: "cur", ProviderID: types.PaymentProviderApple, TransactionID: "2000", Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PendingDowngrade: &models.PendingDowngrade{PaymentItemID: "vip_month", EffectiveAt: renew},
+		})},
+	}
+
+	res := buildUserMembership("u1", sub, items, txns, now)
+	require.Equal(t, string(types.SubscriptionStatusActive), res.Subscription.Status)
+	require.True(t, expire.Equal(res.Subscription.ExpireAt))
+	require.Len(t, res.ActiveItems, 1)
+	require.Equal(t, "2000", res.ActiveItems[0].TransactionID)
+	require.Equal(t, types.PaymentProviderApple, res.ActiveItems[0].ProviderID)
+	require.NotNil(t, res.PendingDowngrade)
+	require.Equal(t, "vip_month", res.PendingDowngrade.PaymentItemID)
+}
+
+func TestBuildUserMembership_ExpiredSubscriptionIsInactive(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour)
+	sub := &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusActive, ExpireAt: &expired}
+
+	res := buildUserMembership("u1", sub, nil, nil, now)
+	require.Equal(t, string(types.SubscriptionStatusInactive), res.Subscription.Status)
+	require.Empty(t, res.ActiveItems)
+	require.Nil(t, res.PendingDowngrade)
+
+	res = buildUserMembership("u2", nil, nil, nil, now)
+	require.Equal(t, "u2", res.UserID)
+	require.Equal(t, string(types.SubscriptionStatusInactive), res.Subscription.Status)
+}
+
+func TestBuildUserMembership_GracePeriodAndBillingRetry(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	graceEnd := now.Add(3 * 24 * time.Hour)
+	sub := &models.Subscription{UserID: "u1", Status: types.SubscriptionStatusGracePeriod, ExpireAt: &graceEnd}
+
+	res := buildUserMembership("u1", sub, nil, nil, now)
+	require.Equal(t, string(types.SubscriptionStatusGracePeriod), res.Subscription.Status)
+	require.True(t, graceEnd.Equal(res.Subscription.ExpireAt))
+
+	// A grace period that ended without an update is not trusted.
+	res = buildUserMembership("u1", sub, nil, nil, graceEnd.Add(time.Hour))
+	require.Equal(t, string(types.SubscriptionStatusInactive), res.Subscription.Status)
+
+	sub.Status = types.SubscriptionStatusBillingRetry
+	res = buildUserMembership("u1", sub, nil, nil, graceEnd.Add(time.Hour))
+	require.Equal(t, string(types.SubscriptionStatusBillingRetry), res.Subscription.Status)
+	require.True(t, graceEnd.Equal(res.Subscription.ExpireAt))
+}
@@ -2,7 +2,8 @@ package subscription
 
 import "go.uber.org/fx"
 
-// Module exposes the subscription service via Fx.
+// Module exposes the subscription service via Fx and schedules settling expired subscriptions.
 var Module = fx.Options(
 	fx.Provide(NewService),
+	fx.Invoke(registerExpiryJob),
 )
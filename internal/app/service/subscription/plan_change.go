@@ -0,0 +1,120 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// getRenewalChainWithTx returns the other stored transactions of item's renewal chain, oldest first.
+func (s *Service) getRenewalChainWithTx(ctx context.Context, tx *gorm.DB, item *models.Transaction) ([]*models.Transaction, error) {
+	if item.ParentTransactionID == nil || *item.ParentTransactionID == "" {
+		return nil, nil
+	}
+	var chain []*models.Transaction
+	if err := tx.WithContext(ctx).
+		Where("provider_id = ? AND parent_transaction_id = ? AND transaction_id <> ?", item.ProviderID, *item.ParentTransactionID, item.TransactionID).
+		Find(&chain).Error; err != nil {
+		return nil, fmt.Errorf("failed to get renewal chain: %w", err)
+	}
+	slices.SortFunc(chain, compareChainOrder)
+	return chain, nil
+}
+
+// compareChainOrder orders a renewal chain by purchase time, which unlike receipt order is the same for
+// every source of the transactions.
+func compareChainOrder(a, b *models.Transaction) int {
+	if c := a.PurchaseAt.Compare(b.PurchaseAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.TransactionID, b.TransactionID)
+}
+
+// chainNeighbours returns the transactions of chain purchased right before and right after item.
+func chainNeighbours(chain []*models.Transaction, item *models.Transaction) (prev, next *models.Transaction) {
+	for _, txn := range chain {
+		switch c := compareChainOrder(txn, item); {
+		case c < 0:
+			prev = txn
+		case c > 0 && next == nil:
+			next = txn
+		}
+	}
+	return prev, next
+}
+
+// applyPlanChange classifies txn by comparing the level of its item with the one of prev, the transaction
+// before it in its renewal chain, and records the result in the extra. An upgrade, or a crossgrade bought
+// while prev was still running, replaces prev, so txn is linked to it with BeforeUpgradedTransactionID.
+// Downgrades and later crossgrades take effect when prev ends and are not linked. It reports whether txn
+// changed, and leaves txn alone when prev is nil. A link txn got some other way, such as an upgrade of
+// items outside any subscription group recorded by an earlier release, is kept unless txn was classified
+// before: only links set here are dropped when the items no longer compare.
+func (s *Service) applyPlanChange(prev, txn *models.Transaction) bool {
+	if prev == nil {
+		return false
+	}
+	change := types.ClassifyPlanChange(s.purchasedItem(prev), s.purchasedItem(txn))
+	if change == "" && (txn.Extra.Data() == nil || txn.Extra.Data().PlanChange == "") {
+		return false
+	}
+	var replaces *string
+	if change == types.PlanChangeUpgrade ||
+		(change == types.PlanChangeCrossgrade && prev.AutoRenewExpireAt != nil && prev.AutoRenewExpireAt.After(txn.PurchaseAt)) {
+		replaces = &prev.TransactionID
+	}
+
+	extra := txn.Extra.Data()
+	if extra == nil {
+		extra = &models.UserSubscriptionItemExtra{}
+	}
+	if extra.PlanChange == change && (replaces == nil) == (txn.BeforeUpgradedTransactionID == nil) &&
+		(replaces == nil || *replaces == *txn.BeforeUpgradedTransactionID) {
+		return false
+	}
+	extra.PlanChange = change
+	txn.Extra = datatypes.NewJSONType(extra)
+	txn.BeforeUpgradedTransactionID = replaces
+	return true
+}
+
+// inheritPlanChange keeps the classification of a stored transaction whose predecessor is not stored.
+func inheritPlanChange(original, item *models.Transaction) {
+	if original == nil {
+		return
+	}
+	if item.BeforeUpgradedTransactionID == nil {
+		item.BeforeUpgradedTransactionID = original.BeforeUpgradedTransactionID
+	}
+	if extra := original.Extra.Data(); extra != nil && extra.PlanChange != "" {
+		itemExtra := item.Extra.Data()
+		if itemExtra == nil {
+			itemExtra = &models.UserSubscriptionItemExtra{}
+		}
+		itemExtra.PlanChange = extra.PlanChange
+		item.Extra = datatypes.NewJSONType(itemExtra)
+	}
+}
+
+// planChangeReason is the change reason of a transaction that switched its chain to another item, or "".
+func planChangeReason(txn *models.Transaction) types.SubscriptionChangeReason {
+	extra := txn.Extra.Data()
+	if extra == nil {
+		return ""
+	}
+	switch extra.PlanChange {
+	case types.PlanChangeUpgrade:
+		return types.UserSubscriptionChangeReasonUpgrade
+	case types.PlanChangeDowngrade:
+		return types.UserSubscriptionChangeReasonDowngrade
+	case types.PlanChangeCrossgrade:
+		return types.UserSubscriptionChangeReasonCrossgrade
+	}
+	return ""
+}
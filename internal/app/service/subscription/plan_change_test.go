@@ -0,0 +1,125 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+func TestApplyPlanChange(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	month := 30 * 24 * time.Hour
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "basic_month", Type: types.PaymentItemTypeAutoRenewableSubscription, SubscriptionGroup: "vip", Level: 1},
+		{ID: "pro_month", Type: types.PaymentItemTypeAutoRenewableSubscription, SubscriptionGroup: "vip", Level: 2},
+		{ID: "pro_year", Type: types.PaymentItemTypeAutoRenewableSubscription, SubscriptionGroup: "vip", Level: 2},
+		{ID: "storage_month", Type: types.PaymentItemTypeAutoRenewableSubscription, SubscriptionGroup: "storage", Level: 1},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+	txn := func(id, paymentItemID string, purchaseAt time.Time) *models.Transaction {
+		return &models.Transaction{
+			ProviderID:        types.PaymentProviderApple,
+			PaymentItemID:     paymentItemID,
+			TransactionID:     id,
+			PurchaseAt:        purchaseAt,
+			AutoRenewExpireAt: ptrTime(purchaseAt.Add(month)),
+			Extra:             datatypes.NewJSONType(&models.UserSubscriptionItemExtra{}),
+		}
+	}
+
+	basic := txn("1", "basic_month", now)
+	upgrade := txn("2", "pro_month", now.Add(10*24*time.Hour))
+	require.True(t, svc.applyPlanChange(basic, upgrade))
+	require.Equal(t, types.PlanChangeUpgrade, upgrade.Extra.Data().PlanChange)
+	require.Equal(t, "1", *upgrade.BeforeUpgradedTransactionID)
+	require.Equal(t, types.UserSubscriptionChangeReasonUpgrade, planChangeReason(upgrade))
+	require.False(t, svc.applyPlanChange(basic, upgrade), "classifying again changes nothing")
+
+	// A downgrade takes effect at the renewal and does not replace the previous period.
+	downgrade := txn("3", "basic_month", upgrade.AutoRenewExpireAt.Add(0))
+	require.True(t, svc.applyPlanChange(upgrade, downgrade))
+	require.Equal(t, types.PlanChangeDowngrade, downgrade.Extra.Data().PlanChange)
+	require.Nil(t, downgrade.BeforeUpgradedTransactionID)
+	require.Equal(t, types.UserSubscriptionChangeReasonDowngrade, planChangeReason(downgrade))
+
+	// A crossgrade replaces the previous period only when bought while it was running.
+	immediate := txn("4", "pro_year", now.Add(5*24*time.Hour))
+	require.True(t, svc.applyPlanChange(txn("5", "pro_month", now), immediate))
+	require.Equal(t, types.PlanChangeCrossgrade, immediate.Extra.Data().PlanChange)
+	require.Equal(t, "5", *immediate.BeforeUpgradedTransactionID)
+	atRenewal := txn("6", "pro_year", now.Add(month))
+	require.True(t, svc.applyPlanChange(txn("7", "pro_month", now), atRenewal))
+	require.Equal(t, types.PlanChangeCrossgrade, atRenewal.Extra.Data().PlanChange)
+	require.Nil(t, atRenewal.BeforeUpgradedTransactionID)
+
+	// Renewals of the same item and items of other groups are not plan changes.
+	renewal := txn("8", "pro_month", upgrade.AutoRenewExpireAt.Add(0))
+	require.False(t, svc.applyPlanChange(upgrade, renewal))
+	require.Empty(t, renewal.Extra.Data().PlanChange)
+	other := txn("9", "storage_month", now.Add(time.Hour))
+	require.False(t, svc.applyPlanChange(basic, other))
+
+	// Reclassifying after a predecessor arrived drops a stale link.
+	stale := txn("10", "pro_month", now.Add(month))
+	stale.BeforeUpgradedTransactionID = &basic.TransactionID
+	stale.Extra.Data().PlanChange = types.PlanChangeUpgrade
+	require.True(t, svc.applyPlanChange(renewal, stale))
+	require.Nil(t, stale.BeforeUpgradedTransactionID)
+	require.Empty(t, planChangeReason(stale))
+}
+
+func TestChainNeighbours(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	chain := []*models.Transaction{
+		{TransactionID: "1", PurchaseAt: now},
+		{TransactionID: "2", PurchaseAt: now.Add(time.Hour)},
+		{TransactionID: "4", PurchaseAt: now.Add(3 * time.Hour)},
+	}
+
+	prev, next := chainNeighbours(chain, &models.Transaction{TransactionID: "3", PurchaseAt: now.Add(2 * time.Hour)})
+	require.Equal(t, "2", prev.TransactionID)
+	require.Equal(t, "4", next.TransactionID)
+
+	prev, next = chainNeighbours(chain, &models.Transaction{TransactionID: "0", PurchaseAt: now.Add(-time.Hour)})
+	require.Nil(t, prev)
+	require.Equal(t, "1", next.TransactionID)
+
+	// Transactions purchased at the same time are ordered by transaction ID.
+	prev, next = chainNeighbours(chain, &models.Transaction{TransactionID: "3", PurchaseAt: now.Add(3 * time.Hour)})
+	require.Equal(t, "2", prev.TransactionID)
+	require.Equal(t, "4", next.TransactionID)
+}
+
+func TestApplyPlanChange_KeepsLinksOfUngroupedItems(t *testing.T) {
+	now := time.Unix(1735689600, 0) // 2025-01-01 UTC
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "basic_month", Type: types.PaymentItemTypeAutoRenewableSubscription},
+		{ID: "pro_month", Type: types.PaymentItemTypeAutoRenewableSubscription},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+	basic := &models.Transaction{ProviderID: types.PaymentProviderApple, PaymentItemID: "basic_month", TransactionID: "1", PurchaseAt: now}
+	upgrade := &models.Transaction{
+		ProviderID:                  types.PaymentProviderApple,
+		PaymentItemID:               "pro_month",
+		TransactionID:               "2",
+		PurchaseAt:                  now.Add(24 * time.Hour),
+		BeforeUpgradedTransactionID: &basic.TransactionID,
+		Extra:                       datatypes.NewJSONType(&models.UserSubscriptionItemExtra{}),
+	}
+
+	// The items are in no subscription group, so they do not compare, and the upgrade recorded by the provider
+	// still replaces the first period.
+	require.False(t, svc.applyPlanChange(basic, upgrade))
+	require.Equal(t, "1", *upgrade.BeforeUpgradedTransactionID)
+	require.Empty(t, upgrade.Extra.Data().PlanChange)
+
+	upgrade.Extra = datatypes.JSONType[*models.UserSubscriptionItemExtra]{}
+	require.False(t, svc.applyPlanChange(basic, upgrade))
+	require.Equal(t, "1", *upgrade.BeforeUpgradedTransactionID)
+}
@@ -0,0 +1,24 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+)
+
+// ListTransactionRefunds returns the refunds and refund reversals of a transaction, oldest first.
+func (s *Service) ListTransactionRefunds(ctx context.Context, providerID types.PaymentProvider, transactionID string) ([]*models.TransactionRefund, error) {
+	if providerID == "" || transactionID == "" {
+		return nil, fmt.Errorf("invalid params: provider_id and transaction_id required")
+	}
+	var refunds []*models.TransactionRefund
+	if err := s.db.WithContext(ctx).
+		Where("provider_id = ? AND transaction_id = ?", providerID, transactionID).
+		Order("created_at, id").
+		Find(&refunds).Error; err != nil {
+		return nil, fmt.Errorf("failed to list transaction refunds: %w", err)
+	}
+	return refunds, nil
+}
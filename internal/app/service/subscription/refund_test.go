@@ -0,0 +1,58 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewTransactionRefund(t *testing.T) {
+	refundAt := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	active := &models.Transaction{UserID: "u1", ProviderID: types.PaymentProviderApple, TransactionID: "1000", PaymentItemID: "vip"}
+	refunded := *active
+	refunded.RefundAt = &refundAt
+	refunded.RevocationReason = lo.ToPtr(models.RevocationReasonRefundAppIssue)
+
+	refund := newTransactionRefund(active, &refunded)
+	require.NotNil(t, refund)
+	require.Equal(t, models.TransactionRefundEventRefunded, refund.Event)
+	require.True(t, refundAt.Equal(refund.RefundAt))
+	require.Equal(t, models.RevocationReasonRefundAppIssue, *refund.Reason)
+	require.Equal(t, "1000", refund.TransactionID)
+
+	// A refunded transaction seen for the first time is recorded too.
+	require.NotNil(t, newTransactionRefund(nil, &refunded))
+
+	reversed := newTransactionRefund(&refunded, active)
+	require.NotNil(t, reversed)
+	require.Equal(t, models.TransactionRefundEventReversed, reversed.Event)
+	require.True(t, refundAt.Equal(reversed.RefundAt))
+
+	require.Nil(t, newTransactionRefund(&refunded, &refunded))
+	require.Nil(t, newTransactionRefund(active, active))
+	require.Nil(t, newTransactionRefund(nil, active))
+}
+
+func TestGetChangeReason_RefundReversed(t *testing.T) {
+	snapshot := &types.PaymentItem{ID: "vip", Type: types.PaymentItemTypeAutoRenewableSubscription}
+	svc := NewService(&config.Config{PaymentItems: []*types.PaymentItem{snapshot}}, nil, zap.NewNop().Sugar())
+	refundAt := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	renewAt := refundAt.Add(30 * 24 * time.Hour)
+	original := &models.Transaction{PaymentItemID: "vip", RefundAt: &refundAt}
+	item := &models.Transaction{PaymentItemID: "vip", NextAutoRenewAt: &renewAt}
+
+	reason, err := svc.getChangeReason(context.Background(), original, item)
+	require.NoError(t, err)
+	require.Equal(t, types.UserSubscriptionChangeReasonRefundReversed, reason)
+
+	reason, err = svc.getChangeReason(context.Background(), nil, item)
+	require.NoError(t, err)
+	require.Equal(t, types.UserSubscriptionChangeReasonPurchase, reason)
+}
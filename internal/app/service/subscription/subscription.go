@@ -3,6 +3,8 @@ package subscription
 import (
 	"context"
 	"fmt"
+	"github.com/fatflowers/cashier/internal/app/service/outbox"
+	"github.com/fatflowers/cashier/internal/app/service/wallet"
 	models "github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/pkg/logctx"
 	"github.com/fatflowers/cashier/pkg/tool"
@@ -11,21 +13,26 @@ import (
 
 	"errors"
 
+	"github.com/samber/lo"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
-// getChangeReason determines the subscription change reason from a transaction
-func (s *Service) getChangeReason(ctx context.Context, item *models.Transaction) (types.SubscriptionChangeReason, error) {
+// getChangeReason determines the subscription change reason from a transaction and its stored version,
+// which is nil for a new transaction.
+func (s *Service) getChangeReason(ctx context.Context, original, item *models.Transaction) (types.SubscriptionChangeReason, error) {
 	if item.RefundAt != nil {
 		return types.UserSubscriptionChangeReasonRefund, nil
 	}
-	paymentItem := item.GetPaymentItemSnapshot()
+	if original != nil && original.RefundAt != nil {
+		return types.UserSubscriptionChangeReasonRefundReversed, nil
+	}
+	paymentItem := s.purchasedItem(item)
 	if paymentItem == nil {
-		paymentItem = s.cfg.GetPaymentItemByID(item.PaymentItemID)
-		if paymentItem == nil {
-			return types.UserSubscriptionChangeReasonPurchase, fmt.Errorf("payment item not found: %s", item.PaymentItemID)
-		}
+		return types.UserSubscriptionChangeReasonPurchase, fmt.Errorf("payment item not found: %s", item.PaymentItemID)
+	}
+	if reason := planChangeReason(item); reason != "" {
+		return reason, nil
 	}
 	if item.BeforeUpgradedTransactionID != nil && *item.BeforeUpgradedTransactionID != "" {
 		return types.UserSubscriptionChangeReasonUpgrade, nil
@@ -38,6 +45,16 @@ func (s *Service) getChangeReason(ctx context.Context, item *models.Transaction)
 	return types.UserSubscriptionChangeReasonPurchase, nil
 }
 
+// purchasedItem returns the item as it was bought, or the catalog entry for transactions without a snapshot.
+// Every use of the item of a stored transaction goes through it, so classification, entitlements, duration
+// and credits all come from the same version of the item after the catalog changes.
+func (s *Service) purchasedItem(txn *models.Transaction) *types.PaymentItem {
+	if item := txn.GetPaymentItemSnapshot(); item != nil {
+		return item
+	}
+	return s.cfg.GetPaymentItemByID(txn.PaymentItemID)
+}
+
 // GetUserActiveSubscriptionItems returns all active subscription items at queryAt for a user.
 func (s *Service) GetUserActiveSubscriptionItems(ctx context.Context, userID string, queryAt time.Time) ([]*UserSubscriptionItem, error) {
 	items, err := s.GetAllUserTransactions(ctx, userID)
@@ -47,27 +64,100 @@ func (s *Service) GetUserActiveSubscriptionItems(ctx context.Context, userID str
 	return s.getAllActiveUserSubscriptionItems(ctx, items, queryAt)
 }
 
+// userLockClass namespaces the per-user advisory locks from other advisory lock users.
+const userLockClass int32 = 0x63617368
+
+// lockUser blocks until tx holds the recomputation lock of userID; it is released when tx ends.
+// An advisory lock is used instead of a row lock because a first purchase has no subscription row yet.
+func lockUser(ctx context.Context, tx *gorm.DB, userID string) error {
+	if err := tx.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", userLockClass, userID).Error; err != nil {
+		return fmt.Errorf("failed to lock user %s: %w", userID, err)
+	}
+	return nil
+}
+
+type upsertOptions struct {
+	reason types.SubscriptionChangeReason
+	check  func(stored *models.Transaction) error
+}
+
+// ErrUpsertSkipped is returned by UpsertUserSubscriptionByItem when a WithStoredCheck check declined the upsert.
+var ErrUpsertSkipped = errors.New("upsert skipped")
+
+// UpsertOption customizes UpsertUserSubscriptionByItem.
+type UpsertOption func(*upsertOptions)
+
+// WithChangeReason records reason on the change logs and membership events instead of the reason derived
+// from the transaction.
+func WithChangeReason(reason types.SubscriptionChangeReason) UpsertOption {
+	return func(o *upsertOptions) { o.reason = reason }
+}
+
+// WithStoredCheck calls check with the stored version of the transaction, or nil, once the user is locked
+// and before anything is written, so check sees the state the upsert replaces. check may adjust the
+// transaction; an error aborts the upsert and is returned, ErrUpsertSkipped meaning nothing is left to apply.
+func WithStoredCheck(check func(stored *models.Transaction) error) UpsertOption {
+	return func(o *upsertOptions) { o.check = check }
+}
+
 // UpsertUserSubscriptionByItem updates user subscription state based on a transaction.
-func (s *Service) UpsertUserSubscriptionByItem(ctx context.Context, item *models.Transaction) error {
-	var subscriptionUpdated bool
-	var subscription *models.Subscription
+// Calls for the same user are serialized, so each recomputation sees every transaction committed before it.
+func (s *Service) UpsertUserSubscriptionByItem(ctx context.Context, item *models.Transaction, opts ...UpsertOption) error {
+	var o upsertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 	var reason types.SubscriptionChangeReason
 
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var err error
 
-		reason, err = s.getChangeReason(ctx, item)
+		if err = lockUser(ctx, tx, item.UserID); err != nil {
+			return err
+		}
+
+		original, err := s.findTransaction(ctx, tx, item.ProviderID, item.TransactionID)
 		if err != nil {
-			return fmt.Errorf("failed to get change reason: %w", err)
+			return err
+		}
+		if o.check != nil {
+			if err := o.check(original); err != nil {
+				return err
+			}
 		}
 
-		if err = s.upsertTransaction(ctx, tx, item, reason); err != nil {
-			return fmt.Errorf("failed to upsert transaction: %w", err)
+		// Plan changes are classified against the stored chain, so they do not depend on the order
+		// transactions arrive in.
+		chain, err := s.getRenewalChainWithTx(ctx, tx, item)
+		if err != nil {
+			return err
+		}
+		prev, next := chainNeighbours(chain, item)
+		if prev == nil {
+			inheritPlanChange(original, item)
 		}
+		s.applyPlanChange(prev, item)
 
-		pgItems, err := s.getAllUserTransactionsWithTx(ctx, tx, item.UserID)
+		reason, err = s.getChangeReason(ctx, original, item)
 		if err != nil {
-			return fmt.Errorf("failed to get user transactions: %w", err)
+			return fmt.Errorf("failed to get change reason: %w", err)
+		}
+		if o.reason != "" {
+			reason = o.reason
+		}
+
+		if err = s.upsertTransaction(ctx, tx, original, item, reason); err != nil {
+			return fmt.Errorf("failed to upsert transaction: %w", err)
+		}
+		// A transaction stored before item arrived was classified without it.
+		if next != nil && s.applyPlanChange(item, next) {
+			if err := tx.WithContext(ctx).Model(next).Select("before_upgraded_transaction_id", "extra").Updates(next).Error; err != nil {
+				return fmt.Errorf("failed to reclassify transaction %s: %w", next.TransactionID, err)
+			}
+		}
+		// Credits follow the item as it was bought.
+		if err := wallet.SyncPurchase(ctx, tx, item, s.purchasedItem(item)); err != nil {
+			return fmt.Errorf("failed to sync credits: %w", err)
 		}
 
 		processTime := time.Now()
@@ -75,63 +165,91 @@ func (s *Service) UpsertUserSubscriptionByItem(ctx context.Context, item *models
 			processTime = item.PurchaseAt
 		}
 
-		items, err := s.getAllActiveUserSubscriptionItems(ctx, pgItems, processTime)
-		if err != nil {
-			return fmt.Errorf("failed to get active subscription items: %w", err)
-		}
-
 		logctx.FromCtx(ctx, s.log).Infof("upsert user subscription by item, user_id=%s, item_id=%s, reason=%s", item.UserID, item.ID, reason)
 
-		if err := s.rebuildUserMembershipActiveItems(ctx, tx, item.UserID, items); err != nil {
-			return fmt.Errorf("failed to rebuild user membership active items: %w", err)
-		}
+		return s.recomputeSubscription(ctx, tx, item.UserID, processTime, reason)
+	})
 
-		if len(items) == 0 {
-			subscription = &models.Subscription{
-				UserID: item.UserID,
-				Status: types.SubscriptionStatusInactive,
-			}
+	if err != nil {
+		return fmt.Errorf("failed to UpsertUserMembershipByItem: %w", err)
+	}
 
-			// business hook can be invoked after Tx commit
-			if err := s.cancelMembership(ctx, tx, item.UserID, reason); err != nil {
-				return err
-			}
-		} else {
-			// set active subscription with last expireAt
-			lastExpire := items[len(items)-1].ExpireAt
-			subscription = &models.Subscription{
-				UserID:   item.UserID,
-				Status:   types.SubscriptionStatusActive,
-				ExpireAt: &lastExpire,
-			}
-			for i := len(items) - 1; i >= 0; i-- {
-				if items[i].NextAutoRenewAt != nil {
-					if items[i].NextAutoRenewAt.After(processTime) {
-						subscription.NextAutoRenewAt = items[i].NextAutoRenewAt
-					}
-					break
-				}
-			}
+	return nil
+}
 
-			updated, err := s.upsertSubscription(ctx, tx, subscription, reason)
-			if err != nil {
-				return err
+// recomputeSubscription rebuilds the active periods and the subscription of userID at processTime from the
+// stored transactions. tx must hold the user lock.
+func (s *Service) recomputeSubscription(ctx context.Context, tx *gorm.DB, userID string, processTime time.Time, reason types.SubscriptionChangeReason) error {
+	pgItems, err := s.getAllUserTransactionsWithTx(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user transactions: %w", err)
+	}
+
+	items, err := s.getAllActiveUserSubscriptionItems(ctx, pgItems, processTime)
+	if err != nil {
+		return fmt.Errorf("failed to get active subscription items: %w", err)
+	}
+
+	if err := s.rebuildUserMembershipActiveItems(ctx, tx, userID, items); err != nil {
+		return fmt.Errorf("failed to rebuild user membership active items: %w", err)
+	}
+
+	status := resolveSubscriptionStatus(items, pgItems, processTime)
+
+	if len(items) == 0 {
+		// business hook can be invoked after Tx commit
+		return s.cancelMembership(ctx, tx, userID, status, reason)
+	}
+
+	// set subscription with last expireAt
+	lastExpire := items[len(items)-1].ExpireAt
+	subscription := &models.Subscription{
+		UserID:   userID,
+		Status:   status,
+		ExpireAt: &lastExpire,
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].NextAutoRenewAt != nil {
+			if items[i].NextAutoRenewAt.After(processTime) {
+				subscription.NextAutoRenewAt = items[i].NextAutoRenewAt
 			}
-			subscriptionUpdated = updated
+			break
 		}
+	}
+	return s.upsertSubscription(ctx, tx, subscription, reason)
+}
 
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to UpsertUserMembershipByItem: %w", err)
+// resolveSubscriptionStatus derives the subscription status at now from the active periods and all
+// transactions of a user.
+func resolveSubscriptionStatus(items []*UserSubscriptionItem, txns []*models.Transaction, now time.Time) types.SubscriptionStatus {
+	for _, item := range items {
+		if item.ActivatedAt.After(now) || !item.ExpireAt.After(now) {
+			continue
+		}
+		if item.inGracePeriod(now) {
+			return types.SubscriptionStatusGracePeriod
+		}
+		return types.SubscriptionStatusActive
 	}
 
-	if subscriptionUpdated {
-		go s.handleMembershipChange(ctx, subscription, reason, item)
+	// Without access, the latest auto-renewable purchase tells whether the provider is still retrying billing.
+	var latest *models.Transaction
+	for _, txn := range txns {
+		if txn == nil || txn.RefundAt != nil || txn.AutoRenewExpireAt == nil || txn.PurchaseAt.After(now) {
+			continue
+		}
+		if latest == nil || txn.PurchaseAt.After(latest.PurchaseAt) {
+			latest = txn
+		}
+	}
+	if latest.IsInBillingRetry() {
+		return types.SubscriptionStatusBillingRetry
 	}
 
-	return nil
+	if len(items) > 0 {
+		return types.SubscriptionStatusActive
+	}
+	return types.SubscriptionStatusInactive
 }
 
 // Data access helpers.
@@ -163,19 +281,25 @@ func (s *Service) rebuildUserMembershipActiveItems(ctx context.Context, tx *gorm
 	return nil
 }
 
-func (s *Service) upsertTransaction(ctx context.Context, tx *gorm.DB, item *models.Transaction, changeReason types.SubscriptionChangeReason) error {
+// findTransaction returns the stored transaction, or nil when it does not exist yet.
+func (s *Service) findTransaction(ctx context.Context, tx *gorm.DB, providerID types.PaymentProvider, transactionID string) (*models.Transaction, error) {
 	var original models.Transaction
 	err := tx.WithContext(ctx).
-		Where("provider_id = ? AND transaction_id = ?", item.ProviderID, item.TransactionID).
+		Where("provider_id = ? AND transaction_id = ?", providerID, transactionID).
 		First(&original).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load original transaction: %w", err)
+	}
+	return &original, nil
+}
 
+func (s *Service) upsertTransaction(ctx context.Context, tx *gorm.DB, original, item *models.Transaction, changeReason types.SubscriptionChangeReason) error {
 	created := false
 
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("failed to load original transaction: %w", err)
-	}
-
-	if err == nil && original.ID != "" {
+	if original != nil {
 		// Preserve ID and important extra fields
 		item.ID = original.ID
 		// Safely copy extra content
@@ -213,35 +337,61 @@ func (s *Service) upsertTransaction(ctx context.Context, tx *gorm.DB, item *mode
 		return fmt.Errorf("failed to upsert transaction: %w", err)
 	}
 
-	// Write change log asynchronously; errors are logged but not returned
-	go func(before *models.Transaction, after *models.Transaction, reason types.SubscriptionChangeReason) {
-		log := &models.TransactionLog{
-			ID:            tool.GenerateUUIDV7(),
-			UserID:        after.UserID,
-			PaymentItemID: after.PaymentItemID,
-			ProviderID:    after.ProviderID,
-			TransactionID: after.TransactionID,
-			Reason:        reason,
-			Before:        datatypes.NewJSONType(before),
-			After:         datatypes.NewJSONType(after),
-			Extra:         datatypes.JSONMap{},
-		}
-		if err := s.db.Save(log).Error; err != nil {
-			logctx.FromCtx(ctx, s.log).Errorf("failed to save transaction log: %v", err)
-		}
-	}(func() *models.Transaction {
-		if original.ID == "" {
-			return nil
-		}
-		return &original
-	}(), item, changeReason)
+	// Change log goes through the outbox so it commits with the transaction.
+	if err := outbox.Enqueue(ctx, tx, outbox.TopicTransactionLog, &models.TransactionLog{
+		ID:            tool.GenerateUUIDV7(),
+		UserID:        item.UserID,
+		PaymentItemID: item.PaymentItemID,
+		ProviderID:    item.ProviderID,
+		TransactionID: item.TransactionID,
+		Reason:        changeReason,
+		Before:        datatypes.NewJSONType(original),
+		After:         datatypes.NewJSONType(item),
+		Extra:         datatypes.JSONMap{},
+		CreatedAt:     time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to write transaction log: %w", err)
+	}
 
 	if created && changeReason == types.UserSubscriptionChangeReasonRefund {
 		logctx.FromCtx(ctx, s.log).Errorf("created refunded transaction not found previously: provider=%s txid=%s user=%s", item.ProviderID, item.TransactionID, item.UserID)
 	}
+
+	if refund := newTransactionRefund(original, item); refund != nil {
+		if err := tx.WithContext(ctx).Create(refund).Error; err != nil {
+			return fmt.Errorf("failed to write transaction refund: %w", err)
+		}
+	}
 	return nil
 }
 
+// newTransactionRefund returns the refund history entry for a change of the refund state of item, or nil
+// when it did not change.
+func newTransactionRefund(original, item *models.Transaction) *models.TransactionRefund {
+	wasRefunded := original != nil && original.RefundAt != nil
+	refund := &models.TransactionRefund{
+		ID:            tool.GenerateUUIDV7(),
+		UserID:        item.UserID,
+		ProviderID:    item.ProviderID,
+		TransactionID: item.TransactionID,
+		PaymentItemID: item.PaymentItemID,
+		CreatedAt:     time.Now(),
+	}
+	switch {
+	case item.RefundAt != nil && !wasRefunded:
+		refund.Event = models.TransactionRefundEventRefunded
+		refund.RefundAt = *item.RefundAt
+		refund.Reason = item.RevocationReason
+	case item.RefundAt == nil && wasRefunded:
+		refund.Event = models.TransactionRefundEventReversed
+		refund.RefundAt = *original.RefundAt
+		refund.Reason = original.RevocationReason
+	default:
+		return nil
+	}
+	return refund
+}
+
 func (s *Service) GetAllUserTransactions(ctx context.Context, userID string) ([]*models.Transaction, error) {
 	var items []*models.Transaction
 	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("purchase_at desc").Find(&items).Error; err != nil {
@@ -258,12 +408,12 @@ func (s *Service) getAllUserTransactionsWithTx(ctx context.Context, tx *gorm.DB,
 	return items, nil
 }
 
-func (s *Service) upsertSubscription(ctx context.Context, tx *gorm.DB, m *models.Subscription, reason types.SubscriptionChangeReason) (bool, error) {
+func (s *Service) upsertSubscription(ctx context.Context, tx *gorm.DB, m *models.Subscription, reason types.SubscriptionChangeReason) error {
 	// Load existing subscription by user_id
 	var original models.Subscription
 	if err := tx.WithContext(ctx).Where("user_id = ?", m.UserID).First(&original).Error; err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, fmt.Errorf("failed to get original subscription: %w", err)
+			return fmt.Errorf("failed to get original subscription: %w", err)
 		}
 	}
 
@@ -286,30 +436,55 @@ func (s *Service) upsertSubscription(ctx context.Context, tx *gorm.DB, m *models
 	}()
 
 	if err := tx.WithContext(ctx).Save(m).Error; err != nil {
-		return false, fmt.Errorf("failed to upsert subscription: %w", err)
+		return fmt.Errorf("failed to upsert subscription: %w", err)
 	}
 
-	updated := (before != nil && before.Valid() != m.Valid()) || (before == nil && m.Valid())
+	if err := outbox.Enqueue(ctx, tx, outbox.TopicSubscriptionLog, &models.SubscriptionLog{
+		ID:        tool.GenerateUUIDV7(),
+		UserID:    m.UserID,
+		Reason:    reason,
+		Before:    datatypes.NewJSONType(before),
+		After:     datatypes.NewJSONType(m),
+		Extra:     datatypes.JSONMap{},
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to write subscription log: %w", err)
+	}
 
-	// async log
-	go func(b *models.Subscription, a *models.Subscription) {
-		log := &models.SubscriptionLog{
-			ID:     tool.GenerateUUIDV7(),
-			UserID: a.UserID,
-			Reason: reason,
-			Before: datatypes.NewJSONType(b),
-			After:  datatypes.NewJSONType(a),
-			Extra:  datatypes.JSONMap{},
+	if event := newMembershipChangeEvent(before, m, reason); event != nil {
+		if err := outbox.Enqueue(ctx, tx, outbox.TopicMembershipChanged, event); err != nil {
+			return fmt.Errorf("failed to write membership change event: %w", err)
 		}
-		if err := s.db.Save(log).Error; err != nil {
-			logctx.FromCtx(ctx, s.log).Errorf("failed to save subscription log: %v", err)
-		}
-	}(before, m)
+	}
+
+	return nil
+}
 
-	return updated, nil
+// newMembershipChangeEvent returns the event for subscribers, or nil when neither status nor expiry changed.
+func newMembershipChangeEvent(before, after *models.Subscription, reason types.SubscriptionChangeReason) *types.MembershipChangeEvent {
+	event := &types.MembershipChangeEvent{
+		ID:           tool.GenerateUUIDV7(),
+		Type:         types.MembershipEventTypeChanged,
+		UserID:       after.UserID,
+		BeforeStatus: types.SubscriptionStatusInactive,
+		AfterStatus:  after.Status,
+		ExpireAt:     after.ExpireAt,
+		Reason:       reason,
+		OccurredAt:   time.Now(),
+	}
+	if before != nil {
+		event.BeforeStatus = before.Status
+		event.BeforeExpireAt = before.ExpireAt
+	}
+	if event.BeforeStatus == event.AfterStatus && lo.FromPtr(event.BeforeExpireAt).Equal(lo.FromPtr(event.ExpireAt)) {
+		return nil
+	}
+	return event
 }
 
-func (s *Service) cancelMembership(ctx context.Context, tx *gorm.DB, userID string, reason types.SubscriptionChangeReason) error {
+// cancelMembership clears the expiry of the user's subscription and sets it to status, which is either
+// inactive or billing_retry.
+func (s *Service) cancelMembership(ctx context.Context, tx *gorm.DB, userID string, status types.SubscriptionStatus, reason types.SubscriptionChangeReason) error {
 	var subscription models.Subscription
 	if err := tx.WithContext(ctx).Where("user_id = ?", userID).First(&subscription).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -318,21 +493,16 @@ func (s *Service) cancelMembership(ctx context.Context, tx *gorm.DB, userID stri
 		return fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	subscription.Status = types.SubscriptionStatusInactive
+	subscription.Status = status
 	subscription.NextAutoRenewAt = nil
 	subscription.ExpireAt = nil
 
-	if _, err := s.upsertSubscription(ctx, tx, &subscription, reason); err != nil {
+	if err := s.upsertSubscription(ctx, tx, &subscription, reason); err != nil {
 		return fmt.Errorf("failed to upsert subscription: %w", err)
 	}
 	return nil
 }
 
-// handleMembershipChange is a placeholder for post-subscription-state-change actions.
-func (s *Service) handleMembershipChange(ctx context.Context, subscription *models.Subscription, reason types.SubscriptionChangeReason, item *models.Transaction) {
-	// no-op: integrate notification hooks here if needed
-}
-
 // SendFreeGift grants an internal gift (for example, a free membership card).
 func (s *Service) SendFreeGift(ctx context.Context, userID, paymentItemID, operatorID string) error {
 	if userID == "" || paymentItemID == "" {
@@ -342,6 +512,9 @@ func (s *Service) SendFreeGift(ctx context.Context, userID, paymentItemID, opera
 	if paymentItem == nil {
 		return fmt.Errorf("payment item not found: %s", paymentItemID)
 	}
+	if paymentItem.IsArchived() {
+		return fmt.Errorf("payment item is archived: %s", paymentItemID)
+	}
 
 	txn := &models.Transaction{
 		UserID:        userID,
@@ -26,7 +26,7 @@ func TestGetChangeReason_Upgrade(t *testing.T) {
 	}
 
 	svc := NewService(&config.Config{}, nil, zap.NewNop().Sugar())
-	reason, err := svc.getChangeReason(context.Background(), item)
+	reason, err := svc.getChangeReason(context.Background(), nil, item)
 	require.NoError(t, err)
 	require.Equal(t, types.UserSubscriptionChangeReasonUpgrade, reason)
 }
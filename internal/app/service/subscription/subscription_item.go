@@ -31,8 +31,11 @@ type UserSubscriptionItem struct {
 	RemainingDurationSeconds int64 `json:"remaining_duration_seconds"`
 	// ActivatedAt is the effective start time.
 	ActivatedAt time.Time `json:"activated_at"`
-	// ExpireAt is the expiration time.
+	// ExpireAt is the expiration time. For an auto-renewable period in a grace period it is the end of the grace period.
 	ExpireAt time.Time `json:"expire_at"`
+	// GracePeriodExpireAt is set when the provider keeps access after a failed renewal of this period.
+	// It is only taken from the latest transaction of a renewal chain.
+	GracePeriodExpireAt *time.Time `json:"grace_period_expire_at,omitempty"`
 }
 
 func providerTransactionKey(provider types.PaymentProvider, transactionID string) string {
@@ -56,16 +59,34 @@ func (item *UserSubscriptionItem) ToUserMembershipActiveItem() *models.UserMembe
 	}
 }
 
+// inGracePeriod reports whether at falls after the paid period but within the grace period. Providers that
+// already report the grace period end as the expiry (Google) have a grace period ending at AutoRenewExpireAt.
+func (item *UserSubscriptionItem) inGracePeriod(at time.Time) bool {
+	if item == nil || item.GracePeriodExpireAt == nil || item.AutoRenewExpireAt == nil || !item.ExpireAt.After(at) {
+		return false
+	}
+	return !item.AutoRenewExpireAt.After(at) || !item.GracePeriodExpireAt.After(*item.AutoRenewExpireAt)
+}
+
+// renewalChainKey groups the renewals of one auto-renewable subscription.
+func renewalChainKey(txn *models.Transaction) string {
+	if txn.ParentTransactionID != nil && *txn.ParentTransactionID != "" {
+		return providerTransactionKey(txn.ProviderID, *txn.ParentTransactionID)
+	}
+	return providerTransactionKey(txn.ProviderID, txn.TransactionID)
+}
+
 func (s *Service) compareUserMembershipItemByPurchaseAt(a, b *models.Transaction) int {
 	return a.PurchaseAt.Compare(b.PurchaseAt)
 }
 
-func (s *Service) processNonRenewableSubscription(result []*UserSubscriptionItem, paymentItem *types.PaymentItem, item *UserSubscriptionItem, queryAt time.Time) ([]*UserSubscriptionItem, error) {
+// processNonRenewableSubscription appends item to result. skipped reports that the item was dropped as refunded.
+func (s *Service) processNonRenewableSubscription(result []*UserSubscriptionItem, paymentItem *types.PaymentItem, item *UserSubscriptionItem, queryAt time.Time) (res []*UserSubscriptionItem, skipped bool, err error) {
 	item.ActivatedAt = item.PurchaseAt
 	if paymentItem.DurationHour != nil {
 		item.RemainingDurationSeconds = int64(*paymentItem.DurationHour * 60 * 60)
 	} else {
-		return nil, fmt.Errorf("duration is nil for non renewable subscription")
+		return nil, false, fmt.Errorf("duration is nil for non renewable subscription")
 	}
 	item.ExpireAt = item.ActivatedAt.Add(time.Duration(item.RemainingDurationSeconds) * time.Second)
 
@@ -81,23 +102,27 @@ func (s *Service) processNonRenewableSubscription(result []*UserSubscriptionItem
 
 	// Skip refunded items when the computed expiration is still after queryAt.
 	if item.RefundAt != nil && item.ExpireAt.After(queryAt) {
-		return result, nil
+		return result, true, nil
 	}
 
-	return append(result, item), nil
+	return append(result, item), false, nil
 }
 
-func (s *Service) processAutoRenewableSubscription(result []*UserSubscriptionItem, item *UserSubscriptionItem, queryAt time.Time) ([]*UserSubscriptionItem, error) {
+// processAutoRenewableSubscription inserts item into result. skipped reports that the item was dropped as refunded.
+func (s *Service) processAutoRenewableSubscription(result []*UserSubscriptionItem, item *UserSubscriptionItem, queryAt time.Time) (res []*UserSubscriptionItem, skipped bool, err error) {
 	// Skip refunded items when the computed expiration is still after queryAt.
 	if item.RefundAt != nil && item.AutoRenewExpireAt.After(queryAt) {
-		return result, nil
+		return result, true, nil
 	}
 
 	item.ActivatedAt = item.PurchaseAt
 	if item.AutoRenewExpireAt != nil {
 		item.ExpireAt = *item.AutoRenewExpireAt
+		if item.GracePeriodExpireAt != nil && item.GracePeriodExpireAt.After(item.ExpireAt) {
+			item.ExpireAt = *item.GracePeriodExpireAt
+		}
 	} else {
-		return nil, fmt.Errorf("auto renew expire at is nil for auto renewable subscription")
+		return nil, false, fmt.Errorf("auto renew expire at is nil for auto renewable subscription")
 	}
 
 	item.RemainingDurationSeconds = int64(item.ExpireAt.Sub(item.PurchaseAt).Seconds())
@@ -137,7 +162,7 @@ func (s *Service) processAutoRenewableSubscription(result []*UserSubscriptionIte
 		}
 	}
 
-	return result, nil
+	return result, false, nil
 }
 
 // selectLastActivePeriods filters and returns the last contiguous active periods.
@@ -175,12 +200,32 @@ func (s *Service) selectLastActivePeriods(items []*UserSubscriptionItem) ([]*Use
 // pgItems: subscription items loaded from database.
 // queryAt: point-in-time used for evaluation.
 func (s *Service) getAllActiveUserSubscriptionItems(ctx context.Context, pgItems []*models.Transaction, queryAt time.Time) ([]*UserSubscriptionItem, error) {
+	timeline, err := s.buildSubscriptionTimeline(ctx, pgItems, queryAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(timeline.periods) == 0 {
+		return nil, nil
+	}
+	return s.selectLastActivePeriods(timeline.periods)
+}
+
+// subscriptionTimeline is every period computed from a user's transactions, before selecting the last active chain.
+type subscriptionTimeline struct {
+	periods []*UserSubscriptionItem
+	skipped []*SkippedTransaction
+}
+
+// buildSubscriptionTimeline lays the transactions purchased up to queryAt out as periods and records
+// the transactions that did not produce one.
+func (s *Service) buildSubscriptionTimeline(ctx context.Context, pgItems []*models.Transaction, queryAt time.Time) (*subscriptionTimeline, error) {
 	if queryAt.IsZero() {
 		return nil, fmt.Errorf("invalid queryAt: zero value")
 	}
 
+	timeline := &subscriptionTimeline{}
 	if len(pgItems) == 0 {
-		return nil, nil
+		return timeline, nil
 	}
 
 	slices.SortStableFunc(pgItems, s.compareUserMembershipItemByPurchaseAt)
@@ -198,39 +243,59 @@ func (s *Service) getAllActiveUserSubscriptionItems(ctx context.Context, pgItems
 		upgradedBefore[providerTransactionKey(pgItem.ProviderID, *pgItem.BeforeUpgradedTransactionID)] = struct{}{}
 	}
 
+	// A grace period only applies to the latest renewal of its chain; once the provider recovers billing,
+	// the new renewal replaces it.
+	latestInChain := make(map[string]*models.Transaction)
+	for _, pgItem := range pgItems {
+		if pgItem == nil || pgItem.PurchaseAt.After(queryAt) {
+			continue
+		}
+		latestInChain[renewalChainKey(pgItem)] = pgItem
+	}
+
 	var result []*UserSubscriptionItem
 
-	for _, pgItem := range pgItems {
+	for index, pgItem := range pgItems {
 		if pgItem == nil {
 			continue
 		}
 		// Stop when purchase time is after queryAt.
 		if pgItem.PurchaseAt.After(queryAt) {
+			for _, later := range pgItems[index:] {
+				if later != nil {
+					timeline.skipped = append(timeline.skipped, &SkippedTransaction{Transaction: later, Reason: TimelineSkipReasonPurchasedLater})
+				}
+			}
 			break
 		}
 		if _, skipped := upgradedBefore[providerTransactionKey(pgItem.ProviderID, pgItem.TransactionID)]; skipped {
+			timeline.skipped = append(timeline.skipped, &SkippedTransaction{Transaction: pgItem, Reason: TimelineSkipReasonUpgraded})
 			continue
 		}
 
 		item := &UserSubscriptionItem{
 			Transaction: *pgItem,
 		}
+		if latestInChain[renewalChainKey(pgItem)] == pgItem {
+			item.GracePeriodExpireAt = pgItem.GetGracePeriodExpireAt()
+		}
 
-		paymentItem := pgItem.GetPaymentItemSnapshot()
+		paymentItem := s.purchasedItem(pgItem)
 		if paymentItem == nil {
-			paymentItem = s.cfg.GetPaymentItemByID(pgItem.PaymentItemID)
-			if paymentItem == nil {
-				return nil, fmt.Errorf("failed to get payment item by id: %s", pgItem.PaymentItemID)
-			}
+			return nil, fmt.Errorf("failed to get payment item by id: %s", pgItem.PaymentItemID)
 		}
 
 		var err error
+		var refunded bool
 		// Branch early by payment item type.
 		switch paymentItem.Type {
 		case types.PaymentItemTypeNonRenewableSubscription:
-			result, err = s.processNonRenewableSubscription(result, paymentItem, item, queryAt)
+			result, refunded, err = s.processNonRenewableSubscription(result, paymentItem, item, queryAt)
 		case types.PaymentItemTypeAutoRenewableSubscription:
-			result, err = s.processAutoRenewableSubscription(result, item, queryAt)
+			result, refunded, err = s.processAutoRenewableSubscription(result, item, queryAt)
+		case types.PaymentItemTypeConsumable, types.PaymentItemTypeNonConsumable:
+			timeline.skipped = append(timeline.skipped, &SkippedTransaction{Transaction: pgItem, Reason: TimelineSkipReasonNotSubscription})
+			continue
 		default:
 			return nil, fmt.Errorf("unsupported payment item type: %s", paymentItem.Type)
 		}
@@ -238,7 +303,11 @@ func (s *Service) getAllActiveUserSubscriptionItems(ctx context.Context, pgItems
 		if err != nil {
 			return nil, fmt.Errorf("failed to process subscription: %w", err)
 		}
+		if refunded {
+			timeline.skipped = append(timeline.skipped, &SkippedTransaction{Transaction: pgItem, Reason: TimelineSkipReasonRefunded})
+		}
 	}
 
-	return s.selectLastActivePeriods(result)
+	timeline.periods = result
+	return timeline, nil
 }
@@ -0,0 +1,75 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+func TestComputeUserSubscriptionTimeline_GracePeriodAndBillingRetry(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip", Type: types.PaymentItemTypeAutoRenewableSubscription},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+
+	renewal := func(id string, purchaseAt time.Time, extra *models.UserSubscriptionItemExtra) *models.Transaction {
+		return &models.Transaction{
+			ID: id, ProviderID: types.PaymentProviderApple, TransactionID: id, ParentTransactionID: lo.ToPtr("orig"),
+			PaymentItemID: "vip", PurchaseAt: purchaseAt, AutoRenewExpireAt: lo.ToPtr(purchaseAt.Add(30 * day)),
+			Extra: datatypes.NewJSONType(extra),
+		}
+	}
+	graceEnd := start.Add(36 * day)
+	failed := renewal("p1", start, &models.UserSubscriptionItemExtra{GracePeriodExpireAt: &graceEnd, InBillingRetry: true})
+
+	// The failed period keeps access until the grace period ends.
+	res, err := svc.computeUserSubscriptionTimeline(context.Background(), "u1", []*models.Transaction{failed}, start.Add(32*day))
+	require.NoError(t, err)
+	require.Equal(t, types.SubscriptionStatusGracePeriod, res.Status)
+	require.True(t, graceEnd.Equal(*res.ExpireAt))
+
+	// Before the paid period ended the same transaction is plainly active.
+	res, err = svc.computeUserSubscriptionTimeline(context.Background(), "u1", []*models.Transaction{failed}, start.Add(10*day))
+	require.NoError(t, err)
+	require.Equal(t, types.SubscriptionStatusActive, res.Status)
+
+	// After the grace period Apple is still retrying, so there is no access.
+	res, err = svc.computeUserSubscriptionTimeline(context.Background(), "u1", []*models.Transaction{failed}, start.Add(40*day))
+	require.NoError(t, err)
+	require.Equal(t, types.SubscriptionStatusBillingRetry, res.Status)
+	require.Nil(t, res.CurrentItem)
+
+	// A recovered renewal replaces the grace period of the failed one.
+	recovered := renewal("p2", start.Add(33*day), &models.UserSubscriptionItemExtra{})
+	res, err = svc.computeUserSubscriptionTimeline(context.Background(), "u1", []*models.Transaction{failed, recovered}, start.Add(34*day))
+	require.NoError(t, err)
+	require.Equal(t, types.SubscriptionStatusActive, res.Status)
+	require.Equal(t, "p2", res.CurrentItem.ID)
+	require.True(t, start.Add(30*day).Equal(res.Periods[0].ExpireAt))
+	require.True(t, start.Add(63*day).Equal(*res.ExpireAt))
+}
+
+func TestResolveSubscriptionStatus_ProviderReportedGracePeriod(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	// Google reports the end of the grace period as the expiry.
+	graceEnd := now.Add(2 * 24 * time.Hour)
+	item := &UserSubscriptionItem{
+		Transaction:         models.Transaction{AutoRenewExpireAt: &graceEnd},
+		ActivatedAt:         now.Add(-30 * 24 * time.Hour),
+		ExpireAt:            graceEnd,
+		GracePeriodExpireAt: &graceEnd,
+	}
+	require.Equal(t, types.SubscriptionStatusGracePeriod, resolveSubscriptionStatus([]*UserSubscriptionItem{item}, nil, now))
+
+	require.Equal(t, types.SubscriptionStatusInactive, resolveSubscriptionStatus(nil, nil, now))
+}
@@ -0,0 +1,92 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+)
+
+// Reasons a transaction produced no period in a timeline.
+const (
+	TimelineSkipReasonRefunded       = "refunded"
+	TimelineSkipReasonUpgraded       = "upgraded"
+	TimelineSkipReasonPurchasedLater = "purchased_after_query_at"
+	// TimelineSkipReasonNotSubscription marks consumable and non-consumable purchases, which grant credits and
+	// permanent entitlements instead of membership time.
+	TimelineSkipReasonNotSubscription = "not_subscription"
+)
+
+type SkippedTransaction struct {
+	Transaction *models.Transaction `json:"transaction"`
+	Reason      string              `json:"reason"`
+}
+
+// UserSubscriptionTimeline is the computed membership history of a user as seen at QueryAt.
+type UserSubscriptionTimeline struct {
+	UserID  string    `json:"user_id"`
+	QueryAt time.Time `json:"query_at"`
+	// Status and ExpireAt are the membership state at QueryAt.
+	Status   types.SubscriptionStatus `json:"status"`
+	ExpireAt *time.Time               `json:"expire_at"`
+	// CurrentItem is the period covering QueryAt, if any.
+	CurrentItem *UserSubscriptionItem `json:"current_item"`
+	// Periods are all computed periods in activation order, each embedding the transaction that produced it.
+	Periods []*UserSubscriptionItem `json:"periods"`
+	// ActiveItems is the last contiguous chain of Periods, the one that decides membership.
+	ActiveItems []*UserSubscriptionItem `json:"active_items"`
+	// Skipped lists transactions that produced no period, with the reason.
+	Skipped []*SkippedTransaction `json:"skipped"`
+}
+
+// GetUserSubscriptionTimeline recomputes the user's membership from all transactions as of queryAt.
+func (s *Service) GetUserSubscriptionTimeline(ctx context.Context, userID string, queryAt time.Time) (*UserSubscriptionTimeline, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("invalid params: user_id required")
+	}
+	if queryAt.IsZero() {
+		queryAt = time.Now()
+	}
+	txns, err := s.GetAllUserTransactions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	return s.computeUserSubscriptionTimeline(ctx, userID, txns, queryAt)
+}
+
+func (s *Service) computeUserSubscriptionTimeline(ctx context.Context, userID string, txns []*models.Transaction, queryAt time.Time) (*UserSubscriptionTimeline, error) {
+	timeline, err := s.buildSubscriptionTimeline(ctx, txns, queryAt)
+	if err != nil {
+		return nil, err
+	}
+	// selectLastActivePeriods reslices; copy the periods first so the full list is kept.
+	periods := append([]*UserSubscriptionItem{}, timeline.periods...)
+	active, err := s.selectLastActivePeriods(timeline.periods)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &UserSubscriptionTimeline{
+		UserID:      userID,
+		QueryAt:     queryAt,
+		Status:      types.SubscriptionStatusInactive,
+		Periods:     periods,
+		ActiveItems: active,
+		Skipped:     timeline.skipped,
+	}
+	for _, item := range active {
+		if !item.ActivatedAt.After(queryAt) && item.ExpireAt.After(queryAt) {
+			res.CurrentItem = item
+			expireAt := active[len(active)-1].ExpireAt
+			res.ExpireAt = &expireAt
+			break
+		}
+	}
+	// Without a current period only billing_retry is reported; a lapsed chain is inactive.
+	if status := resolveSubscriptionStatus(active, txns, queryAt); res.CurrentItem != nil || status == types.SubscriptionStatusBillingRetry {
+		res.Status = status
+	}
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestComputeUserSubscriptionTimeline(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	monthHours := int64(30 * 24)
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "card", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &monthHours},
+		{ID: "vip", Type: types.PaymentItemTypeAutoRenewableSubscription},
+	}}
+	svc := NewService(cfg, nil, zap.NewNop().Sugar())
+
+	txs := []*models.Transaction{
+		// An old card, followed by a gap.
+		{ID: "old", ProviderID: types.PaymentProviderInner, TransactionID: "g1", PaymentItemID: "card", PurchaseAt: start.Add(-90 * day)},
+		{ID: "a", ProviderID: types.PaymentProviderApple, TransactionID: "1", PaymentItemID: "vip", PurchaseAt: start, AutoRenewExpireAt: lo.ToPtr(start.Add(30 * day))},
+		{ID: "b", ProviderID: types.PaymentProviderApple, TransactionID: "2", PaymentItemID: "vip", PurchaseAt: start.Add(10 * day), AutoRenewExpireAt: lo.ToPtr(start.Add(40 * day)), BeforeUpgradedTransactionID: lo.ToPtr("1")},
+		{ID: "r", ProviderID: types.PaymentProviderInner, TransactionID: "g2", PaymentItemID: "card", PurchaseAt: start.Add(11 * day), RefundAt: lo.ToPtr(start.Add(12 * day))},
+		{ID: "later", ProviderID: types.PaymentProviderApple, TransactionID: "3", PaymentItemID: "vip", PurchaseAt: start.Add(40 * day), AutoRenewExpireAt: lo.ToPtr(start.Add(70 * day))},
+	}
+
+	queryAt := start.Add(20 * day)
+	res, err := svc.computeUserSubscriptionTimeline(context.Background(), "u1", txs, queryAt)
+	require.NoError(t, err)
+
+	require.Equal(t, types.SubscriptionStatusActive, res.Status)
+	require.Equal(t, "b", res.CurrentItem.ID)
+	require.True(t, start.Add(40*day).Equal(*res.ExpireAt))
+	require.Equal(t, []string{"old", "b"}, lo.Map(res.Periods, func(it *UserSubscriptionItem, _ int) string { return it.ID }))
+	require.Equal(t, []string{"b"}, lo.Map(res.ActiveItems, func(it *UserSubscriptionItem, _ int) string { return it.ID }))
+
+	reasons := lo.SliceToMap(res.Skipped, func(s *SkippedTransaction) (string, string) { return s.Transaction.ID, s.Reason })
+	require.Equal(t, map[string]string{
+		"a":     TimelineSkipReasonUpgraded,
+		"r":     TimelineSkipReasonRefunded,
+		"later": TimelineSkipReasonPurchasedLater,
+	}, reasons)
+
+	// Before the upgrade, the original purchase is the current period.
+	res, err = svc.computeUserSubscriptionTimeline(context.Background(), "u1", txs, start.Add(5*day))
+	require.NoError(t, err)
+	require.Equal(t, "a", res.CurrentItem.ID)
+
+	// After everything expired the user is inactive.
+	res, err = svc.computeUserSubscriptionTimeline(context.Background(), "u1", txs[:3], start.Add(50*day))
+	require.NoError(t, err)
+	require.Equal(t, types.SubscriptionStatusInactive, res.Status)
+	require.Nil(t, res.CurrentItem)
+	require.Nil(t, res.ExpireAt)
+}
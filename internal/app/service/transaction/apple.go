@@ -10,7 +10,6 @@ import (
 	models "github.com/fatflowers/cashier/internal/models"
 	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
 	"github.com/fatflowers/cashier/pkg/config"
-	"github.com/fatflowers/cashier/pkg/logctx"
 	types "github.com/fatflowers/cashier/pkg/types"
 	"strings"
 	"time"
@@ -30,10 +29,11 @@ type AppleTransactionManager struct {
 	db        *gorm.DB
 	subSvc    *subscription.Service
 	notifSvc  *notificationlog.Service
+	usage     UsageProvider
 	log       *zap.SugaredLogger
 }
 
-func NewAppleTransactionManager(cfg *config.Config, db *gorm.DB, sub *subscription.Service, notif *notificationlog.Service, log *zap.SugaredLogger) (*AppleTransactionManager, error) {
+func NewAppleTransactionManager(cfg *config.Config, db *gorm.DB, sub *subscription.Service, notif *notificationlog.Service, usage UsageProvider, log *zap.SugaredLogger) (*AppleTransactionManager, error) {
 	opts := &apple_iap.GetAppleIAPClientOptions{
 		KeyID:        cfg.AppleIAP.KeyID,
 		KeyContent:   cfg.AppleIAP.KeyContent,
@@ -46,21 +46,30 @@ func NewAppleTransactionManager(cfg *config.Config, db *gorm.DB, sub *subscripti
 	if err != nil {
 		return nil, fmt.Errorf("failed to init Apple IAP client: %w", err)
 	}
-	return &AppleTransactionManager{iapClient: cli, opts: opts, cfg: cfg, db: db, subSvc: sub, notifSvc: notif, log: log}, nil
+	return &AppleTransactionManager{iapClient: cli, opts: opts, cfg: cfg, db: db, subSvc: sub, notifSvc: notif, usage: usage, log: log}, nil
 }
 
 // Request/response types are defined in manager.go in this package.
 
 func (a *AppleTransactionManager) getPaymentItemByProviderItemID(providerID types.PaymentProvider, providerItemID string) *types.PaymentItem {
-	for _, it := range a.cfg.PaymentItems {
-		if it.ProviderID == providerID && it.ProviderItemID == providerItemID {
-			return it
+	item, _ := a.cfg.GetPaymentItemByProviderItemID(context.Background(), providerID, providerItemID)
+	return item
+}
+
+func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWSTransaction) (*models.Transaction, error) {
+	var statuses *api.StatusResponse
+	if ti.Type == api.AutoRenewable {
+		var err error
+		statuses, err = a.iapClient.GetALLSubscriptionStatuses(ctx, ti.TransactionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subscription status: %w", err)
 		}
 	}
-	return nil
+	return a.toTransactionWithStatuses(ctx, ti, statuses)
 }
 
-func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWSTransaction) (*models.Transaction, error) {
+// toTransactionWithStatuses maps ti, taking the renewal state of auto-renewable transactions from statuses.
+func (a *AppleTransactionManager) toTransactionWithStatuses(ctx context.Context, ti *api.JWSTransaction, statuses *api.StatusResponse) (*models.Transaction, error) {
 	paymentItem := a.getPaymentItemByProviderItemID(types.PaymentProviderApple, ti.ProductID)
 	if paymentItem == nil {
 		return nil, fmt.Errorf("payment item not found for product: %s", ti.ProductID)
@@ -80,10 +89,11 @@ func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWS
 		PaymentItemID: paymentItem.ID,
 		TransactionID: ti.TransactionID,
 		PurchaseAt:    time.UnixMilli(int64(ti.PurchaseDate)),
-		Price:         ti.Price * 100,
-		Currency:      ti.Currency,
+		Price:         AppleMoney(ti.Price, ti.Currency),
+		Storefront:    ti.Storefront,
 		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
 			PaymentItemSnapshot: paymentItem,
+			Offer:               AppleOffer(ti.OfferType, ti.OfferIdentifier, string(ti.OfferDiscountType)),
 		}),
 	}
 
@@ -91,7 +101,14 @@ func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWS
 		res.ParentTransactionID = lo.ToPtr(ti.OriginalTransactionId)
 	}
 	if ti.RevocationDate > 0 {
-		res.RefundAt = lo.ToPtr(time.UnixMilli(int64(ti.RevocationDate)))
+		revokedAt := time.UnixMilli(ti.RevocationDate)
+		res.RevocationDate = lo.ToPtr(revokedAt)
+		if ti.IsUpgraded {
+			res.RevocationReason = lo.ToPtr(models.RevocationReasonUpgraded)
+		} else {
+			res.RefundAt = lo.ToPtr(revokedAt)
+			res.RevocationReason = lo.ToPtr(AppleRevocationReason(lo.FromPtr(ti.RevocationReason)))
+		}
 	}
 
 	if ti.Type == api.AutoRenewable {
@@ -100,10 +117,8 @@ func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWS
 		} else {
 			return nil, fmt.Errorf("auto renew transaction expires date is 0")
 		}
-
-		statuses, err := a.iapClient.GetALLSubscriptionStatuses(ctx, ti.TransactionID, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get subscription status: %w", err)
+		if statuses == nil {
+			return nil, fmt.Errorf("subscription status is required for auto renew transaction %s", ti.TransactionID)
 		}
 
 		for _, item := range statuses.Data {
@@ -116,6 +131,14 @@ func (a *AppleTransactionManager) toTransaction(ctx context.Context, ti *api.JWS
 					return nil, fmt.Errorf("failed to parse signed renewal info: %w", err)
 				}
 				renewalInfo := value.(*api.JWSRenewalInfoDecodedPayload)
+				if renewalInfo.OriginalTransactionId == ti.OriginalTransactionId {
+					if renewalInfo.GracePeriodExpiresDate > 0 {
+						res.Extra.Data().GracePeriodExpireAt = lo.ToPtr(time.UnixMilli(renewalInfo.GracePeriodExpiresDate))
+					}
+					res.Extra.Data().InBillingRetry = lo.FromPtr(renewalInfo.IsInBillingRetryPeriod)
+					res.Extra.Data().PendingDowngrade = ApplePendingDowngrade(ctx, a.cfg, ti.ProductID, renewalInfo.AutoRenewProductId,
+						renewalInfo.AutoRenewStatus == api.AutoRenewStatusOn, int64(renewalInfo.RenewalDate))
+				}
 				if renewalInfo.ProductId == ti.ProductID && renewalInfo.AutoRenewStatus == api.AutoRenewStatusOn && renewalInfo.RenewalDate > 0 {
 					res.NextAutoRenewAt = lo.ToPtr(time.UnixMilli(int64(renewalInfo.RenewalDate)))
 					if res.ParentTransactionID == nil {
@@ -160,39 +183,28 @@ func (a *AppleTransactionManager) getTransactionByProviderTransactionID(ctx cont
 	return &item, nil
 }
 
-func detectAppleUpgrade(parseResult *VerifiedData, txInfo *api.JWSTransaction) (string, bool) {
-	if parseResult == nil || parseResult.AppleReceipt == nil || txInfo == nil {
-		return "", false
-	}
-
-	receiptInfos := parseResult.AppleReceipt.LatestReceiptInfo
-	if len(receiptInfos) == 0 {
-		receiptInfos = parseResult.AppleReceipt.Receipt.InApp
-	}
-
-	currentIdx := -1
-	for i, info := range receiptInfos {
-		if info.TransactionID == txInfo.TransactionID {
-			currentIdx = i
-			break
-		}
+// TransactionUpdateTimes returns when each stored Apple transaction of transactionIDs was last written.
+// Transactions that are not stored are left out.
+func (a *AppleTransactionManager) TransactionUpdateTimes(ctx context.Context, transactionIDs []string) (map[string]time.Time, error) {
+	var rows []struct {
+		TransactionID string
+		UpdatedAt     time.Time
 	}
-
-	if currentIdx < 0 || currentIdx+1 >= len(receiptInfos) {
-		return "", false
+	if err := a.db.WithContext(ctx).Model(&models.Transaction{}).
+		Select("transaction_id, updated_at").
+		Where("provider_id = ? AND transaction_id IN ?", types.PaymentProviderApple, transactionIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
 	}
-
-	nextInfo := receiptInfos[currentIdx+1]
-	if nextInfo.IsUpgraded != "true" || nextInfo.TransactionID == "" {
-		return "", false
+	res := make(map[string]time.Time, len(rows))
+	for _, r := range rows {
+		res[r.TransactionID] = r.UpdatedAt
 	}
-
-	return nextInfo.TransactionID, true
+	return res, nil
 }
 
 func (a *AppleTransactionManager) VerifyTransaction(ctx context.Context, req *TransactionVerifyRequest) (*VerifyTransactionResult, error) {
 	result := &VerifyTransactionResult{}
-	logger := logctx.FromCtx(ctx, a.log)
 	// Prepare and save a 'received' notification log
 	var userIDPtr *string
 	if v, ok := ctx.Value("user_id").(string); ok && v != "" {
@@ -263,7 +275,9 @@ func (a *AppleTransactionManager) VerifyTransaction(ctx context.Context, req *Tr
 		return nil, retErr
 	}
 
-	if txInfo.Type != api.AutoRenewable && txInfo.Type != api.NonRenewable {
+	switch txInfo.Type {
+	case api.AutoRenewable, api.NonRenewable, api.Consumable, api.NonConsumable:
+	default:
 		retErr = fmt.Errorf("unsupported transaction type: %s", txInfo.Type)
 		return nil, retErr
 	}
@@ -275,39 +289,6 @@ func (a *AppleTransactionManager) VerifyTransaction(ctx context.Context, req *Tr
 	}
 	mappedItem = item
 
-	if txInfo.Type == api.AutoRenewable {
-		if req.ServerVerificationData == "" {
-			retErr = fmt.Errorf("server verification data is empty")
-			return nil, retErr
-		}
-
-		parseResult, err := a.ParseVerificationData(ctx, &VerificationDataRequest{
-			ProviderID:  string(types.PaymentProviderApple),
-			ReceiptData: req.ServerVerificationData,
-		})
-		if err != nil {
-			retErr = fmt.Errorf("failed to parse verification data: %w", err)
-			return nil, retErr
-		}
-
-		downgradeVipID, downgradeAt, ok, err := detectAppleDowngrade(ctx, parseResult, txInfo, func(ctx context.Context, provider types.PaymentProvider, providerItemID string) (*types.PaymentItem, error) {
-			return a.cfg.GetPaymentItemByProviderItemID(ctx, provider, providerItemID)
-		})
-		if err != nil {
-			// Downgrade detection is best-effort and should not block verify flow.
-			logger.Warnw("detect apple downgrade failed", "transaction_id", txInfo.TransactionID, "error", err.Error())
-		} else if ok {
-			result.DowngradeToVipID = downgradeVipID
-			result.DowngradeNextAutoRenewAt = downgradeAt
-		}
-
-		beforeUpgradeTransactionID, isUpgrade := detectAppleUpgrade(parseResult, txInfo)
-		result.IsUpgrade = isUpgrade
-		if isUpgrade {
-			item.BeforeUpgradedTransactionID = lo.ToPtr(beforeUpgradeTransactionID)
-		}
-	}
-
 	if txInfo.Type == api.AutoRenewable && item.ParentTransactionID != nil {
 		exists, err := a.existsSamePurchaseTransaction(ctx, txInfo.TransactionID, types.PaymentProviderApple, *item.ParentTransactionID, item.PurchaseAt)
 		if err != nil {
@@ -320,11 +301,18 @@ func (a *AppleTransactionManager) VerifyTransaction(ctx context.Context, req *Tr
 		}
 	}
 
-	// Persist via subscription service
+	// Persist via subscription service, which classifies the plan change against the rest of the chain.
 	if err := a.subSvc.UpsertUserSubscriptionByItem(ctx, item); err != nil {
 		retErr = fmt.Errorf("failed to upsert membership: %w", err)
 		return nil, retErr
 	}
+	if extra := item.Extra.Data(); extra != nil {
+		result.IsUpgrade = extra.PlanChange == types.PlanChangeUpgrade
+		if extra.PendingDowngrade != nil {
+			result.DowngradeToVipID = extra.PendingDowngrade.PaymentItemID
+			result.DowngradeNextAutoRenewAt = lo.ToPtr(extra.PendingDowngrade.EffectiveAt)
+		}
+	}
 
 	if persisted, err := a.getTransactionByProviderTransactionID(ctx, types.PaymentProviderApple, txInfo.TransactionID); err == nil {
 		result.UserTransaction = persisted
@@ -343,6 +331,61 @@ func (a *AppleTransactionManager) ParseVerificationData(ctx context.Context, req
 	return &VerifiedData{AppleReceipt: receipt}, nil
 }
 
+// AppleRevocationReason maps Apple's revocationReason of a refunded transaction: 1 is an issue with the app,
+// 0 any other reason.
+func AppleRevocationReason(reason int32) string {
+	if reason == 1 {
+		return models.RevocationReasonRefundAppIssue
+	}
+	return models.RevocationReasonRefund
+}
+
+// appleOfferTypes maps Apple's offerType to the offer types of models.Offer.
+var appleOfferTypes = map[int32]string{
+	1: models.OfferTypeIntroductory,
+	2: models.OfferTypePromotional,
+	3: models.OfferTypeOfferCode,
+	4: models.OfferTypeWinBack,
+}
+
+// AppleOffer maps the offer fields of an Apple transaction; nil is returned when it was not bought with an offer.
+func AppleOffer(offerType int32, id, discountType string) *models.Offer {
+	if offerType == 0 {
+		return nil
+	}
+	t, ok := appleOfferTypes[offerType]
+	if !ok {
+		t = fmt.Sprintf("apple_%d", offerType)
+	}
+	return &models.Offer{Type: t, ID: id, DiscountType: discountType}
+}
+
+// RefundTransaction applies the refund state Apple currently reports for transactionId. Apple refunds are
+// requested by customers from Apple, so this recovers a missed REFUND or REFUND_REVERSED notification rather
+// than issuing a refund; outRefundId is not used.
 func (a *AppleTransactionManager) RefundTransaction(ctx context.Context, transactionId string, outRefundId string) error {
-	return fmt.Errorf("apple refund transaction is not supported yet")
+	if _, err := a.getTransactionByProviderTransactionID(ctx, types.PaymentProviderApple, transactionId); err != nil {
+		return fmt.Errorf("failed to get transaction %s: %w", transactionId, err)
+	}
+	infoResp, err := a.iapClient.GetTransactionInfo(ctx, transactionId)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction info: %w", err)
+	}
+	txInfo, err := a.iapClient.ParseSignedTransaction(infoResp.SignedTransactionInfo)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed transaction: %w", err)
+	}
+	item, err := a.toTransaction(ctx, txInfo)
+	if err != nil {
+		return fmt.Errorf("failed to map transaction: %w", err)
+	}
+	if err := a.subSvc.UpsertUserSubscriptionByItem(ctx, item); err != nil {
+		return fmt.Errorf("failed to upsert membership: %w", err)
+	}
+	return nil
+}
+
+// GetNotificationHistory returns one page of the App Store Server Notifications Apple sent in a time window.
+func (a *AppleTransactionManager) GetNotificationHistory(ctx context.Context, body api.NotificationHistoryRequest, paginationToken string) (*api.NotificationHistoryResponses, error) {
+	return a.iapClient.GetNotificationHistory(ctx, body, paginationToken)
 }
@@ -0,0 +1,221 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// UsageProvider reports how much a user used the app, for the playTime of consumption information.
+// The default reports nothing; an app replaces it with fx.Decorate.
+type UsageProvider interface {
+	// PlayTime returns the user's total usage. ok is false when the usage is unknown.
+	PlayTime(ctx context.Context, userID string) (playTime time.Duration, ok bool, err error)
+}
+
+type noUsageProvider struct{}
+
+func NewNoUsageProvider() UsageProvider {
+	return noUsageProvider{}
+}
+
+func (noUsageProvider) PlayTime(ctx context.Context, userID string) (time.Duration, bool, error) {
+	return 0, false, nil
+}
+
+// ConsumptionResult is the outcome of answering a CONSUMPTION_REQUEST, recorded in payment_notification_log.
+type ConsumptionResult struct {
+	TransactionID string `json:"transaction_id"`
+	// Sent is false when consumption information is not configured to be shared.
+	Sent       bool                        `json:"sent"`
+	StatusCode int                         `json:"status_code,omitempty"`
+	Request    *api.ConsumptionRequestBody `json:"request,omitempty"`
+}
+
+// consumptionStats is what the store knows about a user when Apple asks for consumption information.
+type consumptionStats struct {
+	FirstPurchaseAt *time.Time
+	// PurchasedUSD and RefundedUSD are lifetime amounts in cents; nil when they cannot be stated in USD.
+	PurchasedUSD *int64
+	RefundedUSD  *int64
+	PlayTime     *time.Duration
+	// Transaction is the stored transaction Apple asks about, nil when it is unknown.
+	Transaction *models.Transaction
+	PaymentItem *types.PaymentItem
+}
+
+// SendConsumptionInfo answers a CONSUMPTION_REQUEST for transactionID, purchased with appAccountToken.
+func (a *AppleTransactionManager) SendConsumptionInfo(ctx context.Context, transactionID string, appAccountToken string) (*ConsumptionResult, error) {
+	res := &ConsumptionResult{TransactionID: transactionID}
+	if !a.cfg.AppleIAP.Consumption.CustomerConsented {
+		return res, nil
+	}
+	userID, err := apple_iap.UUIDToUserID(appAccountToken)
+	if err != nil {
+		return res, fmt.Errorf("invalid app account token: %w", err)
+	}
+
+	stats, err := a.loadConsumptionStats(ctx, transactionID, userID)
+	if err != nil {
+		return res, err
+	}
+	body := newConsumptionRequestBody(stats, &a.cfg.AppleIAP.Consumption, time.Now())
+	body.AppAccountToken = appAccountToken
+	res.Request = body
+
+	res.StatusCode, err = a.iapClient.SendConsumptionInfo(ctx, transactionID, *body)
+	if err != nil {
+		return res, fmt.Errorf("failed to send consumption info: %w", err)
+	}
+	res.Sent = true
+	return res, nil
+}
+
+// consumptionTotalsSelect sums the lifetime purchases and refunds of a user in US cents. Prices stored with
+// another currency or exponent make the totals undeclarable, so they are counted in non_usd instead.
+const consumptionTotalsSelect = `MIN(purchase_at) AS first_purchase_at,
+	COALESCE(SUM(price_amount) FILTER (WHERE price_currency = 'USD' AND price_exponent = 2), 0) AS purchased,
+	COALESCE(SUM(price_amount) FILTER (WHERE price_currency = 'USD' AND price_exponent = 2 AND refund_at IS NOT NULL), 0) AS refunded,
+	COUNT(*) FILTER (WHERE (price_currency <> 'USD' OR price_exponent <> 2) AND price_amount > 0) AS non_usd`
+
+func (a *AppleTransactionManager) loadConsumptionStats(ctx context.Context, transactionID string, userID string) (*consumptionStats, error) {
+	stats := &consumptionStats{}
+
+	txn, err := a.getTransactionByProviderTransactionID(ctx, types.PaymentProviderApple, transactionID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", transactionID, err)
+	}
+	if txn != nil {
+		stats.Transaction = txn
+		stats.PaymentItem = txn.GetPaymentItemSnapshot()
+		if stats.PaymentItem == nil {
+			stats.PaymentItem = a.cfg.GetPaymentItemByID(txn.PaymentItemID)
+		}
+	}
+
+	var totals struct {
+		FirstPurchaseAt *time.Time
+		Purchased       int64
+		Refunded        int64
+		NonUSD          int64
+	}
+	if err := a.db.WithContext(ctx).Model(&models.Transaction{}).
+		Select(consumptionTotalsSelect).
+		Where("user_id = ? AND provider_id != ?", userID, types.PaymentProviderInner).
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum user transactions: %w", err)
+	}
+	stats.FirstPurchaseAt = totals.FirstPurchaseAt
+	// Amounts in other currencies cannot be bucketed in dollars, so the lifetime totals stay undeclared.
+	if totals.NonUSD == 0 {
+		stats.PurchasedUSD = lo.ToPtr(totals.Purchased)
+		stats.RefundedUSD = lo.ToPtr(totals.Refunded)
+	}
+
+	used, ok, err := a.usage.PlayTime(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get play time: %w", err)
+	}
+	if ok {
+		stats.PlayTime = &used
+	}
+	return stats, nil
+}
+
+// newConsumptionRequestBody maps stats onto the enumerated values of Apple's ConsumptionRequest.
+func newConsumptionRequestBody(stats *consumptionStats, cfg *config.AppleConsumptionConfig, now time.Time) *api.ConsumptionRequestBody {
+	return &api.ConsumptionRequestBody{
+		AccountTenure:            accountTenure(stats.FirstPurchaseAt, now),
+		ConsumptionStatus:        consumptionStatus(stats.Transaction, stats.PaymentItem, now),
+		CustomerConsented:        cfg.CustomerConsented,
+		DeliveryStatus:           0, // delivered and working properly
+		LifetimeDollarsPurchased: lifetimeDollars(stats.PurchasedUSD),
+		LifetimeDollarsRefunded:  lifetimeDollars(stats.RefundedUSD),
+		Platform:                 1, // Apple platform
+		PlayTime:                 playTime(stats.PlayTime),
+		SampleContentProvided:    cfg.SampleContentProvided,
+		UserStatus:               1, // active
+		RefundPreference:         refundPreference(cfg.RefundPreference),
+	}
+}
+
+// bucket returns the 1-based index of the first bound v is below, or len(bounds)+1.
+func bucket[T int64 | time.Duration](v T, bounds ...T) int32 {
+	for i, b := range bounds {
+		if v < b {
+			return int32(i + 1)
+		}
+	}
+	return int32(len(bounds) + 1)
+}
+
+const day = 24 * time.Hour
+
+func accountTenure(firstPurchaseAt *time.Time, now time.Time) int32 {
+	if firstPurchaseAt == nil {
+		return 0
+	}
+	return bucket(now.Sub(*firstPurchaseAt), 3*day, 10*day, 30*day, 90*day, 180*day, 365*day)
+}
+
+func lifetimeDollars(cents *int64) int32 {
+	if cents == nil {
+		return 0
+	}
+	if *cents <= 0 {
+		return 1
+	}
+	return bucket(*cents, 50_00, 100_00, 500_00, 1000_00, 2000_00) + 1
+}
+
+func playTime(d *time.Duration) int32 {
+	if d == nil {
+		return 0
+	}
+	return bucket(*d, 5*time.Minute, time.Hour, 6*time.Hour, day, 4*day, 16*day)
+}
+
+// consumptionStatus reports how much of the purchased period has elapsed: 1 not consumed, 2 partially
+// consumed, 3 fully consumed, 0 when the period is unknown.
+func consumptionStatus(txn *models.Transaction, item *types.PaymentItem, now time.Time) int32 {
+	if txn == nil {
+		return 0
+	}
+	expireAt := txn.AutoRenewExpireAt
+	if expireAt == nil && item != nil && item.DurationHour != nil {
+		expireAt = lo.ToPtr(txn.PurchaseAt.Add(time.Duration(*item.DurationHour) * time.Hour))
+	}
+	switch {
+	case expireAt == nil:
+		return 0
+	case !now.After(txn.PurchaseAt):
+		return 1
+	case now.Before(*expireAt):
+		return 2
+	default:
+		return 3
+	}
+}
+
+func refundPreference(preference string) int32 {
+	switch preference {
+	case "grant":
+		return 1
+	case "decline":
+		return 2
+	case "no_preference":
+		return 3
+	default:
+		return 0
+	}
+}
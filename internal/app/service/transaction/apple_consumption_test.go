@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+)
+
+func TestNewConsumptionRequestBody(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	purchaseAt := now.Add(-10 * 24 * time.Hour)
+	monthHours := int64(30 * 24)
+	stats := &consumptionStats{
+		FirstPurchaseAt: lo.ToPtr(now.Add(-45 * 24 * time.Hour)),
+		PurchasedUSD:    lo.ToPtr(int64(129_99)),
+		RefundedUSD:     lo.ToPtr(int64(0)),
+		PlayTime:        lo.ToPtr(90 * time.Minute),
+		Transaction:     &models.Transaction{PurchaseAt: purchaseAt},
+		PaymentItem:     &types.PaymentItem{Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &monthHours},
+	}
+
+	body := newConsumptionRequestBody(stats, &config.AppleConsumptionConfig{CustomerConsented: true, RefundPreference: "decline"}, now)
+	require.Equal(t, int32(4), body.AccountTenure)
+	require.Equal(t, int32(4), body.LifetimeDollarsPurchased)
+	require.Equal(t, int32(1), body.LifetimeDollarsRefunded)
+	require.Equal(t, int32(3), body.PlayTime)
+	require.Equal(t, int32(2), body.ConsumptionStatus)
+	require.Equal(t, int32(2), body.RefundPreference)
+	require.True(t, body.CustomerConsented)
+	require.False(t, body.SampleContentProvided)
+
+	undeclared := newConsumptionRequestBody(&consumptionStats{}, &config.AppleConsumptionConfig{CustomerConsented: true}, now)
+	require.Zero(t, undeclared.AccountTenure)
+	require.Zero(t, undeclared.LifetimeDollarsPurchased)
+	require.Zero(t, undeclared.PlayTime)
+	require.Zero(t, undeclared.ConsumptionStatus)
+	require.Zero(t, undeclared.RefundPreference)
+}
+
+func TestConsumptionBuckets(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, int32(1), accountTenure(lo.ToPtr(now.Add(-time.Hour)), now))
+	require.Equal(t, int32(7), accountTenure(lo.ToPtr(now.Add(-400*24*time.Hour)), now))
+
+	require.Equal(t, int32(2), lifetimeDollars(lo.ToPtr(int64(1))))
+	require.Equal(t, int32(3), lifetimeDollars(lo.ToPtr(int64(50_00))))
+	require.Equal(t, int32(7), lifetimeDollars(lo.ToPtr(int64(2000_00))))
+
+	require.Equal(t, int32(1), playTime(lo.ToPtr(time.Minute)))
+	require.Equal(t, int32(7), playTime(lo.ToPtr(20*24*time.Hour)))
+
+	expireAt := now.Add(-time.Hour)
+	require.Equal(t, int32(3), consumptionStatus(&models.Transaction{PurchaseAt: now.Add(-48 * time.Hour), AutoRenewExpireAt: &expireAt}, nil, now))
+	require.Equal(t, int32(1), consumptionStatus(&models.Transaction{PurchaseAt: now, AutoRenewExpireAt: lo.ToPtr(now.Add(time.Hour))}, nil, now))
+}
+
+func TestSendConsumptionInfo_WithoutConsentSendsNothing(t *testing.T) {
+	a := &AppleTransactionManager{cfg: &config.Config{}}
+	res, err := a.SendConsumptionInfo(context.Background(), "1000", "")
+	require.NoError(t, err)
+	require.False(t, res.Sent)
+	require.Nil(t, res.Request)
+}
+
+func TestConsumptionTotalsSelect_UsesTransactionColumns(t *testing.T) {
+	s, err := schema.Parse(&models.Transaction{}, &sync.Map{}, schema.NamingStrategy{SingularTable: true})
+	require.NoError(t, err)
+	for _, column := range regexp.MustCompile(`\b(\w+_at|price\w*|currency)\b`).FindAllString(consumptionTotalsSelect, -1) {
+		if column == "first_purchase_at" {
+			continue
+		}
+		require.NotNil(t, s.LookUpField(column), "unknown transaction column %q", column)
+	}
+}
+
+func TestLifetimeDollars_ApplePricesInCents(t *testing.T) {
+	// Apple reports $49.99 as 49990 milliunits; the stored price must land in the $0.01-$49.99 bucket.
+	price := AppleMoney(49990, "USD")
+	require.Equal(t, 2, price.Exponent)
+	require.Equal(t, int32(2), lifetimeDollars(lo.ToPtr(price.Amount)))
+	require.Equal(t, int32(3), lifetimeDollars(lo.ToPtr(AppleMoney(50000, "USD").Amount)))
+}
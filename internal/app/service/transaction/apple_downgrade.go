@@ -2,83 +2,31 @@ package transaction
 
 import (
 	"context"
-	"fmt"
-	"strconv"
 	"time"
 
-	"github.com/awa/go-iap/appstore"
-	"github.com/awa/go-iap/appstore/api"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
 	types "github.com/fatflowers/cashier/pkg/types"
 )
 
-type applePaymentItemLookupFn func(ctx context.Context, provider types.PaymentProvider, providerItemID string) (*types.PaymentItem, error)
-
-func detectAppleDowngrade(ctx context.Context, parseResult *VerifiedData, txInfo *api.JWSTransaction, lookup applePaymentItemLookupFn) (string, *time.Time, bool, error) {
-	if parseResult == nil || parseResult.AppleReceipt == nil || txInfo == nil {
-		return "", nil, false, nil
-	}
-
-	var pending *appstore.PendingRenewalInfo
-	for i := range parseResult.AppleReceipt.PendingRenewalInfo {
-		v := &parseResult.AppleReceipt.PendingRenewalInfo[i]
-		if v.OriginalTransactionID == txInfo.OriginalTransactionId {
-			pending = v
-			break
-		}
-	}
-	if pending == nil || pending.SubscriptionAutoRenewStatus != "1" || pending.ProductID == pending.SubscriptionAutoRenewProductID {
-		return "", nil, false, nil
-	}
-
-	receiptInfos := parseResult.AppleReceipt.LatestReceiptInfo
-	if len(receiptInfos) == 0 {
-		// Fallback to in_app when latest_receipt_info is absent.
-		receiptInfos = parseResult.AppleReceipt.Receipt.InApp
-	}
-
-	var latestMS int64
-	matchedReceipt := false
-	missingExpires := false
-	invalidExpires := false
-	for _, info := range receiptInfos {
-		if string(info.OriginalTransactionID) != pending.OriginalTransactionID || info.ProductID != pending.ProductID {
-			continue
-		}
-		matchedReceipt = true
-		if info.ExpiresDateMS == "" {
-			missingExpires = true
-			continue
-		}
-		ms, err := strconv.ParseInt(info.ExpiresDateMS, 10, 64)
-		if err != nil {
-			invalidExpires = true
-			continue
-		}
-		if ms > latestMS {
-			latestMS = ms
-		}
-	}
-	if latestMS == 0 {
-		if matchedReceipt && (missingExpires || invalidExpires) {
-			if invalidExpires {
-				return "", nil, false, fmt.Errorf("invalid expires_date_ms in receipt for original_transaction_id=%s", pending.OriginalTransactionID)
-			}
-			return "", nil, false, fmt.Errorf("missing expires_date_ms in receipt for original_transaction_id=%s", pending.OriginalTransactionID)
-		}
-		return "", nil, false, nil
-	}
-
-	if lookup == nil {
-		return "", nil, false, fmt.Errorf("apple payment item lookup is nil")
-	}
-	item, err := lookup(ctx, types.PaymentProviderApple, pending.SubscriptionAutoRenewProductID)
-	if err != nil {
-		return "", nil, false, fmt.Errorf("failed to lookup payment item by provider item id=%s: %w", pending.SubscriptionAutoRenewProductID, err)
-	}
-	if item == nil || item.ID == "" {
-		return "", nil, false, fmt.Errorf("payment item not found for provider item id=%s", pending.SubscriptionAutoRenewProductID)
-	}
-
-	next := time.UnixMilli(latestMS)
-	return item.ID, &next, true, nil
+// ApplePendingDowngrade returns the change Apple scheduled for the next renewal of a subscription whose
+// current product is productID, from the signed renewal info: autoRenewProductID is the product it renews
+// into at renewalDateMS, in Unix milliseconds. Apple applies upgrades immediately, so a different renewal
+// product is a downgrade, or a crossgrade when the levels of both items in their subscription group are equal.
+// It returns nil when auto-renew is off, the product does not change, or the renewal product is not in the
+// catalog.
+func ApplePendingDowngrade(ctx context.Context, cfg *config.Config, productID, autoRenewProductID string, autoRenewOn bool, renewalDateMS int64) *models.PendingDowngrade {
+	if !autoRenewOn || renewalDateMS <= 0 || autoRenewProductID == "" || autoRenewProductID == productID {
+		return nil
+	}
+	next, err := cfg.GetPaymentItemByProviderItemID(ctx, types.PaymentProviderApple, autoRenewProductID)
+	if err != nil || next == nil {
+		return nil
+	}
+	current, err := cfg.GetPaymentItemByProviderItemID(ctx, types.PaymentProviderApple, productID)
+	if err == nil && types.ClassifyPlanChange(current, next) == types.PlanChangeUpgrade {
+		// Only possible when the catalog levels disagree with the App Store; the upgrade arrives as a new transaction.
+		return nil
+	}
+	return &models.PendingDowngrade{PaymentItemID: next.ID, EffectiveAt: time.UnixMilli(renewalDateMS)}
 }
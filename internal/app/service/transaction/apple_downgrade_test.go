@@ -2,192 +2,36 @@ package transaction
 
 import (
 	"context"
-	"fmt"
 	"testing"
 	"time"
 
-	"github.com/awa/go-iap/appstore"
-	"github.com/awa/go-iap/appstore/api"
+	"github.com/fatflowers/cashier/pkg/config"
 	types "github.com/fatflowers/cashier/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
-func TestDetectAppleDowngrade_Success(t *testing.T) {
+func TestApplePendingDowngrade(t *testing.T) {
 	ctx := context.Background()
-
-	lookup := func(_ context.Context, _ types.PaymentProvider, providerItemID string) (*types.PaymentItem, error) {
-		require.Equal(t, "vip.low.month", providerItemID)
-		return &types.PaymentItem{ID: "vip_low"}, nil
-	}
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: []appstore.InApp{{
-			OriginalTransactionID: "orig-1",
-			ProductID:             "vip.high.month",
-			ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: "1770724800000"},
-		}},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, lookup)
-	require.NoError(t, err)
-	require.True(t, ok)
-	require.Equal(t, "vip_low", vipID)
-	require.Equal(t, time.UnixMilli(1770724800000), *nextAt)
-}
-
-func TestDetectAppleDowngrade_FallbackToReceiptInApp(t *testing.T) {
-	ctx := context.Background()
-
-	lookup := func(_ context.Context, _ types.PaymentProvider, providerItemID string) (*types.PaymentItem, error) {
-		require.Equal(t, "vip.low.month", providerItemID)
-		return &types.PaymentItem{ID: "vip_low"}, nil
-	}
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: nil,
-		Receipt: appstore.Receipt{
-			InApp: []appstore.InApp{{
-				OriginalTransactionID: "orig-1",
-				ProductID:             "vip.high.month",
-				ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: "1770724800000"},
-			}},
-		},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, lookup)
-	require.NoError(t, err)
-	require.True(t, ok)
-	require.Equal(t, "vip_low", vipID)
-	require.Equal(t, time.UnixMilli(1770724800000), *nextAt)
-}
-
-func TestDetectAppleDowngrade_LookupError_ReturnsWarningError(t *testing.T) {
-	ctx := context.Background()
-
-	lookup := func(_ context.Context, _ types.PaymentProvider, _ string) (*types.PaymentItem, error) {
-		return nil, fmt.Errorf("lookup failed")
-	}
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: []appstore.InApp{{
-			OriginalTransactionID: "orig-1",
-			ProductID:             "vip.high.month",
-			ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: "1770724800000"},
-		}},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, lookup)
-	require.Error(t, err)
-	require.False(t, ok)
-	require.Empty(t, vipID)
-	require.Nil(t, nextAt)
-}
-
-func TestDetectAppleDowngrade_AutoRenewOff(t *testing.T) {
-	ctx := context.Background()
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "0",
-		}},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, nil)
-	require.NoError(t, err)
-	require.False(t, ok)
-	require.Empty(t, vipID)
-	require.Nil(t, nextAt)
-}
-
-func TestDetectAppleDowngrade_ExpiresMissing(t *testing.T) {
-	ctx := context.Background()
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: []appstore.InApp{{
-			OriginalTransactionID: "orig-1",
-			ProductID:             "vip.high.month",
-			ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: ""},
-		}},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, nil)
-	require.Error(t, err)
-	require.False(t, ok)
-	require.Empty(t, vipID)
-	require.Nil(t, nextAt)
-}
-
-func TestDetectAppleDowngrade_ExpiresInvalid(t *testing.T) {
-	ctx := context.Background()
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: []appstore.InApp{{
-			OriginalTransactionID: "orig-1",
-			ProductID:             "vip.high.month",
-			ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: "bad"},
-		}},
-	}}
-
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, nil)
-	require.Error(t, err)
-	require.False(t, ok)
-	require.Empty(t, vipID)
-	require.Nil(t, nextAt)
-}
-
-func TestDetectAppleDowngrade_OriginalTransactionMismatch(t *testing.T) {
-	ctx := context.Background()
-
-	parseResult := &VerifiedData{AppleReceipt: &appstore.IAPResponse{
-		PendingRenewalInfo: []appstore.PendingRenewalInfo{{
-			OriginalTransactionID:          "orig-1",
-			ProductID:                      "vip.high.month",
-			SubscriptionAutoRenewProductID: "vip.low.month",
-			SubscriptionAutoRenewStatus:    "1",
-		}},
-		LatestReceiptInfo: []appstore.InApp{{
-			OriginalTransactionID: "orig-other",
-			ProductID:             "vip.high.month",
-			ExpiresDate:           appstore.ExpiresDate{ExpiresDateMS: "1770724800000"},
-		}},
+	renewAtMS := int64(1770724800000)
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip_high", ProviderID: types.PaymentProviderApple, ProviderItemID: "vip.high.month", SubscriptionGroup: "vip", Level: 2},
+		{ID: "vip_low", ProviderID: types.PaymentProviderApple, ProviderItemID: "vip.low.month", SubscriptionGroup: "vip", Level: 1},
+		{ID: "vip_high_year", ProviderID: types.PaymentProviderApple, ProviderItemID: "vip.high.year", SubscriptionGroup: "vip", Level: 2},
 	}}
 
-	vipID, nextAt, ok, err := detectAppleDowngrade(ctx, parseResult, &api.JWSTransaction{OriginalTransactionId: "orig-1"}, nil)
-	require.NoError(t, err)
-	require.False(t, ok)
-	require.Empty(t, vipID)
-	require.Nil(t, nextAt)
+	got := ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.low.month", true, renewAtMS)
+	require.NotNil(t, got)
+	require.Equal(t, "vip_low", got.PaymentItemID)
+	require.True(t, time.UnixMilli(renewAtMS).Equal(got.EffectiveAt))
+
+	// A crossgrade also waits for the renewal.
+	got = ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.high.year", true, renewAtMS)
+	require.NotNil(t, got)
+	require.Equal(t, "vip_high_year", got.PaymentItemID)
+
+	require.Nil(t, ApplePendingDowngrade(ctx, cfg, "vip.low.month", "vip.high.month", true, renewAtMS), "upgrades are not pending")
+	require.Nil(t, ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.high.month", true, renewAtMS), "same product")
+	require.Nil(t, ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.low.month", false, renewAtMS), "auto-renew off")
+	require.Nil(t, ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.unknown", true, renewAtMS), "unknown product")
+	require.Nil(t, ApplePendingDowngrade(ctx, cfg, "vip.high.month", "vip.low.month", true, 0), "no renewal date")
 }
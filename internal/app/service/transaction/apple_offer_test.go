@@ -0,0 +1,44 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/api"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppleToTransaction_RecordsStorefrontAndOffer(t *testing.T) {
+	day := int64(24)
+	a := &AppleTransactionManager{cfg: &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "day_pass", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.example.day", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &day},
+	}}}
+	token, err := apple_iap.UserIDToUUID("10001")
+	require.NoError(t, err)
+	ti := &api.JWSTransaction{
+		TransactionID:     "1000",
+		ProductID:         "com.example.day",
+		AppAccountToken:   token,
+		Type:              api.NonRenewable,
+		PurchaseDate:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+		Storefront:        "DEU",
+		OfferType:         2,
+		OfferIdentifier:   "spring",
+		OfferDiscountType: api.OfferDiscountTypePayUpFront,
+	}
+
+	txn, err := a.toTransaction(context.Background(), ti)
+	require.NoError(t, err)
+	require.Equal(t, "DEU", txn.Storefront)
+	require.Equal(t, &models.Offer{Type: models.OfferTypePromotional, ID: "spring", DiscountType: "PAY_UP_FRONT"}, txn.Extra.Data().Offer)
+
+	ti.OfferType = 0
+	txn, err = a.toTransaction(context.Background(), ti)
+	require.NoError(t, err)
+	require.Nil(t, txn.Extra.Data().Offer)
+}
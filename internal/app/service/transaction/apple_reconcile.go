@@ -0,0 +1,362 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/scheduler"
+	"github.com/fatflowers/cashier/internal/app/service/subscription"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAppleReconcileWindow        = 72 * time.Hour
+	defaultAppleReconcileHistoryWindow = 90 * 24 * time.Hour
+	appleReconcileBatchSize            = 100
+)
+
+// AppleReconciler compares auto-renewable Apple subscriptions near expiry with the App Store Server API and
+// applies what the notifications missed, such as a renewal or a refund. Every difference is recorded in
+// reconcile_drift.
+type AppleReconciler struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	apple  *AppleTransactionManager
+	subSvc *subscription.Service
+	log    *zap.SugaredLogger
+}
+
+func NewAppleReconciler(cfg *config.Config, db *gorm.DB, apple *AppleTransactionManager, sub *subscription.Service, log *zap.SugaredLogger) *AppleReconciler {
+	return &AppleReconciler{cfg: cfg, db: db, apple: apple, subSvc: sub, log: log}
+}
+
+// AppleReconcileResult summarizes a reconciliation run; the drifts are stored under RunID.
+type AppleReconcileResult struct {
+	RunID   string `json:"run_id"`
+	Users   int    `json:"users"`
+	Chains  int    `json:"chains"`
+	Drifts  int    `json:"drifts"`
+	Applied int    `json:"applied"`
+	Failed  int    `json:"failed"`
+}
+
+// Reconcile compares the Apple renewal chains of every subscription expiring within the configured window of now,
+// and of every subscription in billing retry, which has no expiry.
+// Failures of a single chain are counted and logged; an error means the subscriptions could not be listed.
+func (r *AppleReconciler) Reconcile(ctx context.Context, now time.Time) (*AppleReconcileResult, error) {
+	window := r.cfg.AppleIAP.Reconcile.Window
+	if window <= 0 {
+		window = defaultAppleReconcileWindow
+	}
+	res := &AppleReconcileResult{RunID: tool.GenerateUUIDV7()}
+
+	var lastID string
+	for {
+		q := r.db.WithContext(ctx).Model(&models.Subscription{}).
+			Where("(expire_at BETWEEN ? AND ? OR (expire_at IS NULL AND status = ?))", now.Add(-window), now.Add(window), types.SubscriptionStatusBillingRetry)
+		if lastID != "" {
+			q = q.Where("id > ?", lastID)
+		}
+		var subs []*models.Subscription
+		if err := q.Order("id").Limit(appleReconcileBatchSize).Find(&subs).Error; err != nil {
+			return res, fmt.Errorf("failed to list subscriptions near expiry: %w", err)
+		}
+		for _, sub := range subs {
+			if err := r.reconcileUser(ctx, res, sub.UserID, now, window); err != nil {
+				if ctx.Err() != nil {
+					return res, ctx.Err()
+				}
+				res.Failed++
+				r.log.Errorw("failed to reconcile user", "run_id", res.RunID, "user_id", sub.UserID, "error", err.Error())
+			}
+		}
+		if len(subs) < appleReconcileBatchSize {
+			return res, nil
+		}
+		lastID = subs[len(subs)-1].ID
+	}
+}
+
+func (r *AppleReconciler) reconcileUser(ctx context.Context, res *AppleReconcileResult, userID string, now time.Time, window time.Duration) error {
+	stored, err := r.subSvc.GetAllUserTransactions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	res.Users++
+	chains := appleRenewalChainsNearExpiry(stored, now.Add(-window), now.Add(window))
+	for _, originalTransactionID := range lo.Keys(chains) {
+		res.Chains++
+		if err := r.reconcileChain(ctx, res, userID, originalTransactionID, chains[originalTransactionID], now); err != nil {
+			return fmt.Errorf("failed to reconcile chain %s: %w", originalTransactionID, err)
+		}
+	}
+	return nil
+}
+
+// appleRenewalChainsNearExpiry groups the stored auto-renewable Apple transactions by original transaction,
+// keeping the chains whose latest period ends between from and to or is in billing retry.
+func appleRenewalChainsNearExpiry(stored []*models.Transaction, from, to time.Time) map[string][]*models.Transaction {
+	chains := lo.GroupBy(lo.Filter(stored, func(t *models.Transaction, _ int) bool {
+		return t.ProviderID == types.PaymentProviderApple && t.AutoRenewExpireAt != nil && t.ParentTransactionID != nil
+	}), func(t *models.Transaction) string { return *t.ParentTransactionID })
+	for id, txns := range chains {
+		latest := lo.MaxBy(txns, func(a, b *models.Transaction) bool { return a.PurchaseAt.After(b.PurchaseAt) })
+		if latest.IsInBillingRetry() {
+			continue
+		}
+		if latest.AutoRenewExpireAt.Before(from) || latest.AutoRenewExpireAt.After(to) {
+			delete(chains, id)
+		}
+	}
+	return chains
+}
+
+func (r *AppleReconciler) reconcileChain(ctx context.Context, res *AppleReconcileResult, userID, originalTransactionID string, stored []*models.Transaction, now time.Time) error {
+	remote, err := r.fetchAppleChain(ctx, originalTransactionID, now)
+	if err != nil {
+		return err
+	}
+	storedByID := lo.KeyBy(stored, func(t *models.Transaction) string { return t.TransactionID })
+
+	for i, item := range remote {
+		latest := i == len(remote)-1
+		// stored was read without the user lock, so it only rules out transactions without drift; the drift
+		// applied is computed again under the lock.
+		if len(appleTransactionDrift(storedByID[item.TransactionID], item, latest)) == 0 {
+			continue
+		}
+
+		before := storedByID[item.TransactionID]
+		var kinds []string
+		var applyErr error
+		if item.UserID != userID {
+			kinds = appleTransactionDrift(before, item, latest)
+			applyErr = fmt.Errorf("transaction belongs to user %s", item.UserID)
+		} else {
+			applyErr = r.subSvc.UpsertUserSubscriptionByItem(ctx, item,
+				subscription.WithChangeReason(types.UserSubscriptionChangeReasonReconcile),
+				subscription.WithStoredCheck(func(stored *models.Transaction) error {
+					before = stored
+					if kinds = appleTransactionDrift(stored, item, latest); len(kinds) == 0 {
+						return subscription.ErrUpsertSkipped
+					}
+					prepareReconciledTransaction(stored, item, latest)
+					return nil
+				}))
+			if errors.Is(applyErr, subscription.ErrUpsertSkipped) {
+				continue
+			}
+			if applyErr != nil && len(kinds) == 0 {
+				kinds = appleTransactionDrift(before, item, latest)
+			}
+		}
+
+		drift := &models.ReconcileDrift{
+			ID:                    tool.GenerateUUIDV7(),
+			RunID:                 res.RunID,
+			UserID:                userID,
+			ProviderID:            types.PaymentProviderApple,
+			OriginalTransactionID: originalTransactionID,
+			TransactionID:         item.TransactionID,
+			Kinds:                 datatypes.NewJSONType(kinds),
+			Before:                datatypes.NewJSONType(before),
+			After:                 datatypes.NewJSONType(item),
+			Applied:               applyErr == nil,
+			CreatedAt:             time.Now(),
+		}
+		if applyErr != nil {
+			drift.Error = lo.ToPtr(applyErr.Error())
+		}
+
+		res.Drifts++
+		if drift.Applied {
+			res.Applied++
+		} else {
+			res.Failed++
+		}
+		r.log.Infow("apple subscription drift", "run_id", res.RunID, "user_id", userID, "transaction_id", item.TransactionID, "kinds", kinds, "applied", drift.Applied)
+		if err := r.db.WithContext(ctx).Create(drift).Error; err != nil {
+			return fmt.Errorf("failed to save reconcile drift: %w", err)
+		}
+	}
+	return nil
+}
+
+// fetchAppleChain returns the transactions Apple reports for a renewal chain within the history window, oldest first.
+func (r *AppleReconciler) fetchAppleChain(ctx context.Context, originalTransactionID string, now time.Time) ([]*models.Transaction, error) {
+	historyWindow := r.cfg.AppleIAP.Reconcile.HistoryWindow
+	if historyWindow <= 0 {
+		historyWindow = defaultAppleReconcileHistoryWindow
+	}
+	cli := r.apple.iapClient
+
+	statuses, err := cli.GetALLSubscriptionStatuses(ctx, originalTransactionID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription status: %w", err)
+	}
+	query := url.Values{}
+	query.Set("startDate", strconv.FormatInt(now.Add(-historyWindow).UnixMilli(), 10))
+	query.Set("productType", "AUTO_RENEWABLE")
+	history, err := cli.GetTransactionHistory(ctx, originalTransactionID, &query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction history: %w", err)
+	}
+
+	signed := lo.FlatMap(history, func(h *api.HistoryResponse, _ int) []string { return h.SignedTransactions })
+	// The status carries the latest transaction even when the history has not caught up with it.
+	for _, group := range statuses.Data {
+		for _, last := range group.LastTransactions {
+			if last.OriginalTransactionId == originalTransactionID && last.SignedTransactionInfo != "" {
+				signed = append(signed, last.SignedTransactionInfo)
+			}
+		}
+	}
+	infos, err := cli.ParseSignedTransactions(signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed transactions: %w", err)
+	}
+
+	byID := map[string]*models.Transaction{}
+	for _, ti := range infos {
+		if ti.OriginalTransactionId != originalTransactionID || ti.Type != api.AutoRenewable {
+			continue
+		}
+		item, err := r.apple.toTransactionWithStatuses(ctx, ti, statuses)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map transaction %s: %w", ti.TransactionID, err)
+		}
+		byID[item.TransactionID] = item
+	}
+	res := lo.Values(byID)
+	sort.Slice(res, func(i, j int) bool { return res[i].PurchaseAt.Before(res[j].PurchaseAt) })
+	return res, nil
+}
+
+// appleTransactionDrift lists how remote differs from the stored transaction. Renewal state is only compared
+// for the latest transaction of the chain; earlier ones keep what was stored when they were current.
+func appleTransactionDrift(stored, remote *models.Transaction, latest bool) []string {
+	if stored == nil {
+		return []string{models.ReconcileDriftMissingTransaction}
+	}
+	var kinds []string
+	switch {
+	case stored.RefundAt == nil && remote.RefundAt != nil:
+		kinds = append(kinds, models.ReconcileDriftRefund)
+	case stored.RefundAt != nil && remote.RefundAt == nil:
+		kinds = append(kinds, models.ReconcileDriftRefundReversed)
+	}
+	if !equalTimePtr(stored.AutoRenewExpireAt, remote.AutoRenewExpireAt) {
+		kinds = append(kinds, models.ReconcileDriftExpireAt)
+	}
+	if !latest {
+		return kinds
+	}
+	if !equalTimePtr(stored.NextAutoRenewAt, remote.NextAutoRenewAt) {
+		kinds = append(kinds, models.ReconcileDriftAutoRenew)
+	}
+	if !equalTimePtr(stored.GetGracePeriodExpireAt(), remote.GetGracePeriodExpireAt()) {
+		kinds = append(kinds, models.ReconcileDriftGracePeriod)
+	}
+	if stored.IsInBillingRetry() != remote.IsInBillingRetry() {
+		kinds = append(kinds, models.ReconcileDriftBillingRetry)
+	}
+	return kinds
+}
+
+// prepareReconciledTransaction keeps what Apple's signed transactions do not carry: the upgrade link, and the
+// renewal state and pending downgrade of an earlier transaction of the chain.
+func prepareReconciledTransaction(stored, remote *models.Transaction, latest bool) {
+	if stored == nil {
+		return
+	}
+	remote.BeforeUpgradedTransactionID = stored.BeforeUpgradedTransactionID
+	extra := remote.Extra.Data()
+	if extra == nil {
+		extra = &models.UserSubscriptionItemExtra{}
+	}
+	storedExtra := stored.Extra.Data()
+	if storedExtra == nil {
+		storedExtra = &models.UserSubscriptionItemExtra{}
+	}
+	if !latest {
+		extra.PendingDowngrade = storedExtra.PendingDowngrade
+		remote.NextAutoRenewAt = stored.NextAutoRenewAt
+		extra.GracePeriodExpireAt = storedExtra.GracePeriodExpireAt
+		extra.InBillingRetry = storedExtra.InBillingRetry
+	}
+	remote.Extra = datatypes.NewJSONType(extra)
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// ListReconcileDriftsRequest filters the drift report; empty fields match everything.
+type ListReconcileDriftsRequest struct {
+	RunID  string `json:"run_id"`
+	UserID string `json:"user_id"`
+	From   int    `json:"from"`
+	Size   int    `json:"size"`
+}
+
+type ListReconcileDriftsResponse struct {
+	Items []*models.ReconcileDrift `json:"items"`
+	Total int64                    `json:"total"`
+}
+
+// ListDrifts returns recorded drifts, newest first.
+func (r *AppleReconciler) ListDrifts(ctx context.Context, req *ListReconcileDriftsRequest) (*ListReconcileDriftsResponse, error) {
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	q := r.db.WithContext(ctx).Model(&models.ReconcileDrift{})
+	if req.RunID != "" {
+		q = q.Where("run_id = ?", req.RunID)
+	}
+	if req.UserID != "" {
+		q = q.Where("user_id = ?", req.UserID)
+	}
+	res := &ListReconcileDriftsResponse{}
+	if err := q.Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count reconcile drifts: %w", err)
+	}
+	if err := q.Order("created_at desc").Offset(req.From).Limit(req.Size).Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list reconcile drifts: %w", err)
+	}
+	return res, nil
+}
+
+// AppleReconcileJob is the scheduler job that runs Reconcile.
+const AppleReconcileJob = "apple_reconcile"
+
+func registerAppleReconcileJob(sched *scheduler.Scheduler, cfg *config.Config, r *AppleReconciler) error {
+	var spec string
+	if interval := cfg.AppleIAP.Reconcile.Interval; interval > 0 {
+		spec = "@every " + interval.String()
+	}
+	return sched.Register(AppleReconcileJob, spec, func(ctx context.Context, now time.Time) error {
+		res, err := r.Reconcile(ctx, now)
+		if err != nil {
+			return err
+		}
+		r.log.Infow("apple reconcile finished", "run_id", res.RunID, "users", res.Users, "chains", res.Chains, "drifts", res.Drifts, "applied", res.Applied, "failed", res.Failed)
+		return nil
+	})
+}
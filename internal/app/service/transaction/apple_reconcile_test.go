@@ -0,0 +1,99 @@
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestAppleRenewalChainsNearExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	month := 30 * 24 * time.Hour
+	txn := func(id, original string, purchaseAt time.Time) *models.Transaction {
+		return &models.Transaction{
+			ProviderID:          types.PaymentProviderApple,
+			TransactionID:       id,
+			ParentTransactionID: lo.ToPtr(original),
+			PurchaseAt:          purchaseAt,
+			AutoRenewExpireAt:   lo.ToPtr(purchaseAt.Add(month)),
+		}
+	}
+	stored := []*models.Transaction{
+		// Chain "a" renews tomorrow.
+		txn("a1", "a", now.Add(-2*month+24*time.Hour)),
+		txn("a2", "a", now.Add(-month+24*time.Hour)),
+		// Chain "b" lapsed long ago.
+		txn("b1", "b", now.Add(-6*month)),
+		{ProviderID: types.PaymentProviderInner, TransactionID: "gift", PurchaseAt: now},
+	}
+	// Chain "c" lapsed weeks ago but Apple is still retrying the renewal.
+	retrying := txn("c1", "c", now.Add(-2*month))
+	retrying.Extra = datatypes.NewJSONType(&models.UserSubscriptionItemExtra{InBillingRetry: true})
+	stored = append(stored, retrying)
+
+	chains := appleRenewalChainsNearExpiry(stored, now.Add(-72*time.Hour), now.Add(72*time.Hour))
+	require.Len(t, chains, 2)
+	require.Len(t, chains["a"], 2)
+	require.Len(t, chains["c"], 1)
+}
+
+func TestAppleTransactionDrift(t *testing.T) {
+	expireAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	renewAt := expireAt
+	stored := &models.Transaction{
+		TransactionID:     "1000",
+		AutoRenewExpireAt: lo.ToPtr(expireAt),
+		NextAutoRenewAt:   lo.ToPtr(renewAt),
+		Extra:             datatypes.NewJSONType(&models.UserSubscriptionItemExtra{}),
+	}
+	same := *stored
+	require.Empty(t, appleTransactionDrift(stored, &same, true))
+	require.Equal(t, []string{models.ReconcileDriftMissingTransaction}, appleTransactionDrift(nil, &same, true))
+
+	refunded := same
+	refunded.RefundAt = lo.ToPtr(expireAt.Add(-time.Hour))
+	refunded.NextAutoRenewAt = nil
+	require.Equal(t, []string{models.ReconcileDriftRefund, models.ReconcileDriftAutoRenew}, appleTransactionDrift(stored, &refunded, true))
+	// Renewal state of an earlier transaction is not compared.
+	require.Equal(t, []string{models.ReconcileDriftRefund}, appleTransactionDrift(stored, &refunded, false))
+	require.Equal(t, []string{models.ReconcileDriftRefundReversed}, appleTransactionDrift(&refunded, stored, false))
+
+	retrying := same
+	retrying.Extra = datatypes.NewJSONType(&models.UserSubscriptionItemExtra{InBillingRetry: true, GracePeriodExpireAt: lo.ToPtr(expireAt.Add(6 * 24 * time.Hour))})
+	require.Equal(t, []string{models.ReconcileDriftGracePeriod, models.ReconcileDriftBillingRetry}, appleTransactionDrift(stored, &retrying, true))
+
+	extended := same
+	extended.AutoRenewExpireAt = lo.ToPtr(expireAt.Add(7 * 24 * time.Hour))
+	require.Equal(t, []string{models.ReconcileDriftExpireAt}, appleTransactionDrift(stored, &extended, false))
+}
+
+func TestPrepareReconciledTransaction(t *testing.T) {
+	renewAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	downgrade := &models.PendingDowngrade{PaymentItemID: "vip_month_basic", EffectiveAt: renewAt}
+	stored := &models.Transaction{
+		BeforeUpgradedTransactionID: lo.ToPtr("999"),
+		NextAutoRenewAt:             lo.ToPtr(renewAt),
+		Extra:                       datatypes.NewJSONType(&models.UserSubscriptionItemExtra{PendingDowngrade: downgrade}),
+	}
+
+	earlier := &models.Transaction{Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{InBillingRetry: true})}
+	prepareReconciledTransaction(stored, earlier, false)
+	require.Equal(t, "999", *earlier.BeforeUpgradedTransactionID)
+	require.Equal(t, downgrade, earlier.Extra.Data().PendingDowngrade)
+	require.True(t, renewAt.Equal(*earlier.NextAutoRenewAt))
+	require.False(t, earlier.IsInBillingRetry())
+
+	latest := &models.Transaction{Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{InBillingRetry: true})}
+	prepareReconciledTransaction(stored, latest, true)
+	require.Nil(t, latest.NextAutoRenewAt)
+	require.True(t, latest.IsInBillingRetry())
+
+	missing := &models.Transaction{}
+	prepareReconciledTransaction(nil, missing, true)
+	require.Nil(t, missing.BeforeUpgradedTransactionID)
+}
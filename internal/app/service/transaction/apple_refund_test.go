@@ -0,0 +1,51 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/api"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_iap"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppleToTransaction_RecordsRevocation(t *testing.T) {
+	day := int64(24)
+	a := &AppleTransactionManager{cfg: &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "day_pass", ProviderID: types.PaymentProviderApple, ProviderItemID: "com.example.day", Type: types.PaymentItemTypeNonRenewableSubscription, DurationHour: &day},
+	}}}
+	token, err := apple_iap.UserIDToUUID("10001")
+	require.NoError(t, err)
+	purchaseAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	revokedAt := purchaseAt.Add(time.Hour)
+	ti := &api.JWSTransaction{
+		TransactionID:   "1000",
+		ProductID:       "com.example.day",
+		AppAccountToken: token,
+		Type:            api.NonRenewable,
+		PurchaseDate:    purchaseAt.UnixMilli(),
+		RevocationDate:  revokedAt.UnixMilli(),
+	}
+
+	txn, err := a.toTransaction(context.Background(), ti)
+	require.NoError(t, err)
+	require.True(t, revokedAt.Equal(*txn.RefundAt))
+	require.True(t, revokedAt.Equal(*txn.RevocationDate))
+	require.Equal(t, models.RevocationReasonRefund, *txn.RevocationReason)
+
+	ti.RevocationReason = lo.ToPtr(int32(1))
+	txn, err = a.toTransaction(context.Background(), ti)
+	require.NoError(t, err)
+	require.Equal(t, models.RevocationReasonRefundAppIssue, *txn.RevocationReason)
+
+	ti.RevocationDate = 0
+	txn, err = a.toTransaction(context.Background(), ti)
+	require.NoError(t, err)
+	require.Nil(t, txn.RefundAt)
+	require.Nil(t, txn.RevocationReason)
+}
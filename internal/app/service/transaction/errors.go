@@ -3,3 +3,6 @@ package transaction
 import "errors"
 
 var ErrVerifyTransactionDuplicate = errors.New("verify transaction duplicate")
+
+// ErrRefundNotSupported is returned when the refund state of a provider's transactions cannot be synced.
+var ErrRefundNotSupported = errors.New("refund sync is not supported for provider")
@@ -0,0 +1,518 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	notificationlog "github.com/fatflowers/cashier/internal/app/service/notification_log"
+	"github.com/fatflowers/cashier/internal/app/service/subscription"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/google/google_play"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/logctx"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var ErrGooglePlayNotConfigured = errors.New("google play is not configured")
+
+const (
+	// googleVoidedPurchaseLookback is how long before its notification a voided purchase is looked up.
+	googleVoidedPurchaseLookback = 24 * time.Hour
+	// googleVoidedPurchaseHistory is how far back the voided purchases list goes.
+	googleVoidedPurchaseHistory = 30 * 24 * time.Hour
+)
+
+// GoogleTransactionManager processes Google Play Billing purchases
+type GoogleTransactionManager struct {
+	client   *google_play.Client
+	cfg      *config.Config
+	db       *gorm.DB
+	subSvc   *subscription.Service
+	notifSvc *notificationlog.Service
+	log      *zap.SugaredLogger
+}
+
+func NewGoogleTransactionManager(cfg *config.Config, db *gorm.DB, sub *subscription.Service, notif *notificationlog.Service, log *zap.SugaredLogger) (*GoogleTransactionManager, error) {
+	m := &GoogleTransactionManager{cfg: cfg, db: db, subSvc: sub, notifSvc: notif, log: log}
+	// Google Play is optional; without a package name every call returns ErrGooglePlayNotConfigured.
+	if cfg.GooglePlay.PackageName == "" {
+		return m, nil
+	}
+	cli, err := google_play.NewClient(&google_play.ClientOptions{
+		PackageName:       cfg.GooglePlay.PackageName,
+		ServiceAccountKey: cfg.GooglePlay.ServiceAccountKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init Google Play client: %w", err)
+	}
+	m.client = cli
+	return m, nil
+}
+
+// googlePurchase is a resolved Play purchase together with the data needed to acknowledge it.
+type googlePurchase struct {
+	transaction   *models.Transaction
+	productID     string
+	purchaseToken string
+	subscription  bool
+	acknowledged  bool
+}
+
+// getPaymentItem resolves a Play product. Subscriptions are looked up as "productId:basePlanId" first so
+// base plans of one subscription can map to different payment items, then by the bare product id.
+func (g *GoogleTransactionManager) getPaymentItem(ctx context.Context, productID, basePlanID string) (*types.PaymentItem, error) {
+	if basePlanID != "" {
+		if item, err := g.cfg.GetPaymentItemByProviderItemID(ctx, types.PaymentProviderGoogle, productID+":"+basePlanID); err == nil {
+			return item, nil
+		}
+	}
+	item, err := g.cfg.GetPaymentItemByProviderItemID(ctx, types.PaymentProviderGoogle, productID)
+	if err != nil {
+		return nil, fmt.Errorf("payment item not found for product: %s", productID)
+	}
+	return item, nil
+}
+
+// googleBaseOrderID strips the "..N" renewal suffix Google appends to the first order id of a subscription.
+func googleBaseOrderID(orderID string) string {
+	if i := strings.Index(orderID, ".."); i >= 0 {
+		return orderID[:i]
+	}
+	return orderID
+}
+
+func (g *GoogleTransactionManager) toSubscriptionTransaction(ctx context.Context, purchase *google_play.SubscriptionPurchaseV2) (*models.Transaction, *google_play.SubscriptionLineItem, error) {
+	if purchase.SubscriptionState == google_play.SubscriptionStatePending {
+		return nil, nil, fmt.Errorf("subscription purchase is pending")
+	}
+	if purchase.ExternalAccountIdentifiers == nil || purchase.ExternalAccountIdentifiers.ObfuscatedExternalAccountID == "" {
+		return nil, nil, fmt.Errorf("obfuscated external account id is empty")
+	}
+	if len(purchase.LineItems) == 0 {
+		return nil, nil, fmt.Errorf("subscription purchase has no line items")
+	}
+
+	var lineItem *google_play.SubscriptionLineItem
+	var paymentItem *types.PaymentItem
+	for _, li := range purchase.LineItems {
+		var basePlanID string
+		if li.OfferDetails != nil {
+			basePlanID = li.OfferDetails.BasePlanID
+		}
+		if item, err := g.getPaymentItem(ctx, li.ProductID, basePlanID); err == nil {
+			lineItem, paymentItem = li, item
+			break
+		}
+	}
+	if lineItem == nil {
+		return nil, nil, fmt.Errorf("payment item not found for product: %s", purchase.LineItems[0].ProductID)
+	}
+
+	orderID := purchase.LatestOrderID
+	if orderID == "" {
+		orderID = lineItem.LatestSuccessfulOrderID
+	}
+	if orderID == "" {
+		return nil, nil, fmt.Errorf("subscription purchase has no order id")
+	}
+
+	expireAt := google_play.ParseTime(lineItem.ExpiryTime)
+	if expireAt.IsZero() {
+		return nil, nil, fmt.Errorf("subscription expiry time is empty")
+	}
+
+	// subscriptionsv2 only reports the start of the whole subscription. For renewal orders the
+	// period start is derived from the expiry and the configured duration of the payment item.
+	purchaseAt := google_play.ParseTime(purchase.StartTime)
+	if orderID != googleBaseOrderID(orderID) && paymentItem.DurationHour != nil {
+		purchaseAt = expireAt.Add(-time.Duration(*paymentItem.DurationHour) * time.Hour)
+	}
+	if purchaseAt.IsZero() {
+		return nil, nil, fmt.Errorf("subscription start time is empty")
+	}
+
+	res := &models.Transaction{
+		UserID:              purchase.ExternalAccountIdentifiers.ObfuscatedExternalAccountID,
+		ProviderID:          types.PaymentProviderGoogle,
+		PaymentItemID:       paymentItem.ID,
+		TransactionID:       orderID,
+		ParentTransactionID: lo.ToPtr(googleBaseOrderID(orderID)),
+		PurchaseAt:          purchaseAt,
+		AutoRenewExpireAt:   lo.ToPtr(expireAt),
+		Storefront:          purchase.RegionCode,
+		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PaymentItemSnapshot: paymentItem,
+		}),
+	}
+	// Google offers have no type; their tags are configured in the Play Console.
+	if lineItem.OfferDetails != nil && lineItem.OfferDetails.OfferID != "" {
+		res.Extra.Data().Offer = &models.Offer{ID: lineItem.OfferDetails.OfferID}
+	}
+
+	if plan := lineItem.AutoRenewingPlan; plan != nil {
+		res.Price = googleMoney(plan.RecurringPrice)
+		if res.Extra.Data().Offer != nil {
+			// The recurring price is that of the base plan; the order has what the offer phase charged.
+			if price, err := g.orderPrice(ctx, orderID, lineItem.ProductID); err != nil {
+				g.log.Warnw("failed to load the offer price of a google play order, keeping the base plan price",
+					"order_id", orderID, "offer_id", lineItem.OfferDetails.OfferID, "error", err.Error())
+			} else {
+				res.Price = price
+			}
+		}
+		if plan.AutoRenewEnabled && (purchase.SubscriptionState == google_play.SubscriptionStateActive || purchase.SubscriptionState == google_play.SubscriptionStateInGracePeriod) {
+			res.NextAutoRenewAt = lo.ToPtr(expireAt)
+		}
+	}
+
+	// Google already reports the end of the grace period as the expiry; account hold has no access while
+	// Google retries the payment.
+	switch purchase.SubscriptionState {
+	case google_play.SubscriptionStateInGracePeriod:
+		res.Extra.Data().GracePeriodExpireAt = lo.ToPtr(expireAt)
+	case google_play.SubscriptionStateOnHold:
+		res.Extra.Data().InBillingRetry = true
+	}
+
+	return res, lineItem, nil
+}
+
+// orderPrice returns what the user paid for productID in the order orderID.
+func (g *GoogleTransactionManager) orderPrice(ctx context.Context, orderID, productID string) (types.Money, error) {
+	if g.client == nil {
+		return types.Money{}, ErrGooglePlayNotConfigured
+	}
+	order, err := g.client.GetOrder(ctx, orderID)
+	if err != nil {
+		return types.Money{}, err
+	}
+	for _, item := range order.LineItems {
+		if item.ProductID == productID && item.Total != nil {
+			return googleMoney(item.Total), nil
+		}
+	}
+	return types.Money{}, fmt.Errorf("order %s has no total for product %s", orderID, productID)
+}
+
+func (g *GoogleTransactionManager) toProductTransaction(ctx context.Context, productID string, purchase *google_play.ProductPurchase) (*models.Transaction, error) {
+	if purchase.PurchaseState == google_play.ProductPurchaseStatePending {
+		return nil, fmt.Errorf("product purchase is pending")
+	}
+	if purchase.ObfuscatedExternalAccountID == "" {
+		return nil, fmt.Errorf("obfuscated external account id is empty")
+	}
+	if purchase.OrderID == "" {
+		return nil, fmt.Errorf("product purchase has no order id")
+	}
+	paymentItem, err := g.getPaymentItem(ctx, productID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// purchases.products does not expose the price; it stays zero until reported by another source.
+	res := &models.Transaction{
+		UserID:        purchase.ObfuscatedExternalAccountID,
+		ProviderID:    types.PaymentProviderGoogle,
+		PaymentItemID: paymentItem.ID,
+		TransactionID: purchase.OrderID,
+		PurchaseAt:    purchase.PurchaseTime(),
+		Storefront:    purchase.RegionCode,
+		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PaymentItemSnapshot: paymentItem,
+		}),
+	}
+	if purchase.PurchaseState == google_play.ProductPurchaseStateCanceled {
+		refundAt, err := g.productRefundAt(ctx, purchase.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		res.RefundAt = &refundAt
+	}
+	return res, nil
+}
+
+// productRefundAt returns when the canceled product purchase orderID was refunded, so that verifying or
+// notifying it again does not move the refund: the time already stored, else the time Google voided it, else
+// now for a purchase Google does not list as voided.
+func (g *GoogleTransactionManager) productRefundAt(ctx context.Context, orderID string) (time.Time, error) {
+	var stored models.Transaction
+	err := g.db.WithContext(ctx).
+		Where("provider_id = ? AND transaction_id = ?", types.PaymentProviderGoogle, orderID).
+		Take(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, fmt.Errorf("failed to get transaction %s: %w", orderID, err)
+	}
+	if err == nil && stored.RefundAt != nil {
+		return *stored.RefundAt, nil
+	}
+	voided, err := g.findVoidedPurchase(ctx, time.Now().Add(-googleVoidedPurchaseHistory), func(v *google_play.VoidedPurchase) bool {
+		return v.OrderID == orderID
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lo.CoalesceOrEmpty(voided.VoidedTime(), time.Now()), nil
+}
+
+// resolvePurchase loads a purchase token from the Play Developer API. productID selects the one-time product
+// API when it maps to a non auto-renewable payment item; otherwise the token is treated as a subscription.
+func (g *GoogleTransactionManager) resolvePurchase(ctx context.Context, productID, purchaseToken string) (*googlePurchase, error) {
+	if g.client == nil {
+		return nil, ErrGooglePlayNotConfigured
+	}
+	if purchaseToken == "" {
+		return nil, fmt.Errorf("purchase token is empty")
+	}
+
+	if productID != "" {
+		if item, err := g.getPaymentItem(ctx, productID, ""); err == nil && !item.Renewable() {
+			purchase, err := g.client.GetProduct(ctx, productID, purchaseToken)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get product purchase: %w", err)
+			}
+			txn, err := g.toProductTransaction(ctx, productID, purchase)
+			if err != nil {
+				return nil, err
+			}
+			return &googlePurchase{
+				transaction:   txn,
+				productID:     productID,
+				purchaseToken: purchaseToken,
+				acknowledged:  purchase.AcknowledgementState != 0,
+			}, nil
+		}
+	}
+
+	purchase, err := g.client.GetSubscriptionV2(ctx, purchaseToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription purchase: %w", err)
+	}
+	txn, lineItem, err := g.toSubscriptionTransaction(ctx, purchase)
+	if err != nil {
+		return nil, err
+	}
+	return &googlePurchase{
+		transaction:   txn,
+		productID:     lineItem.ProductID,
+		purchaseToken: purchaseToken,
+		subscription:  true,
+		acknowledged:  purchase.AcknowledgementState != google_play.AcknowledgementStatePending,
+	}, nil
+}
+
+func (g *GoogleTransactionManager) acknowledge(ctx context.Context, p *googlePurchase) error {
+	if p.acknowledged {
+		return nil
+	}
+	if p.subscription {
+		return g.client.AcknowledgeSubscription(ctx, p.productID, p.purchaseToken)
+	}
+	return g.client.AcknowledgeProduct(ctx, p.productID, p.purchaseToken)
+}
+
+// GetTransactionByNotification maps a Real-time Developer Notification onto a transaction.
+// It returns nil for notifications that carry no purchase, such as test notifications. Voided purchases are
+// confirmed with the Play Developer API before the transaction is refunded.
+func (g *GoogleTransactionManager) GetTransactionByNotification(ctx context.Context, n *google_play.DeveloperNotification) (*models.Transaction, error) {
+	if n == nil {
+		return nil, fmt.Errorf("notification is empty")
+	}
+	eventTime := n.EventTime()
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+
+	switch {
+	case n.SubscriptionNotification != nil:
+		p, err := g.resolvePurchase(ctx, "", n.SubscriptionNotification.PurchaseToken)
+		if err != nil {
+			return nil, err
+		}
+		if n.SubscriptionNotification.NotificationType == google_play.SubscriptionNotificationTypeRevoked && p.transaction.RefundAt == nil {
+			p.transaction.RefundAt = lo.ToPtr(eventTime)
+		}
+		return p.transaction, nil
+	case n.OneTimeProductNotification != nil:
+		p, err := g.resolvePurchase(ctx, n.OneTimeProductNotification.SKU, n.OneTimeProductNotification.PurchaseToken)
+		if err != nil {
+			return nil, err
+		}
+		if n.OneTimeProductNotification.NotificationType == google_play.OneTimeProductNotificationTypeCanceled && p.transaction.RefundAt == nil {
+			p.transaction.RefundAt = lo.ToPtr(eventTime)
+		}
+		return p.transaction, nil
+	case n.VoidedPurchaseNotification != nil:
+		voidedAt, err := g.confirmVoidedPurchase(ctx, n.VoidedPurchaseNotification, eventTime)
+		if err != nil {
+			return nil, err
+		}
+		// Voided purchases only carry the order id; refund the transaction recorded earlier.
+		var item models.Transaction
+		err = g.db.WithContext(ctx).
+			Where("provider_id = ? AND transaction_id = ?", types.PaymentProviderGoogle, n.VoidedPurchaseNotification.OrderID).
+			First(&item).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to load voided transaction %s: %w", n.VoidedPurchaseNotification.OrderID, err)
+		}
+		if item.RefundAt == nil {
+			item.RefundAt = lo.ToPtr(voidedAt)
+		}
+		return &item, nil
+	default:
+		return nil, nil
+	}
+}
+
+// confirmVoidedPurchase looks a voided purchase notification up in the voided purchases list of the Play
+// Developer API, so a forged notification cannot refund a purchase, and returns when the purchase was voided.
+func (g *GoogleTransactionManager) confirmVoidedPurchase(ctx context.Context, n *google_play.VoidedPurchaseNotification, eventTime time.Time) (time.Time, error) {
+	voided, err := g.findVoidedPurchase(ctx, eventTime.Add(-googleVoidedPurchaseLookback), func(v *google_play.VoidedPurchase) bool {
+		return v.OrderID == n.OrderID && v.PurchaseToken == n.PurchaseToken
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if voided == nil {
+		return time.Time{}, fmt.Errorf("voided purchase %s is not in the voided purchases list", n.OrderID)
+	}
+	return lo.CoalesceOrEmpty(voided.VoidedTime(), eventTime), nil
+}
+
+// findVoidedPurchase returns the first purchase voided since start that matches, or nil.
+func (g *GoogleTransactionManager) findVoidedPurchase(ctx context.Context, start time.Time, match func(*google_play.VoidedPurchase) bool) (*google_play.VoidedPurchase, error) {
+	if g.client == nil {
+		return nil, ErrGooglePlayNotConfigured
+	}
+	end := time.Now()
+	pageToken := ""
+	for {
+		page, err := g.client.ListVoidedPurchases(ctx, start, end, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list voided purchases: %w", err)
+		}
+		for _, v := range page.VoidedPurchases {
+			if match(v) {
+				return v, nil
+			}
+		}
+		if pageToken = page.NextPageToken(); pageToken == "" {
+			return nil, nil
+		}
+	}
+}
+
+func (g *GoogleTransactionManager) VerifyTransaction(ctx context.Context, req *TransactionVerifyRequest) (*VerifyTransactionResult, error) {
+	result := &VerifyTransactionResult{}
+	logger := logctx.FromCtx(ctx, g.log)
+	var userIDPtr *string
+	if v, ok := ctx.Value("user_id").(string); ok && v != "" {
+		userIDPtr = &v
+	}
+	var traceID string
+	if v, ok := ctx.Value("traceID").(string); ok {
+		traceID = v
+	}
+	dataBytes, _ := json.Marshal(req)
+	g.notifSvc.Save(ctx, &models.PaymentNotificationLog{
+		ProviderID:       string(types.PaymentProviderGoogle),
+		UserID:           userIDPtr,
+		TraceID:          traceID,
+		TransactionID:    req.TransactionID,
+		NotificationTime: time.Now(),
+		Data:             datatypes.JSON(dataBytes),
+		Status:           models.PaymentNotificationLogStatusReceived,
+	})
+
+	var mappedItem *models.Transaction
+	var retErr error
+	defer func() {
+		resMap := map[string]any{
+			"membership_item": mappedItem,
+		}
+		if retErr != nil {
+			resMap["error"] = retErr.Error()
+		}
+		resBytes, _ := json.Marshal(resMap)
+		status := models.PaymentNotificationLogStatusHandled
+		if retErr != nil {
+			status = models.PaymentNotificationLogStatusHandleFailed
+		}
+		g.notifSvc.Save(ctx, &models.PaymentNotificationLog{
+			ProviderID: string(types.PaymentProviderGoogle),
+			UserID:     userIDPtr,
+			TraceID:    traceID,
+			TransactionID: func() string {
+				if mappedItem != nil {
+					return mappedItem.TransactionID
+				}
+				return req.TransactionID
+			}(),
+			NotificationTime: time.Now(),
+			Data:             datatypes.JSON(dataBytes),
+			Result:           func() *datatypes.JSON { j := datatypes.JSON(resBytes); return &j }(),
+			Status:           status,
+		})
+	}()
+
+	// On Android the purchase token is the server verification data.
+	purchase, err := g.resolvePurchase(ctx, req.ProductID, req.ServerVerificationData)
+	if err != nil {
+		retErr = fmt.Errorf("failed to resolve purchase: %w", err)
+		return nil, retErr
+	}
+	item := purchase.transaction
+	mappedItem = item
+
+	if err := g.subSvc.UpsertUserSubscriptionByItem(ctx, item); err != nil {
+		retErr = fmt.Errorf("failed to upsert membership: %w", err)
+		return nil, retErr
+	}
+
+	// Acknowledge only after the entitlement is persisted; a failure is retried on the next verify.
+	if err := g.acknowledge(ctx, purchase); err != nil {
+		logger.Warnw("acknowledge google purchase failed", "transaction_id", item.TransactionID, "error", err.Error())
+	}
+
+	result.UserTransaction = item
+	return result, nil
+}
+
+func (g *GoogleTransactionManager) ParseVerificationData(ctx context.Context, req *VerificationDataRequest) (*VerifiedData, error) {
+	return nil, fmt.Errorf("google play verification data parsing is not supported")
+}
+
+// RefundTransaction applies the voided state Google reports for the order transactionId, recovering a missed
+// voided purchase notification. Google only lists purchases voided in the last 30 days; outRefundId is not used.
+func (g *GoogleTransactionManager) RefundTransaction(ctx context.Context, transactionId string, outRefundId string) error {
+	if g.client == nil {
+		return ErrGooglePlayNotConfigured
+	}
+	var item models.Transaction
+	if err := g.db.WithContext(ctx).
+		Where("provider_id = ? AND transaction_id = ?", types.PaymentProviderGoogle, transactionId).
+		First(&item).Error; err != nil {
+		return fmt.Errorf("failed to get transaction %s: %w", transactionId, err)
+	}
+	if item.RefundAt != nil {
+		return nil
+	}
+	voided, err := g.findVoidedPurchase(ctx, time.Now().Add(-googleVoidedPurchaseHistory), func(v *google_play.VoidedPurchase) bool {
+		return v.OrderID == transactionId
+	})
+	if err != nil || voided == nil {
+		return err
+	}
+	item.RefundAt = lo.ToPtr(lo.CoalesceOrEmpty(voided.VoidedTime(), time.Now()))
+	if err := g.subSvc.UpsertUserSubscriptionByItem(ctx, &item); err != nil {
+		return fmt.Errorf("failed to upsert membership: %w", err)
+	}
+	return nil
+}
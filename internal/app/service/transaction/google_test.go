@@ -0,0 +1,172 @@
+package transaction
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/google/google_play"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGoogleBaseOrderID(t *testing.T) {
+	require.Equal(t, "GPA.1111-2222", googleBaseOrderID("GPA.1111-2222"))
+	require.Equal(t, "GPA.1111-2222", googleBaseOrderID("GPA.1111-2222..3"))
+}
+
+func TestGoogleToSubscriptionTransaction_RenewalUsesBasePlanItem(t *testing.T) {
+	month := int64(30 * 24)
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip_month", ProviderID: types.PaymentProviderGoogle, ProviderItemID: "vip", Type: types.PaymentItemTypeAutoRenewableSubscription, DurationHour: &month},
+		{ID: "vip_month_monthly", ProviderID: types.PaymentProviderGoogle, ProviderItemID: "vip:monthly", Type: types.PaymentItemTypeAutoRenewableSubscription, DurationHour: &month},
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /androidpublisher/v3/applications/com.example.app/orders/GPA.1111-2222..2", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"orderId": "GPA.1111-2222..2", "lineItems": [{"productId": "vip", "total": {"currencyCode": "USD", "units": "2", "nanos": 490000000}}]}`))
+	})
+	g := newGoogleTestManager(t, mux)
+	g.cfg, g.log = cfg, zap.NewNop().Sugar()
+
+	purchase := &google_play.SubscriptionPurchaseV2{
+		LatestOrderID:              "GPA.1111-2222..2",
+		RegionCode:                 "US",
+		SubscriptionState:          google_play.SubscriptionStateActive,
+		StartTime:                  "2026-01-01T00:00:00Z",
+		ExternalAccountIdentifiers: &google_play.ExternalAccountIdentifiers{ObfuscatedExternalAccountID: "u1"},
+		LineItems: []*google_play.SubscriptionLineItem{{
+			ProductID:    "vip",
+			ExpiryTime:   "2026-03-31T00:00:00Z",
+			OfferDetails: &google_play.OfferDetails{BasePlanID: "monthly", OfferID: "winback-50"},
+			AutoRenewingPlan: &google_play.AutoRenewingPlan{
+				AutoRenewEnabled: true,
+				RecurringPrice:   &google_play.Money{CurrencyCode: "USD", Units: "4", Nanos: 990000000},
+			},
+		}},
+	}
+
+	txn, lineItem, err := g.toSubscriptionTransaction(context.Background(), purchase)
+	require.NoError(t, err)
+	require.Equal(t, "vip", lineItem.ProductID)
+	require.Equal(t, "u1", txn.UserID)
+	require.Equal(t, "vip_month_monthly", txn.PaymentItemID)
+	require.Equal(t, "GPA.1111-2222..2", txn.TransactionID)
+	require.Equal(t, "GPA.1111-2222", *txn.ParentTransactionID)
+	// The order has the price of the offer phase, not the base plan's recurring price.
+	require.Equal(t, types.Money{Amount: 249, Currency: "USD", Exponent: 2}, txn.Price)
+	require.Equal(t, "US", txn.Storefront)
+	require.Equal(t, &models.Offer{ID: "winback-50"}, txn.Extra.Data().Offer)
+	expire := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	require.True(t, expire.Equal(*txn.AutoRenewExpireAt))
+	require.True(t, expire.Equal(*txn.NextAutoRenewAt))
+	require.True(t, expire.Add(-30*24*time.Hour).Equal(txn.PurchaseAt))
+
+	// Without access to the order the base plan price is kept.
+	purchase.LatestOrderID = "GPA.1111-2222..3"
+	txn, _, err = g.toSubscriptionTransaction(context.Background(), purchase)
+	require.NoError(t, err)
+	require.Equal(t, types.Money{Amount: 499, Currency: "USD", Exponent: 2}, txn.Price)
+}
+
+func TestGoogleToSubscriptionTransaction_GracePeriodAndAccountHold(t *testing.T) {
+	month := int64(30 * 24)
+	g := &GoogleTransactionManager{cfg: &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip_month", ProviderID: types.PaymentProviderGoogle, ProviderItemID: "vip", Type: types.PaymentItemTypeAutoRenewableSubscription, DurationHour: &month},
+	}}}
+	purchase := func(state string) *google_play.SubscriptionPurchaseV2 {
+		return &google_play.SubscriptionPurchaseV2{
+			LatestOrderID:              "GPA.1111-2222",
+			SubscriptionState:          state,
+			StartTime:                  "2026-01-01T00:00:00Z",
+			ExternalAccountIdentifiers: &google_play.ExternalAccountIdentifiers{ObfuscatedExternalAccountID: "u1"},
+			LineItems: []*google_play.SubscriptionLineItem{{
+				ProductID:        "vip",
+				ExpiryTime:       "2026-02-07T00:00:00Z",
+				AutoRenewingPlan: &google_play.AutoRenewingPlan{AutoRenewEnabled: true},
+			}},
+		}
+	}
+	expire := time.Date(2026, 2, 7, 0, 0, 0, 0, time.UTC)
+
+	txn, _, err := g.toSubscriptionTransaction(context.Background(), purchase(google_play.SubscriptionStateInGracePeriod))
+	require.NoError(t, err)
+	require.True(t, expire.Equal(*txn.GetGracePeriodExpireAt()))
+	require.False(t, txn.IsInBillingRetry())
+
+	txn, _, err = g.toSubscriptionTransaction(context.Background(), purchase(google_play.SubscriptionStateOnHold))
+	require.NoError(t, err)
+	require.Nil(t, txn.GetGracePeriodExpireAt())
+	require.True(t, txn.IsInBillingRetry())
+	require.Nil(t, txn.NextAutoRenewAt)
+}
+
+func TestGoogleToSubscriptionTransaction_RequiresAccountID(t *testing.T) {
+	g := &GoogleTransactionManager{cfg: &config.Config{}}
+	_, _, err := g.toSubscriptionTransaction(context.Background(), &google_play.SubscriptionPurchaseV2{
+		SubscriptionState: google_play.SubscriptionStateActive,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "obfuscated external account id is empty")
+}
+
+func TestGoogleResolvePurchase_NotConfigured(t *testing.T) {
+	g := &GoogleTransactionManager{cfg: &config.Config{}}
+	_, err := g.resolvePurchase(context.Background(), "", "tok-1")
+	require.ErrorIs(t, err, ErrGooglePlayNotConfigured)
+}
+
+func newGoogleTestManager(t *testing.T, mux *http.ServeMux) *GoogleTransactionManager {
+	t.Helper()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-1", "expires_in": 3600})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	account, err := json.Marshal(map[string]string{
+		"client_email": "cashier@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":    srv.URL + "/token",
+	})
+	require.NoError(t, err)
+	cli, err := google_play.NewClient(&google_play.ClientOptions{PackageName: "com.example.app", ServiceAccountKey: string(account), BaseURL: srv.URL})
+	require.NoError(t, err)
+	return &GoogleTransactionManager{client: cli, cfg: &config.Config{}}
+}
+
+func TestGoogleConfirmVoidedPurchase(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /androidpublisher/v3/applications/com.example.app/purchases/voidedpurchases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "" {
+			_, _ = w.Write([]byte(`{"tokenPagination": {"nextPageToken": "p2"}, "voidedPurchases": [{"purchaseToken": "tok-0", "orderId": "GPA.0"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"voidedPurchases": [{"purchaseToken": "tok-1", "orderId": "GPA.1", "voidedTimeMillis": "1767230000000"}]}`))
+	})
+	g := newGoogleTestManager(t, mux)
+	eventTime := time.UnixMilli(1767240000000)
+
+	voidedAt, err := g.confirmVoidedPurchase(context.Background(), &google_play.VoidedPurchaseNotification{PurchaseToken: "tok-1", OrderID: "GPA.1"}, eventTime)
+	require.NoError(t, err)
+	require.Equal(t, time.UnixMilli(1767230000000), voidedAt)
+
+	// A notification Google does not list, such as a forged one, refunds nothing.
+	_, err = g.confirmVoidedPurchase(context.Background(), &google_play.VoidedPurchaseNotification{PurchaseToken: "tok-2", OrderID: "GPA.1"}, eventTime)
+	require.ErrorContains(t, err, "not in the voided purchases list")
+	_, err = g.GetTransactionByNotification(context.Background(), &google_play.DeveloperNotification{
+		VoidedPurchaseNotification: &google_play.VoidedPurchaseNotification{PurchaseToken: "tok-9", OrderID: "GPA.9"},
+	})
+	require.ErrorContains(t, err, "not in the voided purchases list")
+}
@@ -12,6 +12,8 @@ type TransactionVerifyRequest struct {
 	ProviderID             string `json:"provider_id"`
 	TransactionID          string `json:"transaction_id"`
 	ServerVerificationData string `json:"server_verification_data"`
+	// ProductID is required by Google Play to verify one-time products.
+	ProductID string `json:"product_id,omitempty"`
 }
 
 type VerificationDataRequest struct {
@@ -46,7 +48,6 @@ type RefundRequest struct {
 type SendFreeGiftRequest struct {
 	UserID        string `json:"user_id"`
 	PaymentItemID string `json:"payment_item_id"`
-	OperatorId    string `json:"operator_id"` // operator ID
 }
 
 // TransactionManager verifies/parses transaction data and grants entitlements.
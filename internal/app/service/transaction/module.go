@@ -1,9 +1,16 @@
 package transaction
 
-import "go.uber.org/fx"
+import (
+	"go.uber.org/fx"
+)
 
 // Module exposes the transaction service via Fx.
 var Module = fx.Options(
+	fx.Provide(NewNoUsageProvider),
 	fx.Provide(NewAppleTransactionManager),
+	fx.Provide(NewGoogleTransactionManager),
+	fx.Provide(NewStripeTransactionManager),
 	fx.Provide(NewService),
+	fx.Provide(NewAppleReconciler),
+	fx.Invoke(registerAppleReconcileJob),
 )
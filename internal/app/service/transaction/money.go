@@ -0,0 +1,27 @@
+package transaction
+
+import (
+	"github.com/fatflowers/cashier/internal/platform/google/google_play"
+	"github.com/fatflowers/cashier/internal/platform/stripe/stripe_api"
+	types "github.com/fatflowers/cashier/pkg/types"
+)
+
+// Each provider reports prices in its own scale; they are stored in the minor units of the currency.
+
+// AppleMoney converts an App Store price, which is in milliunits of the currency.
+func AppleMoney(milliunits int64, currency string) types.Money {
+	return types.MoneyFromScaled(milliunits, 3, currency)
+}
+
+// googleMoney converts a Google Play price, nil when the plan has none.
+func googleMoney(m *google_play.Money) types.Money {
+	if m == nil {
+		return types.Money{}
+	}
+	return types.MoneyFromScaled(m.Billionths(), 9, m.CurrencyCode)
+}
+
+// stripeMoney converts a Stripe amount, which is in Stripe's smallest unit of the currency.
+func stripeMoney(amount int64, currency string) types.Money {
+	return types.MoneyFromScaled(amount, stripe_api.CurrencyExponent(currency), currency)
+}
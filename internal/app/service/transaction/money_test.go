@@ -0,0 +1,29 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/fatflowers/cashier/internal/platform/google/google_play"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderMoney(t *testing.T) {
+	// Apple prices are in milliunits whatever the currency.
+	require.Equal(t, types.Money{Amount: 999, Currency: "USD", Exponent: 2}, AppleMoney(9990, "USD"))
+	require.Equal(t, types.Money{Amount: 1200, Currency: "JPY", Exponent: 0}, AppleMoney(1200000, "JPY"))
+	require.Equal(t, types.Money{Amount: 4900, Currency: "KRW", Exponent: 0}, AppleMoney(4900000, "KRW"))
+	require.Equal(t, types.Money{Amount: 1250, Currency: "BHD", Exponent: 3}, AppleMoney(1250, "BHD"))
+	require.Equal(t, types.Money{Amount: 100, Currency: "EUR", Exponent: 2}, AppleMoney(995, "eur"), "rounded half away from zero")
+	require.Equal(t, types.Money{Amount: -100, Currency: "EUR", Exponent: 2}, AppleMoney(-995, "EUR"))
+
+	require.Equal(t, types.Money{Amount: 499, Currency: "USD", Exponent: 2}, googleMoney(&google_play.Money{CurrencyCode: "USD", Units: "4", Nanos: 990000000}))
+	require.Equal(t, types.Money{Amount: 650, Currency: "JPY", Exponent: 0}, googleMoney(&google_play.Money{CurrencyCode: "JPY", Units: "650"}))
+	require.Equal(t, types.Money{}, googleMoney(nil))
+
+	// Stripe amounts follow ISO 4217 except for a few currencies such as ISK and MGA.
+	require.Equal(t, types.Money{Amount: 999, Currency: "USD", Exponent: 2}, stripeMoney(999, "usd"))
+	require.Equal(t, types.Money{Amount: 1200, Currency: "JPY", Exponent: 0}, stripeMoney(1200, "jpy"))
+	require.Equal(t, types.Money{Amount: 1490, Currency: "ISK", Exponent: 0}, stripeMoney(149000, "isk"))
+	require.Equal(t, types.Money{Amount: 500000, Currency: "MGA", Exponent: 2}, stripeMoney(5000, "mga"))
+}
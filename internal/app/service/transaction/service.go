@@ -14,21 +14,24 @@ import (
 )
 
 type Service struct {
-	cfg                     *config.Config
-	log                     *zap.SugaredLogger
-	appleTransactionManager *AppleTransactionManager
-	subSvc                  *subscription.Service
-	db                      *gorm.DB
+	cfg                      *config.Config
+	log                      *zap.SugaredLogger
+	appleTransactionManager  *AppleTransactionManager
+	googleTransactionManager *GoogleTransactionManager
+	subSvc                   *subscription.Service
+	db                       *gorm.DB
 }
 
-func NewService(cfg *config.Config, log *zap.SugaredLogger, apple *AppleTransactionManager, sub *subscription.Service, db *gorm.DB) TransactionManager {
-	return &Service{cfg: cfg, log: log, appleTransactionManager: apple, subSvc: sub, db: db}
+func NewService(cfg *config.Config, log *zap.SugaredLogger, apple *AppleTransactionManager, google *GoogleTransactionManager, sub *subscription.Service, db *gorm.DB) TransactionManager {
+	return &Service{cfg: cfg, log: log, appleTransactionManager: apple, googleTransactionManager: google, subSvc: sub, db: db}
 }
 
 func (s *Service) VerifyTransaction(ctx context.Context, req *TransactionVerifyRequest) (*VerifyTransactionResult, error) {
 	switch req.ProviderID {
 	case string(types.PaymentProviderApple):
 		return s.appleTransactionManager.VerifyTransaction(ctx, req)
+	case string(types.PaymentProviderGoogle):
+		return s.googleTransactionManager.VerifyTransaction(ctx, req)
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", req.ProviderID)
 	}
@@ -43,9 +46,44 @@ func (s *Service) ParseVerificationData(ctx context.Context, req *VerificationDa
 	}
 }
 
+// RefundTransaction dispatches to the provider of the stored transaction with transactionId.
 func (s *Service) RefundTransaction(ctx context.Context, transactionId string, outRefundId string) error {
-	// For Apple, not supported currently
-	return fmt.Errorf("refund not supported for provider")
+	var providers []types.PaymentProvider
+	if err := s.db.WithContext(ctx).Model(&models.Transaction{}).
+		Where("transaction_id = ?", transactionId).
+		Distinct().Pluck("provider_id", &providers).Error; err != nil {
+		return fmt.Errorf("failed to get transaction provider: %w", err)
+	}
+	switch len(providers) {
+	case 0:
+		return fmt.Errorf("transaction not found: %s", transactionId)
+	case 1:
+	default:
+		return fmt.Errorf("transaction id %s exists for several providers: %v", transactionId, providers)
+	}
+
+	refunder, err := s.refunder(providers[0])
+	if err != nil {
+		return err
+	}
+	return refunder.RefundTransaction(ctx, transactionId, outRefundId)
+}
+
+type transactionRefunder interface {
+	RefundTransaction(ctx context.Context, transactionId string, outRefundId string) error
+}
+
+// refunder returns the manager that syncs the refund state of provider's transactions. Stripe refunds are
+// only recorded from charge.refunded events, which Stripe retries and can resend from the Dashboard.
+func (s *Service) refunder(provider types.PaymentProvider) (transactionRefunder, error) {
+	switch provider {
+	case types.PaymentProviderApple:
+		return s.appleTransactionManager, nil
+	case types.PaymentProviderGoogle:
+		return s.googleTransactionManager, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrRefundNotSupported, provider)
+	}
 }
 
 // filtersAnd is a helper to combine multiple CommonFilter into a single clause.Expression
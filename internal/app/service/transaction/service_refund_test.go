@@ -0,0 +1,30 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceRefunder(t *testing.T) {
+	apple, google := &AppleTransactionManager{}, &GoogleTransactionManager{cfg: &config.Config{}}
+	s := &Service{appleTransactionManager: apple, googleTransactionManager: google}
+
+	r, err := s.refunder(types.PaymentProviderApple)
+	require.NoError(t, err)
+	require.Same(t, apple, r)
+	r, err = s.refunder(types.PaymentProviderGoogle)
+	require.NoError(t, err)
+	require.Same(t, google, r)
+
+	// Stripe refunds are only recorded from charge.refunded events.
+	_, err = s.refunder(types.PaymentProviderStripe)
+	require.ErrorIs(t, err, ErrRefundNotSupported)
+	_, err = s.refunder(types.PaymentProviderInner)
+	require.ErrorIs(t, err, ErrRefundNotSupported)
+
+	require.ErrorIs(t, google.RefundTransaction(context.Background(), "GPA.1", ""), ErrGooglePlayNotConfigured)
+}
@@ -0,0 +1,275 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/stripe/stripe_api"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+var ErrStripeNotConfigured = errors.New("stripe is not configured")
+
+// Metadata keys written on checkout sessions and subscriptions.
+const (
+	stripeMetadataUserID        = "user_id"
+	stripeMetadataPaymentItemID = "payment_item_id"
+)
+
+// StripeTransactionManager creates Stripe checkout sessions and maps Stripe webhook events onto transactions.
+type StripeTransactionManager struct {
+	client *stripe_api.Client
+	cfg    *config.Config
+	log    *zap.SugaredLogger
+}
+
+func NewStripeTransactionManager(cfg *config.Config, log *zap.SugaredLogger) (*StripeTransactionManager, error) {
+	m := &StripeTransactionManager{cfg: cfg, log: log}
+	// Stripe is optional; without a secret key every call returns ErrStripeNotConfigured.
+	if cfg.Stripe.SecretKey == "" {
+		return m, nil
+	}
+	cli, err := stripe_api.NewClient(&stripe_api.ClientOptions{SecretKey: cfg.Stripe.SecretKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init Stripe client: %w", err)
+	}
+	m.client = cli
+	return m, nil
+}
+
+type CreateCheckoutSessionRequest struct {
+	UserID        string `json:"user_id"`
+	PaymentItemID string `json:"payment_item_id"`
+	// SuccessURL and CancelURL override the configured defaults.
+	SuccessURL string `json:"success_url,omitempty"`
+	CancelURL  string `json:"cancel_url,omitempty"`
+}
+
+type CreateCheckoutSessionResponse struct {
+	SessionID string `json:"session_id"`
+	URL       string `json:"url"`
+}
+
+// CreateCheckoutSession starts a Stripe hosted checkout for a Stripe payment item. The item's
+// ProviderItemID is the Stripe price id; auto-renewable items use subscription mode.
+func (m *StripeTransactionManager) CreateCheckoutSession(ctx context.Context, req *CreateCheckoutSessionRequest) (*CreateCheckoutSessionResponse, error) {
+	if m.client == nil {
+		return nil, ErrStripeNotConfigured
+	}
+	if req.UserID == "" || req.PaymentItemID == "" {
+		return nil, fmt.Errorf("invalid params: user_id and payment_item_id required")
+	}
+	paymentItem := m.cfg.GetPaymentItemByID(req.PaymentItemID)
+	if paymentItem == nil || paymentItem.ProviderID != types.PaymentProviderStripe {
+		return nil, fmt.Errorf("stripe payment item not found: %s", req.PaymentItemID)
+	}
+	if paymentItem.IsArchived() {
+		return nil, fmt.Errorf("payment item is archived: %s", req.PaymentItemID)
+	}
+
+	params := &stripe_api.CheckoutSessionParams{
+		Mode:              stripe_api.CheckoutModePayment,
+		PriceID:           paymentItem.ProviderItemID,
+		SuccessURL:        lo.CoalesceOrEmpty(req.SuccessURL, m.cfg.Stripe.SuccessURL),
+		CancelURL:         lo.CoalesceOrEmpty(req.CancelURL, m.cfg.Stripe.CancelURL),
+		ClientReferenceID: req.UserID,
+		Metadata: map[string]string{
+			stripeMetadataUserID:        req.UserID,
+			stripeMetadataPaymentItemID: paymentItem.ID,
+		},
+	}
+	if paymentItem.Renewable() {
+		params.Mode = stripe_api.CheckoutModeSubscription
+	}
+	if params.SuccessURL == "" || params.CancelURL == "" {
+		return nil, fmt.Errorf("success_url and cancel_url are required")
+	}
+
+	session, err := m.client.CreateCheckoutSession(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkout session: %w", err)
+	}
+	return &CreateCheckoutSessionResponse{SessionID: session.ID, URL: session.URL}, nil
+}
+
+// GetTransactionByEvent maps a verified Stripe event onto a transaction.
+// It returns nil for events that do not change a purchase.
+func (m *StripeTransactionManager) GetTransactionByEvent(ctx context.Context, event *stripe_api.Event) (*models.Transaction, error) {
+	if m.client == nil {
+		return nil, ErrStripeNotConfigured
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event is empty")
+	}
+
+	switch event.Type {
+	case stripe_api.EventCheckoutSessionCompleted:
+		var session stripe_api.CheckoutSession
+		if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+			return nil, fmt.Errorf("failed to decode checkout session: %w", err)
+		}
+		// Subscription checkouts are recorded from their invoice.paid event.
+		if session.Mode != stripe_api.CheckoutModePayment || session.PaymentStatus != "paid" {
+			return nil, nil
+		}
+		return m.checkoutSessionToTransaction(&session)
+	case stripe_api.EventInvoicePaid:
+		var invoice stripe_api.Invoice
+		if err := json.Unmarshal(event.Data.Object, &invoice); err != nil {
+			return nil, fmt.Errorf("failed to decode invoice: %w", err)
+		}
+		if invoice.SubscriptionID() == "" {
+			return nil, nil
+		}
+		sub, err := m.client.GetSubscription(ctx, invoice.SubscriptionID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subscription: %w", err)
+		}
+		return m.invoiceToTransaction(ctx, &invoice, sub)
+	case stripe_api.EventCustomerSubscriptionUpdated, stripe_api.EventCustomerSubscriptionDeleted:
+		var sub stripe_api.Subscription
+		if err := json.Unmarshal(event.Data.Object, &sub); err != nil {
+			return nil, fmt.Errorf("failed to decode subscription: %w", err)
+		}
+		if sub.LatestInvoice == "" {
+			return nil, nil
+		}
+		invoice, err := m.client.GetInvoice(ctx, sub.LatestInvoice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get invoice: %w", err)
+		}
+		if invoice.Status != "paid" {
+			return nil, nil
+		}
+		return m.invoiceToTransaction(ctx, invoice, &sub)
+	case stripe_api.EventChargeRefunded:
+		var charge stripe_api.Charge
+		if err := json.Unmarshal(event.Data.Object, &charge); err != nil {
+			return nil, fmt.Errorf("failed to decode charge: %w", err)
+		}
+		// Partial refunds keep the entitlement.
+		if !charge.Refunded {
+			return nil, nil
+		}
+		txn, err := m.getTransactionByCharge(ctx, &charge)
+		if err != nil || txn == nil {
+			return txn, err
+		}
+		txn.RefundAt = lo.ToPtr(time.Unix(event.Created, 0))
+		return txn, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (m *StripeTransactionManager) getTransactionByCharge(ctx context.Context, charge *stripe_api.Charge) (*models.Transaction, error) {
+	if charge.Invoice != "" {
+		invoice, err := m.client.GetInvoice(ctx, charge.Invoice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get invoice: %w", err)
+		}
+		// Invoices of one-off checkouts are recorded from their checkout session below.
+		if invoice.SubscriptionID() != "" {
+			sub, err := m.client.GetSubscription(ctx, invoice.SubscriptionID())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get subscription: %w", err)
+			}
+			return m.invoiceToTransaction(ctx, invoice, sub)
+		}
+	}
+	if charge.PaymentIntent == "" {
+		return nil, nil
+	}
+	session, err := m.client.GetCheckoutSessionByPaymentIntent(ctx, charge.PaymentIntent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout session: %w", err)
+	}
+	if session == nil {
+		return nil, nil
+	}
+	return m.checkoutSessionToTransaction(session)
+}
+
+func (m *StripeTransactionManager) checkoutSessionToTransaction(session *stripe_api.CheckoutSession) (*models.Transaction, error) {
+	userID := lo.CoalesceOrEmpty(session.Metadata[stripeMetadataUserID], session.ClientReferenceID)
+	if userID == "" {
+		return nil, fmt.Errorf("checkout session %s has no user id", session.ID)
+	}
+	paymentItem := m.cfg.GetPaymentItemByID(session.Metadata[stripeMetadataPaymentItemID])
+	if paymentItem == nil {
+		return nil, fmt.Errorf("payment item not found for checkout session: %s", session.ID)
+	}
+
+	return &models.Transaction{
+		UserID:        userID,
+		ProviderID:    types.PaymentProviderStripe,
+		PaymentItemID: paymentItem.ID,
+		TransactionID: session.ID,
+		PurchaseAt:    time.Unix(session.Created, 0),
+		Price:         stripeMoney(session.AmountTotal, session.Currency),
+		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PaymentItemSnapshot: paymentItem,
+		}),
+	}, nil
+}
+
+// invoiceToTransaction records one paid subscription invoice as one period. The subscription id plays the
+// role of the original transaction id, and sub decides whether the period will renew.
+func (m *StripeTransactionManager) invoiceToTransaction(ctx context.Context, invoice *stripe_api.Invoice, sub *stripe_api.Subscription) (*models.Transaction, error) {
+	var line *stripe_api.InvoiceLineItem
+	var paymentItem *types.PaymentItem
+	for _, l := range invoice.Lines.Data {
+		item, err := m.cfg.GetPaymentItemByProviderItemID(ctx, types.PaymentProviderStripe, l.PriceID())
+		if err == nil {
+			line, paymentItem = l, item
+			break
+		}
+	}
+	if line == nil {
+		return nil, fmt.Errorf("payment item not found for invoice: %s", invoice.ID)
+	}
+
+	metadata := invoice.SubscriptionMetadata()
+	if sub != nil && len(sub.Metadata) > 0 {
+		metadata = sub.Metadata
+	}
+	userID := lo.CoalesceOrEmpty(metadata[stripeMetadataUserID], line.Metadata[stripeMetadataUserID])
+	if userID == "" {
+		return nil, fmt.Errorf("invoice %s has no user id", invoice.ID)
+	}
+	if line.Period.End == 0 {
+		return nil, fmt.Errorf("invoice %s line has no period", invoice.ID)
+	}
+
+	expireAt := time.Unix(line.Period.End, 0)
+	res := &models.Transaction{
+		UserID:              userID,
+		ProviderID:          types.PaymentProviderStripe,
+		PaymentItemID:       paymentItem.ID,
+		TransactionID:       invoice.ID,
+		ParentTransactionID: lo.ToPtr(invoice.SubscriptionID()),
+		PurchaseAt:          time.Unix(line.Period.Start, 0),
+		AutoRenewExpireAt:   lo.ToPtr(expireAt),
+		Price:               stripeMoney(invoice.AmountPaid, invoice.Currency),
+		Extra: datatypes.NewJSONType(&models.UserSubscriptionItemExtra{
+			PaymentItemSnapshot: paymentItem,
+		}),
+	}
+
+	if sub.IsRenewing() {
+		res.NextAutoRenewAt = lo.ToPtr(expireAt)
+	}
+	// A subscription canceled immediately ends the current period early.
+	if sub != nil && sub.Status == stripe_api.SubscriptionStatusCanceled && sub.EndedAt > 0 && sub.EndedAt < line.Period.End {
+		res.AutoRenewExpireAt = lo.ToPtr(time.Unix(sub.EndedAt, 0))
+	}
+	return res, nil
+}
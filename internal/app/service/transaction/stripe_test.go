@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/platform/stripe/stripe_api"
+	"github.com/fatflowers/cashier/pkg/config"
+	types "github.com/fatflowers/cashier/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+const stripeTestInvoice = `{
+	"id": "in_1",
+	"status": "paid",
+	"amount_paid": 999,
+	"currency": "usd",
+	"parent": {"subscription_details": {"subscription": "sub_1", "metadata": {"user_id": "u1"}}},
+	"lines": {"data": [{"id": "il_1", "period": {"start": 1767225600, "end": 1769904000}, "pricing": {"price_details": {"price": "price_month"}}}]}
+}`
+
+func newStripeTestManager(t *testing.T, subscription string) *StripeTransactionManager {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/subscriptions/sub_1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(subscription))
+	})
+	mux.HandleFunc("GET /v1/invoices/in_1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(stripeTestInvoice))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cli, err := stripe_api.NewClient(&stripe_api.ClientOptions{SecretKey: "sk_test", BaseURL: srv.URL})
+	require.NoError(t, err)
+	month := int64(30 * 24)
+	cfg := &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip_month", ProviderID: types.PaymentProviderStripe, ProviderItemID: "price_month", Type: types.PaymentItemTypeAutoRenewableSubscription, DurationHour: &month},
+	}}
+	return &StripeTransactionManager{client: cli, cfg: cfg}
+}
+
+func TestStripeGetTransactionByEvent_InvoicePaid(t *testing.T) {
+	m := newStripeTestManager(t, `{"id":"sub_1","status":"active"}`)
+	event := &stripe_api.Event{ID: "evt_1", Type: stripe_api.EventInvoicePaid}
+	event.Data.Object = []byte(stripeTestInvoice)
+
+	txn, err := m.GetTransactionByEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Equal(t, "u1", txn.UserID)
+	require.Equal(t, "vip_month", txn.PaymentItemID)
+	require.Equal(t, "in_1", txn.TransactionID)
+	require.Equal(t, "sub_1", *txn.ParentTransactionID)
+	require.Equal(t, types.Money{Amount: 999, Currency: "USD", Exponent: 2}, txn.Price)
+	require.True(t, time.Unix(1769904000, 0).Equal(*txn.AutoRenewExpireAt))
+	require.NotNil(t, txn.NextAutoRenewAt)
+	require.Nil(t, txn.RefundAt)
+}
+
+func TestStripeGetTransactionByEvent_ChargeRefunded(t *testing.T) {
+	m := newStripeTestManager(t, `{"id":"sub_1","status":"active","cancel_at_period_end":true}`)
+	event := &stripe_api.Event{ID: "evt_2", Type: stripe_api.EventChargeRefunded, Created: 1768000000}
+	event.Data.Object = []byte(`{"id":"ch_1","invoice":"in_1","refunded":true}`)
+
+	txn, err := m.GetTransactionByEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Equal(t, "in_1", txn.TransactionID)
+	require.Nil(t, txn.NextAutoRenewAt)
+	require.True(t, time.Unix(1768000000, 0).Equal(*txn.RefundAt))
+
+	// Partial refunds keep the entitlement.
+	event.Data.Object = []byte(`{"id":"ch_1","invoice":"in_1","refunded":false}`)
+	txn, err = m.GetTransactionByEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Nil(t, txn)
+}
+
+func TestStripeCreateCheckoutSession_NotConfigured(t *testing.T) {
+	m := &StripeTransactionManager{cfg: &config.Config{}}
+	_, err := m.CreateCheckoutSession(context.Background(), &CreateCheckoutSessionRequest{UserID: "u1", PaymentItemID: "vip_month"})
+	require.ErrorIs(t, err, ErrStripeNotConfigured)
+}
+
+func TestStripeGetTransactionByEvent_OneOffChargeRefunded(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/invoices/in_2", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"in_2","status":"paid","amount_paid":1999,"currency":"usd"}`))
+	})
+	mux.HandleFunc("GET /v1/subscriptions/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected subscription lookup: %s", r.URL.Path)
+	})
+	mux.HandleFunc("GET /v1/checkout/sessions", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "pi_1", r.URL.Query().Get("payment_intent"))
+		_, _ = w.Write([]byte(`{"data":[{"id":"cs_1","mode":"payment","payment_status":"paid","amount_total":1999,"currency":"usd","created":1767225600,"metadata":{"user_id":"u1","payment_item_id":"vip_month"}}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	cli, err := stripe_api.NewClient(&stripe_api.ClientOptions{SecretKey: "sk_test", BaseURL: srv.URL})
+	require.NoError(t, err)
+	m := &StripeTransactionManager{client: cli, cfg: &config.Config{PaymentItems: []*types.PaymentItem{
+		{ID: "vip_month", ProviderID: types.PaymentProviderStripe, ProviderItemID: "price_once", Type: types.PaymentItemTypeNonConsumable},
+	}}}
+
+	// Checkouts with invoice creation attach an invoice without a subscription to the charge.
+	event := &stripe_api.Event{ID: "evt_3", Type: stripe_api.EventChargeRefunded, Created: 1768000000}
+	event.Data.Object = []byte(`{"id":"ch_2","invoice":"in_2","payment_intent":"pi_1","refunded":true}`)
+	txn, err := m.GetTransactionByEvent(context.Background(), event)
+	require.NoError(t, err)
+	require.Equal(t, "cs_1", txn.TransactionID)
+	require.True(t, time.Unix(1768000000, 0).Equal(*txn.RefundAt))
+}
@@ -0,0 +1,8 @@
+package wallet
+
+import "go.uber.org/fx"
+
+// Module provides the credit wallet.
+var Module = fx.Options(
+	fx.Provide(New),
+)
@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/tool"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientCredits is returned by Spend when the balance is lower than the amount.
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	// ErrIdempotencyKeyReused is returned by Spend when the key was used for a different spend.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different spend")
+)
+
+// Service keeps the per-user credit ledger. Consumable purchases grant credits through SyncPurchase, the
+// product backend spends them through Spend.
+type Service struct {
+	db  *gorm.DB
+	log *zap.SugaredLogger
+}
+
+func New(db *gorm.DB, log *zap.SugaredLogger) *Service {
+	return &Service{db: db, log: log}
+}
+
+// SyncPurchase makes the credits granted for a consumable transaction match its refund state: a purchase
+// grants the credits of the item, a refund claws them back and a refund reversal grants them again. It runs in
+// tx so the ledger commits with the transaction; calling it again without a state change adds nothing.
+func SyncPurchase(ctx context.Context, tx *gorm.DB, txn *models.Transaction, item *types.PaymentItem) error {
+	if item == nil || !item.IsConsumable() {
+		return nil
+	}
+	want := item.Credits
+	if txn.RefundAt != nil {
+		want = 0
+	}
+	var granted struct {
+		Net   int64
+		Count int64
+	}
+	if err := tx.WithContext(ctx).Model(&models.CreditLedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0) AS net, COUNT(*) AS count").
+		Where("provider_id = ? AND transaction_id = ?", txn.ProviderID, txn.TransactionID).
+		Scan(&granted).Error; err != nil {
+		return fmt.Errorf("failed to sum credits of transaction %s: %w", txn.TransactionID, err)
+	}
+	delta := want - granted.Net
+	if delta == 0 {
+		return nil
+	}
+
+	entry := &models.CreditLedgerEntry{
+		UserID:        txn.UserID,
+		Kind:          models.CreditEntryKindGrant,
+		Amount:        delta,
+		ProviderID:    txn.ProviderID,
+		TransactionID: txn.TransactionID,
+		Reason:        "purchase",
+	}
+	switch {
+	case delta < 0:
+		entry.Kind = models.CreditEntryKindClawback
+		entry.Reason = "refund"
+	case granted.Count > 0:
+		entry.Reason = "refund_reversed"
+	}
+	balance, err := lockBalance(ctx, tx, txn.UserID)
+	if err != nil {
+		return err
+	}
+	// Clawbacks may leave a negative balance when the credits were already spent.
+	return addEntry(ctx, tx, balance, entry, true)
+}
+
+// lockBalance returns the balance row of userID, creating it when missing, locked until tx ends.
+func lockBalance(ctx context.Context, tx *gorm.DB, userID string) (*models.CreditBalance, error) {
+	if err := tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.CreditBalance{UserID: userID, UpdatedAt: time.Now()}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create credit balance: %w", err)
+	}
+	var balance models.CreditBalance
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).Take(&balance).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock credit balance: %w", err)
+	}
+	return &balance, nil
+}
+
+// addEntry applies entry to a balance locked by lockBalance and records it with the resulting balance.
+func addEntry(ctx context.Context, tx *gorm.DB, balance *models.CreditBalance, entry *models.CreditLedgerEntry, allowNegative bool) error {
+	next := balance.Balance + entry.Amount
+	if next < 0 && !allowNegative {
+		return fmt.Errorf("%w: balance %d, spending %d", ErrInsufficientCredits, balance.Balance, -entry.Amount)
+	}
+	now := time.Now()
+	if err := tx.WithContext(ctx).Model(balance).Updates(map[string]any{"balance": next, "updated_at": now}).Error; err != nil {
+		return fmt.Errorf("failed to update credit balance: %w", err)
+	}
+	entry.ID = tool.GenerateUUIDV7()
+	entry.BalanceAfter = next
+	entry.CreatedAt = now
+	if err := tx.WithContext(ctx).Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record credit ledger entry: %w", err)
+	}
+	return nil
+}
+
+// GetBalance returns the credit balance of a user, zero when they never had credits.
+func (s *Service) GetBalance(ctx context.Context, userID string) (*models.CreditBalance, error) {
+	balance := &models.CreditBalance{UserID: userID}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Take(balance).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get credit balance: %w", err)
+	}
+	return balance, nil
+}
+
+type SpendRequest struct {
+	UserID string `json:"user_id"`
+	Amount int64  `json:"amount"`
+	// IdempotencyKey identifies the spend; retrying with the same key returns the first entry.
+	IdempotencyKey string `json:"idempotency_key"`
+	Reason         string `json:"reason"`
+}
+
+// Spend takes credits from a user's balance. It fails with ErrInsufficientCredits rather than going negative.
+func (s *Service) Spend(ctx context.Context, req *SpendRequest) (*models.CreditLedgerEntry, error) {
+	if req.UserID == "" || req.IdempotencyKey == "" {
+		return nil, errors.New("user_id and idempotency_key are required")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	entry := &models.CreditLedgerEntry{
+		UserID:         req.UserID,
+		Kind:           models.CreditEntryKindSpend,
+		Amount:         -req.Amount,
+		IdempotencyKey: &req.IdempotencyKey,
+		Reason:         req.Reason,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		balance, err := lockBalance(ctx, tx, req.UserID)
+		if err != nil {
+			return err
+		}
+		// Checked under the balance lock so concurrent retries of one spend see each other.
+		var existing models.CreditLedgerEntry
+		err = tx.Where("idempotency_key = ?", req.IdempotencyKey).Take(&existing).Error
+		if err == nil {
+			if existing.UserID != req.UserID || existing.Amount != entry.Amount {
+				return ErrIdempotencyKeyReused
+			}
+			entry = &existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to look up idempotency key: %w", err)
+		}
+		return addEntry(ctx, tx, balance, entry, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+type ListCreditLedgerRequest struct {
+	UserID string `json:"user_id"`
+	Kind   string `json:"kind"`
+	From   int    `json:"from"`
+	Size   int    `json:"size"`
+}
+
+type ListCreditLedgerResponse struct {
+	Items []*models.CreditLedgerEntry `json:"items"`
+	Total int64                       `json:"total"`
+}
+
+// ListEntries lists the ledger entries of a user, newest first.
+func (s *Service) ListEntries(ctx context.Context, req *ListCreditLedgerRequest) (*ListCreditLedgerResponse, error) {
+	if req.UserID == "" {
+		return nil, errors.New("user_id is required")
+	}
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	q := s.db.WithContext(ctx).Model(&models.CreditLedgerEntry{}).Where("user_id = ?", req.UserID)
+	if req.Kind != "" {
+		q = q.Where("kind = ?", req.Kind)
+	}
+	res := &ListCreditLedgerResponse{}
+	if err := q.Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count credit ledger entries: %w", err)
+	}
+	if err := q.Order("created_at desc").Offset(req.From).Limit(req.Size).Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list credit ledger entries: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	models "github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/internal/platform/db"
+	"github.com/fatflowers/cashier/pkg/tool"
+	types "github.com/fatflowers/cashier/pkg/types"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testDatabaseDSNEnv = "CASHIER_TEST_DATABASE_DSN"
+
+func newPostgresTestService(t *testing.T) *Service {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseDSNEnv)
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := gdb.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	log := zap.NewNop().Sugar()
+	m, err := db.NewMigrator(gdb, log)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	return New(gdb, log)
+}
+
+func TestService_PurchaseRefundAndSpend(t *testing.T) {
+	s := newPostgresTestService(t)
+	ctx := context.Background()
+	userID := "wallet_" + tool.GenerateUUIDV7()
+	t.Cleanup(func() {
+		s.db.Where("user_id = ?", userID).Delete(&models.CreditLedgerEntry{})
+		s.db.Where("user_id = ?", userID).Delete(&models.CreditBalance{})
+	})
+	item := &types.PaymentItem{ID: "coins_100", Type: types.PaymentItemTypeConsumable, Credits: 100}
+	txn := &models.Transaction{UserID: userID, ProviderID: types.PaymentProviderApple, PaymentItemID: item.ID, TransactionID: "tx_" + userID}
+	sync := func() {
+		t.Helper()
+		require.NoError(t, s.db.Transaction(func(tx *gorm.DB) error { return SyncPurchase(ctx, tx, txn, item) }))
+	}
+	balance := func() int64 {
+		t.Helper()
+		b, err := s.GetBalance(ctx, userID)
+		require.NoError(t, err)
+		return b.Balance
+	}
+
+	require.Zero(t, balance())
+	sync()
+	sync()
+	require.Equal(t, int64(100), balance(), "syncing again grants nothing")
+
+	spend := &SpendRequest{UserID: userID, Amount: 70, IdempotencyKey: "spend_" + userID, Reason: "export"}
+	first, err := s.Spend(ctx, spend)
+	require.NoError(t, err)
+	require.Equal(t, int64(30), first.BalanceAfter)
+	replay, err := s.Spend(ctx, spend)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, replay.ID)
+	require.Equal(t, int64(30), balance())
+
+	_, err = s.Spend(ctx, &SpendRequest{UserID: userID, Amount: 40, IdempotencyKey: "spend_" + userID})
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	_, err = s.Spend(ctx, &SpendRequest{UserID: userID, Amount: 40, IdempotencyKey: "other_" + userID})
+	require.ErrorIs(t, err, ErrInsufficientCredits)
+
+	// A refund claws back the whole grant even when part of it was spent.
+	txn.RefundAt = lo.ToPtr(time.Now())
+	sync()
+	require.Equal(t, int64(-70), balance())
+	txn.RefundAt = nil
+	sync()
+	require.Equal(t, int64(30), balance())
+
+	res, err := s.ListEntries(ctx, &ListCreditLedgerRequest{UserID: userID})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), res.Total)
+	require.Equal(t, "refund_reversed", res.Items[0].Reason)
+	res, err = s.ListEntries(ctx, &ListCreditLedgerRequest{UserID: userID, Kind: models.CreditEntryKindClawback})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Total)
+	require.Equal(t, int64(-100), res.Items[0].Amount)
+}
+
+func TestSpend_Validates(t *testing.T) {
+	s := New(nil, zap.NewNop().Sugar())
+	_, err := s.Spend(context.Background(), &SpendRequest{UserID: "u1", Amount: 1})
+	require.Error(t, err)
+	_, err = s.Spend(context.Background(), &SpendRequest{UserID: "u1", Amount: 0, IdempotencyKey: "k"})
+	require.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package webhook
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the membership webhook service and runs its delivery loop.
+var Module = fx.Options(
+	fx.Provide(New),
+	fx.Invoke(registerDeliveryLoop),
+)
+
+func registerDeliveryLoop(lc fx.Lifecycle, s *Service) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			s.Start()
+			return nil
+		},
+		OnStop: s.Stop,
+	})
+}
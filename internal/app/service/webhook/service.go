@@ -0,0 +1,402 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/app/service/outbox"
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/fatflowers/cashier/pkg/tool"
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMaxAttempts  = 8
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 20
+	requestTimeout      = 10 * time.Second
+	// claimLease keeps a claimed delivery from being picked up by another instance while it is in flight. It is
+	// renewed right before each send, so it only has to outlast one request.
+	claimLease     = 2 * time.Minute
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 6 * time.Hour
+)
+
+var ErrDeadLetterNotFound = errors.New("webhook dead letter not found")
+
+// Service delivers membership change events to the configured subscriber endpoints.
+type Service struct {
+	cfg        *config.Config
+	db         *gorm.DB
+	log        *zap.SugaredLogger
+	httpClient *http.Client
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cfg *config.Config, db *gorm.DB, log *zap.SugaredLogger, dispatcher *outbox.Dispatcher) *Service {
+	s := &Service{
+		cfg:        cfg,
+		db:         db,
+		log:        log,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+	dispatcher.Register(outbox.TopicMembershipChanged, s.fanOut)
+	return s
+}
+
+func (s *Service) maxAttempts() int {
+	if s.cfg.Webhook.MaxAttempts > 0 {
+		return s.cfg.Webhook.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+// fanOut turns one membership change into one pending delivery per endpoint. It runs inside the
+// outbox dispatcher transaction, so deliveries are created exactly once.
+func (s *Service) fanOut(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	if len(s.cfg.Webhook.Endpoints) == 0 {
+		return nil
+	}
+	var e types.MembershipChangeEvent
+	if err := json.Unmarshal(event.Payload, &e); err != nil {
+		return fmt.Errorf("failed to decode membership event: %w", err)
+	}
+	now := time.Now()
+	deliveries := make([]*models.WebhookDelivery, 0, len(s.cfg.Webhook.Endpoints))
+	for _, endpoint := range s.cfg.Webhook.Endpoints {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID:            tool.GenerateUUIDV7(),
+			EndpointID:    endpoint.ID,
+			EventID:       e.ID,
+			EventType:     e.Type,
+			Payload:       event.Payload,
+			Status:        models.WebhookDeliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if err := tx.WithContext(ctx).Create(deliveries).Error; err != nil {
+		return fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// Start runs the delivery loop until Stop.
+func (s *Service) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(defaultPollInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				n, err := s.DeliverBatch(ctx)
+				if err != nil {
+					if !errors.Is(err, context.Canceled) {
+						s.log.Errorw("webhook delivery failed", "error", err.Error())
+					}
+					break
+				}
+				if n < defaultBatchSize {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for in-flight deliveries. A delivery interrupted by Stop is released without counting as an
+// attempt, and the rest of the batch is retried once its lease expires.
+func (s *Service) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	done := make(chan struct{})
+	go func() { s.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeliverBatch claims due deliveries, sends them outside any DB transaction and records the results.
+//
+// A claim sets next_attempt_at to the end of the lease, and that value identifies the claim: renewing the lease
+// and recording the attempt only touch the row while it still holds it, so a delivery re-claimed by another
+// instance after the lease ran out is neither sent nor recorded twice by this one.
+func (s *Service) DeliverBatch(ctx context.Context) (int, error) {
+	var deliveries []*models.WebhookDelivery
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryStatusPending, now).
+			Order("next_attempt_at, id").
+			Limit(defaultBatchSize).
+			Find(&deliveries).Error; err != nil {
+			return fmt.Errorf("failed to load webhook deliveries: %w", err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		claimedUntil := leaseEnd(now)
+		ids := make([]string, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+			d.NextAttemptAt = claimedUntil
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", claimedUntil).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			// Stopping: the remaining claims expire and another instance picks them up.
+			return len(deliveries), ctx.Err()
+		}
+		held, err := s.renewClaim(ctx, d)
+		if err != nil {
+			s.log.Errorw("failed to renew webhook delivery claim", "id", d.ID, "error", err.Error())
+			continue
+		}
+		if !held {
+			s.log.Warnw("webhook delivery claim lost, skipping", "id", d.ID)
+			continue
+		}
+		statusCode, sendErr := s.send(ctx, d)
+		if sendErr != nil && ctx.Err() != nil {
+			if err := s.releaseClaim(context.WithoutCancel(ctx), d); err != nil {
+				s.log.Errorw("failed to release webhook delivery claim", "id", d.ID, "error", err.Error())
+			}
+			return len(deliveries), ctx.Err()
+		}
+		if err := s.recordAttempt(context.WithoutCancel(ctx), d, statusCode, sendErr); err != nil {
+			s.log.Errorw("failed to record webhook attempt", "id", d.ID, "error", err.Error())
+		}
+	}
+	return len(deliveries), nil
+}
+
+// leaseEnd is the end of a lease taken at now, at the microsecond precision Postgres stores so that it can be
+// matched against the stored value.
+func leaseEnd(now time.Time) time.Time {
+	return now.Add(claimLease).Truncate(time.Microsecond)
+}
+
+// claimed scopes an update to d while this instance still holds its claim.
+func claimed(db *gorm.DB, d *models.WebhookDelivery) *gorm.DB {
+	return db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", d.ID, models.WebhookDeliveryStatusPending, d.NextAttemptAt)
+}
+
+// renewClaim extends the lease on d for one more request and reports whether this instance still holds it.
+func (s *Service) renewClaim(ctx context.Context, d *models.WebhookDelivery) (bool, error) {
+	claimedUntil := leaseEnd(time.Now())
+	res := claimed(s.db.WithContext(ctx), d).Update("next_attempt_at", claimedUntil)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	d.NextAttemptAt = claimedUntil
+	return true, nil
+}
+
+// releaseClaim makes d due again without counting an attempt.
+func (s *Service) releaseClaim(ctx context.Context, d *models.WebhookDelivery) error {
+	return claimed(s.db.WithContext(ctx), d).Update("next_attempt_at", time.Now()).Error
+}
+
+// send posts the signed payload. Any non-2xx response counts as a failure.
+func (s *Service) send(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	endpoint := s.cfg.GetWebhookEndpointByID(d.EndpointID)
+	if endpoint == nil {
+		return 0, fmt.Errorf("webhook endpoint not configured: %s", d.EndpointID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, d.EventID)
+	req.Header.Set(EventTypeHeader, d.EventType)
+	req.Header.Set(SignatureHeader, signatureHeaderValue(time.Now().Unix(), d.Payload, endpoint.Secret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordAttempt marks a delivery delivered, schedules its retry, or moves it to the dead-letter table. Nothing
+// is recorded once the claim on d is lost.
+func (s *Service) recordAttempt(ctx context.Context, d *models.WebhookDelivery, statusCode int, sendErr error) error {
+	now := time.Now()
+	attempts := d.Attempts + 1
+	updates := map[string]any{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"updated_at":       now,
+	}
+	if sendErr == nil {
+		updates["status"] = models.WebhookDeliveryStatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+		_, err := s.updateClaimed(claimed(s.db.WithContext(ctx), d), d, updates)
+		return err
+	}
+
+	s.log.Warnw("webhook delivery attempt failed", "id", d.ID, "endpoint_id", d.EndpointID, "attempts", attempts, "error", sendErr.Error())
+	updates["last_error"] = sendErr.Error()
+	if attempts < s.maxAttempts() {
+		updates["next_attempt_at"] = now.Add(retryDelay(attempts))
+		_, err := s.updateClaimed(claimed(s.db.WithContext(ctx), d), d, updates)
+		return err
+	}
+
+	updates["status"] = models.WebhookDeliveryStatusDead
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		held, err := s.updateClaimed(claimed(tx, d), d, updates)
+		if err != nil || !held {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WebhookDeadLetter{
+			ID:             tool.GenerateUUIDV7(),
+			DeliveryID:     d.ID,
+			EndpointID:     d.EndpointID,
+			EventID:        d.EventID,
+			EventType:      d.EventType,
+			Payload:        d.Payload,
+			Attempts:       attempts,
+			LastStatusCode: statusCode,
+			LastError:      sendErr.Error(),
+			CreatedAt:      now,
+		}).Error
+	})
+}
+
+// updateClaimed applies updates to the claimed delivery and reports whether the claim was still held.
+func (s *Service) updateClaimed(q *gorm.DB, d *models.WebhookDelivery, updates map[string]any) (bool, error) {
+	res := q.Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		s.log.Warnw("webhook delivery claim lost, attempt not recorded", "id", d.ID)
+		return false, nil
+	}
+	return true, nil
+}
+
+// retryDelay is an exponential backoff starting at baseRetryDelay and capped at maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+type ListDeadLettersRequest struct {
+	EndpointID string `json:"endpoint_id"`
+	From       int    `json:"from"`
+	Size       int    `json:"size"`
+}
+
+type ListDeadLettersResponse struct {
+	Items []*models.WebhookDeadLetter `json:"items"`
+	Total int64                       `json:"total"`
+}
+
+// ListDeadLetters returns dead-lettered deliveries, newest first.
+func (s *Service) ListDeadLetters(ctx context.Context, req *ListDeadLettersRequest) (*ListDeadLettersResponse, error) {
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	q := s.db.WithContext(ctx).Model(&models.WebhookDeadLetter{})
+	if req.EndpointID != "" {
+		q = q.Where("endpoint_id = ?", req.EndpointID)
+	}
+	res := &ListDeadLettersResponse{}
+	if err := q.Count(&res.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	if err := q.Order("created_at desc").Offset(req.From).Limit(req.Size).Find(&res.Items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return res, nil
+}
+
+// Redeliver queues a dead-lettered event as a fresh delivery and removes the dead letter.
+// It returns the id of the new delivery.
+func (s *Service) Redeliver(ctx context.Context, deadLetterID string) (string, error) {
+	var deliveryID string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dl models.WebhookDeadLetter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", deadLetterID).First(&dl).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeadLetterNotFound
+			}
+			return fmt.Errorf("failed to get dead letter: %w", err)
+		}
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			ID:            tool.GenerateUUIDV7(),
+			EndpointID:    dl.EndpointID,
+			EventID:       dl.EventID,
+			EventType:     dl.EventType,
+			Payload:       datatypes.JSON(dl.Payload),
+			Status:        models.WebhookDeliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := tx.Create(delivery).Error; err != nil {
+			return fmt.Errorf("failed to create delivery: %w", err)
+		}
+		if err := tx.Delete(&dl).Error; err != nil {
+			return fmt.Errorf("failed to delete dead letter: %w", err)
+		}
+		deliveryID = delivery.ID
+		return nil
+	})
+	return deliveryID, err
+}
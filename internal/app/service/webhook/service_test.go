@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatflowers/cashier/internal/models"
+	"github.com/fatflowers/cashier/pkg/config"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSend_SignsPayload(t *testing.T) {
+	payload := []byte(`{"id":"evt-1","type":"membership.changed","user_id":"u1"}`)
+	var gotSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, body)
+		require.Equal(t, "evt-1", r.Header.Get(EventIDHeader))
+		gotSignature = r.Header.Get(SignatureHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := &Service{
+		cfg:        &config.Config{Webhook: config.WebhookConfig{Endpoints: []*config.WebhookEndpoint{{ID: "app", URL: srv.URL, Secret: "s3cret"}}}},
+		log:        zap.NewNop().Sugar(),
+		httpClient: srv.Client(),
+	}
+	code, err := s.send(context.Background(), &models.WebhookDelivery{ID: "d1", EndpointID: "app", EventID: "evt-1", EventType: "membership.changed", Payload: payload})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, code)
+
+	var ts int64
+	var sig string
+	_, err = fmt.Sscanf(strings.Replace(gotSignature, ",v1=", " ", 1), "t=%d %s", &ts, &sig)
+	require.NoError(t, err)
+	require.Equal(t, ComputeSignature(ts, payload, "s3cret"), sig)
+}
+
+func TestSend_FailsOnNon2xxAndUnknownEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	s := &Service{
+		cfg:        &config.Config{Webhook: config.WebhookConfig{Endpoints: []*config.WebhookEndpoint{{ID: "app", URL: srv.URL}}}},
+		log:        zap.NewNop().Sugar(),
+		httpClient: srv.Client(),
+	}
+	code, err := s.send(context.Background(), &models.WebhookDelivery{EndpointID: "app", Payload: []byte(`{}`)})
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadGateway, code)
+
+	_, err = s.send(context.Background(), &models.WebhookDelivery{EndpointID: "gone", Payload: []byte(`{}`)})
+	require.ErrorContains(t, err, "webhook endpoint not configured")
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, baseRetryDelay, retryDelay(1))
+	require.Equal(t, 4*baseRetryDelay, retryDelay(3))
+	require.Equal(t, maxRetryDelay, retryDelay(50))
+	require.Less(t, retryDelay(defaultMaxAttempts-1), 2*time.Hour)
+}
+
+func TestLeaseEnd_MatchesStoredPrecision(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 123456789, time.UTC)
+	require.Equal(t, time.Date(2026, 3, 1, 10, 2, 0, 123456000, time.UTC), leaseEnd(now))
+	require.Greater(t, claimLease, requestTimeout, "a renewed lease outlasts the request it covers")
+}
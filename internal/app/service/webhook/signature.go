@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+const (
+	SignatureHeader = "X-Cashier-Signature"
+	EventIDHeader   = "X-Cashier-Event-Id"
+	EventTypeHeader = "X-Cashier-Event-Type"
+)
+
+// ComputeSignature returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the endpoint secret.
+// Subscribers recompute it to verify a delivery.
+func ComputeSignature(timestamp int64, body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signatureHeaderValue formats the X-Cashier-Signature header: "t=<unix seconds>,v1=<signature>".
+func signatureHeaderValue(timestamp int64, body []byte, secret string) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, ComputeSignature(timestamp, body, secret))
+}
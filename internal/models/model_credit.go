@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/types"
+)
+
+// Kinds of credit ledger entries.
+const (
+	// CreditEntryKindGrant adds the credits of a consumable purchase, or restores them when a refund is reversed.
+	CreditEntryKindGrant = "grant"
+	// CreditEntryKindSpend takes credits the product backend spent on behalf of the user.
+	CreditEntryKindSpend = "spend"
+	// CreditEntryKindClawback takes back the credits of a refunded purchase, even if the balance goes negative.
+	CreditEntryKindClawback = "clawback"
+)
+
+// CreditLedgerEntry is one change of a user's credit balance. Entries are never updated.
+type CreditLedgerEntry struct {
+	ID     string `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	UserID string `gorm:"column:user_id;type:varchar(64);not null;index:idx_credit_ledger_user_created,priority:1" json:"user_id"`
+	Kind   string `gorm:"column:kind;type:varchar(32);not null" json:"kind"`
+	// Amount is positive for grants and negative for spends and clawbacks.
+	Amount       int64 `gorm:"column:amount;not null" json:"amount"`
+	BalanceAfter int64 `gorm:"column:balance_after;not null" json:"balance_after"`
+	// ProviderID and TransactionID identify the purchase of grants and clawbacks; empty for spends.
+	ProviderID    types.PaymentProvider `gorm:"column:provider_id;type:varchar(64);not null;default:'';index:idx_credit_ledger_transaction,priority:1" json:"provider_id,omitempty"`
+	TransactionID string                `gorm:"column:transaction_id;type:varchar(64);not null;default:'';index:idx_credit_ledger_transaction,priority:2" json:"transaction_id,omitempty"`
+	// IdempotencyKey is the caller's key of a spend; a repeated spend with the same key is not applied twice.
+	IdempotencyKey *string   `gorm:"column:idempotency_key;type:varchar(128);uniqueIndex:idx_credit_ledger_idempotency_key" json:"idempotency_key,omitempty"`
+	Reason         string    `gorm:"column:reason;type:varchar(255);not null;default:''" json:"reason,omitempty"`
+	CreatedAt      time.Time `gorm:"index:idx_credit_ledger_user_created,priority:2" json:"created_at"`
+}
+
+func (CreditLedgerEntry) TableName() string { return "credit_ledger" }
+
+// CreditBalance is the sum of a user's ledger entries, kept so spends can lock and check it.
+type CreditBalance struct {
+	UserID    string    `gorm:"column:user_id;type:varchar(64);primary_key" json:"user_id"`
+	Balance   int64     `gorm:"column:balance;not null" json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (CreditBalance) TableName() string { return "credit_balance" }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// FxRate is the exchange rate of one day: one unit of BaseCurrency is worth Rate units of QuoteCurrency.
+type FxRate struct {
+	// RateDate is the UTC day the rate applies to, formatted as time.DateOnly.
+	RateDate      string    `gorm:"column:rate_date;type:varchar(10);primary_key" json:"rate_date"`
+	BaseCurrency  string    `gorm:"column:base_currency;type:varchar(3);primary_key" json:"base_currency"`
+	QuoteCurrency string    `gorm:"column:quote_currency;type:varchar(3);primary_key" json:"quote_currency"`
+	Rate          float64   `gorm:"column:rate;type:double precision;not null" json:"rate"`
+	Source        string    `gorm:"column:source;type:varchar(64);not null;default:''" json:"source,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (FxRate) TableName() string { return "fx_rate" }
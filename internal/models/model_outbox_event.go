@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type OutboxEventStatus string
+
+const (
+	OutboxEventStatusPending    OutboxEventStatus = "pending"
+	OutboxEventStatusDispatched OutboxEventStatus = "dispatched"
+	// OutboxEventStatusFailed marks events that exhausted their retries and need manual attention.
+	OutboxEventStatusFailed OutboxEventStatus = "failed"
+)
+
+// OutboxEvent is a side effect written in the same DB transaction as the state change that caused it.
+// The outbox dispatcher applies pending events after commit.
+type OutboxEvent struct {
+	ID           string            `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	Topic        string            `gorm:"column:topic;type:varchar(64);not null" json:"topic"`
+	Payload      datatypes.JSON    `gorm:"column:payload;type:jsonb;not null" json:"payload"`
+	Status       OutboxEventStatus `gorm:"column:status;type:varchar(32);not null;index:idx_outbox_status_available,priority:1" json:"status"`
+	Attempts     int               `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError    string            `gorm:"column:last_error;type:text" json:"last_error"`
+	AvailableAt  time.Time         `gorm:"column:available_at;not null;index:idx_outbox_status_available,priority:2" json:"available_at"`
+	DispatchedAt *time.Time        `gorm:"column:dispatched_at" json:"dispatched_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (OutboxEvent) TableName() string { return "outbox_event" }
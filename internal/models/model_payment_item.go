@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"gorm.io/datatypes"
+)
+
+// PaymentItem is a catalog entry. Transactions keep a snapshot of the version they were bought with.
+type PaymentItem struct {
+	ID             string                `gorm:"column:id;type:varchar(64);primary_key" json:"id"`
+	ProviderID     types.PaymentProvider `gorm:"column:provider_id;type:varchar(64);not null;uniqueIndex:idx_payment_item_provider_item,priority:1" json:"provider_id"`
+	ProviderItemID string                `gorm:"column:provider_item_id;type:varchar(255);not null;uniqueIndex:idx_payment_item_provider_item,priority:2" json:"provider_item_id"`
+	Type           types.PaymentItemType `gorm:"column:type;type:varchar(64);not null" json:"type"`
+	DurationHour   *int64                `gorm:"column:duration_hour" json:"duration_hour"`
+	Credits        int64                 `gorm:"column:credits;not null;default:0" json:"credits"`
+	// Entitlements are the entitlements the item grants; empty grants types.DefaultEntitlement.
+	Entitlements      datatypes.JSONType[[]*types.EntitlementGrant] `gorm:"column:entitlements;type:jsonb;not null;default:'[]'" json:"entitlements" swaggertype:"array,object"`
+	SubscriptionGroup string                                        `gorm:"column:subscription_group;type:varchar(64);not null;default:''" json:"subscription_group"`
+	Level             int                                           `gorm:"column:level;not null;default:0" json:"level"`
+	Status            types.PaymentItemStatus                       `gorm:"column:status;type:varchar(32);not null" json:"status"`
+	Version           int64                                         `gorm:"column:version;not null" json:"version"`
+	CreatedAt         time.Time                                     `json:"created_at"`
+	UpdatedAt         time.Time                                     `json:"updated_at"`
+}
+
+func (PaymentItem) TableName() string { return "payment_item" }
+
+func (m *PaymentItem) ToType() *types.PaymentItem {
+	return &types.PaymentItem{
+		ID:                m.ID,
+		ProviderID:        m.ProviderID,
+		ProviderItemID:    m.ProviderItemID,
+		Type:              m.Type,
+		DurationHour:      m.DurationHour,
+		Credits:           m.Credits,
+		Entitlements:      m.Entitlements.Data(),
+		SubscriptionGroup: m.SubscriptionGroup,
+		Level:             m.Level,
+		Status:            m.Status,
+		Version:           m.Version,
+	}
+}
+
+// PaymentItemVersion keeps every version of a catalog entry and who made it.
+type PaymentItemVersion struct {
+	ID            string                                 `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	PaymentItemID string                                 `gorm:"column:payment_item_id;type:varchar(64);not null;uniqueIndex:idx_payment_item_version,priority:1" json:"payment_item_id"`
+	Version       int64                                  `gorm:"column:version;not null;uniqueIndex:idx_payment_item_version,priority:2" json:"version"`
+	Item          datatypes.JSONType[*types.PaymentItem] `gorm:"column:item;type:jsonb;not null" json:"item" swaggertype:"object"`
+	Operator      string                                 `gorm:"column:operator;type:varchar(64);not null" json:"operator"`
+	CreatedAt     time.Time                              `json:"created_at"`
+}
+
+func (PaymentItemVersion) TableName() string { return "payment_item_version" }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type PaymentNotificationDedupStatus string
+
+const (
+	PaymentNotificationDedupStatusProcessing   PaymentNotificationDedupStatus = "processing"
+	PaymentNotificationDedupStatusHandled      PaymentNotificationDedupStatus = "handled"
+	PaymentNotificationDedupStatusHandleFailed PaymentNotificationDedupStatus = "handle_failed"
+)
+
+// PaymentNotificationDedup records each provider notification id once so redeliveries are not re-applied.
+type PaymentNotificationDedup struct {
+	ProviderID     string                         `gorm:"column:provider_id;type:varchar(64);primaryKey" json:"provider_id"`
+	NotificationID string                         `gorm:"column:notification_id;type:varchar(128);primaryKey" json:"notification_id"`
+	TransactionID  string                         `gorm:"column:transaction_id;type:varchar(128)" json:"transaction_id"`
+	Status         PaymentNotificationDedupStatus `gorm:"column:status;type:varchar(64);not null" json:"status"`
+	Attempts       int                            `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	CreatedAt      time.Time                      `json:"created_at"`
+	UpdatedAt      time.Time                      `json:"updated_at"`
+}
+
+func (PaymentNotificationDedup) TableName() string { return "payment_notification_dedup" }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/types"
+
+	"gorm.io/datatypes"
+)
+
+// Drift kinds recorded in ReconcileDrift.Kinds.
+const (
+	// ReconcileDriftMissingTransaction is a transaction the provider reports that was never stored, such as a missed renewal.
+	ReconcileDriftMissingTransaction = "missing_transaction"
+	// ReconcileDriftRefund is a refund the provider reports that was not stored.
+	ReconcileDriftRefund = "refund"
+	// ReconcileDriftRefundReversed is a stored refund the provider no longer reports.
+	ReconcileDriftRefundReversed = "refund_reversed"
+	// ReconcileDriftExpireAt is a different expiry time.
+	ReconcileDriftExpireAt = "expire_at"
+	// ReconcileDriftAutoRenew is a different auto-renewal state of the latest transaction.
+	ReconcileDriftAutoRenew = "auto_renew"
+	// ReconcileDriftGracePeriod is a different grace period end of the latest transaction.
+	ReconcileDriftGracePeriod = "grace_period"
+	// ReconcileDriftBillingRetry is a different billing retry state of the latest transaction.
+	ReconcileDriftBillingRetry = "billing_retry"
+)
+
+// ReconcileDrift records a transaction whose stored state differed from the provider during a reconciliation
+// run, and whether the correction was applied.
+type ReconcileDrift struct {
+	ID         string                `gorm:"column:id;primary_key;type:uuid" json:"id"`
+	RunID      string                `gorm:"column:run_id;type:uuid;not null;index:idx_reconcile_drift_run_id" json:"run_id"`
+	UserID     string                `gorm:"column:user_id;type:varchar(64);not null;index:idx_reconcile_drift_user_id" json:"user_id"`
+	ProviderID types.PaymentProvider `gorm:"column:provider_id;type:varchar(64);not null" json:"provider_id"`
+	// OriginalTransactionID identifies the renewal chain that was reconciled.
+	OriginalTransactionID string                           `gorm:"column:original_transaction_id;type:varchar(64);not null" json:"original_transaction_id"`
+	TransactionID         string                           `gorm:"column:transaction_id;type:varchar(64);not null" json:"transaction_id"`
+	Kinds                 datatypes.JSONType[[]string]     `gorm:"column:kinds;type:jsonb;not null" json:"kinds" swaggertype:"array,string"`
+	Before                datatypes.JSONType[*Transaction] `gorm:"column:before;type:jsonb" json:"before" swaggertype:"object"`
+	After                 datatypes.JSONType[*Transaction] `gorm:"column:after;type:jsonb" json:"after" swaggertype:"object"`
+	Applied               bool                             `gorm:"column:applied;not null" json:"applied"`
+	Error                 *string                          `gorm:"column:error;type:text" json:"error"`
+	CreatedAt             time.Time                        `gorm:"index:idx_reconcile_drift_created_at" json:"created_at"`
+}
+
+func (ReconcileDrift) TableName() string {
+	return "reconcile_drift"
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// SchedulerLease is a lease on a named role held by one replica until ExpiresAt. The scheduler uses it to
+// elect the replica that runs the jobs.
+type SchedulerLease struct {
+	Name      string    `gorm:"column:name;type:varchar(64);primary_key" json:"name"`
+	Holder    string    `gorm:"column:holder;type:varchar(128);not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SchedulerLease) TableName() string { return "scheduler_lease" }
+
+type JobRunStatus string
+
+const (
+	// JobRunStatusRunning is a run in progress, or one whose replica stopped before it finished.
+	JobRunStatusRunning   JobRunStatus = "running"
+	JobRunStatusSucceeded JobRunStatus = "succeeded"
+	JobRunStatusFailed    JobRunStatus = "failed"
+)
+
+// JobRun records one run of a scheduled job. A job runs at most once per ScheduledAt.
+type JobRun struct {
+	ID          string       `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	Job         string       `gorm:"column:job;type:varchar(64);not null;uniqueIndex:idx_job_run_job_scheduled_at,priority:1" json:"job"`
+	ScheduledAt time.Time    `gorm:"column:scheduled_at;not null;uniqueIndex:idx_job_run_job_scheduled_at,priority:2" json:"scheduled_at"`
+	Holder      string       `gorm:"column:holder;type:varchar(128);not null" json:"holder"`
+	Status      JobRunStatus `gorm:"column:status;type:varchar(32);not null" json:"status"`
+	Error       *string      `gorm:"column:error;type:text" json:"error"`
+	StartedAt   time.Time    `gorm:"column:started_at;not null;index:idx_job_run_started_at" json:"started_at"`
+	FinishedAt  *time.Time   `gorm:"column:finished_at" json:"finished_at"`
+}
+
+func (JobRun) TableName() string { return "job_run" }
@@ -31,7 +31,7 @@ func (Subscription) TableName() string {
 
 func (userSubscription *Subscription) Valid() bool {
 	return userSubscription != nil &&
-		userSubscription.Status == types.SubscriptionStatusActive &&
+		userSubscription.Status.Entitled() &&
 		userSubscription.ExpireAt != nil &&
 		userSubscription.ExpireAt.After(time.Now())
 }
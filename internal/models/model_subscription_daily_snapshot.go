@@ -24,6 +24,11 @@ type SubscriptionDailySnapshot struct {
 	UserID            string    `gorm:"column:user_id;type:varchar(64);not null;uniqueIndex:idx_user_id_snapshot_date,priority:1" json:"user_id"`
 	SnapshotDate      string    `gorm:"column:snapshot_date;uniqueIndex:idx_user_id_snapshot_date,priority:2" json:"snapshot_date"`
 	SnapshotCreatedAt time.Time `gorm:"column:snapshot_created_at" json:"snapshot_created_at"`
+	// Mrr is the monthly recurring revenue of the user's current auto-renewable subscription: its price
+	// normalized to a month. It is zero while the user has no paid access.
+	Mrr types.Money `gorm:"embedded;embeddedPrefix:mrr_" json:"mrr"`
+	// MrrCaptured tells snapshots with an Mrr from those taken before it was recorded, whose Mrr is zero.
+	MrrCaptured bool `gorm:"column:mrr_captured;not null;default:false" json:"mrr_captured"`
 }
 
 func (SubscriptionDailySnapshot) TableName() string {
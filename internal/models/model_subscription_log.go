@@ -11,7 +11,7 @@ import (
 // Use case: troubleshooting.
 type SubscriptionLog struct {
 	ID     string `gorm:"column:id;type:uuid;primary_key" json:"id"`
-	UserID string `gorm:"column:user_id;type:varchar(64);index:idx_user_id_id,priority:1;not null"`
+	UserID string `gorm:"column:user_id;type:varchar(64);index:idx_subscription_log_user_id;not null"`
 	// Reason is the change reason.
 	Reason types.SubscriptionChangeReason `gorm:"column:reason;type:varchar(64);not null"`
 	// Before stores subscription data before the change in JSON format.
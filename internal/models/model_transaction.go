@@ -14,17 +14,52 @@ type UserSubscriptionItemExtra struct {
 	PaymentItemSnapshot *types.PaymentItem `json:"payment_item_snapshot"`
 	// IsFirstPurchase indicates whether this is the user's first purchase
 	IsFirstPurchase bool `json:"is_first_purchase"`
+	// PendingDowngrade is the lower item the subscription switches to at its next renewal, when the provider reported one.
+	PendingDowngrade *PendingDowngrade `json:"pending_downgrade,omitempty"`
+	// GracePeriodExpireAt is set after a failed renewal when the provider keeps access until then.
+	GracePeriodExpireAt *time.Time `json:"grace_period_expire_at,omitempty"`
+	// InBillingRetry reports that the provider is retrying a failed renewal of this period.
+	InBillingRetry bool `json:"in_billing_retry,omitempty"`
+	// PlanChange is set when this transaction switched its renewal chain to another item of the subscription group.
+	PlanChange types.PlanChange `json:"plan_change,omitempty"`
+	// Offer is the offer the transaction was bought with, if any.
+	Offer *Offer `json:"offer,omitempty"`
+}
+
+// Offer types reported by Apple; Google offers only have an ID.
+const (
+	OfferTypeIntroductory = "introductory"
+	OfferTypePromotional  = "promotional"
+	OfferTypeOfferCode    = "offer_code"
+	OfferTypeWinBack      = "win_back"
+)
+
+// Offer describes a discount or trial a transaction was bought with.
+type Offer struct {
+	Type string `json:"type,omitempty"`
+	ID   string `json:"id,omitempty"`
+	// DiscountType is how the offer is paid, e.g. Apple's FREE_TRIAL or PAY_AS_YOU_GO.
+	DiscountType string `json:"discount_type,omitempty"`
+}
+
+// PendingDowngrade describes a downgrade scheduled by the provider for the next renewal.
+type PendingDowngrade struct {
+	PaymentItemID string    `json:"payment_item_id"`
+	EffectiveAt   time.Time `json:"effective_at"`
 }
 
 // Transaction stores a user subscription purchase record.
 type Transaction struct {
-	ID            string                `gorm:"column:id;primary_key;type:uuid;index:idx_user_id_id,priority:2,sort:desc" json:"id"`
-	UserID        string                `gorm:"column:user_id;type:varchar(64);not null;index:idx_user_id_id,priority:1" json:"user_id"`
+	ID            string                `gorm:"column:id;primary_key;type:uuid;index:idx_transaction_user_id_id,priority:2,sort:desc" json:"id"`
+	UserID        string                `gorm:"column:user_id;type:varchar(64);not null;index:idx_transaction_user_id_id,priority:1" json:"user_id"`
 	ProviderID    types.PaymentProvider `gorm:"column:provider_id;type:varchar(64);not null;uniqueIndex:unique_provider_id_transaction_id,priority:1;uniqueIndex:unique_provider_id_before_upgraded_transaction_id,priority:1" json:"provider_id"`
 	PaymentItemID string                `gorm:"column:payment_item_id;type:varchar(64);not null" json:"payment_item_id"`
 	TransactionID string                `gorm:"column:transaction_id;type:varchar(64);not null;uniqueIndex:unique_provider_id_transaction_id,priority:2" json:"transaction_id"`
-	Currency      string                `gorm:"column:currency;type:varchar(64);not null" json:"currency"`
-	Price         int64                 `gorm:"column:price;type:bigint;not null" json:"price"`
+	// Price is what the user paid, in the minor units of its currency.
+	Price types.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+	// Storefront is the country of the store the purchase was made in, as the provider reports it: an ISO
+	// 3166-1 alpha-3 code for Apple, alpha-2 for Google.
+	Storefront string `gorm:"column:storefront;type:varchar(8);not null;default:''" json:"storefront,omitempty"`
 	// ParentTransactionID is the parent transaction ID used for auto-renewal.
 	ParentTransactionID *string `gorm:"column:parent_transaction_id;type:varchar(64);" json:"parent_transaction_id"`
 	// PurchaseAt is the purchase time.
@@ -35,17 +70,30 @@ type Transaction struct {
 	AutoRenewExpireAt *time.Time `gorm:"column:expire_at;default:null" json:"expire_at"`
 	// If IsAutoRenewable is true, NextAutoRenewAt is the next auto-renewal time; otherwise it is nil.
 	NextAutoRenewAt *time.Time `gorm:"column:next_auto_renew_at;default:null" json:"next_auto_renew_at"`
-	// During subscription upgrades, the original transaction may carry revocationDate and revocationReason to mark invalidation.
+	// RevocationDate and RevocationReason are set when the provider refunded or revoked the transaction, or when an
+	// upgrade replaced it (RevocationReasonUpgraded, without RefundAt).
 	RevocationDate   *time.Time `gorm:"column:revocation_date;default:null" json:"revocation_date"`
 	RevocationReason *string    `gorm:"column:revocation_reason;type:varchar(64);default:null" json:"revocation_reason"`
 	// BeforeUpgradedTransactionID points to the transaction_id this record upgrades from.
 	BeforeUpgradedTransactionID *string `gorm:"column:before_upgraded_transaction_id;type:varchar(64);uniqueIndex:unique_provider_id_before_upgraded_transaction_id,priority:2" json:"before_upgraded_transaction_id"`
 
-	Extra     datatypes.JSONType[*UserSubscriptionItemExtra] `gorm:"column:extra;type:jsonb;default:'{}'" json:"extra"`
+	Extra     datatypes.JSONType[*UserSubscriptionItemExtra] `gorm:"column:extra;type:jsonb;default:'{}'" json:"extra" swaggertype:"object"`
 	CreatedAt time.Time                                      `json:"created_at"`
 	UpdatedAt time.Time                                      `json:"updated_at"`
 }
 
+// Revocation reasons recorded in Transaction.RevocationReason.
+const (
+	// RevocationReasonRefund is a refund for a reason other than an issue with the app, such as an accidental purchase.
+	RevocationReasonRefund = "refund"
+	// RevocationReasonRefundAppIssue is a refund because of an actual or perceived issue with the app.
+	RevocationReasonRefundAppIssue = "refund_app_issue"
+	// RevocationReasonRevoke is access withdrawn without a refund to the user, such as a lost Family Sharing entitlement.
+	RevocationReasonRevoke = "revoke"
+	// RevocationReasonUpgraded marks a transaction replaced by an upgrade; it is not a refund.
+	RevocationReasonUpgraded = "upgraded"
+)
+
 func (Transaction) TableName() string {
 	return "transaction"
 }
@@ -58,6 +106,22 @@ func (item *Transaction) IsAutoRenewable() bool {
 	return item.NextAutoRenewAt != nil
 }
 
+func (item *Transaction) GetGracePeriodExpireAt() *time.Time {
+	if item == nil || item.Extra.Data() == nil {
+		return nil
+	}
+
+	return item.Extra.Data().GracePeriodExpireAt
+}
+
+func (item *Transaction) IsInBillingRetry() bool {
+	if item == nil || item.Extra.Data() == nil {
+		return false
+	}
+
+	return item.Extra.Data().InBillingRetry
+}
+
 func (item *Transaction) GetPaymentItemSnapshot() *types.PaymentItem {
 	if item == nil || item.Extra.Data() == nil {
 		return nil
@@ -10,8 +10,8 @@ import (
 // TransactionLog records changes to user subscription transactions.
 // Use case: troubleshooting subscription transaction changes.
 type TransactionLog struct {
-	ID            string                `gorm:"column:id;primary_key;type:uuid;index:idx_user_id_id,priority:2,sort:desc"`
-	UserID        string                `gorm:"column:user_id;type:varchar(64);index:idx_user_id_id,priority:1;not null"`
+	ID            string                `gorm:"column:id;primary_key;type:uuid;index:idx_transaction_log_user_id_id,priority:2,sort:desc"`
+	UserID        string                `gorm:"column:user_id;type:varchar(64);index:idx_transaction_log_user_id_id,priority:1;not null"`
 	PaymentItemID string                `gorm:"column:payment_item_id;type:varchar(64);not null"`
 	ProviderID    types.PaymentProvider `gorm:"column:provider_id;type:varchar(64);not null"`
 	TransactionID string                `gorm:"column:transaction_id;type:varchar(64);not null"`
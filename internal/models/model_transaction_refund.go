@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fatflowers/cashier/pkg/types"
+)
+
+type TransactionRefundEvent string
+
+const (
+	TransactionRefundEventRefunded TransactionRefundEvent = "refunded"
+	TransactionRefundEventReversed TransactionRefundEvent = "reversed"
+)
+
+// TransactionRefund records each refund and refund reversal of a transaction, oldest first.
+// Transaction.RefundAt only holds the current state.
+type TransactionRefund struct {
+	ID            string                 `gorm:"column:id;primary_key;type:uuid" json:"id"`
+	UserID        string                 `gorm:"column:user_id;type:varchar(64);not null" json:"user_id"`
+	ProviderID    types.PaymentProvider  `gorm:"column:provider_id;type:varchar(64);not null;index:idx_transaction_refund_provider_transaction,priority:1" json:"provider_id"`
+	TransactionID string                 `gorm:"column:transaction_id;type:varchar(64);not null;index:idx_transaction_refund_provider_transaction,priority:2" json:"transaction_id"`
+	PaymentItemID string                 `gorm:"column:payment_item_id;type:varchar(64);not null" json:"payment_item_id"`
+	Event         TransactionRefundEvent `gorm:"column:event;type:varchar(32);not null" json:"event"`
+	// RefundAt is when the provider refunded the transaction; for a reversal, the refund that was reversed.
+	RefundAt time.Time `gorm:"column:refund_at;not null" json:"refund_at"`
+	// Reason is the revocation reason of the refund, see RevocationReasonRefund.
+	Reason    *string   `gorm:"column:reason;type:varchar(64)" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (TransactionRefund) TableName() string {
+	return "transaction_refund"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryStatusDead means the delivery exhausted its retries and was copied to webhook_dead_letter.
+	WebhookDeliveryStatusDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event to be delivered to one subscriber endpoint.
+type WebhookDelivery struct {
+	ID             string                `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	EndpointID     string                `gorm:"column:endpoint_id;type:varchar(64);not null" json:"endpoint_id"`
+	EventID        string                `gorm:"column:event_id;type:varchar(64);not null;index" json:"event_id"`
+	EventType      string                `gorm:"column:event_type;type:varchar(64);not null" json:"event_type"`
+	Payload        datatypes.JSON        `gorm:"column:payload;type:jsonb;not null" json:"payload" swaggertype:"object"`
+	Status         WebhookDeliveryStatus `gorm:"column:status;type:varchar(32);not null;index:idx_webhook_delivery_status_next,priority:1" json:"status"`
+	Attempts       int                   `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"column:next_attempt_at;not null;index:idx_webhook_delivery_status_next,priority:2" json:"next_attempt_at"`
+	LastStatusCode int                   `gorm:"column:last_status_code" json:"last_status_code"`
+	LastError      string                `gorm:"column:last_error;type:text" json:"last_error"`
+	DeliveredAt    *time.Time            `gorm:"column:delivered_at" json:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhook_delivery" }
+
+// WebhookDeadLetter keeps deliveries that failed every attempt until an admin redelivers them.
+type WebhookDeadLetter struct {
+	ID             string         `gorm:"column:id;type:uuid;primary_key" json:"id"`
+	DeliveryID     string         `gorm:"column:delivery_id;type:uuid;not null;uniqueIndex" json:"delivery_id"`
+	EndpointID     string         `gorm:"column:endpoint_id;type:varchar(64);not null" json:"endpoint_id"`
+	EventID        string         `gorm:"column:event_id;type:varchar(64);not null" json:"event_id"`
+	EventType      string         `gorm:"column:event_type;type:varchar(64);not null" json:"event_type"`
+	Payload        datatypes.JSON `gorm:"column:payload;type:jsonb;not null" json:"payload" swaggertype:"object"`
+	Attempts       int            `gorm:"column:attempts;not null" json:"attempts"`
+	LastStatusCode int            `gorm:"column:last_status_code" json:"last_status_code"`
+	LastError      string         `gorm:"column:last_error;type:text" json:"last_error"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+func (WebhookDeadLetter) TableName() string { return "webhook_dead_letter" }
@@ -0,0 +1,126 @@
+// Package apple_notificationtest signs App Store Server Notification fixtures with a throwaway
+// certificate chain, for tests that exercise signature parsing end to end.
+package apple_notificationtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
+)
+
+// Signer holds a root, intermediate and leaf certificate mirroring Apple's x5c chain.
+type Signer struct {
+	// RootCertPem is passed to apple_notification.NewWithRootCert.
+	RootCertPem string
+
+	leafKey *ecdsa.PrivateKey
+	x5c     []string
+}
+
+func NewSigner() (*Signer, error) {
+	rootKey, rootCert, err := newCert("Test Root CA", nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	interKey, interCert, err := newCert("Test Intermediate CA", rootCert, rootKey, true)
+	if err != nil {
+		return nil, err
+	}
+	leafKey, leafCert, err := newCert("Test Leaf", interCert, interKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{
+		RootCertPem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootCert.Raw})),
+		leafKey:     leafKey,
+		x5c: []string{
+			base64.StdEncoding.EncodeToString(leafCert.Raw),
+			base64.StdEncoding.EncodeToString(interCert.Raw),
+			base64.StdEncoding.EncodeToString(rootCert.Raw),
+		},
+	}, nil
+}
+
+// SignNotification signs transaction and renewal info, when given, into payload.Data and returns the
+// signedPayload of the request body.
+func (s *Signer) SignNotification(payload *apple_notification.NotificationPayload, transaction *apple_notification.TransactionInfo, renewal *apple_notification.RenewalInfo) (string, error) {
+	if transaction != nil {
+		signed, err := s.Sign(transaction)
+		if err != nil {
+			return "", err
+		}
+		payload.Data.SignedTransactionInfo = signed
+	}
+	if renewal != nil {
+		signed, err := s.Sign(renewal)
+		if err != nil {
+			return "", err
+		}
+		payload.Data.SignedRenewalInfo = signed
+	}
+	return s.Sign(payload)
+}
+
+// SignRequest returns the JSON request body Apple posts to the webhook.
+func (s *Signer) SignRequest(payload *apple_notification.NotificationPayload, transaction *apple_notification.TransactionInfo, renewal *apple_notification.RenewalInfo) ([]byte, error) {
+	signed, err := s.SignNotification(payload, transaction, renewal)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&apple_notification.AppStoreServerRequest{SignedPayload: signed})
+}
+
+// Sign returns claims as an ES256 JWS carrying the x5c chain.
+func (s *Signer) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = s.x5c
+	return token.SignedString(s.leafKey)
+}
+
+func newCert(cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
@@ -28,11 +28,17 @@ at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
 -----END CERTIFICATE-----`
 
 func New(payload string) (*AppStoreServerNotification, error) {
+	return NewWithRootCert(payload, appleRootCAG3RootPem)
+}
+
+// NewWithRootCert verifies the payload against rootCertPem instead of the Apple Root CA - G3,
+// so tests can use locally signed fixtures.
+func NewWithRootCert(payload string, rootCertPem string) (*AppStoreServerNotification, error) {
 	asn := &AppStoreServerNotification{}
 	asn.IsValid = false
 	asn.IsTestNotification = false
 	asn.IsSandbox = false
-	asn.appleRootCert = appleRootCAG3RootPem
+	asn.appleRootCert = rootCertPem
 	err := asn.parseJwtSignedPayload(payload)
 	if err != nil {
 		return nil, err
@@ -44,8 +50,8 @@ func (asn *AppStoreServerNotification) extractHeaderByIndex(payload string, inde
 	// get header from token
 	payloadArr := strings.Split(payload, ".")
 
-	// convert header to byte
-	headerByte, err := base64.RawStdEncoding.DecodeString(payloadArr[0])
+	// convert header to byte; JWS segments use the URL-safe alphabet
+	headerByte, err := base64.RawURLEncoding.DecodeString(payloadArr[0])
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +157,7 @@ func (asn *AppStoreServerNotification) parseJwtSignedPayload(payload string) err
 		return err
 	}
 	asn.Payload = notificationPayload
-	asn.IsTestNotification = asn.Payload.NotificationType == "TEST"
+	asn.IsTestNotification = asn.Payload.NotificationType == NotificationTypeTest
 	asn.IsSandbox = asn.Payload.Data.Environment == "Sandbox"
 
 	if asn.IsTestNotification {
@@ -159,16 +165,18 @@ func (asn *AppStoreServerNotification) parseJwtSignedPayload(payload string) err
 		return nil
 	}
 
-	// transaction info
-	transactionInfo := &TransactionInfo{}
-	payload = asn.Payload.Data.SignedTransactionInfo
-	_, err = jwt.ParseWithClaims(payload, transactionInfo, func(token *jwt.Token) (interface{}, error) {
-		return asn.extractPublicKeyFromPayload(payload)
-	})
-	if err != nil {
-		return err
+	// transaction info; summary notifications such as RENEWAL_EXTENSION carry none
+	if asn.Payload.Data.SignedTransactionInfo != "" {
+		transactionInfo := &TransactionInfo{}
+		payload = asn.Payload.Data.SignedTransactionInfo
+		_, err = jwt.ParseWithClaims(payload, transactionInfo, func(token *jwt.Token) (interface{}, error) {
+			return asn.extractPublicKeyFromPayload(payload)
+		})
+		if err != nil {
+			return err
+		}
+		asn.TransactionInfo = transactionInfo
 	}
-	asn.TransactionInfo = transactionInfo
 
 	// renewal info
 	if asn.Payload.Data.SignedRenewalInfo != "" {
@@ -0,0 +1,40 @@
+package apple_notification_test
+
+import (
+	"testing"
+
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification"
+	"github.com/fatflowers/cashier/internal/platform/apple/apple_notification/apple_notificationtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_RejectsChainNotIssuedByApple(t *testing.T) {
+	signer, err := apple_notificationtest.NewSigner()
+	require.NoError(t, err)
+	signed, err := signer.SignNotification(&apple_notification.NotificationPayload{NotificationType: apple_notification.NotificationTypeTest}, nil, nil)
+	require.NoError(t, err)
+
+	_, err = apple_notification.New(signed)
+	require.Error(t, err)
+
+	n, err := apple_notification.NewWithRootCert(signed, signer.RootCertPem)
+	require.NoError(t, err)
+	require.True(t, n.IsTestNotification)
+}
+
+func TestNewWithRootCert_SummaryWithoutTransaction(t *testing.T) {
+	signer, err := apple_notificationtest.NewSigner()
+	require.NoError(t, err)
+	signed, err := signer.SignNotification(&apple_notification.NotificationPayload{
+		NotificationType: apple_notification.NotificationTypeRenewalExtension,
+		Subtype:          apple_notification.SubtypeSummary,
+		Summary:          apple_notification.NotificationSummary{ProductId: "com.example.vip.monthly", SucceededCount: 3},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	n, err := apple_notification.NewWithRootCert(signed, signer.RootCertPem)
+	require.NoError(t, err)
+	require.True(t, n.IsValid)
+	require.Nil(t, n.TransactionInfo)
+	require.Equal(t, int64(3), n.Payload.Summary.SucceededCount)
+}
@@ -2,6 +2,55 @@ package apple_notification
 
 import "github.com/golang-jwt/jwt"
 
+// Notification types of App Store Server Notifications V2.
+// https://developer.apple.com/documentation/appstoreservernotifications/notificationtype
+const (
+	NotificationTypeConsumptionRequest     = "CONSUMPTION_REQUEST"
+	NotificationTypeDidChangeRenewalPref   = "DID_CHANGE_RENEWAL_PREF"
+	NotificationTypeDidChangeRenewalStatus = "DID_CHANGE_RENEWAL_STATUS"
+	NotificationTypeDidFailToRenew         = "DID_FAIL_TO_RENEW"
+	NotificationTypeDidRenew               = "DID_RENEW"
+	NotificationTypeExpired                = "EXPIRED"
+	NotificationTypeExternalPurchaseToken  = "EXTERNAL_PURCHASE_TOKEN"
+	NotificationTypeGracePeriodExpired     = "GRACE_PERIOD_EXPIRED"
+	NotificationTypeMetadataUpdate         = "METADATA_UPDATE"
+	NotificationTypeMigration              = "MIGRATION"
+	NotificationTypeOfferRedeemed          = "OFFER_REDEEMED"
+	NotificationTypeOneTimeCharge          = "ONE_TIME_CHARGE"
+	NotificationTypePriceChange            = "PRICE_CHANGE"
+	NotificationTypePriceIncrease          = "PRICE_INCREASE"
+	NotificationTypeRefund                 = "REFUND"
+	NotificationTypeRefundDeclined         = "REFUND_DECLINED"
+	NotificationTypeRefundReversed         = "REFUND_REVERSED"
+	NotificationTypeRenewalExtended        = "RENEWAL_EXTENDED"
+	NotificationTypeRenewalExtension       = "RENEWAL_EXTENSION"
+	NotificationTypeRescindConsent         = "RESCIND_CONSENT"
+	NotificationTypeRevoke                 = "REVOKE"
+	NotificationTypeSubscribed             = "SUBSCRIBED"
+	NotificationTypeTest                   = "TEST"
+)
+
+// Notification subtypes.
+// https://developer.apple.com/documentation/appstoreservernotifications/subtype
+const (
+	SubtypeAccepted          = "ACCEPTED"
+	SubtypeAutoRenewDisabled = "AUTO_RENEW_DISABLED"
+	SubtypeAutoRenewEnabled  = "AUTO_RENEW_ENABLED"
+	SubtypeBillingRecovery   = "BILLING_RECOVERY"
+	SubtypeBillingRetry      = "BILLING_RETRY"
+	SubtypeDowngrade         = "DOWNGRADE"
+	SubtypeFailure           = "FAILURE"
+	SubtypeGracePeriod       = "GRACE_PERIOD"
+	SubtypeInitialBuy        = "INITIAL_BUY"
+	SubtypePending           = "PENDING"
+	SubtypePriceIncrease     = "PRICE_INCREASE"
+	SubtypeProductNotForSale = "PRODUCT_NOT_FOR_SALE"
+	SubtypeResubscribe       = "RESUBSCRIBE"
+	SubtypeSummary           = "SUMMARY"
+	SubtypeUpgrade           = "UPGRADE"
+	SubtypeVoluntary         = "VOLUNTARY"
+)
+
 type AppStoreServerNotification struct {
 	appleRootCert string
 	Payload       *NotificationPayload `json:"payload"`
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/fatflowers/cashier/internal/platform/db/migrations"
+)
+
+// migrationLockKey is the pg_advisory_lock key serializing migration runs across replicas.
+const migrationLockKey int64 = 0x6361736869657201
+
+const schemaMigrationsDDL = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+    "version"    bigint PRIMARY KEY,
+    "name"       varchar(255) NOT NULL,
+    "applied_at" timestamptz NOT NULL DEFAULT now()
+)`
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var ErrNoMigrationToRollback = errors.New("no migration to roll back")
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration is a row of the schema_migrations table.
+type SchemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey"`
+	Name      string    `gorm:"column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Missing marks a version recorded in schema_migrations that has no file in this build.
+	Missing bool
+}
+
+// LoadMigrations reads <version>_<name>.up.sql / .down.sql pairs from fsys, sorted by version.
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	res := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", mig.Version, mig.Name)
+		}
+		res = append(res, mig)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations.
+type Migrator struct {
+	db         *gorm.DB
+	log        *zap.SugaredLogger
+	migrations []*Migration
+}
+
+func NewMigrator(db *gorm.DB, log *zap.SugaredLogger) (*Migrator, error) {
+	ms, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, log: log, migrations: ms}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var applied []*Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range pendingMigrations(m.migrations, done) {
+			m.log.Infow("applying migration", "version", mig.Version, "name", mig.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations and returns the ones rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive")
+	}
+	var reverted []*Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var rows []*SchemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		if len(rows) == 0 {
+			return ErrNoMigrationToRollback
+		}
+		for _, row := range rows {
+			mig := m.find(row.Version)
+			if mig == nil {
+				return fmt.Errorf("migration %d_%s is applied but missing from this build", row.Version, row.Name)
+			}
+			m.log.Infow("rolling back migration", "version", mig.Version, "name", mig.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(mig.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", mig.Version).Error
+			}); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration, plus applied versions missing from this build.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	db := m.db.WithContext(ctx)
+	var rows []*SchemaMigration
+	// Status is read-only: a database that was never migrated simply has nothing applied.
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Order("version").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+	return buildMigrationStatus(m.migrations, rows), nil
+}
+
+// Pending returns the migrations not yet applied.
+func (m *Migrator) Pending(ctx context.Context) ([]*MigrationStatus, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []*MigrationStatus
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+// withLock runs fn on a single pooled connection holding the migration advisory lock.
+// Advisory locks belong to the session, so lock, migrations and unlock must share that connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// The context may be canceled by now; the unlock must still reach the session.
+			if err := conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).Error; err != nil {
+				m.log.Warnw("failed to release migration lock", "err", err)
+			}
+		}()
+		if err := conn.Exec(schemaMigrationsDDL).Error; err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) appliedVersions(conn *gorm.DB) (map[int64]bool, error) {
+	var versions []int64
+	if err := conn.Model(&SchemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	res := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		res[v] = true
+	}
+	return res, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+func pendingMigrations(all []*Migration, applied map[int64]bool) []*Migration {
+	var res []*Migration
+	for _, mig := range all {
+		if !applied[mig.Version] {
+			res = append(res, mig)
+		}
+	}
+	return res
+}
+
+func buildMigrationStatus(all []*Migration, rows []*SchemaMigration) []*MigrationStatus {
+	byVersion := make(map[int64]*SchemaMigration, len(rows))
+	for _, row := range rows {
+		byVersion[row.Version] = row
+	}
+
+	res := make([]*MigrationStatus, 0, len(all))
+	for _, mig := range all {
+		s := &MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if row, ok := byVersion[mig.Version]; ok {
+			s.AppliedAt = &row.AppliedAt
+			delete(byVersion, mig.Version)
+		}
+		res = append(res, s)
+	}
+	for _, row := range rows {
+		if _, ok := byVersion[row.Version]; ok {
+			res = append(res, &MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &row.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/fatflowers/cashier/internal/platform/db/migrations"
+)
+
+func TestLoadMigrations_SortsPairsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX b;")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX b;")},
+		"0001_init.up.sql":        {Data: []byte("CREATE TABLE a;")},
+		"0001_init.down.sql":      {Data: []byte("DROP TABLE a;")},
+		"README.md":               {Data: []byte("ignored")},
+	}
+
+	ms, err := LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	require.Equal(t, int64(1), ms[0].Version)
+	require.Equal(t, "init", ms[0].Name)
+	require.Equal(t, "CREATE TABLE a;", ms[0].Up)
+	require.Equal(t, "DROP TABLE a;", ms[0].Down)
+	require.Equal(t, int64(2), ms[1].Version)
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	_, err := LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql": {Data: []byte("CREATE TABLE a;")},
+	})
+	require.ErrorContains(t, err, "both up and down")
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql":    {Data: []byte("CREATE TABLE a;")},
+		"0001_init.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"0001_other.up.sql":   {Data: []byte("CREATE TABLE b;")},
+		"0001_other.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	require.ErrorContains(t, err, "duplicate migration version 1")
+}
+
+func TestEmbeddedMigrations_CoverEveryModel(t *testing.T) {
+	ms, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+	for i, m := range ms {
+		require.Equal(t, int64(i+1), m.Version, "migration versions must be contiguous")
+	}
+
+	var all strings.Builder
+	for _, m := range ms {
+		all.WriteString(m.Up)
+	}
+	cache := &sync.Map{}
+	for _, model := range autoMigrateModels {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		require.NoError(t, err)
+		require.Contains(t, all.String(), fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s"`, s.Table))
+		// AutoMigrate creates indexes the migrations name differently a second time.
+		for _, idx := range s.ParseIndexes() {
+			require.True(t, strings.Contains(all.String(), fmt.Sprintf(`INDEX IF NOT EXISTS "%s" ON "%s"`, idx.Name, s.Table)),
+				"index %s of %s is not created by a migration", idx.Name, s.Table)
+		}
+	}
+}
+
+func TestBuildMigrationStatus(t *testing.T) {
+	all := []*Migration{{Version: 1, Name: "init"}, {Version: 2, Name: "next"}}
+	appliedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []*SchemaMigration{
+		{Version: 1, Name: "init", AppliedAt: appliedAt},
+		{Version: 3, Name: "from_newer_build", AppliedAt: appliedAt},
+	}
+
+	status := buildMigrationStatus(all, rows)
+	require.Len(t, status, 3)
+	require.Equal(t, &appliedAt, status[0].AppliedAt)
+	require.Nil(t, status[1].AppliedAt)
+	require.Equal(t, int64(3), status[2].Version)
+	require.True(t, status[2].Missing)
+
+	pending := pendingMigrations(all, map[int64]bool{1: true})
+	require.Len(t, pending, 1)
+	require.Equal(t, "next", pending[0].Name)
+}
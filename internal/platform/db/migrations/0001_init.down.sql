@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS "webhook_dead_letter";
+DROP TABLE IF EXISTS "webhook_delivery";
+DROP TABLE IF EXISTS "outbox_event";
+DROP TABLE IF EXISTS "payment_notification_dedup";
+DROP TABLE IF EXISTS "payment_notification_log";
+DROP TABLE IF EXISTS "user_membership_active_item";
+DROP TABLE IF EXISTS "transaction_log";
+DROP TABLE IF EXISTS "transaction";
+DROP TABLE IF EXISTS "subscription_daily_snapshot";
+DROP TABLE IF EXISTS "subscription_log";
+DROP TABLE IF EXISTS "subscription";
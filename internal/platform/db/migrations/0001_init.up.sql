@@ -0,0 +1,163 @@
+-- Baseline schema. Every statement is idempotent so databases created by the
+-- former startup AutoMigrate can adopt versioned migrations with `migrate up`.
+
+CREATE TABLE IF NOT EXISTS "subscription" (
+    "id"                 uuid PRIMARY KEY,
+    "user_id"            varchar(64) NOT NULL,
+    "status"             varchar(64) NOT NULL,
+    "next_auto_renew_at" timestamptz,
+    "expire_at"          timestamptz,
+    "extra"              jsonb DEFAULT '{}',
+    "created_at"         timestamptz,
+    "updated_at"         timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_subscription_user_id" ON "subscription" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "subscription_log" (
+    "id"         uuid PRIMARY KEY,
+    "user_id"    varchar(64) NOT NULL,
+    "reason"     varchar(64) NOT NULL,
+    "before"     jsonb DEFAULT 'null',
+    "after"      jsonb DEFAULT 'null',
+    "extra"      jsonb DEFAULT '{}',
+    "created_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_subscription_log_user_id" ON "subscription_log" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "subscription_daily_snapshot" (
+    "id"                  uuid PRIMARY KEY,
+    "status"              varchar(64) NOT NULL,
+    "next_auto_renew_at"  timestamptz,
+    "expire_at"           timestamptz,
+    "extra"               jsonb DEFAULT '{}',
+    "created_at"          timestamptz,
+    "updated_at"          timestamptz,
+    "user_id"             varchar(64) NOT NULL,
+    "snapshot_date"       text,
+    "snapshot_created_at" timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_id_snapshot_date" ON "subscription_daily_snapshot" ("user_id", "snapshot_date");
+
+CREATE TABLE IF NOT EXISTS "transaction" (
+    "id"                             uuid PRIMARY KEY,
+    "user_id"                        varchar(64) NOT NULL,
+    "provider_id"                    varchar(64) NOT NULL,
+    "payment_item_id"                varchar(64) NOT NULL,
+    "transaction_id"                 varchar(64) NOT NULL,
+    "currency"                       varchar(64) NOT NULL,
+    "price"                          bigint NOT NULL,
+    "parent_transaction_id"          varchar(64),
+    "purchase_at"                    timestamptz,
+    "refund_at"                      timestamptz,
+    "expire_at"                      timestamptz,
+    "next_auto_renew_at"             timestamptz,
+    "revocation_date"                timestamptz,
+    "revocation_reason"              varchar(64),
+    "before_upgraded_transaction_id" varchar(64),
+    "extra"                          jsonb DEFAULT '{}',
+    "created_at"                     timestamptz,
+    "updated_at"                     timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_transaction_user_id_id" ON "transaction" ("user_id", "id" DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS "unique_provider_id_transaction_id" ON "transaction" ("provider_id", "transaction_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "unique_provider_id_before_upgraded_transaction_id" ON "transaction" ("provider_id", "before_upgraded_transaction_id");
+
+CREATE TABLE IF NOT EXISTS "transaction_log" (
+    "id"              uuid PRIMARY KEY,
+    "user_id"         varchar(64) NOT NULL,
+    "payment_item_id" varchar(64) NOT NULL,
+    "provider_id"     varchar(64) NOT NULL,
+    "transaction_id"  varchar(64) NOT NULL,
+    "reason"          varchar(64) NOT NULL,
+    "before"          jsonb DEFAULT 'null',
+    "after"           jsonb DEFAULT 'null',
+    "extra"           jsonb DEFAULT '{}',
+    "created_at"      timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_transaction_log_user_id_id" ON "transaction_log" ("user_id", "id" DESC);
+
+CREATE TABLE IF NOT EXISTS "user_membership_active_item" (
+    "id"                         uuid PRIMARY KEY,
+    "user_transaction_id"        uuid NOT NULL,
+    "payment_item_id"            varchar(64) NOT NULL,
+    "user_id"                    varchar(64) NOT NULL,
+    "remaining_duration_seconds" bigint NOT NULL,
+    "activated_at"               timestamptz NOT NULL,
+    "expire_at"                  timestamptz NOT NULL,
+    "next_auto_renew_at"         timestamptz,
+    "created_at"                 timestamptz,
+    "updated_at"                 timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_user_membership_active_item_user_transaction_id" ON "user_membership_active_item" ("user_transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_user_active_time" ON "user_membership_active_item" ("user_id", "activated_at", "expire_at");
+
+CREATE TABLE IF NOT EXISTS "payment_notification_log" (
+    "id"                uuid PRIMARY KEY,
+    "provider_id"       varchar(64) NOT NULL,
+    "user_id"           varchar(64),
+    "trace_id"          varchar(128),
+    "transaction_id"    varchar(128),
+    "notification_time" timestamptz,
+    "data"              jsonb,
+    "result"            jsonb,
+    "status"            varchar(64) NOT NULL,
+    "created_at"        timestamptz,
+    "updated_at"        timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS "payment_notification_dedup" (
+    "provider_id"     varchar(64),
+    "notification_id" varchar(128),
+    "transaction_id"  varchar(128),
+    "status"          varchar(64) NOT NULL,
+    "attempts"        bigint NOT NULL DEFAULT 0,
+    "created_at"      timestamptz,
+    "updated_at"      timestamptz,
+    PRIMARY KEY ("provider_id", "notification_id")
+);
+
+CREATE TABLE IF NOT EXISTS "outbox_event" (
+    "id"            uuid PRIMARY KEY,
+    "topic"         varchar(64) NOT NULL,
+    "payload"       jsonb NOT NULL,
+    "status"        varchar(32) NOT NULL,
+    "attempts"      bigint NOT NULL DEFAULT 0,
+    "last_error"    text,
+    "available_at"  timestamptz NOT NULL,
+    "dispatched_at" timestamptz,
+    "created_at"    timestamptz,
+    "updated_at"    timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_status_available" ON "outbox_event" ("status", "available_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_delivery" (
+    "id"               uuid PRIMARY KEY,
+    "endpoint_id"      varchar(64) NOT NULL,
+    "event_id"         varchar(64) NOT NULL,
+    "event_type"       varchar(64) NOT NULL,
+    "payload"          jsonb NOT NULL,
+    "status"           varchar(32) NOT NULL,
+    "attempts"         bigint NOT NULL DEFAULT 0,
+    "next_attempt_at"  timestamptz NOT NULL,
+    "last_status_code" bigint,
+    "last_error"       text,
+    "delivered_at"     timestamptz,
+    "created_at"       timestamptz,
+    "updated_at"       timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_delivery_event_id" ON "webhook_delivery" ("event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_delivery_status_next" ON "webhook_delivery" ("status", "next_attempt_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_dead_letter" (
+    "id"               uuid PRIMARY KEY,
+    "delivery_id"      uuid NOT NULL,
+    "endpoint_id"      varchar(64) NOT NULL,
+    "event_id"         varchar(64) NOT NULL,
+    "event_type"       varchar(64) NOT NULL,
+    "payload"          jsonb NOT NULL,
+    "attempts"         bigint NOT NULL,
+    "last_status_code" bigint,
+    "last_error"       text,
+    "created_at"       timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_dead_letter_delivery_id" ON "webhook_dead_letter" ("delivery_id");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "transaction_refund" (
+    "id"              uuid PRIMARY KEY,
+    "user_id"         varchar(64) NOT NULL,
+    "provider_id"     varchar(64) NOT NULL,
+    "transaction_id"  varchar(64) NOT NULL,
+    "payment_item_id" varchar(64) NOT NULL,
+    "event"           varchar(32) NOT NULL,
+    "refund_at"       timestamptz NOT NULL,
+    "reason"          varchar(64),
+    "created_at"      timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_transaction_refund_provider_transaction" ON "transaction_refund" ("provider_id", "transaction_id");
+
+-- Transactions refunded before the history existed start with their current refund.
+INSERT INTO "transaction_refund" ("id", "user_id", "provider_id", "transaction_id", "payment_item_id", "event", "refund_at", "reason", "created_at")
+SELECT gen_random_uuid(), "user_id", "provider_id", "transaction_id", "payment_item_id", 'refunded', "refund_at", "revocation_reason", now()
+FROM "transaction"
+WHERE "refund_at" IS NOT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "reconcile_drift" (
+    "id"                      uuid PRIMARY KEY,
+    "run_id"                  uuid NOT NULL,
+    "user_id"                 varchar(64) NOT NULL,
+    "provider_id"             varchar(64) NOT NULL,
+    "original_transaction_id" varchar(64) NOT NULL,
+    "transaction_id"          varchar(64) NOT NULL,
+    "kinds"                   jsonb NOT NULL,
+    "before"                  jsonb,
+    "after"                   jsonb,
+    "applied"                 boolean NOT NULL,
+    "error"                   text,
+    "created_at"              timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_reconcile_drift_run_id" ON "reconcile_drift" ("run_id");
+CREATE INDEX IF NOT EXISTS "idx_reconcile_drift_user_id" ON "reconcile_drift" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_reconcile_drift_created_at" ON "reconcile_drift" ("created_at");
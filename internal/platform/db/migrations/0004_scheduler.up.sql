@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "scheduler_lease" (
+    "name"       varchar(64) PRIMARY KEY,
+    "holder"     varchar(128) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "updated_at" timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS "job_run" (
+    "id"           uuid PRIMARY KEY,
+    "job"          varchar(64) NOT NULL,
+    "scheduled_at" timestamptz NOT NULL,
+    "holder"       varchar(128) NOT NULL,
+    "status"       varchar(32) NOT NULL,
+    "error"        text,
+    "started_at"   timestamptz NOT NULL,
+    "finished_at"  timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_job_run_job_scheduled_at" ON "job_run" ("job", "scheduled_at");
+CREATE INDEX IF NOT EXISTS "idx_job_run_started_at" ON "job_run" ("started_at");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "payment_item_version";
+DROP TABLE IF EXISTS "payment_item";
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS "payment_item" (
+    "id"               varchar(64) PRIMARY KEY,
+    "provider_id"      varchar(64) NOT NULL,
+    "provider_item_id" varchar(255) NOT NULL,
+    "type"             varchar(64) NOT NULL,
+    "duration_hour"    bigint,
+    "status"           varchar(32) NOT NULL,
+    "version"          bigint NOT NULL,
+    "created_at"       timestamptz,
+    "updated_at"       timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_item_provider_item" ON "payment_item" ("provider_id", "provider_item_id");
+
+CREATE TABLE IF NOT EXISTS "payment_item_version" (
+    "id"              uuid PRIMARY KEY,
+    "payment_item_id" varchar(64) NOT NULL,
+    "version"         bigint NOT NULL,
+    "item"            jsonb NOT NULL,
+    "operator"        varchar(64) NOT NULL,
+    "created_at"      timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_item_version" ON "payment_item_version" ("payment_item_id", "version");
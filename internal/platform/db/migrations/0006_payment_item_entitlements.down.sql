@@ -0,0 +1 @@
+ALTER TABLE "payment_item" DROP COLUMN IF EXISTS "entitlements";
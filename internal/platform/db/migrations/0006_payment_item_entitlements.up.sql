@@ -0,0 +1 @@
+ALTER TABLE "payment_item" ADD COLUMN IF NOT EXISTS "entitlements" jsonb NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+ALTER TABLE "payment_item" DROP COLUMN IF EXISTS "level";
+ALTER TABLE "payment_item" DROP COLUMN IF EXISTS "subscription_group";
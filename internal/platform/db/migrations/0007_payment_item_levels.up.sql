@@ -0,0 +1,2 @@
+ALTER TABLE "payment_item" ADD COLUMN IF NOT EXISTS "subscription_group" varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE "payment_item" ADD COLUMN IF NOT EXISTS "level" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "credit_balance";
+DROP TABLE IF EXISTS "credit_ledger";
+ALTER TABLE "payment_item" DROP COLUMN IF EXISTS "credits";
@@ -0,0 +1,23 @@
+ALTER TABLE "payment_item" ADD COLUMN IF NOT EXISTS "credits" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "credit_ledger" (
+    "id"              uuid PRIMARY KEY,
+    "user_id"         varchar(64) NOT NULL,
+    "kind"            varchar(32) NOT NULL,
+    "amount"          bigint NOT NULL,
+    "balance_after"   bigint NOT NULL,
+    "provider_id"     varchar(64) NOT NULL DEFAULT '',
+    "transaction_id"  varchar(64) NOT NULL DEFAULT '',
+    "idempotency_key" varchar(128),
+    "reason"          varchar(255) NOT NULL DEFAULT '',
+    "created_at"      timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_credit_ledger_user_created" ON "credit_ledger" ("user_id", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_credit_ledger_transaction" ON "credit_ledger" ("provider_id", "transaction_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_credit_ledger_idempotency_key" ON "credit_ledger" ("idempotency_key");
+
+CREATE TABLE IF NOT EXISTS "credit_balance" (
+    "user_id"    varchar(64) PRIMARY KEY,
+    "balance"    bigint NOT NULL,
+    "updated_at" timestamptz
+);
@@ -0,0 +1,16 @@
+-- Restores the previous representation: Apple milliunits times 100, Google hundredths, Stripe smallest units.
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, 5 - "price_exponent"))
+WHERE "provider_id" = 'apple';
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, 2 - "price_exponent"))
+WHERE "provider_id" = 'google' AND "price_exponent" <> 2;
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, CASE
+        WHEN "price_currency" IN ('MGA') THEN 0
+        ELSE 2
+    END - "price_exponent"))
+WHERE "provider_id" = 'stripe' AND "price_currency" IN ('ISK', 'IQD', 'LYD', 'MGA');
+
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS "price_exponent";
+ALTER TABLE "transaction" ALTER COLUMN "price_currency" DROP DEFAULT;
+ALTER TABLE "transaction" ALTER COLUMN "price_amount" DROP DEFAULT;
+ALTER TABLE "transaction" RENAME COLUMN "price_currency" TO "currency";
+ALTER TABLE "transaction" RENAME COLUMN "price_amount" TO "price";
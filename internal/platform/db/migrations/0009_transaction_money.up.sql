@@ -0,0 +1,30 @@
+ALTER TABLE "transaction" RENAME COLUMN "price" TO "price_amount";
+ALTER TABLE "transaction" RENAME COLUMN "currency" TO "price_currency";
+ALTER TABLE "transaction" ALTER COLUMN "price_amount" SET DEFAULT 0;
+ALTER TABLE "transaction" ALTER COLUMN "price_currency" SET DEFAULT '';
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "price_exponent" smallint NOT NULL DEFAULT 2;
+
+-- ISO 4217 minor units, see types.CurrencyExponent.
+UPDATE "transaction" SET
+    "price_currency" = UPPER("price_currency"),
+    "price_exponent" = CASE
+        WHEN UPPER("price_currency") IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN UPPER("price_currency") IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN UPPER("price_currency") IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END;
+
+-- Apple prices were stored as milliunits times 100.
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, "price_exponent" - 5))
+WHERE "provider_id" = 'apple';
+
+-- Google prices were stored in hundredths.
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, "price_exponent" - 2))
+WHERE "provider_id" = 'google' AND "price_exponent" <> 2;
+
+-- Stripe amounts were stored in Stripe's smallest unit, which differs from ISO 4217 for these currencies.
+UPDATE "transaction" SET "price_amount" = ROUND("price_amount" * POWER(10::numeric, "price_exponent" - CASE
+        WHEN "price_currency" IN ('MGA') THEN 0
+        ELSE 2
+    END))
+WHERE "provider_id" = 'stripe' AND "price_currency" IN ('ISK', 'IQD', 'LYD', 'MGA');
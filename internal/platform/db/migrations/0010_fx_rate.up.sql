@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "fx_rate" (
+    "rate_date"      varchar(10) NOT NULL,
+    "base_currency"  varchar(3) NOT NULL,
+    "quote_currency" varchar(3) NOT NULL,
+    "rate"           double precision NOT NULL,
+    "source"         varchar(64) NOT NULL DEFAULT '',
+    "updated_at"     timestamptz,
+    PRIMARY KEY ("rate_date", "base_currency", "quote_currency")
+);
@@ -0,0 +1 @@
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS "storefront" varchar(8) NOT NULL DEFAULT '';
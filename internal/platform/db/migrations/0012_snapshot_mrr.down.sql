@@ -0,0 +1,3 @@
+ALTER TABLE "subscription_daily_snapshot" DROP COLUMN IF EXISTS "mrr_exponent";
+ALTER TABLE "subscription_daily_snapshot" DROP COLUMN IF EXISTS "mrr_currency";
+ALTER TABLE "subscription_daily_snapshot" DROP COLUMN IF EXISTS "mrr_amount";
@@ -0,0 +1,3 @@
+ALTER TABLE "subscription_daily_snapshot" ADD COLUMN IF NOT EXISTS "mrr_amount" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "subscription_daily_snapshot" ADD COLUMN IF NOT EXISTS "mrr_currency" varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE "subscription_daily_snapshot" ADD COLUMN IF NOT EXISTS "mrr_exponent" smallint NOT NULL DEFAULT 2;
@@ -0,0 +1,2 @@
+-- Upgrades are not turned back into refunds.
+SELECT 1;
@@ -0,0 +1,20 @@
+-- Apple upgrades used to be stored as refunds, and 0002 copied them into transaction_refund. A transaction
+-- another one upgrades from was replaced, not refunded: keep its revocation and drop the refund.
+WITH "upgraded" AS (
+    UPDATE "transaction" t
+    SET "revocation_date"   = COALESCE(t."revocation_date", t."refund_at"),
+        "revocation_reason" = 'upgraded',
+        "refund_at"         = NULL
+    WHERE t."provider_id" = 'apple'
+      AND t."refund_at" IS NOT NULL
+      AND (t."revocation_reason" = 'upgraded' OR EXISTS (
+          SELECT 1 FROM "transaction" u
+          WHERE u."provider_id" = t."provider_id" AND u."before_upgraded_transaction_id" = t."transaction_id"
+      ))
+    RETURNING t."provider_id", t."transaction_id"
+)
+DELETE FROM "transaction_refund" r
+USING "upgraded"
+WHERE r."provider_id" = "upgraded"."provider_id"
+  AND r."transaction_id" = "upgraded"."transaction_id"
+  AND r."event" = 'refunded';
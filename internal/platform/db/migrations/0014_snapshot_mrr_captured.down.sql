@@ -0,0 +1 @@
+ALTER TABLE "subscription_daily_snapshot" DROP COLUMN IF EXISTS "mrr_captured";
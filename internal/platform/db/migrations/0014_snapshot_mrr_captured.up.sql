@@ -0,0 +1,2 @@
+-- Snapshots taken before 0012 have no MRR; only those saved from now on record it.
+ALTER TABLE "subscription_daily_snapshot" ADD COLUMN IF NOT EXISTS "mrr_captured" boolean NOT NULL DEFAULT false;
@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL schema migrations.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql and applied in version order.
+// Applied migrations must never be edited; add a new version instead.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
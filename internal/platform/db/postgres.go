@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 
 	"go.uber.org/fx"
 	"go.uber.org/zap"
@@ -28,22 +29,59 @@ func NewDB(l *zap.SugaredLogger, cfg *cfgpkg.Config) (*gorm.DB, error) {
 }
 
 var Module = fx.Options(
-	fx.Provide(NewDB),
-	fx.Invoke(AutoMigrate),
+	fx.Provide(NewDB, NewMigrator),
+	fx.Invoke(checkSchema),
 	fx.Invoke(registerDBClose),
 )
 
-// AutoMigrate runs GORM migrations on startup
+// checkSchema refuses to start against a database with pending migrations. Schema changes are
+// applied by `migrate up`; database.auto_migrate switches to GORM AutoMigrate for local development.
+func checkSchema(l *zap.SugaredLogger, cfg *cfgpkg.Config, db *gorm.DB, m *Migrator) error {
+	if cfg.Database.AutoMigrate {
+		if cfg.Env == cfgpkg.EnvProd {
+			return fmt.Errorf("database.auto_migrate is not allowed when env is %s", cfg.Env)
+		}
+		return AutoMigrate(l, db)
+	}
+
+	pending, err := m.Pending(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema has %d pending migrations (next: %d_%s), run `migrate up` first",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// autoMigrateModels lists every persisted model. Each table must also be created by a versioned migration.
+var autoMigrateModels = []any{
+	&models.Subscription{},
+	&models.SubscriptionLog{},
+	&models.SubscriptionDailySnapshot{},
+	&models.Transaction{},
+	&models.TransactionLog{},
+	&models.TransactionRefund{},
+	&models.ReconcileDrift{},
+	&models.SchedulerLease{},
+	&models.JobRun{},
+	&models.PaymentNotificationLog{},
+	&models.PaymentNotificationDedup{},
+	&models.OutboxEvent{},
+	&models.WebhookDelivery{},
+	&models.WebhookDeadLetter{},
+	&models.UserMembershipActiveItem{},
+	&models.PaymentItem{},
+	&models.PaymentItemVersion{},
+	&models.CreditLedgerEntry{},
+	&models.CreditBalance{},
+	&models.FxRate{},
+}
+
+// AutoMigrate runs GORM migrations for local development. It never drops or renames columns.
 func AutoMigrate(l *zap.SugaredLogger, db *gorm.DB) error {
-	if err := db.AutoMigrate(
-		&models.Subscription{},
-		&models.SubscriptionLog{},
-		&models.SubscriptionDailySnapshot{},
-		&models.Transaction{},
-		&models.TransactionLog{},
-		&models.PaymentNotificationLog{},
-		&models.UserMembershipActiveItem{},
-	); err != nil {
+	if err := db.AutoMigrate(autoMigrateModels...); err != nil {
 		l.Errorf("automigrate failed: %v", err)
 		return err
 	}
@@ -0,0 +1,290 @@
+package google_play
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	DefaultBaseURL  = "https://androidpublisher.googleapis.com"
+	DefaultTokenURL = "https://oauth2.googleapis.com/token"
+
+	androidPublisherScope = "https://www.googleapis.com/auth/androidpublisher"
+	jwtBearerGrantType    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+type ClientOptions struct {
+	PackageName string
+	// ServiceAccountKey is the JSON key file content of a service account
+	// that has been granted access in the Play Console.
+	ServiceAccountKey string
+	// BaseURL and TokenURL override Google endpoints, mainly for tests.
+	BaseURL    string
+	TokenURL   string
+	HTTPClient *http.Client
+}
+
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// Client is a minimal Google Play Developer API client covering purchase lookup and acknowledgement.
+type Client struct {
+	packageName string
+	baseURL     string
+	tokenURL    string
+	clientEmail string
+	keyID       string
+	signKey     any
+	httpClient  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expireAt    time.Time
+}
+
+// APIError is returned when the Play Developer API responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("google play api error: status=%d body=%s", e.StatusCode, e.Body)
+}
+
+func NewClient(opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		return nil, errors.New("opts is nil")
+	}
+	if opts.PackageName == "" {
+		return nil, errors.New("package name is empty")
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal([]byte(opts.ServiceAccountKey), &key); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("service account key is missing client_email or private_key")
+	}
+	signKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+
+	c := &Client{
+		packageName: opts.PackageName,
+		baseURL:     strings.TrimRight(opts.BaseURL, "/"),
+		tokenURL:    opts.TokenURL,
+		clientEmail: key.ClientEmail,
+		keyID:       key.PrivateKeyID,
+		signKey:     signKey,
+		httpClient:  opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultBaseURL
+	}
+	if c.tokenURL == "" {
+		c.tokenURL = key.TokenURI
+	}
+	if c.tokenURL == "" {
+		c.tokenURL = DefaultTokenURL
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return c, nil
+}
+
+func (c *Client) PackageName() string { return c.packageName }
+
+// GetSubscriptionV2 fetches purchases.subscriptionsv2.get for a purchase token.
+func (c *Client) GetSubscriptionV2(ctx context.Context, purchaseToken string) (*SubscriptionPurchaseV2, error) {
+	var res SubscriptionPurchaseV2
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/purchases/subscriptionsv2/tokens/%s",
+		url.PathEscape(c.packageName), url.PathEscape(purchaseToken))
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetProduct fetches purchases.products.get for a one-time product purchase token.
+func (c *Client) GetProduct(ctx context.Context, productID, purchaseToken string) (*ProductPurchase, error) {
+	var res ProductPurchase
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/purchases/products/%s/tokens/%s",
+		url.PathEscape(c.packageName), url.PathEscape(productID), url.PathEscape(purchaseToken))
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListVoidedPurchases fetches one page of purchases.voidedpurchases.list, subscriptions included, for purchases
+// voided between startTime and endTime. pageToken is empty for the first page.
+func (c *Client) ListVoidedPurchases(ctx context.Context, startTime, endTime time.Time, pageToken string) (*VoidedPurchasesListResponse, error) {
+	q := url.Values{}
+	q.Set("startTime", strconv.FormatInt(startTime.UnixMilli(), 10))
+	q.Set("endTime", strconv.FormatInt(endTime.UnixMilli(), 10))
+	q.Set("type", "1")
+	if pageToken != "" {
+		q.Set("token", pageToken)
+	}
+	var res VoidedPurchasesListResponse
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/purchases/voidedpurchases?%s", url.PathEscape(c.packageName), q.Encode())
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetOrder fetches orders.get, the amounts an order was charged. It needs the "View financial data" permission.
+func (c *Client) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	var res Order
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/orders/%s", url.PathEscape(c.packageName), url.PathEscape(orderID))
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AcknowledgeSubscription acknowledges a subscription purchase. Google refunds unacknowledged purchases after three days.
+func (c *Client) AcknowledgeSubscription(ctx context.Context, subscriptionID, purchaseToken string) error {
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/purchases/subscriptions/%s/tokens/%s:acknowledge",
+		url.PathEscape(c.packageName), url.PathEscape(subscriptionID), url.PathEscape(purchaseToken))
+	return c.do(ctx, http.MethodPost, path, struct{}{}, nil)
+}
+
+// AcknowledgeProduct acknowledges a one-time product purchase.
+func (c *Client) AcknowledgeProduct(ctx context.Context, productID, purchaseToken string) error {
+	path := fmt.Sprintf("/androidpublisher/v3/applications/%s/purchases/products/%s/tokens/%s:acknowledge",
+		url.PathEscape(c.packageName), url.PathEscape(productID), url.PathEscape(purchaseToken))
+	return c.do(ctx, http.MethodPost, path, struct{}{}, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	token, err := c.getAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("google play request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// getAccessToken exchanges a signed service account assertion for an OAuth access token and caches it until shortly before expiry.
+func (c *Client) getAccessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.expireAt) {
+		return c.accessToken, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   c.clientEmail,
+		"scope": androidPublisherScope,
+		"aud":   c.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if c.keyID != "" {
+		token.Header["kid"] = c.keyID
+	}
+	assertion, err := token.SignedString(c.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token assertion: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", jwtBearerGrantType)
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", errors.New("token response has empty access_token")
+	}
+
+	c.accessToken = tokenResp.AccessToken
+	// Refresh one minute early to avoid using a token that expires in flight.
+	c.expireAt = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+	return c.accessToken, nil
+}
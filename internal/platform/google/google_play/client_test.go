@@ -0,0 +1,151 @@
+package google_play
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServiceAccountKey(t *testing.T, tokenURL string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	b, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "cashier@example.iam.gserviceaccount.com",
+		"private_key":    string(pemKey),
+		"private_key_id": "key-1",
+		"token_uri":      tokenURL,
+	})
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestClient_GetSubscriptionV2AndAcknowledge(t *testing.T) {
+	tokenRequests := 0
+	acknowledged := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, jwtBearerGrantType, r.PostForm.Get("grant_type"))
+		require.NotEmpty(t, r.PostForm.Get("assertion"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-1", "expires_in": 3600})
+	})
+	mux.HandleFunc("GET /androidpublisher/v3/applications/com.example.app/purchases/subscriptionsv2/tokens/tok-1", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer at-1", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{
+			"latestOrderId": "GPA.1111-2222..1",
+			"subscriptionState": "SUBSCRIPTION_STATE_ACTIVE",
+			"acknowledgementState": "ACKNOWLEDGEMENT_STATE_PENDING",
+			"startTime": "2026-01-01T00:00:00Z",
+			"externalAccountIdentifiers": {"obfuscatedExternalAccountId": "u1"},
+			"lineItems": [{"productId": "vip", "expiryTime": "2026-03-01T00:00:00.123Z",
+				"autoRenewingPlan": {"autoRenewEnabled": true, "recurringPrice": {"currencyCode": "USD", "units": "9", "nanos": 990000000}}}]
+		}`))
+	})
+	mux.HandleFunc("POST /androidpublisher/v3/applications/com.example.app/purchases/subscriptions/vip/tokens/tok-1:acknowledge", func(w http.ResponseWriter, r *http.Request) {
+		acknowledged = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cli, err := NewClient(&ClientOptions{
+		PackageName:       "com.example.app",
+		ServiceAccountKey: newTestServiceAccountKey(t, srv.URL+"/token"),
+		BaseURL:           srv.URL,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	sub, err := cli.GetSubscriptionV2(ctx, "tok-1")
+	require.NoError(t, err)
+	require.Equal(t, "GPA.1111-2222..1", sub.LatestOrderID)
+	require.Equal(t, "u1", sub.ExternalAccountIdentifiers.ObfuscatedExternalAccountID)
+	require.Len(t, sub.LineItems, 1)
+	require.Equal(t, int64(9_990_000_000), sub.LineItems[0].AutoRenewingPlan.RecurringPrice.Billionths())
+	require.Equal(t, 2026, ParseTime(sub.LineItems[0].ExpiryTime).Year())
+
+	require.NoError(t, cli.AcknowledgeSubscription(ctx, "vip", "tok-1"))
+	require.True(t, acknowledged)
+	// The access token is cached across calls.
+	require.Equal(t, 1, tokenRequests)
+}
+
+func TestClient_APIErrorStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-1", "expires_in": 3600})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":410}}`, http.StatusGone)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cli, err := NewClient(&ClientOptions{
+		PackageName:       "com.example.app",
+		ServiceAccountKey: newTestServiceAccountKey(t, srv.URL+"/token"),
+		BaseURL:           srv.URL,
+	})
+	require.NoError(t, err)
+
+	_, err = cli.GetProduct(context.Background(), "coins", "tok-1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusGone, apiErr.StatusCode)
+}
+
+func TestClient_ListVoidedPurchases(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-1", "expires_in": 3600})
+	})
+	mux.HandleFunc("GET /androidpublisher/v3/applications/com.example.app/purchases/voidedpurchases", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		require.Equal(t, "1767225600000", q.Get("startTime"))
+		require.Equal(t, "1767312000000", q.Get("endTime"))
+		require.Equal(t, "1", q.Get("type"))
+		if q.Get("token") == "" {
+			_, _ = w.Write([]byte(`{"tokenPagination": {"nextPageToken": "p2"},
+				"voidedPurchases": [{"purchaseToken": "tok-1", "orderId": "GPA.1", "voidedTimeMillis": "1767230000000"}]}`))
+			return
+		}
+		require.Equal(t, "p2", q.Get("token"))
+		_, _ = w.Write([]byte(`{"voidedPurchases": [{"purchaseToken": "tok-2", "orderId": "GPA.2"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cli, err := NewClient(&ClientOptions{
+		PackageName:       "com.example.app",
+		ServiceAccountKey: newTestServiceAccountKey(t, srv.URL+"/token"),
+		BaseURL:           srv.URL,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start, end := time.UnixMilli(1767225600000), time.UnixMilli(1767312000000)
+	page, err := cli.ListVoidedPurchases(ctx, start, end, "")
+	require.NoError(t, err)
+	require.Len(t, page.VoidedPurchases, 1)
+	require.Equal(t, "GPA.1", page.VoidedPurchases[0].OrderID)
+	require.Equal(t, time.UnixMilli(1767230000000), page.VoidedPurchases[0].VoidedTime())
+	require.Equal(t, "p2", page.NextPageToken())
+
+	page, err = cli.ListVoidedPurchases(ctx, start, end, page.NextPageToken())
+	require.NoError(t, err)
+	require.Equal(t, "tok-2", page.VoidedPurchases[0].PurchaseToken)
+	require.Empty(t, page.NextPageToken())
+}
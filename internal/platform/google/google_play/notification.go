@@ -0,0 +1,117 @@
+package google_play
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// https://developer.android.com/google/play/billing/rtdn-reference#sub
+const (
+	SubscriptionNotificationTypeRecovered            = 1
+	SubscriptionNotificationTypeRenewed              = 2
+	SubscriptionNotificationTypeCanceled             = 3
+	SubscriptionNotificationTypePurchased            = 4
+	SubscriptionNotificationTypeOnHold               = 5
+	SubscriptionNotificationTypeInGracePeriod        = 6
+	SubscriptionNotificationTypeRestarted            = 7
+	SubscriptionNotificationTypePriceChangeConfirmed = 8
+	SubscriptionNotificationTypeDeferred             = 9
+	SubscriptionNotificationTypePaused               = 10
+	SubscriptionNotificationTypePauseScheduleChanged = 11
+	SubscriptionNotificationTypeRevoked              = 12
+	SubscriptionNotificationTypeExpired              = 13
+)
+
+// https://developer.android.com/google/play/billing/rtdn-reference#one-time
+const (
+	OneTimeProductNotificationTypePurchased = 1
+	OneTimeProductNotificationTypeCanceled  = 2
+)
+
+// PushRequest is the envelope Cloud Pub/Sub posts to push subscriptions.
+type PushRequest struct {
+	Message struct {
+		Data        string            `json:"data"`
+		MessageID   string            `json:"messageId"`
+		PublishTime string            `json:"publishTime"`
+		Attributes  map[string]string `json:"attributes"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// DeveloperNotification is the Real-time Developer Notification carried in PushRequest.Message.Data.
+// https://developer.android.com/google/play/billing/rtdn-reference#json_specification
+type DeveloperNotification struct {
+	Version                    string                      `json:"version"`
+	PackageName                string                      `json:"packageName"`
+	EventTimeMillis            string                      `json:"eventTimeMillis"`
+	SubscriptionNotification   *SubscriptionNotification   `json:"subscriptionNotification,omitempty"`
+	OneTimeProductNotification *OneTimeProductNotification `json:"oneTimeProductNotification,omitempty"`
+	VoidedPurchaseNotification *VoidedPurchaseNotification `json:"voidedPurchaseNotification,omitempty"`
+	TestNotification           *TestNotification           `json:"testNotification,omitempty"`
+
+	// MessageID is the Pub/Sub message id, unique per published notification.
+	MessageID string `json:"messageId,omitempty"`
+}
+
+type SubscriptionNotification struct {
+	Version          string `json:"version"`
+	NotificationType int    `json:"notificationType"`
+	PurchaseToken    string `json:"purchaseToken"`
+	SubscriptionID   string `json:"subscriptionId"`
+}
+
+type OneTimeProductNotification struct {
+	Version          string `json:"version"`
+	NotificationType int    `json:"notificationType"`
+	PurchaseToken    string `json:"purchaseToken"`
+	SKU              string `json:"sku"`
+}
+
+type VoidedPurchaseNotification struct {
+	PurchaseToken string `json:"purchaseToken"`
+	OrderID       string `json:"orderId"`
+	ProductType   int    `json:"productType"`
+	RefundType    int    `json:"refundType"`
+}
+
+type TestNotification struct {
+	Version string `json:"version"`
+}
+
+// EventTime parses EventTimeMillis; the zero time is returned when it is absent.
+func (n *DeveloperNotification) EventTime() time.Time {
+	if n == nil || n.EventTimeMillis == "" {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(n.EventTimeMillis, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// ParsePushRequest decodes a Pub/Sub push body into a developer notification.
+func ParsePushRequest(body []byte) (*DeveloperNotification, error) {
+	var req PushRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode pubsub push request: %w", err)
+	}
+	if req.Message.Data == "" {
+		return nil, errors.New("pubsub message data is empty")
+	}
+	data, err := base64.StdEncoding.DecodeString(req.Message.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pubsub message data: %w", err)
+	}
+	var n DeveloperNotification
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode developer notification: %w", err)
+	}
+	n.MessageID = req.Message.MessageID
+	return &n, nil
+}
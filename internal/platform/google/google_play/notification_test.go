@@ -0,0 +1,35 @@
+package google_play
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePushRequest(t *testing.T) {
+	data := `{"version":"1.0","packageName":"com.example.app","eventTimeMillis":"1767225600000",
+		"subscriptionNotification":{"version":"1.0","notificationType":2,"purchaseToken":"tok-1","subscriptionId":"vip"}}`
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"data":      base64.StdEncoding.EncodeToString([]byte(data)),
+			"messageId": "msg-1",
+		},
+		"subscription": "projects/p/subscriptions/s",
+	})
+	require.NoError(t, err)
+
+	n, err := ParsePushRequest(body)
+	require.NoError(t, err)
+	require.Equal(t, "msg-1", n.MessageID)
+	require.Equal(t, "com.example.app", n.PackageName)
+	require.NotNil(t, n.SubscriptionNotification)
+	require.Equal(t, SubscriptionNotificationTypeRenewed, n.SubscriptionNotification.NotificationType)
+	require.Equal(t, int64(1767225600000), n.EventTime().UnixMilli())
+}
+
+func TestParsePushRequest_EmptyData(t *testing.T) {
+	_, err := ParsePushRequest([]byte(`{"message":{"messageId":"msg-1"}}`))
+	require.Error(t, err)
+}
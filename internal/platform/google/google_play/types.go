@@ -0,0 +1,187 @@
+package google_play
+
+import (
+	"strconv"
+	"time"
+)
+
+// Subscription states returned by purchases.subscriptionsv2.
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2#subscriptionstate
+const (
+	SubscriptionStatePending                 = "SUBSCRIPTION_STATE_PENDING"
+	SubscriptionStateActive                  = "SUBSCRIPTION_STATE_ACTIVE"
+	SubscriptionStatePaused                  = "SUBSCRIPTION_STATE_PAUSED"
+	SubscriptionStateInGracePeriod           = "SUBSCRIPTION_STATE_IN_GRACE_PERIOD"
+	SubscriptionStateOnHold                  = "SUBSCRIPTION_STATE_ON_HOLD"
+	SubscriptionStateCanceled                = "SUBSCRIPTION_STATE_CANCELED"
+	SubscriptionStateExpired                 = "SUBSCRIPTION_STATE_EXPIRED"
+	SubscriptionStatePendingPurchaseCanceled = "SUBSCRIPTION_STATE_PENDING_PURCHASE_CANCELED"
+)
+
+const (
+	AcknowledgementStatePending      = "ACKNOWLEDGEMENT_STATE_PENDING"
+	AcknowledgementStateAcknowledged = "ACKNOWLEDGEMENT_STATE_ACKNOWLEDGED"
+)
+
+// Purchase states returned by purchases.products.
+const (
+	ProductPurchaseStatePurchased = 0
+	ProductPurchaseStateCanceled  = 1
+	ProductPurchaseStatePending   = 2
+)
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.subscriptionsv2
+type SubscriptionPurchaseV2 struct {
+	Kind                       string                      `json:"kind"`
+	RegionCode                 string                      `json:"regionCode"`
+	LineItems                  []*SubscriptionLineItem     `json:"lineItems"`
+	StartTime                  string                      `json:"startTime"`
+	SubscriptionState          string                      `json:"subscriptionState"`
+	LatestOrderID              string                      `json:"latestOrderId"`
+	LinkedPurchaseToken        string                      `json:"linkedPurchaseToken"`
+	AcknowledgementState       string                      `json:"acknowledgementState"`
+	ExternalAccountIdentifiers *ExternalAccountIdentifiers `json:"externalAccountIdentifiers"`
+	TestPurchase               *struct{}                   `json:"testPurchase"`
+}
+
+type SubscriptionLineItem struct {
+	ProductID               string            `json:"productId"`
+	ExpiryTime              string            `json:"expiryTime"`
+	LatestSuccessfulOrderID string            `json:"latestSuccessfulOrderId"`
+	AutoRenewingPlan        *AutoRenewingPlan `json:"autoRenewingPlan"`
+	PrepaidPlan             *struct {
+		AllowExtendAfterTime string `json:"allowExtendAfterTime"`
+	} `json:"prepaidPlan"`
+	OfferDetails *OfferDetails `json:"offerDetails"`
+}
+
+type AutoRenewingPlan struct {
+	AutoRenewEnabled bool   `json:"autoRenewEnabled"`
+	RecurringPrice   *Money `json:"recurringPrice"`
+}
+
+type OfferDetails struct {
+	BasePlanID string   `json:"basePlanId"`
+	OfferID    string   `json:"offerId"`
+	OfferTags  []string `json:"offerTags"`
+}
+
+type ExternalAccountIdentifiers struct {
+	ExternalAccountID           string `json:"externalAccountId"`
+	ObfuscatedExternalAccountID string `json:"obfuscatedExternalAccountId"`
+	ObfuscatedExternalProfileID string `json:"obfuscatedExternalProfileId"`
+}
+
+// Money mirrors google.type.Money: Units is the whole part encoded as a string, Nanos the fractional part.
+type Money struct {
+	CurrencyCode string `json:"currencyCode"`
+	Units        string `json:"units"`
+	Nanos        int64  `json:"nanos"`
+}
+
+// Billionths returns the amount in billionths of the currency unit.
+func (m *Money) Billionths() int64 {
+	if m == nil {
+		return 0
+	}
+	units, _ := strconv.ParseInt(m.Units, 10, 64)
+	return units*1_000_000_000 + m.Nanos
+}
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.products
+type ProductPurchase struct {
+	Kind                        string `json:"kind"`
+	PurchaseTimeMillis          string `json:"purchaseTimeMillis"`
+	PurchaseState               int    `json:"purchaseState"`
+	ConsumptionState            int    `json:"consumptionState"`
+	DeveloperPayload            string `json:"developerPayload"`
+	OrderID                     string `json:"orderId"`
+	PurchaseType                *int   `json:"purchaseType"`
+	AcknowledgementState        int    `json:"acknowledgementState"`
+	PurchaseToken               string `json:"purchaseToken"`
+	ProductID                   string `json:"productId"`
+	Quantity                    int    `json:"quantity"`
+	ObfuscatedExternalAccountID string `json:"obfuscatedExternalAccountId"`
+	ObfuscatedExternalProfileID string `json:"obfuscatedExternalProfileId"`
+	RegionCode                  string `json:"regionCode"`
+}
+
+// PurchaseTime parses PurchaseTimeMillis; the zero time is returned when it is absent.
+func (p *ProductPurchase) PurchaseTime() time.Time {
+	if p == nil || p.PurchaseTimeMillis == "" {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(p.PurchaseTimeMillis, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/orders
+type Order struct {
+	OrderID   string           `json:"orderId"`
+	State     string           `json:"state"`
+	Total     *Money           `json:"total"`
+	Tax       *Money           `json:"tax"`
+	LineItems []*OrderLineItem `json:"lineItems"`
+}
+
+// OrderLineItem is one product of an Order. Total is what the user paid for it, discounts and tax included.
+type OrderLineItem struct {
+	ProductID    string `json:"productId"`
+	ListingPrice *Money `json:"listingPrice"`
+	Total        *Money `json:"total"`
+	Tax          *Money `json:"tax"`
+}
+
+// https://developers.google.com/android-publisher/api-ref/rest/v3/purchases.voidedpurchases
+type VoidedPurchase struct {
+	Kind               string `json:"kind"`
+	PurchaseToken      string `json:"purchaseToken"`
+	PurchaseTimeMillis string `json:"purchaseTimeMillis"`
+	VoidedTimeMillis   string `json:"voidedTimeMillis"`
+	OrderID            string `json:"orderId"`
+	VoidedSource       int    `json:"voidedSource"`
+	VoidedReason       int    `json:"voidedReason"`
+	RefundType         int    `json:"refundType"`
+}
+
+// VoidedTime parses VoidedTimeMillis; the zero time is returned when it is absent.
+func (v *VoidedPurchase) VoidedTime() time.Time {
+	if v == nil || v.VoidedTimeMillis == "" {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(v.VoidedTimeMillis, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+type VoidedPurchasesListResponse struct {
+	VoidedPurchases []*VoidedPurchase `json:"voidedPurchases"`
+	TokenPagination *struct {
+		NextPageToken string `json:"nextPageToken"`
+	} `json:"tokenPagination"`
+}
+
+// NextPageToken returns the token of the next page, or "" on the last page.
+func (r *VoidedPurchasesListResponse) NextPageToken() string {
+	if r == nil || r.TokenPagination == nil {
+		return ""
+	}
+	return r.TokenPagination.NextPageToken
+}
+
+// ParseTime parses an RFC 3339 timestamp as used by the v2 subscription API; the zero time is returned on failure.
+func ParseTime(v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
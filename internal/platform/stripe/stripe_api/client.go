@@ -0,0 +1,180 @@
+package stripe_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://api.stripe.com"
+
+type ClientOptions struct {
+	SecretKey string
+	// BaseURL overrides the Stripe API endpoint, mainly for tests.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Client is a minimal Stripe API client covering checkout sessions and invoice lookup.
+type Client struct {
+	secretKey  string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// APIError is returned when Stripe responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("stripe api error: status=%d type=%s code=%s message=%s", e.StatusCode, e.Type, e.Code, e.Message)
+}
+
+func NewClient(opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		return nil, errors.New("opts is nil")
+	}
+	if opts.SecretKey == "" {
+		return nil, errors.New("secret key is empty")
+	}
+	c := &Client{
+		secretKey:  opts.SecretKey,
+		baseURL:    strings.TrimRight(opts.BaseURL, "/"),
+		httpClient: opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultBaseURL
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return c, nil
+}
+
+type CheckoutSessionParams struct {
+	// Mode is CheckoutModeSubscription or CheckoutModePayment.
+	Mode              string
+	PriceID           string
+	SuccessURL        string
+	CancelURL         string
+	ClientReferenceID string
+	// Metadata is copied to the session and to the subscription or payment intent it creates.
+	Metadata map[string]string
+}
+
+// CreateCheckoutSession creates a hosted checkout session for a single price.
+func (c *Client) CreateCheckoutSession(ctx context.Context, params *CheckoutSessionParams) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", params.Mode)
+	form.Set("line_items[0][price]", params.PriceID)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	if params.ClientReferenceID != "" {
+		form.Set("client_reference_id", params.ClientReferenceID)
+	}
+	for k, v := range params.Metadata {
+		form.Set(fmt.Sprintf("metadata[%s]", k), v)
+		switch params.Mode {
+		case CheckoutModeSubscription:
+			form.Set(fmt.Sprintf("subscription_data[metadata][%s]", k), v)
+		case CheckoutModePayment:
+			form.Set(fmt.Sprintf("payment_intent_data[metadata][%s]", k), v)
+		}
+	}
+
+	var res CheckoutSession
+	if err := c.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetInvoice retrieves an invoice by id.
+func (c *Client) GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	var res Invoice
+	if err := c.do(ctx, http.MethodGet, "/v1/invoices/"+url.PathEscape(invoiceID), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetSubscription retrieves a subscription by id.
+func (c *Client) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	var res Subscription
+	if err := c.do(ctx, http.MethodGet, "/v1/subscriptions/"+url.PathEscape(subscriptionID), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetCheckoutSessionByPaymentIntent returns the checkout session that created a payment intent, or nil if none.
+func (c *Client) GetCheckoutSessionByPaymentIntent(ctx context.Context, paymentIntentID string) (*CheckoutSession, error) {
+	var res struct {
+		Data []*CheckoutSession `json:"data"`
+	}
+	q := url.Values{}
+	q.Set("payment_intent", paymentIntentID)
+	if err := c.do(ctx, http.MethodGet, "/v1/checkout/sessions?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Data) == 0 {
+		return nil, nil
+	}
+	return res.Data[0], nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, form url.Values, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var envelope struct {
+			Error *APIError `json:"error"`
+		}
+		if json.Unmarshal(respBody, &envelope) == nil && envelope.Error != nil {
+			apiErr.Type, apiErr.Code, apiErr.Message = envelope.Error.Type, envelope.Error.Code, envelope.Error.Message
+		} else {
+			apiErr.Message = string(respBody)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
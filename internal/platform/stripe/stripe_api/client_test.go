@@ -0,0 +1,59 @@
+package stripe_api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CreateCheckoutSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/checkout/sessions", r.URL.Path)
+		user, _, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "sk_test", user)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, CheckoutModeSubscription, r.PostForm.Get("mode"))
+		require.Equal(t, "price_1", r.PostForm.Get("line_items[0][price]"))
+		require.Equal(t, "u1", r.PostForm.Get("client_reference_id"))
+		require.Equal(t, "u1", r.PostForm.Get("metadata[user_id]"))
+		require.Equal(t, "u1", r.PostForm.Get("subscription_data[metadata][user_id]"))
+		require.Empty(t, r.PostForm.Get("payment_intent_data[metadata][user_id]"))
+		_, _ = w.Write([]byte(`{"id":"cs_1","url":"https://checkout.stripe.com/c/cs_1"}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&ClientOptions{SecretKey: "sk_test", BaseURL: srv.URL})
+	require.NoError(t, err)
+	session, err := c.CreateCheckoutSession(context.Background(), &CheckoutSessionParams{
+		Mode:              CheckoutModeSubscription,
+		PriceID:           "price_1",
+		SuccessURL:        "https://example.com/ok",
+		CancelURL:         "https://example.com/cancel",
+		ClientReferenceID: "u1",
+		Metadata:          map[string]string{"user_id": "u1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "cs_1", session.ID)
+	require.Equal(t, "https://checkout.stripe.com/c/cs_1", session.URL)
+}
+
+func TestClient_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"resource_missing","message":"No such invoice"}}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(&ClientOptions{SecretKey: "sk_test", BaseURL: srv.URL})
+	require.NoError(t, err)
+	_, err = c.GetInvoice(context.Background(), "in_missing")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "resource_missing", apiErr.Code)
+}
@@ -0,0 +1,191 @@
+package stripe_api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	CheckoutModeSubscription = "subscription"
+	CheckoutModePayment      = "payment"
+)
+
+// Event types consumed by Cashier.
+const (
+	EventCheckoutSessionCompleted    = "checkout.session.completed"
+	EventInvoicePaid                 = "invoice.paid"
+	EventCustomerSubscriptionUpdated = "customer.subscription.updated"
+	EventCustomerSubscriptionDeleted = "customer.subscription.deleted"
+	EventChargeRefunded              = "charge.refunded"
+)
+
+const (
+	SubscriptionStatusActive   = "active"
+	SubscriptionStatusTrialing = "trialing"
+	SubscriptionStatusPastDue  = "past_due"
+	SubscriptionStatusCanceled = "canceled"
+)
+
+// https://docs.stripe.com/api/events/object
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// https://docs.stripe.com/api/checkout/sessions/object
+type CheckoutSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	Mode              string            `json:"mode"`
+	Status            string            `json:"status"`
+	PaymentStatus     string            `json:"payment_status"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	PaymentIntent     string            `json:"payment_intent"`
+	Subscription      string            `json:"subscription"`
+	AmountTotal       int64             `json:"amount_total"`
+	Currency          string            `json:"currency"`
+	Created           int64             `json:"created"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// https://docs.stripe.com/api/invoices/object
+type Invoice struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	BillingReason string `json:"billing_reason"`
+	AmountPaid    int64  `json:"amount_paid"`
+	Currency      string `json:"currency"`
+	Created       int64  `json:"created"`
+	// Subscription and SubscriptionDetails are populated by API versions before 2025-03-31;
+	// later versions report them under Parent.
+	Subscription        string               `json:"subscription"`
+	SubscriptionDetails *SubscriptionDetails `json:"subscription_details"`
+	Parent              *struct {
+		SubscriptionDetails *SubscriptionDetails `json:"subscription_details"`
+	} `json:"parent"`
+	Lines struct {
+		Data []*InvoiceLineItem `json:"data"`
+	} `json:"lines"`
+}
+
+type SubscriptionDetails struct {
+	Subscription string            `json:"subscription"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+type InvoiceLineItem struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+	Period struct {
+		Start int64 `json:"start"`
+		End   int64 `json:"end"`
+	} `json:"period"`
+	Price   *Price `json:"price"`
+	Pricing *struct {
+		PriceDetails *struct {
+			Price string `json:"price"`
+		} `json:"price_details"`
+	} `json:"pricing"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// PriceID returns the price of the line for both legacy and current API versions.
+func (l *InvoiceLineItem) PriceID() string {
+	if l == nil {
+		return ""
+	}
+	if l.Price != nil && l.Price.ID != "" {
+		return l.Price.ID
+	}
+	if l.Pricing != nil && l.Pricing.PriceDetails != nil {
+		return l.Pricing.PriceDetails.Price
+	}
+	return ""
+}
+
+type Price struct {
+	ID string `json:"id"`
+}
+
+// SubscriptionID returns the subscription the invoice bills, if any.
+func (i *Invoice) SubscriptionID() string {
+	if i.Subscription != "" {
+		return i.Subscription
+	}
+	if d := i.subscriptionDetails(); d != nil {
+		return d.Subscription
+	}
+	return ""
+}
+
+// SubscriptionMetadata returns the metadata of the billed subscription as snapshotted on the invoice.
+func (i *Invoice) SubscriptionMetadata() map[string]string {
+	if d := i.subscriptionDetails(); d != nil {
+		return d.Metadata
+	}
+	return nil
+}
+
+func (i *Invoice) subscriptionDetails() *SubscriptionDetails {
+	if i.SubscriptionDetails != nil {
+		return i.SubscriptionDetails
+	}
+	if i.Parent != nil {
+		return i.Parent.SubscriptionDetails
+	}
+	return nil
+}
+
+// https://docs.stripe.com/api/subscriptions/object
+type Subscription struct {
+	ID                string            `json:"id"`
+	Status            string            `json:"status"`
+	CancelAtPeriodEnd bool              `json:"cancel_at_period_end"`
+	EndedAt           int64             `json:"ended_at"`
+	LatestInvoice     string            `json:"latest_invoice"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// IsRenewing reports whether Stripe will bill the subscription again at period end.
+func (s *Subscription) IsRenewing() bool {
+	if s == nil || s.CancelAtPeriodEnd {
+		return false
+	}
+	return s.Status == SubscriptionStatusActive || s.Status == SubscriptionStatusTrialing || s.Status == SubscriptionStatusPastDue
+}
+
+// https://docs.stripe.com/api/charges/object
+type Charge struct {
+	ID             string `json:"id"`
+	Invoice        string `json:"invoice"`
+	PaymentIntent  string `json:"payment_intent"`
+	Refunded       bool   `json:"refunded"`
+	AmountRefunded int64  `json:"amount_refunded"`
+	Created        int64  `json:"created"`
+}
+
+// UpperCurrency normalizes Stripe's lowercase ISO currency codes.
+func UpperCurrency(c string) string {
+	return strings.ToUpper(c)
+}
+
+// stripeExponents lists the currencies whose amounts Stripe does not express in hundredths.
+// https://docs.stripe.com/currencies#zero-decimal
+var stripeExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "JPY": 0, "KMF": 0, "KRW": 0, "MGA": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyExponent returns the number of decimal digits of Stripe amounts in currency c. It differs from
+// ISO 4217 for some currencies, such as ISK, which Stripe expresses in hundredths.
+func CurrencyExponent(c string) int {
+	if e, ok := stripeExponents[UpperCurrency(c)]; ok {
+		return e
+	}
+	return 2
+}
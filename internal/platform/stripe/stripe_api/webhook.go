@@ -0,0 +1,91 @@
+package stripe_api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "Stripe-Signature"
+	// DefaultTolerance is the maximum age of a signed webhook accepted by ConstructEvent.
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSignatureHeader = errors.New("invalid stripe signature header")
+	ErrNoValidSignature       = errors.New("no valid stripe signature found")
+	ErrSignatureExpired       = errors.New("stripe signature timestamp outside tolerance")
+)
+
+// ComputeSignature returns the hex HMAC-SHA256 Stripe uses for the v1 scheme.
+func ComputeSignature(timestamp int64, payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a Stripe-Signature header ("t=...,v1=...,v1=...") against payload.
+func VerifySignature(payload []byte, header string, secret string, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrInvalidSignatureHeader
+			}
+			timestamp = ts
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignatureHeader
+	}
+
+	expected := ComputeSignature(timestamp, payload, secret)
+	matched := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return ErrNoValidSignature
+	}
+
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+	return nil
+}
+
+// ConstructEvent verifies the signature and decodes the webhook payload.
+func ConstructEvent(payload []byte, header string, secret string) (*Event, error) {
+	if err := VerifySignature(payload, header, secret, DefaultTolerance, time.Now()); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode stripe event: %w", err)
+	}
+	return &event, nil
+}
@@ -0,0 +1,35 @@
+package stripe_api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"invoice.paid"}`)
+	now := time.Unix(1700000000, 0)
+	sig := ComputeSignature(now.Unix(), payload, "whsec_test")
+
+	header := fmt.Sprintf("t=%d,v1=deadbeef,v1=%s", now.Unix(), sig)
+	require.NoError(t, VerifySignature(payload, header, "whsec_test", DefaultTolerance, now))
+
+	require.ErrorIs(t, VerifySignature(payload, header, "whsec_other", DefaultTolerance, now), ErrNoValidSignature)
+	require.ErrorIs(t, VerifySignature([]byte(`{}`), header, "whsec_test", DefaultTolerance, now), ErrNoValidSignature)
+	require.ErrorIs(t, VerifySignature(payload, header, "whsec_test", DefaultTolerance, now.Add(10*time.Minute)), ErrSignatureExpired)
+	require.ErrorIs(t, VerifySignature(payload, "v1="+sig, "whsec_test", DefaultTolerance, now), ErrInvalidSignatureHeader)
+}
+
+func TestConstructEvent(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"charge.refunded","created":1700000000,"data":{"object":{"id":"ch_1"}}}`)
+	ts := time.Now().Unix()
+	header := fmt.Sprintf("t=%d,v1=%s", ts, ComputeSignature(ts, payload, "whsec_test"))
+
+	event, err := ConstructEvent(payload, header, "whsec_test")
+	require.NoError(t, err)
+	require.Equal(t, "evt_1", event.ID)
+	require.Equal(t, EventChargeRefunded, event.Type)
+	require.JSONEq(t, `{"id":"ch_1"}`, string(event.Data.Object))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/fatflowers/cashier/pkg/types"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/fx"
@@ -18,6 +19,8 @@ type ServerConfig struct {
 
 type DBConfig struct {
 	DSN string `mapstructure:"dsn"`
+	// AutoMigrate runs GORM AutoMigrate on startup instead of requiring `migrate up`. Dev only.
+	AutoMigrate bool `mapstructure:"auto_migrate"`
 }
 
 type Env string
@@ -28,12 +31,38 @@ const (
 )
 
 type Config struct {
-	Env          Env                  `mapstructure:"env"`
-	Server       ServerConfig         `mapstructure:"server"`
-	Database     DBConfig             `mapstructure:"database"`
+	Env      Env          `mapstructure:"env"`
+	Server   ServerConfig `mapstructure:"server"`
+	Database DBConfig     `mapstructure:"database"`
+	// PaymentItems seeds the payment item catalog. Changes to an item already in the catalog are applied as a new
+	// version unless the item was last changed through the admin API.
 	PaymentItems []*types.PaymentItem `mapstructure:"payment_items"`
+	Catalog      CatalogConfig        `mapstructure:"catalog"`
 	AppleIAP     AppleIAPConfig       `mapstructure:"apple_iap"`
+	GooglePlay   GooglePlayConfig     `mapstructure:"google_play"`
+	Stripe       StripeConfig         `mapstructure:"stripe"`
+	Webhook      WebhookConfig        `mapstructure:"webhook"`
+	AdminAuth    AdminAuthConfig      `mapstructure:"admin_auth"`
+	Scheduler    SchedulerConfig      `mapstructure:"scheduler"`
+	Outbox       OutboxConfig         `mapstructure:"outbox"`
+	Revenue      RevenueConfig        `mapstructure:"revenue"`
 	MetricsAddr  string               `mapstructure:"metrics_addr"`
+
+	// paymentItems serves payment item lookups once the catalog is loaded.
+	paymentItems PaymentItemSource
+}
+
+// CatalogConfig configures the in-memory copy of the payment item catalog.
+type CatalogConfig struct {
+	// RefreshInterval is how often each replica reloads the catalog to pick up changes made elsewhere.
+	// Defaults to 30s.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// PaymentItemSource looks up payment items by ID or by provider item ID. Items must not be modified.
+type PaymentItemSource interface {
+	PaymentItemByID(id string) *types.PaymentItem
+	PaymentItemByProviderItemID(providerID types.PaymentProvider, providerItemID string) *types.PaymentItem
 }
 
 type AppleIAPConfig struct {
@@ -43,9 +72,200 @@ type AppleIAPConfig struct {
 	Issuer       string `mapstructure:"issuer"`
 	SharedSecret string `mapstructure:"shared_secret"`
 	IsProd       bool   `mapstructure:"is_prod"`
+	// Consumption configures the answer to CONSUMPTION_REQUEST notifications.
+	Consumption AppleConsumptionConfig `mapstructure:"consumption"`
+	// NotificationRecovery schedules replaying notifications the webhook missed.
+	NotificationRecovery AppleNotificationRecoveryConfig `mapstructure:"notification_recovery"`
+	// Reconcile schedules comparing subscriptions near expiry with the App Store Server API.
+	Reconcile AppleReconcileConfig `mapstructure:"reconcile"`
+}
+
+// AppleNotificationRecoveryConfig periodically reads Apple's notification history and handles the notifications
+// that are not recorded as handled.
+type AppleNotificationRecoveryConfig struct {
+	// Interval between runs; zero disables the job unless scheduler.jobs sets its schedule.
+	Interval time.Duration `mapstructure:"interval"`
+	// Lookback is the window each run reads, ending now. Defaults to 24h.
+	Lookback time.Duration `mapstructure:"lookback"`
+}
+
+// AppleConsumptionConfig configures the consumption information sent to Apple when a customer requests a
+// refund. Apple only accepts it with the customer's consent, so nothing is sent unless CustomerConsented is set.
+type AppleConsumptionConfig struct {
+	// CustomerConsented declares that the app's terms obtain the customer's consent to share consumption data.
+	CustomerConsented bool `mapstructure:"customer_consented"`
+	// SampleContentProvided reports whether the app offers a free sample or trial before purchase.
+	SampleContentProvided bool `mapstructure:"sample_content_provided"`
+	// RefundPreference is "grant", "decline" or "no_preference"; empty leaves it undeclared.
+	RefundPreference string `mapstructure:"refund_preference"`
+}
+
+// AppleReconcileConfig periodically compares auto-renewable subscriptions near expiry with Apple and corrects
+// what the notifications missed.
+type AppleReconcileConfig struct {
+	// Interval between runs; zero disables the job unless scheduler.jobs sets its schedule.
+	Interval time.Duration `mapstructure:"interval"`
+	// Window selects subscriptions expiring up to Window before or after now. Defaults to 72h.
+	Window time.Duration `mapstructure:"window"`
+	// HistoryWindow is how far back the transactions of each renewal chain are compared. Defaults to 90 days.
+	HistoryWindow time.Duration `mapstructure:"history_window"`
+}
+
+type GooglePlayConfig struct {
+	PackageName string `mapstructure:"package_name"`
+	// ServiceAccountKey is the JSON key of a service account linked in the Play Console.
+	ServiceAccountKey string `mapstructure:"service_account_key"`
+	// PushToken must match the "token" query parameter of Pub/Sub push requests; notifications are refused
+	// while it is empty.
+	PushToken string `mapstructure:"push_token"`
+}
+
+type StripeConfig struct {
+	SecretKey string `mapstructure:"secret_key"`
+	// WebhookSecret is the endpoint signing secret (whsec_...) used to verify Stripe-Signature.
+	WebhookSecret string `mapstructure:"webhook_secret"`
+	// SuccessURL and CancelURL are the default checkout redirect targets.
+	SuccessURL string `mapstructure:"success_url"`
+	CancelURL  string `mapstructure:"cancel_url"`
+}
+
+// AdminAuthConfig configures authentication for /api/v1/admin. Requests are rejected when neither
+// API keys nor a JWT secret are configured.
+type AdminAuthConfig struct {
+	APIKeys []*AdminAPIKey `mapstructure:"api_keys"`
+	// JWTSecret verifies HS256 bearer tokens. Tokens carry the operator in "sub" and a "role" claim.
+	JWTSecret string `mapstructure:"jwt_secret"`
+	// JWTIssuer, when set, must match the "iss" claim.
+	JWTIssuer string `mapstructure:"jwt_issuer"`
+}
+
+type AdminAPIKey struct {
+	// Operator identifies the caller in audit records.
+	Operator string `mapstructure:"operator"`
+	Key      string `mapstructure:"key"`
+	Role     string `mapstructure:"role"`
+	// Scopes are granted in addition to the role's scopes.
+	Scopes []string `mapstructure:"scopes"`
+}
+
+// SchedulerConfig configures the background jobs. Replicas elect a leader through a lease in Postgres and
+// only the leader runs jobs.
+type SchedulerConfig struct {
+	// Jobs overrides the schedule of a job by name with a cron expression in UTC, "@every <duration>", or "-"
+	// to disable it.
+	Jobs map[string]string `mapstructure:"jobs"`
+	// LeaseDuration is how long the leader holds the lease without renewing it, and so how long jobs pause
+	// after the leader dies. Defaults to 30s.
+	LeaseDuration time.Duration `mapstructure:"lease_duration"`
+	// RunRetention is how long job runs are kept before the job_run_purge job deletes them. Defaults to 30
+	// days.
+	RunRetention time.Duration `mapstructure:"run_retention"`
+}
+
+// OutboxConfig configures the outbox of side effects written with state changes.
+type OutboxConfig struct {
+	// Retention is how long dispatched events are kept before the outbox_purge job deletes them. Defaults
+	// to 7 days.
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// RevenueConfig configures the net revenue statistics, which deduct the sales tax included in prices and the
+// store commission from what customers paid.
+type RevenueConfig struct {
+	// Commission overrides the commission of a provider. Apple and Google default to 30%, reduced to 15%
+	// once a subscription has been paid for a year; other providers default to none.
+	Commission map[types.PaymentProvider]*CommissionConfig `mapstructure:"commission"`
+	// Taxes lists the sales tax rates included in the prices of storefronts.
+	Taxes []*TaxRule `mapstructure:"taxes"`
+	// DefaultTaxRate is included in the prices of storefronts no tax rule lists, and of transactions stored
+	// before migration 0011 recorded storefronts.
+	DefaultTaxRate float64 `mapstructure:"default_tax_rate"`
+}
+
+// CommissionConfig is the share of the price net of tax a store keeps, e.g. 0.3.
+type CommissionConfig struct {
+	Rate float64 `mapstructure:"rate"`
+	// ReducedRate applies once a subscription has been paid for ReducedAfter, or to every transaction with
+	// SmallBusiness, as in the App Store Small Business Program.
+	ReducedRate   float64       `mapstructure:"reduced_rate"`
+	ReducedAfter  time.Duration `mapstructure:"reduced_after"`
+	SmallBusiness bool          `mapstructure:"small_business"`
+}
+
+// TaxRule is the sales tax rate included in the prices of storefronts. Storefronts are matched as providers
+// report them: ISO 3166-1 alpha-3 codes for Apple, alpha-2 for Google.
+type TaxRule struct {
+	Storefronts []string `mapstructure:"storefronts"`
+	Rate        float64  `mapstructure:"rate"`
+}
+
+// defaultStoreCommission is the standard commission of the app stores.
+var defaultStoreCommission = &CommissionConfig{Rate: 0.3, ReducedRate: 0.15, ReducedAfter: 365 * 24 * time.Hour}
+
+// CommissionRate returns the commission rate of provider for a transaction paid tenure after the start of
+// its subscription.
+func (c *RevenueConfig) CommissionRate(provider types.PaymentProvider, tenure time.Duration) float64 {
+	commission, ok := c.Commission[provider]
+	if !ok {
+		if provider != types.PaymentProviderApple && provider != types.PaymentProviderGoogle {
+			return 0
+		}
+		commission = defaultStoreCommission
+	}
+	if commission == nil {
+		return 0
+	}
+	if commission.SmallBusiness || (commission.ReducedAfter > 0 && tenure >= commission.ReducedAfter) {
+		return commission.ReducedRate
+	}
+	return commission.Rate
+}
+
+// TaxRate returns the sales tax rate included in the prices of storefront.
+func (c *RevenueConfig) TaxRate(storefront string) float64 {
+	for _, rule := range c.Taxes {
+		for _, s := range rule.Storefronts {
+			if strings.EqualFold(s, storefront) {
+				return rule.Rate
+			}
+		}
+	}
+	return c.DefaultTaxRate
+}
+
+// WebhookConfig lists the product backends notified of membership changes.
+type WebhookConfig struct {
+	Endpoints []*WebhookEndpoint `mapstructure:"endpoints"`
+	// MaxAttempts is the number of deliveries tried before an event is dead-lettered. Defaults to 8.
+	MaxAttempts int `mapstructure:"max_attempts"`
+}
+
+type WebhookEndpoint struct {
+	ID  string `mapstructure:"id"`
+	URL string `mapstructure:"url"`
+	// Secret signs the request body; see the X-Cashier-Signature header.
+	Secret string `mapstructure:"secret"`
+}
+
+func (c *Config) GetWebhookEndpointByID(id string) *WebhookEndpoint {
+	for _, e := range c.Webhook.Endpoints {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// UsePaymentItemSource serves payment item lookups from src instead of PaymentItems. It must be called
+// before the config is shared between goroutines.
+func (c *Config) UsePaymentItemSource(src PaymentItemSource) {
+	c.paymentItems = src
 }
 
 func (c *Config) GetPaymentItemByID(id string) *types.PaymentItem {
+	if c.paymentItems != nil {
+		return c.paymentItems.PaymentItemByID(id)
+	}
 	for _, item := range c.PaymentItems {
 		if item.ID == id {
 			return item
@@ -55,7 +275,12 @@ func (c *Config) GetPaymentItemByID(id string) *types.PaymentItem {
 }
 
 func (c *Config) GetPaymentItemByProviderItemID(ctx context.Context, providerID types.PaymentProvider, providerItemID string) (*types.PaymentItem, error) {
-
+	if c.paymentItems != nil {
+		if item := c.paymentItems.PaymentItemByProviderItemID(providerID, providerItemID); item != nil {
+			return item, nil
+		}
+		return nil, fmt.Errorf("payment item not found")
+	}
 	for _, item := range c.PaymentItems {
 		if item.ProviderID == providerID && item.ProviderItemID == providerItemID {
 			return item, nil
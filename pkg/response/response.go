@@ -4,14 +4,18 @@ package response
 type APIResponseCode int
 
 const (
-	APIResponseCodeOK         APIResponseCode = 0
-	APIResponseCodeBadRequest APIResponseCode = 40000
-	APIResponseCodeError      APIResponseCode = 50000
+	APIResponseCodeOK           APIResponseCode = 0
+	APIResponseCodeBadRequest   APIResponseCode = 40000
+	APIResponseCodeUnauthorized APIResponseCode = 40100
+	APIResponseCodeForbidden    APIResponseCode = 40300
+	APIResponseCodeError        APIResponseCode = 50000
 )
 
 var codeToMsg = map[APIResponseCode]string{
-	APIResponseCodeOK:         "ok",
-	APIResponseCodeBadRequest: "unexpected error",
+	APIResponseCodeOK:           "ok",
+	APIResponseCodeBadRequest:   "unexpected error",
+	APIResponseCodeUnauthorized: "unauthorized",
+	APIResponseCodeForbidden:    "forbidden",
 }
 
 // APIResponse is the generic response envelope used by HTTP APIs.
@@ -0,0 +1,52 @@
+package types
+
+import "strings"
+
+// Money is an amount in the minor units of an ISO 4217 currency: Amount / 10^Exponent units of Currency,
+// e.g. {999, "USD", 2} is 9.99 USD and {1200, "JPY", 0} is 1200 JPY.
+type Money struct {
+	Amount   int64  `json:"amount" gorm:"column:amount;type:bigint;not null;default:0"`
+	Currency string `json:"currency" gorm:"column:currency;type:varchar(64);not null;default:''"`
+	// Exponent is the number of minor unit digits of Currency, see CurrencyExponent.
+	Exponent int `json:"exponent" gorm:"column:exponent;type:smallint;not null;default:2"`
+}
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not a hundredth.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent returns the number of minor unit digits of an ISO 4217 currency code, 2 for codes it does not know.
+func CurrencyExponent(currency string) int {
+	if e, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return e
+	}
+	return 2
+}
+
+// MoneyFromScaled converts amount, in 10^-scale units of currency, to the minor units of currency. Digits
+// beyond the minor unit are rounded half away from zero.
+func MoneyFromScaled(amount int64, scale int, currency string) Money {
+	currency = strings.ToUpper(currency)
+	exponent := CurrencyExponent(currency)
+	for ; scale < exponent; scale++ {
+		amount *= 10
+	}
+	if scale > exponent {
+		div := int64(1)
+		for ; scale > exponent; scale-- {
+			div *= 10
+		}
+		q, r := amount/div, amount%div
+		if 2*r >= div {
+			q++
+		} else if 2*r <= -div {
+			q--
+		}
+		amount = q
+	}
+	return Money{Amount: amount, Currency: currency, Exponent: exponent}
+}
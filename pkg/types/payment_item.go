@@ -5,6 +5,7 @@ type PaymentProvider string
 const (
 	PaymentProviderApple  PaymentProvider = "apple"
 	PaymentProviderGoogle PaymentProvider = "google"
+	PaymentProviderStripe PaymentProvider = "stripe"
 	PaymentProviderInner  PaymentProvider = "inner"
 )
 
@@ -13,6 +14,28 @@ type PaymentItemType string
 const (
 	PaymentItemTypeAutoRenewableSubscription PaymentItemType = "auto_renewable_subscription"
 	PaymentItemTypeNonRenewableSubscription  PaymentItemType = "non_renewable_subscription"
+	// PaymentItemTypeConsumable items add Credits to the user's credit balance on every purchase.
+	PaymentItemTypeConsumable PaymentItemType = "consumable"
+	// PaymentItemTypeNonConsumable items are bought once and grant their entitlements permanently.
+	PaymentItemTypeNonConsumable PaymentItemType = "non_consumable"
+)
+
+// DefaultEntitlement is granted by payment items that do not list entitlements, so a catalog without
+// entitlements keeps a single membership.
+const DefaultEntitlement = "membership"
+
+// EntitlementGrant is a named entitlement granted by a payment item at a tier; higher tiers grant more.
+type EntitlementGrant struct {
+	Entitlement string `json:"entitlement" mapstructure:"entitlement"`
+	Tier        int    `json:"tier" mapstructure:"tier"`
+}
+
+type PaymentItemStatus string
+
+const (
+	PaymentItemStatusActive PaymentItemStatus = "active"
+	// PaymentItemStatusArchived items are no longer sold, but purchases and renewals of them are still honored.
+	PaymentItemStatusArchived PaymentItemStatus = "archived"
 )
 
 type PaymentItem struct {
@@ -22,12 +45,71 @@ type PaymentItem struct {
 	Type           PaymentItemType `json:"type" mapstructure:"type"`
 	// DurationHour is set for duration-based products and nil for non-duration products.
 	DurationHour *int64 `json:"duration_hour" mapstructure:"duration_hour"`
+	// Credits is the number of credits a consumable item adds to the credit balance.
+	Credits int64 `json:"credits,omitempty" mapstructure:"credits"`
+	// Entitlements lists what the item grants; empty grants DefaultEntitlement at tier 0.
+	Entitlements []*EntitlementGrant `json:"entitlements,omitempty" mapstructure:"entitlements"`
+	// SubscriptionGroup groups the items a subscription can switch between; changes are only classified
+	// between items of the same group.
+	SubscriptionGroup string `json:"subscription_group,omitempty" mapstructure:"subscription_group"`
+	// Level ranks the items of a subscription group; higher levels are higher tiers.
+	Level int `json:"level,omitempty" mapstructure:"level"`
+	// Status is empty for items that were never stored in the catalog and treated as active.
+	Status PaymentItemStatus `json:"status,omitempty" mapstructure:"status"`
+	// Version is the catalog version of the item, incremented on every change; 0 outside the catalog.
+	Version int64 `json:"version,omitempty" mapstructure:"-"`
 }
 
 func (item *PaymentItem) IsSubscription() bool {
 	return item.Type == PaymentItemTypeAutoRenewableSubscription || item.Type == PaymentItemTypeNonRenewableSubscription
 }
 
+func (item *PaymentItem) IsConsumable() bool {
+	return item.Type == PaymentItemTypeConsumable
+}
+
+func (item *PaymentItem) IsNonConsumable() bool {
+	return item.Type == PaymentItemTypeNonConsumable
+}
+
 func (item *PaymentItem) Renewable() bool {
 	return item.Type == PaymentItemTypeAutoRenewableSubscription
 }
+
+func (item *PaymentItem) IsArchived() bool {
+	return item.Status == PaymentItemStatusArchived
+}
+
+// GetEntitlements returns the entitlements the item grants.
+func (item *PaymentItem) GetEntitlements() []*EntitlementGrant {
+	if len(item.Entitlements) == 0 {
+		return []*EntitlementGrant{{Entitlement: DefaultEntitlement}}
+	}
+	return item.Entitlements
+}
+
+// PlanChange classifies a switch between two items of a subscription group.
+type PlanChange string
+
+const (
+	PlanChangeUpgrade   PlanChange = "upgrade"
+	PlanChangeDowngrade PlanChange = "downgrade"
+	// PlanChangeCrossgrade is a switch to another item of the same level, such as a different duration.
+	PlanChangeCrossgrade PlanChange = "crossgrade"
+)
+
+// ClassifyPlanChange compares the levels of two items. It returns "" when either is nil, they are the same
+// item, or they do not belong to the same subscription group.
+func ClassifyPlanChange(from, to *PaymentItem) PlanChange {
+	if from == nil || to == nil || from.ID == to.ID || from.SubscriptionGroup == "" || from.SubscriptionGroup != to.SubscriptionGroup {
+		return ""
+	}
+	switch {
+	case to.Level > from.Level:
+		return PlanChangeUpgrade
+	case to.Level < from.Level:
+		return PlanChangeDowngrade
+	default:
+		return PlanChangeCrossgrade
+	}
+}
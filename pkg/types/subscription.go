@@ -7,8 +7,18 @@ type SubscriptionStatus string
 const (
 	SubscriptionStatusActive   SubscriptionStatus = "active"
 	SubscriptionStatusInactive SubscriptionStatus = "inactive"
+	// SubscriptionStatusGracePeriod keeps access after a failed renewal while the provider retries billing.
+	SubscriptionStatusGracePeriod SubscriptionStatus = "grace_period"
+	// SubscriptionStatusBillingRetry has no access; the provider is still retrying a failed renewal,
+	// so the user should be asked to fix the payment method.
+	SubscriptionStatusBillingRetry SubscriptionStatus = "billing_retry"
 )
 
+// Entitled reports whether the status grants access until the subscription's expire_at.
+func (s SubscriptionStatus) Entitled() bool {
+	return s == SubscriptionStatusActive || s == SubscriptionStatusGracePeriod
+}
+
 type SubscriptionChangeReason string
 
 const (
@@ -16,7 +26,16 @@ const (
 	UserSubscriptionChangeReasonRefund      SubscriptionChangeReason = "refund"
 	UserSubscriptionChangeReasonCancelRenew SubscriptionChangeReason = "cancelRenew"
 	UserSubscriptionChangeReasonUpgrade     SubscriptionChangeReason = "upgrade"
-	UserSubscriptionChangeReasonGift        SubscriptionChangeReason = "gift"
+	UserSubscriptionChangeReasonDowngrade   SubscriptionChangeReason = "downgrade"
+	// UserSubscriptionChangeReasonCrossgrade switches to another item of the same level.
+	UserSubscriptionChangeReasonCrossgrade SubscriptionChangeReason = "crossgrade"
+	UserSubscriptionChangeReasonGift       SubscriptionChangeReason = "gift"
+	// UserSubscriptionChangeReasonRefundReversed restores a transaction whose refund the provider reversed.
+	UserSubscriptionChangeReasonRefundReversed SubscriptionChangeReason = "refundReversed"
+	// UserSubscriptionChangeReasonReconcile corrects drift found by comparing stored transactions with the provider.
+	UserSubscriptionChangeReasonReconcile SubscriptionChangeReason = "reconcile"
+	// UserSubscriptionChangeReasonExpire settles a subscription whose access ran out without a provider event.
+	UserSubscriptionChangeReasonExpire SubscriptionChangeReason = "expire"
 )
 
 type UserSubsctiptionInfo struct {
@@ -24,3 +43,18 @@ type UserSubsctiptionInfo struct {
 	NextAutoRenewAt *time.Time `json:"next_auto_renew_at"`
 	ExpireAt        time.Time  `json:"expire_at"`
 }
+
+const MembershipEventTypeChanged = "membership.changed"
+
+// MembershipChangeEvent is sent to subscriber endpoints whenever a user's subscription changes.
+type MembershipChangeEvent struct {
+	ID             string                   `json:"id"`
+	Type           string                   `json:"type"`
+	UserID         string                   `json:"user_id"`
+	BeforeStatus   SubscriptionStatus       `json:"before_status"`
+	AfterStatus    SubscriptionStatus       `json:"after_status"`
+	BeforeExpireAt *time.Time               `json:"before_expire_at"`
+	ExpireAt       *time.Time               `json:"expire_at"`
+	Reason         SubscriptionChangeReason `json:"reason"`
+	OccurredAt     time.Time                `json:"occurred_at"`
+}